@@ -0,0 +1,76 @@
+package admin
+
+import (
+	"context"
+	"errors"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/user/credential"
+	"github.com/RagOfJoes/mylo/user/identity"
+	"github.com/gofrs/uuid"
+)
+
+var (
+	ErrInvalidAPIKey         = errors.New("Invalid or missing API key")
+	ErrInvalidIdentityID     = errors.New("Invalid identity id provided")
+	ErrInvalidCreatePayload  = errors.New("Invalid identity payload provided")
+	ErrInvalidProfilePayload = errors.New("Invalid profile payload provided")
+	ErrNoPassword            = errors.New("Identity does not have a password to reset")
+)
+
+// Identity defines the administrative view of an identity
+type Identity struct {
+	identity.Identity
+	// CredentialTypes defines the methods that the identity can authenticate with
+	CredentialTypes []credential.CredentialType `json:"credential_types"`
+	// Locked is true when the identity has been locked due to too many failed login attempts
+	Locked bool `json:"locked"`
+	// PasswordResetRequired is true when a password reset has been forced
+	PasswordResetRequired bool `json:"password_reset_required"`
+}
+
+// CreatePayload defines the fields that an identity can be created with
+type CreatePayload struct {
+	// Email is what it is
+	Email string `json:"email" validate:"required,min=1,email"`
+	// Username is what it is
+	Username string `json:"username" validate:"required,min=4,max=20,alphanum"`
+	// FirstName is what it is
+	FirstName string `json:"first_name" validate:"omitempty,max=64,alphanumunicode"`
+	// LastName is what it is
+	LastName string `json:"last_name" validate:"omitempty,max=64,alphanumunicode"`
+	// Password is what it is
+	Password string `json:"password" validate:"required,min=6,max=128"`
+	// Verified marks the email as already verified so that a verification email isn't sent
+	Verified bool `json:"verified"`
+}
+
+// ProfilePayload defines the profile fields that can be updated
+type ProfilePayload struct {
+	// Avatar is a url to the User's avatar
+	Avatar string `json:"avatar" form:"avatar" validate:"omitempty,max=1024,url"`
+	// FirstName is what it is
+	FirstName string `json:"first_name" form:"first_name" validate:"omitempty,max=64,alphanumunicode"`
+	// LastName is what it is
+	LastName string `json:"last_name" form:"last_name" validate:"omitempty,max=64,alphanumunicode"`
+}
+
+type Service interface {
+	// List finds a page of identities that match the filter
+	List(ctx context.Context, filter identity.Filter, page int, perPage int) (*identity.Page, error)
+	// Create creates an identity with an email contact and a password
+	Create(ctx context.Context, payload CreatePayload) (*Identity, error)
+	// Find finds an identity along with its contacts and credential types
+	Find(ctx context.Context, id uuid.UUID) (*Identity, error)
+	// UpdateProfile updates the profile fields of an identity
+	UpdateProfile(ctx context.Context, id uuid.UUID, payload ProfilePayload) (*Identity, error)
+	// Delete deletes an identity. Soft deleted identities can be restored
+	Delete(ctx context.Context, id uuid.UUID, permanent bool) error
+	// Restore restores a soft deleted identity
+	Restore(ctx context.Context, id uuid.UUID) error
+	// ForcePasswordReset refuses any further password logins, revokes every session and emails a recovery link to the
+	// identity
+	ForcePasswordReset(ctx context.Context, id uuid.UUID, client internal.Client) error
+	// RevokeSessions revokes every session that belongs to an identity
+	RevokeSessions(ctx context.Context, id uuid.UUID) error
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/RagOfJoes/mylo/admin"
+	"github.com/RagOfJoes/mylo/event"
+	"github.com/RagOfJoes/mylo/flow/recovery"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/internal/validate"
+	"github.com/RagOfJoes/mylo/session"
+	"github.com/RagOfJoes/mylo/user/contact"
+	"github.com/RagOfJoes/mylo/user/credential"
+	"github.com/RagOfJoes/mylo/user/identity"
+	"github.com/gofrs/uuid"
+	"golang.org/x/sync/errgroup"
+)
+
+type service struct {
+	eb  event.Bus
+	rs  recovery.Service
+	se  session.Service
+	cos contact.Service
+	cs  credential.Service
+	is  identity.Service
+}
+
+func NewAdminService(eb event.Bus, rs recovery.Service, se session.Service, cos contact.Service, cs credential.Service, is identity.Service) admin.Service {
+	return &service{
+		eb:  eb,
+		rs:  rs,
+		se:  se,
+		cos: cos,
+		cs:  cs,
+		is:  is,
+	}
+}
+
+func (s *service) List(ctx context.Context, filter identity.Filter, page int, perPage int) (*identity.Page, error) {
+	return s.is.List(ctx, filter, page, perPage)
+}
+
+func (s *service) Create(ctx context.Context, payload admin.CreatePayload) (*admin.Identity, error) {
+	if err := validate.Check(payload); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", admin.ErrInvalidCreatePayload)
+	}
+	newUser, err := s.is.Create(ctx, identity.Identity{
+		Email:     payload.Email,
+		FirstName: payload.FirstName,
+		LastName:  payload.LastName,
+	}, payload.Username, payload.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	newContact := contact.Contact{
+		IdentityID: newUser.ID,
+		State:      contact.Sent,
+		Value:      payload.Email,
+	}
+	if payload.Verified {
+		now := time.Now()
+		newContact.State = contact.Completed
+		newContact.Verified = true
+		newContact.VerifiedAt = &now
+	}
+	// Same as registration, undo the identity if either its contact or its password can't be created
+	var eg errgroup.Group
+	eg.Go(func() error {
+		vc, err := s.cos.Add(ctx, newContact)
+		if err != nil {
+			return internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", admin.ErrInvalidCreatePayload)
+		}
+		newUser.Contacts = append(newUser.Contacts, vc...)
+		return nil
+	})
+	eg.Go(func() error {
+		_, err := s.cs.CreatePassword(ctx, newUser.ID, payload.Password, []credential.Identifier{
+			{
+				Type:  "email",
+				Value: payload.Email,
+			},
+			{
+				Type:  "username",
+				Value: payload.Username,
+			},
+		})
+		return err
+	})
+	if err := eg.Wait(); err != nil {
+		if derr := s.is.Delete(ctx, newUser.ID.String(), true); derr != nil {
+			return nil, internal.WrapErrorf(derr, internal.ErrorCodeInternal, "Failed to remove partially created identity %s after: %v", newUser.ID, err)
+		}
+		return nil, err
+	}
+	// Unverified identities are sent the same welcome email as if they had registered themselves
+	cfg := config.Get()
+	if err := s.eb.Publish(ctx, event.IdentityRegistered{
+		Identity:   *newUser,
+		RequestURL: fmt.Sprintf("/%s", cfg.Verification.URL),
+	}); err != nil {
+		log.Print(err)
+	}
+	return s.Find(ctx, newUser.ID)
+}
+
+func (s *service) Find(ctx context.Context, id uuid.UUID) (*admin.Identity, error) {
+	found, err := s.is.Find(ctx, id.String())
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", admin.ErrInvalidIdentityID)
+	}
+	creds, err := s.cs.FindAllIdentity(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	types := []credential.CredentialType{}
+	for _, c := range creds {
+		// Enrollments that were never confirmed can't be used to authenticate
+		if c.Type == credential.TOTP && !confirmed(c) {
+			continue
+		}
+		types = append(types, c.Type)
+	}
+	return &admin.Identity{
+		Identity:              *found,
+		CredentialTypes:       types,
+		Locked:                found.Locked(),
+		PasswordResetRequired: found.PasswordResetRequired,
+	}, nil
+}
+
+func (s *service) UpdateProfile(ctx context.Context, id uuid.UUID, payload admin.ProfilePayload) (*admin.Identity, error) {
+	if err := validate.Check(payload); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", admin.ErrInvalidProfilePayload)
+	}
+	found, err := s.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	found.Avatar = payload.Avatar
+	found.FirstName = payload.FirstName
+	found.LastName = payload.LastName
+	updated, err := s.is.Update(ctx, found.Identity)
+	if err != nil {
+		return nil, err
+	}
+	found.Identity = *updated
+	return found, nil
+}
+
+func (s *service) Delete(ctx context.Context, id uuid.UUID, permanent bool) error {
+	// Soft deleted identities can only be found when listing so skip the lookup for permanent deletes
+	if !permanent {
+		if _, err := s.Find(ctx, id); err != nil {
+			return err
+		}
+	}
+	// Make sure a deleted identity can't keep using its existing sessions
+	if err := s.se.DestroyAllIdentity(ctx, id); err != nil {
+		return err
+	}
+	return s.is.Delete(ctx, id.String(), permanent)
+}
+
+func (s *service) Restore(ctx context.Context, id uuid.UUID) error {
+	return s.is.Restore(ctx, id)
+}
+
+func (s *service) ForcePasswordReset(ctx context.Context, id uuid.UUID, client internal.Client) error {
+	if _, err := s.Find(ctx, id); err != nil {
+		return err
+	}
+	creds, err := s.cs.FindAllIdentity(ctx, id)
+	if err != nil {
+		return err
+	}
+	var password *credential.Credential
+	for i, c := range creds {
+		if c.Type == credential.Password && len(c.Identifiers) > 0 {
+			password = &creds[i]
+			break
+		}
+	}
+	if password == nil {
+		return internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", admin.ErrNoPassword)
+	}
+
+	if err := s.is.RequirePasswordReset(ctx, id, true); err != nil {
+		return err
+	}
+	if err := s.se.DestroyAllIdentity(ctx, id); err != nil {
+		return err
+	}
+	// Start a recovery flow on the identity's behalf so that they're emailed a link to set a new password
+	cfg := config.Get()
+	flow, err := s.rs.New(ctx, fmt.Sprintf("/%s", cfg.Recovery.URL), client)
+	if err != nil {
+		return err
+	}
+	if _, err := s.rs.SubmitIdentifier(ctx, *flow, recovery.IdentifierPayload{Identifier: password.Identifiers[0].Value}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *service) RevokeSessions(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.Find(ctx, id); err != nil {
+		return err
+	}
+	return s.se.DestroyAllIdentity(ctx, id)
+}
+
+// confirmed checks whether a totp credential has been confirmed
+func confirmed(c credential.Credential) bool {
+	var values credential.CredentialTOTP
+	if err := json.Unmarshal([]byte(c.Values), &values); err != nil {
+		return false
+	}
+	return values.Confirmed
+}
@@ -0,0 +1,193 @@
+package transport
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/RagOfJoes/mylo/admin"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/transport"
+	"github.com/RagOfJoes/mylo/user/identity"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+const defaultPerPage = 20
+
+type Http struct {
+	s admin.Service
+}
+
+// NewAdminHttp attaches the admin routes. These should only ever be attached to the admin server's router, behind
+// APIKeyMiddleware
+func NewAdminHttp(s admin.Service, r *gin.Engine) {
+	h := &Http{
+		s: s,
+	}
+
+	group := r.Group("/identities")
+	{
+		group.GET("/", h.list())
+		group.GET("/:id", h.get())
+		group.PUT("/:id", h.update())
+		group.DELETE("/:id", h.delete())
+		group.POST("/:id/restore", h.restore())
+		group.POST("/:id/password-reset", h.forcePasswordReset())
+		group.DELETE("/:id/sessions", h.revokeSessions())
+	}
+}
+
+func (h *Http) list() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", identity.ErrInvalidPagination))
+			return
+		}
+		perPage, err := strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(defaultPerPage)))
+		if err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", identity.ErrInvalidPagination))
+			return
+		}
+		filter := identity.Filter{
+			Search:  c.Query("search"),
+			Deleted: c.Query("deleted") == "true",
+		}
+		found, err := h.s.List(c.Request.Context(), filter, page, perPage)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Payload: found,
+		})
+	}
+}
+
+func (h *Http) get() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := identityID(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		found, err := h.s.Find(c.Request.Context(), id)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Payload: found,
+		})
+	}
+}
+
+func (h *Http) update() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := identityID(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		var payload admin.ProfilePayload
+		if err := c.ShouldBind(&payload); err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", admin.ErrInvalidProfilePayload))
+			return
+		}
+		updated, err := h.s.UpdateProfile(c.Request.Context(), id, payload)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Payload: updated,
+		})
+	}
+}
+
+func (h *Http) delete() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := identityID(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if err := h.s.Delete(c.Request.Context(), id, c.Query("permanent") == "true"); err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+		})
+	}
+}
+
+func (h *Http) restore() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := identityID(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if err := h.s.Restore(c.Request.Context(), id); err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+		})
+	}
+}
+
+func (h *Http) forcePasswordReset() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := identityID(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if err := h.s.ForcePasswordReset(c.Request.Context(), id, transport.Client(c.Request)); err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+		})
+	}
+}
+
+func (h *Http) revokeSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := identityID(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if err := h.s.RevokeSessions(c.Request.Context(), id); err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+		})
+	}
+}
+
+// identityID parses the identity id route param
+func identityID(c *gin.Context) (uuid.UUID, error) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil || id == uuid.Nil {
+		return uuid.Nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", admin.ErrInvalidIdentityID)
+	}
+	return id, nil
+}
@@ -0,0 +1,39 @@
+package transport
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"strings"
+
+	"github.com/RagOfJoes/mylo/admin"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyMiddleware rejects any request that doesn't provide one of the configured keys, either in the `X-Api-Key`
+// header or as a bearer token
+func APIKeyMiddleware(keys []config.AdminKey) gin.HandlerFunc {
+	// Compare digests so that the comparison takes the same time regardless of the key's length
+	digests := make([][32]byte, 0, len(keys))
+	for _, k := range keys {
+		digests = append(digests, sha256.Sum256([]byte(k.Key)))
+	}
+	return func(c *gin.Context) {
+		provided := c.GetHeader("X-Api-Key")
+		if provided == "" {
+			provided = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+		digest := sha256.Sum256([]byte(provided))
+		valid := 0
+		for _, d := range digests {
+			valid |= subtle.ConstantTimeCompare(digest[:], d[:])
+		}
+		if provided == "" || valid != 1 {
+			c.Error(internal.NewErrorf(internal.ErrorCodeUnauthorized, "%v", admin.ErrInvalidAPIKey))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
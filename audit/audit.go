@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"context"
+	"errors"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/gofrs/uuid"
+)
+
+var (
+	ErrInvalidPagination = errors.New("Invalid page or per_page provided")
+)
+
+// Outcome defines whether an action succeeded
+type Outcome string
+
+const (
+	Success Outcome = "success"
+	Failure Outcome = "failure"
+)
+
+// Entry defines a single security relevant action. Entries are append-only and are never updated once created
+type Entry struct {
+	internal.Base
+	// ActorID defines the identity that performed the action, if one is known
+	ActorID *uuid.UUID `json:"actor_id,omitempty" gorm:"index;type:uuid;default:null"`
+	// SessionID defines the session that the action was performed with, if any
+	SessionID *uuid.UUID `json:"session_id,omitempty" gorm:"index;type:uuid;default:null"`
+	// Client defines the client that performed the action
+	Client internal.Client `json:"client" gorm:"embedded;embeddedPrefix:client_"`
+	// Action defines what was done. This is either the topic of a domain event ie. `login.failed` or, when no event
+	// was published, the method and route of the request ie. `POST /settings/:flow_id`
+	Action string `json:"action" gorm:"index;size:128;not null" validate:"required,max=128"`
+	// Target defines what the action was performed on ie. the identifier used to login or a session id
+	Target string `json:"target,omitempty" gorm:"size:512"`
+	// Outcome defines whether the action succeeded
+	Outcome Outcome `json:"outcome" gorm:"not null" validate:"required,oneof='success' 'failure'"`
+	// Reason defines why the action failed
+	Reason string `json:"reason,omitempty" gorm:"size:255"`
+}
+
+// Page defines a single page of entries, ordered from newest to oldest
+type Page struct {
+	Entries []Entry `json:"entries"`
+	Page    int     `json:"page"`
+	PerPage int     `json:"per_page"`
+	Total   int64   `json:"total"`
+}
+
+type Repository interface {
+	// Create creates new entries
+	Create(ctx context.Context, newEntries ...Entry) error
+	// GetAllActor retrieves a page of entries performed by an actor along with the total number of entries
+	GetAllActor(ctx context.Context, actorID uuid.UUID, offset int, limit int) ([]Entry, int64, error)
+}
+
+type Service interface {
+	// Record appends entries to the log
+	Record(ctx context.Context, entries ...Entry) error
+	// FindAllActor finds a page of entries performed by an actor. Pages start at 1
+	FindAllActor(ctx context.Context, actorID uuid.UUID, page int, perPage int) (*Page, error)
+}
+
+// TableName overrides GORM's table name
+func (Entry) TableName() string {
+	return "audit_log"
+}
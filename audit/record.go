@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/gofrs/uuid"
+)
+
+type recordKey struct{}
+
+// Record collects what happened during a single request so that it can be written to the log once the request has
+// finished. Every method is safe to call on a nil Record, which is what FromContext returns outside of a request
+type Record struct {
+	mu        sync.Mutex
+	client    internal.Client
+	actorID   *uuid.UUID
+	sessionID *uuid.UUID
+	entries   []Entry
+}
+
+// WithRecord returns a copy of ctx that carries a new Record for client
+func WithRecord(ctx context.Context, client internal.Client) (context.Context, *Record) {
+	r := &Record{client: client}
+	return context.WithValue(ctx, recordKey{}, r), r
+}
+
+// FromContext retrieves the Record carried by ctx, if any
+func FromContext(ctx context.Context) *Record {
+	r, _ := ctx.Value(recordKey{}).(*Record)
+	return r
+}
+
+// SetSession records the session, and the identity it belongs to, that the request was made with
+func (r *Record) SetSession(id uuid.UUID, identityID *uuid.UUID) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessionID = &id
+	if identityID != nil {
+		actorID := *identityID
+		r.actorID = &actorID
+	}
+}
+
+// Add adds an entry that describes an action taken during the request. The request's actor, session and client are
+// filled in when the entry is written
+func (r *Record) Add(e Entry) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, e)
+}
+
+// Empty checks whether no entries have been added
+func (r *Record) Empty() bool {
+	if r == nil {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.entries) == 0
+}
+
+// Entries builds the entries that should be written for the request. If nothing was added then a single entry for
+// fallback is returned instead. An entry's outcome is downgraded to a failure when the request itself failed since the
+// action would've been rolled back
+func (r *Record) Entries(fallback Entry, outcome Outcome, reason string) []Entry {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := r.entries
+	if len(entries) == 0 {
+		fallback.Outcome = outcome
+		fallback.Reason = reason
+		entries = []Entry{fallback}
+	}
+	built := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		if e.ActorID == nil {
+			e.ActorID = r.actorID
+		}
+		e.SessionID = r.sessionID
+		e.Client = r.client
+		if outcome == Failure && e.Outcome != Failure {
+			e.Outcome = Failure
+			e.Reason = reason
+		} else if e.Outcome == "" {
+			e.Outcome = Success
+		}
+		built = append(built, e)
+	}
+	return built
+}
@@ -0,0 +1,40 @@
+package gorm
+
+import (
+	"context"
+
+	"github.com/RagOfJoes/mylo/audit"
+	"github.com/RagOfJoes/mylo/persistence"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type gormAuditRepository struct {
+	DB *gorm.DB
+}
+
+func NewGormAuditRepository(d *gorm.DB) audit.Repository {
+	return &gormAuditRepository{DB: d}
+}
+
+func (g *gormAuditRepository) Create(ctx context.Context, newEntries ...audit.Entry) error {
+	if len(newEntries) == 0 {
+		return nil
+	}
+	clone := newEntries
+	return persistence.Conn(ctx, g.DB).Create(&clone).Error
+}
+
+func (g *gormAuditRepository) GetAllActor(ctx context.Context, actorID uuid.UUID, offset int, limit int) ([]audit.Entry, int64, error) {
+	var total int64
+	entries := []audit.Entry{}
+	// Start a new session so that the conditions can be shared by both queries
+	conn := persistence.Conn(ctx, g.DB).Model(&audit.Entry{}).Where("actor_id = ?", actorID).Session(&gorm.Session{})
+	if err := conn.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := conn.Order("created_at desc").Offset(offset).Limit(limit).Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+
+	"github.com/RagOfJoes/mylo/audit"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/gofrs/uuid"
+)
+
+const maxPerPage = 100
+
+type service struct {
+	r audit.Repository
+}
+
+func NewAuditService(r audit.Repository) audit.Service {
+	return &service{
+		r: r,
+	}
+}
+
+func (s *service) Record(ctx context.Context, entries ...audit.Entry) error {
+	for i := range entries {
+		if len(entries[i].Reason) > 255 {
+			entries[i].Reason = entries[i].Reason[:255]
+		}
+		if len(entries[i].Target) > 512 {
+			entries[i].Target = entries[i].Target[:512]
+		}
+	}
+	if err := s.r.Create(ctx, entries...); err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to record audit entries")
+	}
+	return nil
+}
+
+func (s *service) FindAllActor(ctx context.Context, actorID uuid.UUID, page int, perPage int) (*audit.Page, error) {
+	if page < 1 || perPage < 1 || perPage > maxPerPage {
+		return nil, internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", audit.ErrInvalidPagination)
+	}
+	entries, total, err := s.r.GetAllActor(ctx, actorID, (page-1)*perPage, perPage)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to retrieve audit entries for: %s", actorID)
+	}
+	return &audit.Page{
+		Entries: entries,
+		Page:    page,
+		PerPage: perPage,
+		Total:   total,
+	}, nil
+}
@@ -0,0 +1,100 @@
+package subscriber
+
+import (
+	"context"
+	"strings"
+
+	"github.com/RagOfJoes/mylo/audit"
+	"github.com/RagOfJoes/mylo/event"
+	"github.com/gofrs/uuid"
+)
+
+type subscriber struct {
+	s audit.Service
+}
+
+// Register subscribes the handler that describes events in the audit log. Events published during a request are added
+// to the request's Record so that they're written with its outcome, anything else is written immediately
+func Register(bus event.Bus, s audit.Service) {
+	sub := &subscriber{
+		s: s,
+	}
+	for _, topic := range []event.Topic{
+		event.TopicIdentityRegistered,
+		event.TopicIdentityDeleted,
+		event.TopicLoginSucceeded,
+		event.TopicLoginFailed,
+		event.TopicLoginCodeRequested,
+		event.TopicSessionRevoked,
+		event.TopicRecoveryRequested,
+		event.TopicRecoveryCompleted,
+		event.TopicContactVerified,
+		event.TopicPasswordChanged,
+	} {
+		bus.Subscribe(topic, sub.handle)
+	}
+}
+
+func (sub *subscriber) handle(ctx context.Context, e event.Event) error {
+	entry := describe(e)
+	if rec := audit.FromContext(ctx); rec != nil {
+		rec.Add(entry)
+		return nil
+	}
+	if entry.Outcome == "" {
+		entry.Outcome = audit.Success
+	}
+	return sub.s.Record(ctx, entry)
+}
+
+// describe builds the entry for an event
+func describe(e event.Event) audit.Entry {
+	entry := audit.Entry{
+		Action: string(e.Topic()),
+	}
+	switch ev := e.(type) {
+	case event.IdentityRegistered:
+		entry.ActorID = actor(ev.Identity.ID)
+		entry.Client = ev.Client
+	case event.IdentityDeleted:
+		entry.Target = ev.IdentityID.String()
+	case event.LoginSucceeded:
+		entry.ActorID = actor(ev.Identity.ID)
+		entry.Target = string(ev.Method)
+		entry.Client = ev.Client
+	case event.LoginFailed:
+		entry.ActorID = ev.IdentityID
+		entry.Target = ev.Identifier
+		entry.Client = ev.Client
+		entry.Outcome = audit.Failure
+		entry.Reason = ev.Reason
+	case event.LoginCodeRequested:
+		entry.ActorID = actor(ev.Identity.ID)
+		entry.Client = ev.Flow.Client
+	case event.SessionRevoked:
+		entry.ActorID = ev.IdentityID
+		ids := make([]string, 0, len(ev.SessionIDs))
+		for _, id := range ev.SessionIDs {
+			ids = append(ids, id.String())
+		}
+		entry.Target = strings.Join(ids, ",")
+	case event.RecoveryRequested:
+		entry.ActorID = actor(ev.Identity.ID)
+		entry.Client = ev.Flow.Client
+	case event.RecoveryCompleted:
+		entry.ActorID = actor(ev.IdentityID)
+		entry.Client = ev.Client
+	case event.ContactVerified:
+		entry.ActorID = actor(ev.Identity.ID)
+		entry.Target = ev.Contact.ID.String()
+		entry.Client = ev.Client
+	case event.PasswordChanged:
+		entry.ActorID = actor(ev.IdentityID)
+		entry.Client = ev.Client
+	}
+	return entry
+}
+
+func actor(id uuid.UUID) *uuid.UUID {
+	return &id
+}
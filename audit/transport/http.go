@@ -0,0 +1,112 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/RagOfJoes/mylo/audit"
+	"github.com/RagOfJoes/mylo/internal"
+	sessionHttp "github.com/RagOfJoes/mylo/session/transport"
+	"github.com/RagOfJoes/mylo/transport"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultPerPage = 20
+
+type Http struct {
+	sh sessionHttp.Http
+	s  audit.Service
+}
+
+func NewAuditHttp(sh sessionHttp.Http, s audit.Service, r *gin.Engine) {
+	h := &Http{
+		sh: sh,
+		s:  s,
+	}
+	r.GET("/me/activity", h.activity())
+}
+
+// AuditMiddleware records every request that changes state, along with reads that triggered an auditable event ie.
+// following a magic link. This must be attached before ErrorMiddleware so that the final status is known
+func AuditMiddleware(s audit.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, rec := audit.WithRecord(c.Request.Context(), transport.Client(c.Request))
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		// Skip unknown routes
+		if c.FullPath() == "" {
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if rec.Empty() {
+				return
+			}
+		}
+
+		outcome := audit.Success
+		reason := ""
+		if status := c.Writer.Status(); status >= http.StatusBadRequest || len(c.Errors) > 0 {
+			outcome = audit.Failure
+			reason = failureReason(c, status)
+		}
+		var params []string
+		for _, p := range c.Params {
+			params = append(params, fmt.Sprintf("%s=%s", p.Key, p.Value))
+		}
+		fallback := audit.Entry{
+			Action: fmt.Sprintf("%s %s", c.Request.Method, c.FullPath()),
+			Target: strings.Join(params, ","),
+		}
+		if err := s.Record(c.Request.Context(), rec.Entries(fallback, outcome, reason)...); err != nil {
+			// TODO: Capture Error Here
+			log.Print(err)
+		}
+	}
+}
+
+func (h *Http) activity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.sh.Session(ctx, c.Request, c.Writer, true)
+		if err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeUnauthorized, "%v", internal.ErrUnauthorized))
+			return
+		}
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", audit.ErrInvalidPagination))
+			return
+		}
+		perPage, err := strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(defaultPerPage)))
+		if err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", audit.ErrInvalidPagination))
+			return
+		}
+		found, err := h.s.FindAllActor(ctx, *sess.IdentityID, page, perPage)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Payload: found,
+		})
+	}
+}
+
+// failureReason retrieves the same message that the client was shown. Internal errors are never exposed
+func failureReason(c *gin.Context, status int) string {
+	var err *internal.Error
+	if len(c.Errors) > 0 && errors.As(c.Errors.Last().Err, &err) && err.Code() != internal.ErrorCodeInternal {
+		return err.Message()
+	}
+	return http.StatusText(status)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"github.com/RagOfJoes/mylo/admin"
+	adminService "github.com/RagOfJoes/mylo/admin/service"
+	"github.com/RagOfJoes/mylo/audit"
+	auditService "github.com/RagOfJoes/mylo/audit/service"
+	auditSubscriber "github.com/RagOfJoes/mylo/audit/subscriber"
+	"github.com/RagOfJoes/mylo/email"
+	"github.com/RagOfJoes/mylo/email/outbox"
+	outboxService "github.com/RagOfJoes/mylo/email/outbox/service"
+	emailSubscriber "github.com/RagOfJoes/mylo/email/subscriber"
+	"github.com/RagOfJoes/mylo/event"
+	"github.com/RagOfJoes/mylo/flow/login"
+	loginService "github.com/RagOfJoes/mylo/flow/login/service"
+	"github.com/RagOfJoes/mylo/flow/oidc"
+	oidcService "github.com/RagOfJoes/mylo/flow/oidc/service"
+	"github.com/RagOfJoes/mylo/flow/recovery"
+	recoveryService "github.com/RagOfJoes/mylo/flow/recovery/service"
+	"github.com/RagOfJoes/mylo/flow/registration"
+	registrationService "github.com/RagOfJoes/mylo/flow/registration/service"
+	"github.com/RagOfJoes/mylo/flow/settings"
+	settingsService "github.com/RagOfJoes/mylo/flow/settings/service"
+	"github.com/RagOfJoes/mylo/flow/verification"
+	verificationService "github.com/RagOfJoes/mylo/flow/verification/service"
+	verificationSubscriber "github.com/RagOfJoes/mylo/flow/verification/subscriber"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/janitor"
+	janitorService "github.com/RagOfJoes/mylo/janitor/service"
+	"github.com/RagOfJoes/mylo/oauth2"
+	oauth2Service "github.com/RagOfJoes/mylo/oauth2/service"
+	"github.com/RagOfJoes/mylo/persistence"
+	"github.com/RagOfJoes/mylo/persistence/memory"
+	"github.com/RagOfJoes/mylo/session"
+	sessionRedis "github.com/RagOfJoes/mylo/session/repository/redis"
+	sessionService "github.com/RagOfJoes/mylo/session/service"
+	"github.com/RagOfJoes/mylo/token"
+	tokenService "github.com/RagOfJoes/mylo/token/service"
+	"github.com/RagOfJoes/mylo/user/contact"
+	contactService "github.com/RagOfJoes/mylo/user/contact/service"
+	"github.com/RagOfJoes/mylo/user/credential"
+	credentialService "github.com/RagOfJoes/mylo/user/credential/service"
+	"github.com/RagOfJoes/mylo/user/identity"
+	identityService "github.com/RagOfJoes/mylo/user/identity/service"
+	"github.com/RagOfJoes/mylo/webhook"
+	webhookService "github.com/RagOfJoes/mylo/webhook/service"
+	webhookSubscriber "github.com/RagOfJoes/mylo/webhook/subscriber"
+)
+
+// services holds every repository and service so that the server and the other commands are wired up the same way
+type services struct {
+	repos repositories
+
+	outbox       outbox.Service
+	webhook      webhook.Service
+	audit        audit.Service
+	session      session.Service
+	token        token.Service
+	contact      contact.Service
+	credential   credential.Service
+	identity     identity.Service
+	verification verification.Service
+	registration registration.Service
+	login        login.Service
+	recovery     recovery.Service
+	oidc         oidc.Service
+	settings     settings.Service
+	oauth2       oauth2.Service
+	admin        admin.Service
+	janitor      janitor.Service
+
+	close func()
+}
+
+// newServices connects to the configured database and builds every service along with their event subscribers. close
+// must be called once the services are no longer needed
+func newServices() (*services, error) {
+	cfg := config.Get()
+	s := &services{close: func() {}}
+
+	// Setup repositories
+	switch cfg.Database.Driver {
+	case "memory":
+		s.repos = newMemoryRepositories(memory.NewStore())
+	default:
+		db, err := persistence.NewGorm()
+		if err != nil {
+			return nil, err
+		}
+		s.repos = newGormRepositories(db)
+		s.close = func() { persistence.CloseGorm(db) }
+	}
+	if cfg.Session.Store == config.RedisStore {
+		rdb, err := persistence.NewRedis()
+		if err != nil {
+			s.close()
+			return nil, err
+		}
+		closeDB := s.close
+		s.close = func() {
+			rdb.Close()
+			closeDB()
+		}
+		s.repos.session = sessionRedis.NewRedisSessionRepository(rdb, s.repos.identity)
+	}
+
+	// Setup Email client
+	email, err := email.New()
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+
+	// Setup event bus
+	bus := event.NewBus()
+	// Setup services
+	s.outbox = outboxService.NewOutboxService(s.repos.outbox, email)
+	s.webhook = webhookService.NewWebhookService(s.repos.webhook, cfg.Webhook, nil)
+	s.audit = auditService.NewAuditService(s.repos.audit)
+	s.session = sessionService.NewSessionService(s.repos.session, bus)
+	s.token = tokenService.NewTokenService(s.repos.token, cfg.Token)
+	s.contact = contactService.NewContactService(s.repos.contact)
+	s.credential = credentialService.NewCredentialService(s.repos.credential)
+	s.identity = identityService.NewIdentityService(s.repos.identity, bus)
+	// Flow Services
+	// These will essentially stitch all other services together
+	s.verification = verificationService.NewVerificationService(s.repos.verification, s.repos.transactor, bus, s.contact, s.credential, s.identity)
+	s.registration = registrationService.NewRegistrationService(s.repos.registration, s.repos.transactor, bus, s.contact, s.credential, s.identity)
+	s.login = loginService.NewLoginService(s.repos.login, s.repos.transactor, bus, s.contact, s.credential, s.identity)
+	s.recovery = recoveryService.NewRecoveryService(s.repos.recovery, s.repos.transactor, bus, s.credential, s.contact, s.identity)
+	s.oidc = oidcService.NewOIDCService(s.repos.oidc, bus, s.contact, s.credential, s.identity)
+	s.settings = settingsService.NewSettingsService(s.repos.settings, bus, s.credential, s.identity)
+	s.oauth2 = oauth2Service.NewOAuth2Service(s.repos.oauth2, s.token, s.session, s.identity)
+	s.admin = adminService.NewAdminService(bus, s.recovery, s.session, s.contact, s.credential, s.identity)
+	// Every table that grows with each request is purged once its rows have expired
+	tables := append(flowTables(s.repos, cfg.Janitor.Retention), janitor.Table{Name: "sessions", Retention: cfg.Janitor.Retention.Sessions, Repository: s.repos.session})
+	s.janitor = janitorService.NewJanitorService(cfg.Janitor, tables...)
+	// Subscribers
+	emailSubscriber.Register(bus, s.outbox)
+	webhookSubscriber.Register(bus, s.webhook)
+	auditSubscriber.Register(bus, s.audit)
+	verificationSubscriber.Register(bus, s.verification)
+	return s, nil
+}
+
+// flowTables lists every flow table that the janitor purges. The names are shared by the janitor's metrics so they have
+// to be the same wherever flows are purged
+func flowTables(repos repositories, retention config.Retention) []janitor.Table {
+	return []janitor.Table{
+		{Name: "logins", Retention: retention.Logins, Repository: repos.login},
+		{Name: "registrations", Retention: retention.Registrations, Repository: repos.registration},
+		{Name: "recoveries", Retention: retention.Recoveries, Repository: repos.recovery},
+		{Name: "verifications", Retention: retention.Verifications, Repository: repos.verification},
+		{Name: "settings", Retention: retention.Settings, Repository: repos.settings},
+		{Name: "oidc", Retention: retention.OIDC, Repository: repos.oidc},
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/RagOfJoes/mylo/internal/config"
+	janitorService "github.com/RagOfJoes/mylo/janitor/service"
+	"github.com/urfave/cli"
+)
+
+var flowsCommand = cli.Command{
+	Name:  "flows",
+	Usage: "Manage flows",
+	Subcommands: []cli.Command{
+		{
+			Name:  "cleanup",
+			Usage: "Delete every expired or finished flow",
+			Flags: []cli.Flag{
+				cli.DurationFlag{Name: "older-than", Usage: "only delete flows that expired or finished at least this long ago"},
+				cli.IntFlag{Name: "batch-size", Value: 1000, Usage: "how many flows to delete at a time"},
+			},
+			Action: cleanupFlows,
+		},
+	},
+}
+
+var janitorCommand = cli.Command{
+	Name:   "janitor",
+	Usage:  "Purge expired flows and sessions once, using the configured retention",
+	Action: runJanitor,
+}
+
+// cleanupFlows purges flows with the janitor, but with the retention and batch size provided instead of the
+// configured ones
+func cleanupFlows(c *cli.Context) error {
+	limit := c.Int("batch-size")
+	if limit < 1 {
+		return errors.New("Batch size must be a positive number")
+	}
+	s, err := openServices()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	retention := c.Duration("older-than")
+	cleanup := janitorService.NewJanitorService(config.Janitor{BatchSize: limit}, flowTables(s.repos, config.Retention{
+		Logins:        retention,
+		Registrations: retention,
+		Recoveries:    retention,
+		Verifications: retention,
+		Settings:      retention,
+		OIDC:          retention,
+	})...)
+	results, err := cleanup.Purge(context.Background())
+	for _, r := range results {
+		fmt.Printf("Deleted %d flows from %s\n", r.Deleted, r.Table)
+	}
+	return err
+}
+
+func runJanitor(c *cli.Context) error {
+	s, err := openServices()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	results, err := s.janitor.Purge(context.Background())
+	for _, r := range results {
+		fmt.Printf("Purged %d expired %s\n", r.Deleted, r.Table)
+	}
+	return err
+}
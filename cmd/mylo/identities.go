@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/RagOfJoes/mylo/admin"
+	"github.com/RagOfJoes/mylo/user/identity"
+	"github.com/gofrs/uuid"
+	"github.com/urfave/cli"
+)
+
+var identitiesCommand = cli.Command{
+	Name:  "identities",
+	Usage: "Manage identities",
+	Subcommands: []cli.Command{
+		{
+			Name:  "create",
+			Usage: "Create an identity with a password",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "email"},
+				cli.StringFlag{Name: "username"},
+				cli.StringFlag{Name: "password", Usage: "Read from stdin when omitted"},
+				cli.StringFlag{Name: "first-name"},
+				cli.StringFlag{Name: "last-name"},
+				cli.BoolFlag{Name: "verified", Usage: "mark the email as verified instead of sending a verification email"},
+			},
+			Action: createIdentity,
+		},
+		{
+			Name:  "list",
+			Usage: "List identities from newest to oldest",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "search", Usage: "match against the email, name and identifiers"},
+				cli.BoolFlag{Name: "deleted", Usage: "list soft deleted identities instead"},
+				cli.IntFlag{Name: "page", Value: 1},
+				cli.IntFlag{Name: "per-page", Value: 20},
+			},
+			Action: listIdentities,
+		},
+		{
+			Name:      "delete",
+			Usage:     "Delete an identity and revoke its sessions",
+			ArgsUsage: "<id>",
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "permanent", Usage: "delete the identity for good instead of soft deleting it"},
+			},
+			Action: deleteIdentity,
+		},
+		{
+			Name:      "import",
+			Usage:     "Create identities from a file with a JSON object per line",
+			ArgsUsage: "<file>",
+			Action:    importIdentities,
+		},
+	},
+}
+
+func createIdentity(c *cli.Context) error {
+	password, err := readPassword(c)
+	if err != nil {
+		return err
+	}
+	s, err := openServices()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	created, err := s.admin.Create(context.Background(), admin.CreatePayload{
+		Email:     c.String("email"),
+		Username:  c.String("username"),
+		Password:  password,
+		FirstName: c.String("first-name"),
+		LastName:  c.String("last-name"),
+		Verified:  c.Bool("verified"),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created %s\n", created.ID)
+	return nil
+}
+
+func listIdentities(c *cli.Context) error {
+	s, err := openServices()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	page, err := s.admin.List(context.Background(), identity.Filter{
+		Search:  c.String("search"),
+		Deleted: c.Bool("deleted"),
+	}, c.Int("page"), c.Int("per-page"))
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEMAIL\tNAME\tCREATED AT")
+	for _, i := range page.Identities {
+		name := strings.TrimSpace(i.FirstName + " " + i.LastName)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", i.ID, i.Email, name, i.CreatedAt.Format(time.RFC3339))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("Page %d, %d identities in total\n", page.Page, page.Total)
+	return nil
+}
+
+func deleteIdentity(c *cli.Context) error {
+	id, err := uuid.FromString(c.Args().First())
+	if err != nil {
+		return admin.ErrInvalidIdentityID
+	}
+	s, err := openServices()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	if err := s.admin.Delete(context.Background(), id, c.Bool("permanent")); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted %s\n", id)
+	return nil
+}
+
+// importIdentities creates an identity for every line of the file. A line that fails doesn't stop the import, instead
+// every failure is reported once the whole file has been read
+func importIdentities(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return errors.New("A file to import must be provided")
+	}
+	file, err := os.Open(c.Args().First())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	s, err := openServices()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	ctx := context.Background()
+	created, failed := 0, 0
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var payload admin.CreatePayload
+		if err := json.Unmarshal(scanner.Bytes(), &payload); err != nil {
+			fmt.Fprintf(os.Stderr, "Line %d: %v\n", line, err)
+			failed++
+			continue
+		}
+		if _, err := s.admin.Create(ctx, payload); err != nil {
+			fmt.Fprintf(os.Stderr, "Line %d (%s): %v\n", line, payload.Email, err)
+			failed++
+			continue
+		}
+		created++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d identities, %d failed\n", created, failed)
+	if failed > 0 {
+		return fmt.Errorf("Failed to import %d identities", failed)
+	}
+	return nil
+}
@@ -1,107 +1,159 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 
-	"github.com/RagOfJoes/mylo/email"
-	loginGorm "github.com/RagOfJoes/mylo/flow/login/repository/gorm"
-	loginService "github.com/RagOfJoes/mylo/flow/login/service"
-	loginTransport "github.com/RagOfJoes/mylo/flow/login/transport"
-	recoveryGorm "github.com/RagOfJoes/mylo/flow/recovery/repository/gorm"
-	recoveryService "github.com/RagOfJoes/mylo/flow/recovery/service"
-	recoveryTransport "github.com/RagOfJoes/mylo/flow/recovery/transport"
-	registrationGorm "github.com/RagOfJoes/mylo/flow/registration/repository/gorm"
-	registrationService "github.com/RagOfJoes/mylo/flow/registration/service"
-	registrationTransport "github.com/RagOfJoes/mylo/flow/registration/transport"
-	verificationGorm "github.com/RagOfJoes/mylo/flow/verification/repository/gorm"
-	verificationService "github.com/RagOfJoes/mylo/flow/verification/service"
-	verificationTransport "github.com/RagOfJoes/mylo/flow/verification/transport"
 	"github.com/RagOfJoes/mylo/internal/config"
-	"github.com/RagOfJoes/mylo/persistence"
-	sessionGorm "github.com/RagOfJoes/mylo/session/repository/gorm"
-	sessionService "github.com/RagOfJoes/mylo/session/service"
-	sessionTransport "github.com/RagOfJoes/mylo/session/transport"
-	"github.com/RagOfJoes/mylo/transport"
-	contactGorm "github.com/RagOfJoes/mylo/user/contact/repository/gorm"
-	contactService "github.com/RagOfJoes/mylo/user/contact/service"
-	credentialGorm "github.com/RagOfJoes/mylo/user/credential/repository/gorm"
 	credentialService "github.com/RagOfJoes/mylo/user/credential/service"
-	identityGorm "github.com/RagOfJoes/mylo/user/identity/repository/gorm"
-	identityService "github.com/RagOfJoes/mylo/user/identity/service"
-	identityTransport "github.com/RagOfJoes/mylo/user/identity/transport"
-	"github.com/gorilla/sessions"
+	"github.com/urfave/cli"
 )
 
-func init() {
-	// Load configuration
-	if err := config.Setup("mylo", "yaml", "/home/mylo/"); err != nil {
-		log.Fatal(err)
-	}
-}
+var errMemoryDriver = errors.New("The memory driver only lives as long as the server, use mylo serve instead")
 
 func main() {
-	cfg := config.Get()
+	app := cli.NewApp()
+	app.Name = "mylo"
+	app.Usage = "Identity and user management"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:   "config, c",
+			Usage:  "path to the configuration file",
+			EnvVar: "MYLO_CONFIG",
+			Value:  "/home/mylo/mylo.yaml",
+		},
+	}
+	app.Before = loadConfig
+	// Running mylo without a command starts the server
+	app.Action = serve
+	app.Commands = []cli.Command{
+		{
+			Name:   "serve",
+			Usage:  "Run the HTTP servers",
+			Action: serve,
+		},
+		migrateCommand,
+		{
+			Name:  "config",
+			Usage: "Manage the configuration",
+			Subcommands: []cli.Command{
+				{
+					Name:  "validate",
+					Usage: "Check that the configuration file is valid",
+					// The configuration is validated as it's loaded so there's nothing left to do by the time this runs
+					Action: func(c *cli.Context) error {
+						fmt.Printf("%s is valid\n", c.GlobalString("config"))
+						return nil
+					},
+				},
+			},
+		},
+		identitiesCommand,
+		{
+			Name:  "sessions",
+			Usage: "Manage sessions",
+			Subcommands: []cli.Command{
+				{
+					Name:  "revoke",
+					Usage: "Revoke every session that belongs to an identity",
+					Flags: []cli.Flag{
+						cli.StringFlag{Name: "identity", Usage: "id, email or username of the identity"},
+					},
+					Action: revokeSessions,
+				},
+			},
+		},
+		flowsCommand,
+		janitorCommand,
+		{
+			Name:      "hash-password",
+			Usage:     "Hash a password with the configured Argon2 parameters",
+			ArgsUsage: " ",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "password", Usage: "password to hash. Read from stdin when omitted"},
+			},
+			Action: func(c *cli.Context) error {
+				password, err := readPassword(c)
+				if err != nil {
+					return err
+				}
+				hash, err := credentialService.HashPassword(password)
+				if err != nil {
+					return err
+				}
+				fmt.Println(hash)
+				return nil
+			},
+		},
+	}
 
-	db, err := persistence.NewGorm()
-	if err != nil {
-		log.Panic(err.Error())
-		return
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
 	}
+}
 
-	// Setup Email client
-	email := email.New()
+// loadConfig loads the configuration file that was passed with --config. The file's extension determines its format
+func loadConfig(c *cli.Context) error {
+	if c.Args().First() == "help" {
+		return nil
+	}
+	path := c.GlobalString("config")
+	ext := filepath.Ext(path)
+	name := strings.TrimSuffix(filepath.Base(path), ext)
+	filetype := strings.TrimPrefix(ext, ".")
+	if filetype == "" {
+		filetype = "yaml"
+	}
+	return config.Setup(name, filetype, filepath.Dir(path))
+}
 
-	// Setup repositories
-	sessionRepository := sessionGorm.NewGormSessionRepository(db)
-	contactRepository := contactGorm.NewGormContactRepository(db)
-	credentialRepository := credentialGorm.NewGormCredentialRepository(db)
-	identityRepository := identityGorm.NewGormUserRepository(db)
-	recoveryRepository := recoveryGorm.NewGormRecoveryRepository(db)
-	verificationRepository := verificationGorm.NewGormVerificationRepository(db)
-	registrationRepository := registrationGorm.NewGormRegistrationRepository(db)
-	loginRepository := loginGorm.NewGormLoginRepository(db)
-	// Setup services
-	sessionService := sessionService.NewSessionService(sessionRepository)
-	contactService := contactService.NewContactService(contactRepository)
-	credentialService := credentialService.NewCredentialService(credentialRepository)
-	identityService := identityService.NewIdentityService(identityRepository)
-	// Flow Services
-	// These will essentially stitch all other services together
-	verificationService := verificationService.NewVerificationService(verificationRepository, contactService, credentialService, identityService)
-	registrationService := registrationService.NewRegistrationService(registrationRepository, contactService, credentialService, identityService)
-	loginService := loginService.NewLoginService(loginRepository, contactService, credentialService, identityService)
-	recoveryService := recoveryService.NewRecoveryService(recoveryRepository, credentialService, contactService)
+// openServices creates the services for commands that operate on the configured database
+func openServices() (*services, error) {
+	if config.Get().Database.Driver == "memory" {
+		return nil, errMemoryDriver
+	}
+	return newServices()
+}
 
-	// Create session manager
-	store := sessions.NewCookieStore([]byte(cfg.Session.Cookie.Name))
-	sessionHttp := sessionTransport.NewSessionHttp(store, sessionService)
+func revokeSessions(c *cli.Context) error {
+	value := c.String("identity")
+	if value == "" {
+		return errors.New("--identity is required")
+	}
+	s, err := openServices()
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
+	defer s.close()
 
-	// Setup HTTP Server
-	router := transport.NewHttp()
-
-	// Attach Middlewares
-	//
-	// Order of execution:
-	// 1. Rate Limiter
-	// 2. Security Middleware (Adds essential security headers to request)
-	// 3. Error Middleware handles any errors that were generated from route execution
-	if cfg.Server.RPS > 0 {
-		router.Use(transport.RateLimiterMiddleware(cfg.Server.RPS))
+	ctx := context.Background()
+	// Identities can be referred to by their id or any of their identifiers
+	found, err := s.identity.Find(ctx, value)
+	if err != nil {
+		return err
 	}
-	router.Use(transport.SecurityMiddleware(), transport.ErrorMiddleware())
-
-	// Attach routes
-	identityTransport.NewIdentityHttp(*sessionHttp, router)
-	verificationTransport.NewVerificationHttp(email, *sessionHttp, verificationService, router)
-	registrationTransport.NewRegistrationHttp(email, *sessionHttp, registrationService, verificationService, router)
-	loginTransport.NewLoginHttp(*sessionHttp, loginService, router)
-	recoveryTransport.NewRecoveryHttp(email, *sessionHttp, recoveryService, identityService, router)
+	if err := s.admin.RevokeSessions(ctx, found.ID); err != nil {
+		return err
+	}
+	fmt.Printf("Revoked every session for %s\n", found.ID)
+	return nil
+}
 
-	// Start HTTP server
-	if err := transport.RunHttp(router); err != nil {
-		log.Panic(err)
+// readPassword reads the password from --password, falling back to the first line of stdin so that it doesn't have
+// to end up in the shell's history
+func readPassword(c *cli.Context) (string, error) {
+	if password := c.String("password"); password != "" {
+		return password, nil
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.New("A password must be provided with --password or through stdin")
 	}
+	return strings.TrimRight(line, "\r\n"), nil
 }
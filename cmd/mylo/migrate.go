@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/persistence"
+	"github.com/urfave/cli"
+	"gorm.io/gorm"
+)
+
+var migrateCommand = cli.Command{
+	Name:  "migrate",
+	Usage: "Manage the database schema",
+	Subcommands: []cli.Command{
+		{
+			Name:   "up",
+			Usage:  "Apply every pending migration",
+			Action: migrateUp,
+		},
+		{
+			Name:      "down",
+			Usage:     "Revert the latest migrations",
+			ArgsUsage: "[steps]",
+			Action:    migrateDown,
+		},
+		{
+			Name:   "status",
+			Usage:  "List every migration and when it was applied",
+			Action: migrateStatus,
+		},
+	},
+}
+
+// openMigrations connects to the database without checking its schema, since that's what the migrate commands are for
+func openMigrations() (*gorm.DB, error) {
+	if driver := config.Get().Database.Driver; driver == "memory" || driver == "sqlite" {
+		return nil, fmt.Errorf("%w, not %s", persistence.ErrNoMigrations, driver)
+	}
+	return persistence.OpenGorm()
+}
+
+func migrateUp(c *cli.Context) error {
+	db, err := openMigrations()
+	if err != nil {
+		return err
+	}
+	defer persistence.CloseGorm(db)
+	applied, err := persistence.MigrateUp(db)
+	for _, m := range applied {
+		fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("Schema is already up to date")
+	}
+	return nil
+}
+
+// migrateDown reverts a single migration unless told otherwise
+func migrateDown(c *cli.Context) error {
+	steps := 1
+	if c.NArg() > 0 {
+		n, err := strconv.Atoi(c.Args().First())
+		if err != nil || n < 1 {
+			return errors.New("Steps must be a positive number")
+		}
+		steps = n
+	}
+	db, err := openMigrations()
+	if err != nil {
+		return err
+	}
+	defer persistence.CloseGorm(db)
+	reverted, err := persistence.MigrateDown(db, steps)
+	for _, m := range reverted {
+		fmt.Printf("Reverted %04d_%s\n", m.Version, m.Name)
+	}
+	return err
+}
+
+func migrateStatus(c *cli.Context) error {
+	db, err := openMigrations()
+	if err != nil {
+		return err
+	}
+	defer persistence.CloseGorm(db)
+	status, err := persistence.MigrationStatus(db)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, m := range status {
+		appliedAt := "pending"
+		if m.AppliedAt != nil {
+			appliedAt = m.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", m.Version, m.Name, appliedAt)
+	}
+	return w.Flush()
+}
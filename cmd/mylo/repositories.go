@@ -0,0 +1,101 @@
+package main
+
+import (
+	"github.com/RagOfJoes/mylo/audit"
+	auditGorm "github.com/RagOfJoes/mylo/audit/repository/gorm"
+	"github.com/RagOfJoes/mylo/email/outbox"
+	outboxGorm "github.com/RagOfJoes/mylo/email/outbox/repository/gorm"
+	"github.com/RagOfJoes/mylo/flow/login"
+	loginGorm "github.com/RagOfJoes/mylo/flow/login/repository/gorm"
+	"github.com/RagOfJoes/mylo/flow/oidc"
+	oidcGorm "github.com/RagOfJoes/mylo/flow/oidc/repository/gorm"
+	"github.com/RagOfJoes/mylo/flow/recovery"
+	recoveryGorm "github.com/RagOfJoes/mylo/flow/recovery/repository/gorm"
+	"github.com/RagOfJoes/mylo/flow/registration"
+	registrationGorm "github.com/RagOfJoes/mylo/flow/registration/repository/gorm"
+	"github.com/RagOfJoes/mylo/flow/settings"
+	settingsGorm "github.com/RagOfJoes/mylo/flow/settings/repository/gorm"
+	"github.com/RagOfJoes/mylo/flow/verification"
+	verificationGorm "github.com/RagOfJoes/mylo/flow/verification/repository/gorm"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/oauth2"
+	oauth2Gorm "github.com/RagOfJoes/mylo/oauth2/repository/gorm"
+	"github.com/RagOfJoes/mylo/persistence"
+	"github.com/RagOfJoes/mylo/persistence/memory"
+	"github.com/RagOfJoes/mylo/session"
+	sessionGorm "github.com/RagOfJoes/mylo/session/repository/gorm"
+	"github.com/RagOfJoes/mylo/token"
+	tokenGorm "github.com/RagOfJoes/mylo/token/repository/gorm"
+	"github.com/RagOfJoes/mylo/user/contact"
+	contactGorm "github.com/RagOfJoes/mylo/user/contact/repository/gorm"
+	"github.com/RagOfJoes/mylo/user/credential"
+	credentialGorm "github.com/RagOfJoes/mylo/user/credential/repository/gorm"
+	"github.com/RagOfJoes/mylo/user/identity"
+	identityGorm "github.com/RagOfJoes/mylo/user/identity/repository/gorm"
+	"github.com/RagOfJoes/mylo/webhook"
+	webhookGorm "github.com/RagOfJoes/mylo/webhook/repository/gorm"
+	"gorm.io/gorm"
+)
+
+// repositories holds every repository that the services are built with so that main doesn't have to care about
+// which database they're backed by
+type repositories struct {
+	transactor   internal.Transactor
+	outbox       outbox.Repository
+	webhook      webhook.Repository
+	audit        audit.Repository
+	token        token.Repository
+	oauth2       oauth2.Repository
+	contact      contact.Repository
+	credential   credential.Repository
+	identity     identity.Repository
+	session      session.Repository
+	recovery     recovery.Repository
+	verification verification.Repository
+	registration registration.Repository
+	login        login.Repository
+	oidc         oidc.Repository
+	settings     settings.Repository
+}
+
+func newGormRepositories(db *gorm.DB) repositories {
+	return repositories{
+		transactor:   persistence.NewGormTransactor(db),
+		outbox:       outboxGorm.NewGormOutboxRepository(db),
+		webhook:      webhookGorm.NewGormWebhookRepository(db),
+		audit:        auditGorm.NewGormAuditRepository(db),
+		token:        tokenGorm.NewGormTokenRepository(db),
+		oauth2:       oauth2Gorm.NewGormOAuth2Repository(db),
+		contact:      contactGorm.NewGormContactRepository(db),
+		credential:   credentialGorm.NewGormCredentialRepository(db),
+		identity:     identityGorm.NewGormUserRepository(db),
+		session:      sessionGorm.NewGormSessionRepository(db),
+		recovery:     recoveryGorm.NewGormRecoveryRepository(db),
+		verification: verificationGorm.NewGormVerificationRepository(db),
+		registration: registrationGorm.NewGormRegistrationRepository(db),
+		login:        loginGorm.NewGormLoginRepository(db),
+		oidc:         oidcGorm.NewGormOIDCRepository(db),
+		settings:     settingsGorm.NewGormSettingsRepository(db),
+	}
+}
+
+func newMemoryRepositories(s *memory.Store) repositories {
+	return repositories{
+		transactor:   memory.NewMemoryTransactor(s),
+		outbox:       memory.NewMemoryOutboxRepository(s),
+		webhook:      memory.NewMemoryWebhookRepository(s),
+		audit:        memory.NewMemoryAuditRepository(s),
+		token:        memory.NewMemoryTokenRepository(s),
+		oauth2:       memory.NewMemoryOAuth2Repository(s),
+		contact:      memory.NewMemoryContactRepository(s),
+		credential:   memory.NewMemoryCredentialRepository(s),
+		identity:     memory.NewMemoryIdentityRepository(s),
+		session:      memory.NewMemorySessionRepository(s),
+		recovery:     memory.NewMemoryRecoveryRepository(s),
+		verification: memory.NewMemoryVerificationRepository(s),
+		registration: memory.NewMemoryRegistrationRepository(s),
+		login:        memory.NewMemoryLoginRepository(s),
+		oidc:         memory.NewMemoryOIDCRepository(s),
+		settings:     memory.NewMemorySettingsRepository(s),
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+
+	adminTransport "github.com/RagOfJoes/mylo/admin/transport"
+	auditTransport "github.com/RagOfJoes/mylo/audit/transport"
+	loginTransport "github.com/RagOfJoes/mylo/flow/login/transport"
+	oidcTransport "github.com/RagOfJoes/mylo/flow/oidc/transport"
+	recoveryTransport "github.com/RagOfJoes/mylo/flow/recovery/transport"
+	registrationTransport "github.com/RagOfJoes/mylo/flow/registration/transport"
+	settingsTransport "github.com/RagOfJoes/mylo/flow/settings/transport"
+	verificationTransport "github.com/RagOfJoes/mylo/flow/verification/transport"
+	"github.com/RagOfJoes/mylo/internal/config"
+	oauth2Transport "github.com/RagOfJoes/mylo/oauth2/transport"
+	sessionTransport "github.com/RagOfJoes/mylo/session/transport"
+	tokenTransport "github.com/RagOfJoes/mylo/token/transport"
+	"github.com/RagOfJoes/mylo/transport"
+	credentialTransport "github.com/RagOfJoes/mylo/user/credential/transport"
+	identityTransport "github.com/RagOfJoes/mylo/user/identity/transport"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+	"github.com/urfave/cli"
+)
+
+// serve runs the public HTTP server, along with the admin one if any API keys have been configured, until it's shut
+// down
+func serve(c *cli.Context) error {
+	cfg := config.Get()
+	s, err := newServices()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	// Create session manager
+	store := sessions.NewCookieStore([]byte(cfg.Session.Cookie.Name))
+	sessionHttp := sessionTransport.NewSessionHttp(store, s.session)
+
+	// Setup HTTP Server
+	router := transport.NewHttp()
+
+	// Attach Middlewares
+	//
+	// Order of execution:
+	// 1. Rate Limiter
+	// 2. Security Middleware (Adds essential security headers to request)
+	// 3. Audit Middleware records the outcome of the request once Error Middleware has responded
+	// 4. Error Middleware handles any errors that were generated from route execution
+	if cfg.Server.RPS > 0 {
+		router.Use(transport.RateLimiterMiddleware(cfg.Server.RPS))
+	}
+	router.Use(transport.SecurityMiddleware(), auditTransport.AuditMiddleware(s.audit), transport.ErrorMiddleware())
+
+	// Attach routes
+	sessionTransport.NewSessionRoutes(sessionHttp, router)
+	tokenTransport.NewTokenHttp(*sessionHttp, s.token, router)
+	identityTransport.NewIdentityHttp(*sessionHttp, router)
+	auditTransport.NewAuditHttp(*sessionHttp, s.audit, router)
+	credentialTransport.NewCredentialHttp(*sessionHttp, s.credential, router)
+	verificationTransport.NewVerificationHttp(*sessionHttp, s.verification, router)
+	registrationTransport.NewRegistrationHttp(*sessionHttp, s.registration, router)
+	loginTransport.NewLoginHttp(*sessionHttp, s.login, router)
+	recoveryTransport.NewRecoveryHttp(*sessionHttp, s.recovery, router)
+	oidcTransport.NewOIDCHttp(*sessionHttp, s.oidc, s.login, router)
+	settingsTransport.NewSettingsHttp(*sessionHttp, s.settings, router)
+	oauth2Transport.NewOAuth2Http(*sessionHttp, s.oauth2, router)
+
+	// Deliver queued emails and webhooks, and purge expired rows, in the background until the server shuts down
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.outbox.Run(ctx)
+	go s.webhook.Run(ctx)
+	go s.janitor.Run(ctx)
+
+	servers := []*http.Server{
+		transport.NewServer(cfg.Server.Host, cfg.Server.Port, router),
+	}
+	// Setup Admin HTTP Server. This runs on its own listener so that it can be kept off of the public network
+	if len(cfg.Admin.Keys) > 0 {
+		adminRouter := transport.NewHttp()
+		adminRouter.Use(auditTransport.AuditMiddleware(s.audit), transport.ErrorMiddleware(), adminTransport.APIKeyMiddleware(cfg.Admin.Keys))
+		adminTransport.NewAdminHttp(s.admin, adminRouter)
+		oauth2Transport.NewClientAdminHttp(s.oauth2, adminRouter)
+		// Metrics, ie. how many rows the janitor has purged
+		adminRouter.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+		servers = append(servers, transport.NewServer(cfg.Admin.Host, cfg.Admin.Port, adminRouter))
+	}
+
+	// Start HTTP servers
+	return transport.RunHttp(servers...)
+}
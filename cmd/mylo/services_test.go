@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RagOfJoes/mylo/event"
+	"github.com/RagOfJoes/mylo/flow/login"
+	"github.com/RagOfJoes/mylo/flow/registration"
+	"github.com/RagOfJoes/mylo/flow/settings"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config/configtest"
+	"github.com/RagOfJoes/mylo/user/identity"
+)
+
+// newTestServices wires every service against the memory driver
+func newTestServices(t *testing.T, overrides map[string]interface{}) *services {
+	t.Helper()
+
+	config := map[string]interface{}{
+		"credential.argon.memory":     1024,
+		"credential.argon.iterations": 1,
+		"login.maxattempts":           3,
+		"login.passwordless":          true,
+	}
+	for key, value := range overrides {
+		config[key] = value
+	}
+	configtest.Setup(t, config)
+	s, err := newServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.close)
+	return s
+}
+
+func register(t *testing.T, s *services) {
+	t.Helper()
+
+	ctx := context.Background()
+	flow, err := s.registration.New(ctx, "http://localhost", internal.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.registration.Submit(ctx, *flow, registration.Payload{
+		Email:     "jane@example.com",
+		Username:  "jane",
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Password:  "correct horse battery staple",
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func submitPassword(t *testing.T, s *services, password string) (*login.Flow, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	flow, err := s.login.New(ctx, "http://localhost", internal.Client{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitted, _, err := s.login.Submit(ctx, *flow, login.Payload{
+		Identifier: "jane",
+		Password:   password,
+	})
+	return submitted, err
+}
+
+// sentCodes claims every email in the outbox and returns the one-time codes that were sent
+func sentCodes(t *testing.T, s *services) []string {
+	t.Helper()
+
+	messages, err := s.repos.outbox.Claim(context.Background(), time.Now(), time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes := []string{}
+	for _, m := range messages {
+		if code, ok := m.Payload.Data["Code"].(string); ok {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// errorCode retrieves the code of an internal error
+func errorCode(err error) internal.ErrorCode {
+	var e *internal.Error
+	if !errors.As(err, &e) {
+		return ""
+	}
+	return e.Code()
+}
+
+func TestLoginLockout(t *testing.T) {
+	s := newTestServices(t, nil)
+	register(t, s)
+
+	if submitted, err := submitPassword(t, s, "correct horse battery staple"); err != nil || submitted.Status != login.Complete {
+		t.Fatalf("expected login to complete, got %v", err)
+	}
+	// Wrong passwords never reveal the lock, not even the one that caused it
+	for i := 0; i < 4; i++ {
+		if _, err := submitPassword(t, s, "wrong password"); errorCode(err) != internal.ErrorCodeInvalidArgument {
+			t.Fatalf("expected attempt %d to fail with an invalid payload, got %v", i+1, err)
+		}
+	}
+	if _, err := submitPassword(t, s, "correct horse battery staple"); !errors.Is(err, login.ErrIdentityLocked) {
+		t.Fatalf("expected identity to be locked, got %v", err)
+	}
+
+	id, err := s.identity.Find(context.Background(), "jane")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.identity.Unlock(context.Background(), id.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := submitPassword(t, s, "correct horse battery staple"); err != nil {
+		t.Errorf("expected login to complete once unlocked, got %v", err)
+	}
+}
+
+func TestLoginRefreshMismatch(t *testing.T) {
+	s := newTestServices(t, nil)
+	register(t, s)
+	ctx := context.Background()
+	jane, err := s.identity.Find(ctx, "jane")
+	if err != nil {
+		t.Fatal(err)
+	}
+	john, err := s.identity.Create(ctx, identity.Identity{Email: "john@example.com", FirstName: "John", LastName: "Doe"}, "john", "correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A refresh flow for John's session can't be completed with Jane's credentials
+	flow, err := s.login.New(ctx, "http://localhost", internal.Client{}, &john.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.login.Submit(ctx, *flow, login.Payload{Identifier: "jane", Password: "correct horse battery staple"}); err == nil || err.Error() != login.ErrReauthenticationMismatch.Error() {
+		t.Fatalf("expected the refresh flow to be refused, got %v", err)
+	}
+	if _, err := s.login.RequestCode(ctx, *flow, login.PasswordlessPayload{Identifier: "jane@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if codes := sentCodes(t, s); len(codes) != 0 {
+		t.Fatalf("expected a code to not be sent to someone else, got %d", len(codes))
+	}
+	entries, _, err := s.repos.audit.GetAllActor(ctx, jane.ID, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Action == string(event.TopicLoginSucceeded) {
+			t.Error("expected no login to be recorded")
+		}
+	}
+}
+
+func TestSettingsSessionWarnLockout(t *testing.T) {
+	s := newTestServices(t, nil)
+	register(t, s)
+	ctx := context.Background()
+	id, err := s.identity.Find(ctx, "jane")
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitSessionWarn := func(password string) error {
+		t.Helper()
+
+		flow, err := s.settings.New(ctx, *id, time.Now().Add(-time.Hour), "http://localhost", internal.Client{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.settings.SubmitSessionWarn(ctx, *flow, *id, settings.SessionWarnPayload{Password: password})
+		return err
+	}
+
+	// Wrong passwords count towards the same lockout as logins do
+	for i := 0; i < 2; i++ {
+		if err := submitSessionWarn("wrong password"); errorCode(err) != internal.ErrorCodeInvalidArgument {
+			t.Fatalf("expected attempt %d to fail with an invalid password, got %v", i+1, err)
+		}
+	}
+	if err := submitSessionWarn("wrong password"); !errors.Is(err, settings.ErrIdentityLocked) {
+		t.Fatalf("expected the attempt that reached the limit to lock the identity, got %v", err)
+	}
+	if err := submitSessionWarn("correct horse battery staple"); !errors.Is(err, settings.ErrIdentityLocked) {
+		t.Fatalf("expected a locked identity to be refused, got %v", err)
+	}
+	if _, err := submitPassword(t, s, "correct horse battery staple"); !errors.Is(err, login.ErrIdentityLocked) {
+		t.Errorf("expected login to be locked too, got %v", err)
+	}
+}
+
+func TestLoginCode(t *testing.T) {
+	s := newTestServices(t, map[string]interface{}{
+		"login.codeinterval": "0s",
+		"login.maxattempts":  0,
+	})
+	register(t, s)
+	ctx := context.Background()
+
+	requestCode := func() (*login.Flow, string) {
+		t.Helper()
+
+		flow, err := s.login.New(ctx, "http://localhost", internal.Client{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		flow, err = s.login.RequestCode(ctx, *flow, login.PasswordlessPayload{Identifier: "jane@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		codes := sentCodes(t, s)
+		if len(codes) != 1 {
+			t.Fatal("expected an email with the code")
+		}
+		return flow, codes[0]
+	}
+
+	// A stale copy of the flow can't be used to get around the attempts that have already been used up
+	flow, code := requestCode()
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	for i := 0; i < 3; i++ {
+		if _, _, err := s.login.SubmitCode(ctx, *flow, login.CodePayload{Code: wrong}); errorCode(err) != internal.ErrorCodeInvalidArgument {
+			t.Fatalf("expected attempt %d to fail with an invalid code, got %v", i+1, err)
+		}
+	}
+	if _, _, err := s.login.SubmitCode(ctx, *flow, login.CodePayload{Code: code}); errorCode(err) != internal.ErrorCodeNotFound {
+		t.Fatalf("expected flow to be out of attempts, got %v", err)
+	}
+
+	flow, code = requestCode()
+	submitted, user, err := s.login.SubmitCode(ctx, *flow, login.CodePayload{Code: code})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if submitted.Status != login.Complete || user == nil {
+		t.Errorf("expected login to complete, got %s", submitted.Status)
+	}
+}
+
+func TestRequestCodeEnumeration(t *testing.T) {
+	s := newTestServices(t, map[string]interface{}{
+		"login.codeinterval": "1h",
+	})
+	register(t, s)
+	ctx := context.Background()
+	requestCode := func(identifier string) *login.Flow {
+		t.Helper()
+
+		flow, err := s.login.New(ctx, "http://localhost", internal.Client{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		flow, err = s.login.RequestCode(ctx, *flow, login.PasswordlessPayload{Identifier: identifier})
+		if err != nil {
+			t.Fatalf("expected the same response for %s, got %v", identifier, err)
+		}
+		if flow.Status != login.CodePending {
+			t.Fatalf("expected the same response for %s, got %s", identifier, flow.Status)
+		}
+		return flow
+	}
+
+	// Unknown identifiers look exactly like known ones, including when a code is submitted
+	unknown := requestCode("john@example.com")
+	if codes := sentCodes(t, s); len(codes) != 0 {
+		t.Fatalf("expected no code to be sent, got %d", len(codes))
+	}
+	if _, _, err := s.login.SubmitCode(ctx, *unknown, login.CodePayload{Code: "000000"}); errorCode(err) != internal.ErrorCodeInvalidArgument {
+		t.Errorf("expected an unknown identifier to fail like a wrong code, got %v", err)
+	}
+
+	// The lock is only revealed once the code has been proven
+	id, err := s.identity.Find(ctx, "jane")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := s.identity.FailLogin(ctx, id.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	locked := requestCode("jane@example.com")
+	codes := sentCodes(t, s)
+	if len(codes) != 1 {
+		t.Fatalf("expected a code to be sent, got %d", len(codes))
+	}
+	if _, _, err := s.login.SubmitCode(ctx, *locked, login.CodePayload{Code: codes[0]}); !errors.Is(err, login.ErrIdentityLocked) {
+		t.Errorf("expected the lock to be revealed after the code was proven, got %v", err)
+	}
+
+	// Neither is the throttle
+	requestCode("jane@example.com")
+	if codes := sentCodes(t, s); len(codes) != 0 {
+		t.Errorf("expected no code to be sent again so soon, got %d", len(codes))
+	}
+}
@@ -1,34 +1,67 @@
 package email
 
 import (
+	"github.com/RagOfJoes/mylo/internal"
 	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/user/identity"
 )
 
+// provider delivers an email that has already been rendered
+type provider interface {
+	send(key string, to []*Email, msg message) error
+}
+
 type client struct {
-	sender  Email
-	host    string
-	apiKey  string
 	appName string
-	// Template ID for Welcome template
-	welcomeID string
-	// Template ID for Verification template
-	verificationID string
-	// Template ID for Recovery template
-	recoveryID string
+	r       *renderer
+	p       provider
 }
 
-func New() Client {
+// New creates a Client for the configured provider
+func New() (Client, error) {
 	cfg := config.Get()
+	r, err := newRenderer(cfg.Email.Templates, cfg.Email.DefaultLocale)
+	if err != nil {
+		return nil, err
+	}
+
+	var p provider
+	switch cfg.Email.Provider {
+	case config.SendGridProvider:
+		p = newSendGrid(cfg.SendGrid)
+	case config.SMTPProvider:
+		p = newSMTP(cfg.Email.SMTP)
+	default:
+		return nil, internal.NewErrorf(internal.ErrorCodeInternal, "Invalid email provider provided: %s", cfg.Email.Provider)
+	}
 	return &client{
 		appName: cfg.Name,
-		apiKey:  cfg.SendGrid.APIKey,
+		r:       r,
+		p:       p,
+	}, nil
+}
 
-		welcomeID:      cfg.SendGrid.WelcomeTemplateID,
-		verificationID: cfg.SendGrid.VerificationTemplateID,
-		recoveryID:     cfg.SendGrid.RecoveryTemplateID,
-		sender: Email{
-			Name:  cfg.SendGrid.SenderName,
-			Email: cfg.SendGrid.SenderEmail,
-		},
+// Locale picks the locale that an email should be rendered in. An identity's preferred locale takes precedence over the
+// locale of the client that made the request
+func Locale(user identity.Identity, client internal.Client) string {
+	if user.Locale != "" {
+		return user.Locale
+	}
+	return client.Locale
+}
+
+func (c *client) Send(key string, msg Message) error {
+	if len(msg.To) == 0 {
+		return internal.NewErrorf(internal.ErrorCodeInternal, "Must provide at least one recipient")
+	}
+	data := map[string]interface{}{}
+	for k, v := range msg.Data {
+		data[k] = v
+	}
+	data["ApplicationName"] = c.appName
+	rendered, err := c.r.render(msg.Template, msg.Locale, data)
+	if err != nil {
+		return err
 	}
+	return c.p.send(key, msg.To, *rendered)
 }
@@ -0,0 +1,30 @@
+package email
+
+import (
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/validate"
+	"github.com/RagOfJoes/mylo/user/identity"
+)
+
+// NewLoginCode creates an email with a one-time code and magic link that can be used to login
+func NewLoginCode(to string, locale string, user identity.Identity, code string, loginURL string) (*Message, error) {
+	// Check `to` is a valid email
+	if err := validate.Var(to, "email"); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Value, %s, provided for the argument `to` must be a valid email.", to)
+	}
+	return &Message{
+		Template: loginCodeTemplate,
+		Locale:   locale,
+		To: []*Email{
+			{
+				Email: to,
+				Name:  user.FirstName,
+			},
+		},
+		Data: map[string]interface{}{
+			"FirstName": user.FirstName,
+			"Code":      code,
+			"LoginURL":  loginURL,
+		},
+	}, nil
+}
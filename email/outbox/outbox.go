@@ -0,0 +1,175 @@
+package outbox
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/RagOfJoes/mylo/email"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+)
+
+var (
+	ErrInvalidIdempotencyKey = errors.New("Invalid idempotency key provided")
+)
+
+// Status defines the current status of a message
+type Status string
+
+const (
+	// Pending is the default Status. The message is waiting to be delivered or retried
+	Pending Status = "Pending"
+	// Sent occurs when the message has been delivered
+	Sent Status = "Sent"
+	// Dead occurs when every attempt to deliver the message has failed. The message will no longer be retried
+	Dead Status = "Dead"
+)
+
+// Message defines an email that has been queued for delivery
+//
+// Messages are created in the same transaction as the flow that triggered them so that an email is never lost or sent
+// for a change that was rolled back
+type Message struct {
+	internal.Base
+	// IdempotencyKey uniquely identifies the email. Queueing a message with a key that already exists is a no-op
+	IdempotencyKey string `json:"idempotency_key" gorm:"uniqueIndex;size:255;not null" validate:"required,max=255"`
+	// Status defines the current status of the message
+	Status Status `json:"status" gorm:"index;not null;default:Pending" validate:"required,oneof='Pending' 'Sent' 'Dead'"`
+	// Payload defines the email that'll be rendered and delivered. This is cleared once the message has been sent, and
+	// its data is redacted once the message is dead-lettered, so that sensitive data, ie. one-time codes, don't linger
+	Payload *Payload `json:"-" gorm:"type:json;default:null"`
+	// Attempts defines the number of times delivery has been attempted
+	Attempts int `json:"attempts" gorm:"not null;default:0"`
+	// NextAttemptAt defines the earliest time the message will be picked up by a dispatcher
+	NextAttemptAt time.Time `json:"next_attempt_at" gorm:"index;not null"`
+	// LastError defines the error from the most recent failed attempt
+	LastError string `json:"last_error,omitempty" gorm:"size:1024"`
+	// SentAt defines the time the message was delivered
+	SentAt *time.Time `json:"sent_at,omitempty" gorm:"default:null"`
+}
+
+// Payload wraps email.Message so that it can be stored as JSON
+type Payload email.Message
+
+type Repository interface {
+	// Create creates a new message. If a message with the same idempotency key already exists then nothing is created
+	Create(ctx context.Context, newMessage Message) error
+	// Claim retrieves up to limit pending messages that are due and pushes their NextAttemptAt back by lease so that
+	// other dispatchers skip them while they're being delivered
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Message, error)
+	// Update updates a message
+	Update(ctx context.Context, updateMessage Message) (*Message, error)
+}
+
+type Service interface {
+	// Enqueue queues an email for delivery. When called within a transaction, the message will only be delivered once the
+	// transaction has been committed
+	Enqueue(ctx context.Context, key string, msg email.Message) error
+	// Dispatch attempts to deliver a single batch of due messages and returns the number of messages that were sent
+	Dispatch(ctx context.Context) (int, error)
+	// Run dispatches messages on the configured interval until ctx is done
+	Run(ctx context.Context)
+}
+
+// TableName overrides GORM's table name
+func (Message) TableName() string {
+	return "email_outbox"
+}
+
+// New creates a new pending message that is due immediately
+func New(key string, msg email.Message) (*Message, error) {
+	if key == "" {
+		return nil, internal.NewErrorf(internal.ErrorCodeInternal, "%v", ErrInvalidIdempotencyKey)
+	}
+	payload := Payload(msg)
+	return &Message{
+		IdempotencyKey: key,
+		Status:         Pending,
+		Payload:        &payload,
+		NextAttemptAt:  time.Now(),
+	}, nil
+}
+
+// Sent marks the message as delivered
+func (m *Message) Sent() {
+	now := time.Now()
+	m.Status = Sent
+	m.SentAt = &now
+	m.Payload = nil
+	m.LastError = ""
+}
+
+// Fail records a failed attempt. The message is retried with an exponential backoff until the configured max attempts
+// has been reached, at which point it's dead-lettered
+func (m *Message) Fail(err error) {
+	cfg := config.Get()
+
+	m.Attempts++
+	m.LastError = err.Error()
+	if len(m.LastError) > 1024 {
+		m.LastError = m.LastError[:1024]
+	}
+	if m.Attempts >= cfg.Email.Outbox.MaxAttempts {
+		m.Status = Dead
+		m.redact()
+		return
+	}
+	m.NextAttemptAt = time.Now().Add(backoff(m.Attempts, cfg.Email.Outbox.Backoff, cfg.Email.Outbox.MaxBackoff))
+}
+
+// redact drops the payload's data, which holds one-time codes and links, while keeping the template and recipients so
+// that a dead message can still be looked into
+func (m *Message) redact() {
+	if m.Payload == nil {
+		return
+	}
+	redacted := *m.Payload
+	redacted.Data = nil
+	m.Payload = &redacted
+}
+
+// backoff doubles base for every attempt that has been made, capped at max
+func backoff(attempts int, base time.Duration, max time.Duration) time.Duration {
+	delay := float64(base) * math.Pow(2, float64(attempts-1))
+	if delay > float64(max) {
+		return max
+	}
+	return time.Duration(delay)
+}
+
+// GORM custom data type funcs for Scanner and Valuer
+// interfaces
+
+// Value returns stringified version of JSON
+func (p *Payload) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	val, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(val), nil
+}
+
+// Scan scans value into Payload struct
+func (p *Payload) Scan(src interface{}) error {
+	var bytes []byte
+	switch v := src.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return fmt.Errorf("failed to unmarshal JSON value: %T", src)
+	}
+	var dest Payload
+	err := json.Unmarshal(bytes, &dest)
+	*p = dest
+	return err
+}
@@ -0,0 +1,57 @@
+package outbox_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/RagOfJoes/mylo/email"
+	"github.com/RagOfJoes/mylo/email/outbox"
+	"github.com/RagOfJoes/mylo/internal/config/configtest"
+)
+
+func newMessage(t *testing.T) *outbox.Message {
+	m, err := outbox.New("login-code", email.Message{
+		Template: "login_code",
+		To:       []*email.Email{{Email: "jane@example.com"}},
+		Data:     map[string]interface{}{"code": "123456"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestSent(t *testing.T) {
+	m := newMessage(t)
+	m.Sent()
+	if m.Status != outbox.Sent || m.SentAt == nil {
+		t.Errorf("expected message to be sent, got %s", m.Status)
+	}
+	if m.Payload != nil {
+		t.Error("expected payload to be cleared once sent")
+	}
+}
+
+func TestFail(t *testing.T) {
+	configtest.Setup(t, map[string]interface{}{"email.outbox.maxattempts": 2})
+
+	m := newMessage(t)
+	m.Fail(errors.New("unavailable"))
+	if m.Status != outbox.Pending || m.Attempts != 1 {
+		t.Fatalf("expected message to be retried, got %s after %d attempts", m.Status, m.Attempts)
+	}
+	if m.Payload == nil || m.Payload.Data["code"] != "123456" {
+		t.Fatal("expected payload to be kept while the message is retried")
+	}
+
+	m.Fail(errors.New("unavailable"))
+	if m.Status != outbox.Dead {
+		t.Fatalf("expected message to be dead-lettered, got %s", m.Status)
+	}
+	if m.Payload == nil || m.Payload.Data != nil {
+		t.Error("expected payload data to be redacted once dead-lettered")
+	}
+	if m.Payload.Template != "login_code" || len(m.Payload.To) != 1 {
+		t.Error("expected template and recipients to be kept once dead-lettered")
+	}
+}
@@ -0,0 +1,61 @@
+package gorm
+
+import (
+	"context"
+	"time"
+
+	"github.com/RagOfJoes/mylo/email/outbox"
+	"github.com/RagOfJoes/mylo/persistence"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormOutboxRepository struct {
+	DB *gorm.DB
+}
+
+func NewGormOutboxRepository(d *gorm.DB) outbox.Repository {
+	return &gormOutboxRepository{DB: d}
+}
+
+func (g *gormOutboxRepository) Create(ctx context.Context, newMessage outbox.Message) error {
+	clone := newMessage
+	return persistence.Conn(ctx, g.DB).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "idempotency_key"}}, DoNothing: true}).
+		Create(&clone).Error
+}
+
+func (g *gormOutboxRepository) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]outbox.Message, error) {
+	var claimed []outbox.Message
+	err := persistence.Conn(ctx, g.DB).Transaction(func(tx *gorm.DB) error {
+		// Skip rows that are locked by other dispatchers rather than waiting on them
+		if err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", outbox.Pending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&claimed).Error; err != nil {
+			return err
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+		ids := make([]interface{}, 0, len(claimed))
+		for _, m := range claimed {
+			ids = append(ids, m.ID)
+		}
+		return tx.Model(&outbox.Message{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+func (g *gormOutboxRepository) Update(ctx context.Context, updateMessage outbox.Message) (*outbox.Message, error) {
+	clone := updateMessage
+	if err := persistence.Conn(ctx, g.DB).Save(&clone).Error; err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/RagOfJoes/mylo/email"
+	"github.com/RagOfJoes/mylo/email/outbox"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+)
+
+var (
+	errMissingPayload = errors.New("Message has no payload to deliver")
+)
+
+type service struct {
+	r outbox.Repository
+	e email.Client
+}
+
+func NewOutboxService(r outbox.Repository, e email.Client) outbox.Service {
+	return &service{
+		r: r,
+		e: e,
+	}
+}
+
+func (s *service) Enqueue(ctx context.Context, key string, msg email.Message) error {
+	newMessage, err := outbox.New(key, msg)
+	if err != nil {
+		return err
+	}
+	if err := s.r.Create(ctx, *newMessage); err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to queue email: %s", key)
+	}
+	return nil
+}
+
+func (s *service) Dispatch(ctx context.Context) (int, error) {
+	cfg := config.Get()
+	claimed, err := s.r.Claim(ctx, time.Now(), cfg.Email.Outbox.Lease, cfg.Email.Outbox.BatchSize)
+	if err != nil {
+		return 0, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to claim queued emails")
+	}
+
+	sent := 0
+	for _, m := range claimed {
+		if err := s.deliver(m); err != nil {
+			m.Fail(err)
+			log.Printf("Failed to deliver email %s (attempt %d, status %s): %v", m.IdempotencyKey, m.Attempts, m.Status, err)
+		} else {
+			m.Sent()
+			sent++
+		}
+		// The claim's lease guarantees that a message that fails to update will be retried once it expires
+		if _, err := s.r.Update(ctx, m); err != nil {
+			return sent, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update queued email: %s", m.IdempotencyKey)
+		}
+	}
+	return sent, nil
+}
+
+func (s *service) Run(ctx context.Context) {
+	cfg := config.Get()
+	ticker := time.NewTicker(cfg.Email.Outbox.Interval)
+	defer ticker.Stop()
+	for {
+		// Keep draining while full batches are being returned so that a backlog is cleared quickly
+		for {
+			sent, err := s.Dispatch(ctx)
+			if err != nil {
+				log.Print(err)
+				break
+			}
+			if sent < cfg.Email.Outbox.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliver renders then sends the message's payload
+func (s *service) deliver(m outbox.Message) error {
+	if m.Payload == nil {
+		return errMissingPayload
+	}
+	return s.e.Send(m.IdempotencyKey, email.Message(*m.Payload))
+}
@@ -1,58 +1,28 @@
 package email
 
 import (
-	"encoding/json"
-
 	"github.com/RagOfJoes/mylo/internal"
-	"github.com/RagOfJoes/mylo/internal/config"
 	"github.com/RagOfJoes/mylo/internal/validate"
-	"github.com/sendgrid/sendgrid-go"
 )
 
-func (c *client) SendRecovery(to []string, recoveryURL string) error {
+// NewRecovery creates an email with a link that can be used to recover an account
+func NewRecovery(to []string, locale string, recoveryURL string) (*Message, error) {
 	// Check `to` is a valid email and build Email
 	var emails []*Email
-	var validationErr error
 	for _, e := range to {
 		if err := validate.Var(e, "email"); err != nil {
-			validationErr = internal.WrapErrorf(err, internal.ErrorCodeInternal, "Value, %s, provided for the argument `to` must be a valid email.", to)
-			break
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Value, %s, provided for the argument `to` must be a valid email.", to)
 		}
 		emails = append(emails, &Email{
 			Email: e,
 		})
 	}
-	if validationErr != nil {
-		return validationErr
-	}
-	// Build payload
-	cfg := config.Get()
-	pay := Payload{
-		From: Email{
-			Name:  cfg.SendGrid.SenderName,
-			Email: cfg.SendGrid.SenderEmail,
-		},
-		TemplateID: cfg.SendGrid.RecoveryTemplateID,
-		Personalizations: []*Personalization{
-			{
-				To: emails,
-				DynamicTemplateData: map[string]interface{}{
-					"ApplicationName": cfg.Name,
-					"RecoveryURL":     recoveryURL,
-				},
-			},
+	return &Message{
+		Template: recoveryTemplate,
+		Locale:   locale,
+		To:       emails,
+		Data: map[string]interface{}{
+			"RecoveryURL": recoveryURL,
 		},
-	}
-	body, err := json.Marshal(pay)
-	if err != nil {
-		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to marshal payload")
-	}
-	// Make request to SendGrid
-	request := sendgrid.GetRequest(c.apiKey, "/v3/mail/send", c.host)
-	request.Method = "POST"
-	request.Body = body
-	if _, err := sendgrid.API(request); err != nil {
-		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to send email")
-	}
-	return nil
+	}, nil
 }
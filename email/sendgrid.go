@@ -0,0 +1,71 @@
+package email
+
+import (
+	"encoding/json"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/sendgrid/sendgrid-go"
+)
+
+type sendGrid struct {
+	sender Email
+	host   string
+	apiKey string
+}
+
+// newSendGrid creates a provider that delivers emails through SendGrid's v3 API
+func newSendGrid(cfg config.SendGrid) provider {
+	return &sendGrid{
+		apiKey: cfg.APIKey,
+		sender: Email{
+			Name:  cfg.SenderName,
+			Email: cfg.SenderEmail,
+		},
+	}
+}
+
+func (s *sendGrid) send(key string, to []*Email, msg message) error {
+	// Build payload
+	pay := Payload{
+		From:    s.sender,
+		Subject: msg.Subject,
+		Personalizations: []*Personalization{
+			{
+				To: to,
+			},
+		},
+		// Attach key so that duplicate deliveries can be traced through SendGrid's event webhook
+		CustomArgs: map[string]string{
+			"idempotency_key": key,
+		},
+		// Plain-text must come before html
+		Content: []*Content{
+			{
+				Type:  "text/plain",
+				Value: msg.Text,
+			},
+			{
+				Type:  "text/html",
+				Value: msg.HTML,
+			},
+		},
+	}
+	body, err := json.Marshal(pay)
+	if err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to marshal payload")
+	}
+	// Make request to SendGrid
+	request := sendgrid.GetRequest(s.apiKey, "/v3/mail/send", s.host)
+	request.Method = "POST"
+	request.Body = body
+	res, err := sendgrid.API(request)
+	if err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to send email")
+	}
+	// SendGrid only returns an error when the request itself fails so the status code needs to be checked as well
+	if res.StatusCode >= 300 {
+		return internal.NewErrorf(internal.ErrorCodeInternal, "Failed to send email. SendGrid responded with %d: %s", res.StatusCode, res.Body)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package email
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+)
+
+const (
+	// smtpTimeout is the maximum amount of time spent establishing a connection with the SMTP server
+	smtpTimeout = time.Second * 10
+)
+
+type smtpClient struct {
+	cfg    config.SMTP
+	sender mail.Address
+}
+
+// newSMTP creates a provider that delivers emails through an SMTP server
+func newSMTP(cfg config.SMTP) provider {
+	return &smtpClient{
+		cfg: cfg,
+		sender: mail.Address{
+			Name:    cfg.SenderName,
+			Address: cfg.SenderEmail,
+		},
+	}
+}
+
+func (s *smtpClient) send(key string, to []*Email, msg message) error {
+	body, err := s.build(key, to, msg)
+	if err != nil {
+		return err
+	}
+
+	c, err := s.dial()
+	if err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to connect to SMTP server")
+	}
+	defer c.Close()
+	if s.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to authenticate with SMTP server")
+		}
+	}
+	if err := c.Mail(s.sender.Address); err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to send email")
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt.Email); err != nil {
+			return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to send email")
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to send email")
+	}
+	if _, err := w.Write(body); err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to send email")
+	}
+	if err := w.Close(); err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to send email")
+	}
+	return c.Quit()
+}
+
+// dial connects to the SMTP server and secures the connection based on the configured encryption
+func (s *smtpClient) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	tlsConfig := &tls.Config{
+		ServerName:         s.cfg.Host,
+		InsecureSkipVerify: s.cfg.InsecureSkipVerify,
+	}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	if s.cfg.Encryption == config.SMTPTLS {
+		conn, err := tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		return smtp.NewClient(conn, s.cfg.Host)
+	}
+
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if s.cfg.Encryption == config.SMTPStartTLS {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// messageID creates a stable Message-ID for key using the domain of the sender
+func (s *smtpClient) messageID(key string) string {
+	domain := s.cfg.Host
+	if at := strings.LastIndex(s.sender.Address, "@"); at != -1 {
+		domain = s.sender.Address[at+1:]
+	}
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(sum[:]), domain)
+}
+
+// build creates a multipart/alternative message with both the plain-text and html bodies
+//
+// The Message-ID is derived from key so that retried deliveries of the same email can be recognized as duplicates
+func (s *smtpClient) build(key string, to []*Email, msg message) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	recipients := make([]string, 0, len(to))
+	for _, rcpt := range to {
+		recipients = append(recipients, (&mail.Address{Name: rcpt.Name, Address: rcpt.Email}).String())
+	}
+	fmt.Fprintf(&buf, "From: %s\r\n", s.sender.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: %s\r\n", s.messageID(key))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{contentType: "text/plain; charset=utf-8", body: msg.Text},
+		{contentType: "text/html; charset=utf-8", body: msg.HTML},
+	}
+	for _, part := range parts {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to build email")
+		}
+		qw := quotedprintable.NewWriter(pw)
+		if _, err := qw.Write([]byte(part.body)); err != nil {
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to build email")
+		}
+		if err := qw.Close(); err != nil {
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to build email")
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to build email")
+	}
+	return buf.Bytes(), nil
+}
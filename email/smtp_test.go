@@ -0,0 +1,240 @@
+package email_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/RagOfJoes/mylo/email"
+	"github.com/RagOfJoes/mylo/internal/config/configtest"
+)
+
+// sink is a local SMTP server, like MailHog, that records every email it receives
+type sink struct {
+	t        *testing.T
+	addr     *net.TCPAddr
+	tls      *tls.Config
+	implicit bool
+
+	mu     sync.Mutex
+	auth   []string
+	emails []*mail.Message
+}
+
+// newSink starts a sink. If implicit is set, connections are secured with TLS from the start, otherwise STARTTLS is
+// offered
+func newSink(t *testing.T, implicit bool) *sink {
+	// Borrow httptest's self-signed certificate
+	srv := httptest.NewUnstartedServer(nil)
+	srv.StartTLS()
+	cert := srv.TLS.Certificates
+	srv.Close()
+
+	s := &sink{t: t, tls: &tls.Config{Certificates: cert}, implicit: implicit}
+	var l net.Listener
+	var err error
+	if implicit {
+		l, err = tls.Listen("tcp", "127.0.0.1:0", s.tls)
+	} else {
+		l, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s.addr = l.Addr().(*net.TCPAddr)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *sink) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP sink")
+	secure := s.implicit
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250-localhost")
+			if !secure {
+				tp.PrintfLine("250-STARTTLS")
+			}
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "STARTTLS":
+			tp.PrintfLine("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				s.t.Error(err)
+				return
+			}
+			conn = tlsConn
+			tp = textproto.NewConn(conn)
+			secure = true
+		case "AUTH":
+			fields := strings.Fields(line)
+			decoded, err := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			if err != nil {
+				tp.PrintfLine("501 Invalid credentials")
+				continue
+			}
+			s.mu.Lock()
+			s.auth = append(s.auth, string(decoded))
+			s.mu.Unlock()
+			tp.PrintfLine("235 Authenticated")
+		case "MAIL", "RCPT", "RSET", "NOOP":
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 Send message")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+			if err != nil {
+				s.t.Error(err)
+			}
+			s.mu.Lock()
+			s.emails = append(s.emails, msg)
+			s.mu.Unlock()
+			tp.PrintfLine("250 Queued")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+func (s *sink) received() ([]string, []*mail.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.auth...), append([]*mail.Message(nil), s.emails...)
+}
+
+// parts decodes the body of every part of a multipart email, keyed by content type
+func parts(t *testing.T, msg *mail.Message) map[string]string {
+	t.Helper()
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("expected a multipart/alternative email, got %s", msg.Header.Get("Content-Type"))
+	}
+	found := map[string]string{}
+	mr := multipart.NewReader(bufio.NewReader(msg.Body), params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return found
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contentType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		found[contentType] = string(body)
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	tests := []struct {
+		name       string
+		encryption string
+		username   string
+	}{
+		{name: "plaintext", encryption: "none"},
+		{name: "starttls", encryption: "starttls", username: "mylo"},
+		{name: "implicit tls", encryption: "tls", username: "mylo"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newSink(t, test.encryption == "tls")
+			configtest.Setup(t, map[string]interface{}{
+				"email.provider":                "smtp",
+				"email.smtp.host":               s.addr.IP.String(),
+				"email.smtp.port":               s.addr.Port,
+				"email.smtp.encryption":         test.encryption,
+				"email.smtp.insecureskipverify": true,
+				"email.smtp.username":           test.username,
+				"email.smtp.password":           "secret",
+				"email.smtp.sendername":         "Mylo",
+				"email.smtp.senderemail":        "mylo@example.com",
+			})
+			c, err := email.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := email.Message{
+				Template: "login_code",
+				To:       []*email.Email{{Email: "jane@example.com", Name: "Jane"}},
+				Data: map[string]interface{}{
+					"FirstName": "Jane",
+					"Code":      "123456",
+					"LoginURL":  "http://localhost/login/link/abc",
+				},
+			}
+			// Retries are sent with the same key
+			for i := 0; i < 2; i++ {
+				if err := c.Send("login_code:abc", msg); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			auth, emails := s.received()
+			if test.username == "" && len(auth) != 0 {
+				t.Errorf("expected authentication to be skipped, got %q", auth)
+			}
+			if test.username != "" && (len(auth) != 2 || auth[0] != "\x00mylo\x00secret") {
+				t.Errorf("expected every connection to authenticate, got %q", auth)
+			}
+			if len(emails) != 2 {
+				t.Fatalf("expected 2 emails, got %d", len(emails))
+			}
+			received := emails[0]
+			if from := received.Header.Get("From"); from != `"Mylo" <mylo@example.com>` {
+				t.Errorf("expected the configured sender, got %s", from)
+			}
+			if to := received.Header.Get("To"); to != `"Jane" <jane@example.com>` {
+				t.Errorf("expected the recipient, got %s", to)
+			}
+			if received.Header.Get("Subject") == "" {
+				t.Error("expected a subject")
+			}
+			if id := received.Header.Get("Message-ID"); id == "" || id != emails[1].Header.Get("Message-ID") {
+				t.Errorf("expected retries to keep the same Message-ID, got %s and %s", id, emails[1].Header.Get("Message-ID"))
+			}
+			bodies := parts(t, received)
+			for _, contentType := range []string{"text/plain", "text/html"} {
+				if !strings.Contains(bodies[contentType], "123456") {
+					t.Errorf("expected the %s body to contain the code, got %q", contentType, bodies[contentType])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package subscriber
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/RagOfJoes/mylo/email"
+	"github.com/RagOfJoes/mylo/email/outbox"
+	"github.com/RagOfJoes/mylo/event"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/user/contact"
+)
+
+type subscriber struct {
+	ob outbox.Service
+}
+
+// Register subscribes the handlers that queue emails in response to events. Every handler runs synchronously so that
+// emails are queued in the same transaction as the change that triggered them
+func Register(bus event.Bus, ob outbox.Service) {
+	s := &subscriber{
+		ob: ob,
+	}
+	bus.Subscribe(event.TopicVerificationRequested, s.verificationRequested)
+	bus.Subscribe(event.TopicRecoveryRequested, s.recoveryRequested)
+	bus.Subscribe(event.TopicLoginCodeRequested, s.loginCodeRequested)
+}
+
+// verificationRequested queues an email with a link that'll verify the contact. The flow's VerifyID is used as the
+// idempotency key so that a contact will only ever receive a single email per flow
+func (s *subscriber) verificationRequested(ctx context.Context, e event.Event) error {
+	ev := e.(event.VerificationRequested)
+	newEmail := email.NewVerification
+	if ev.Welcome {
+		newEmail = email.NewWelcome
+	}
+
+	cfg := config.Get()
+	url := fmt.Sprintf("%s/%s/%s", cfg.Server.URL, cfg.Verification.URL, ev.Flow.VerifyID)
+	msg, err := newEmail(ev.Contact.Value, email.Locale(ev.Identity, ev.Flow.Client), ev.Identity, url)
+	if err != nil {
+		return err
+	}
+	return s.ob.Enqueue(ctx, fmt.Sprintf("verification:%s", ev.Flow.VerifyID), *msg)
+}
+
+// recoveryRequested queues a recovery email for every verified backup contact. If the identity only has a single
+// contact then that'll be used instead
+func (s *subscriber) recoveryRequested(ctx context.Context, e event.Event) error {
+	ev := e.(event.RecoveryRequested)
+	var emails []string
+	if len(ev.Identity.Contacts) == 1 {
+		emails = append(emails, ev.Identity.Contacts[0].Value)
+	} else {
+		for _, c := range ev.Identity.Contacts {
+			if c.Type == contact.Backup && c.Verified && c.State == contact.Completed {
+				emails = append(emails, c.Value)
+			}
+		}
+	}
+
+	cfg := config.Get()
+	recoveryURL := fmt.Sprintf("%s/%s/%s", cfg.Server.URL, cfg.Recovery.URL, ev.Flow.RecoverID)
+	msg, err := email.NewRecovery(emails, email.Locale(ev.Identity, ev.Flow.Client), recoveryURL)
+	if err != nil {
+		return err
+	}
+	return s.ob.Enqueue(ctx, fmt.Sprintf("recovery:%s", ev.Flow.RecoverID), *msg)
+}
+
+// loginCodeRequested queues an email with the one-time code and magic link
+func (s *subscriber) loginCodeRequested(ctx context.Context, e event.Event) error {
+	ev := e.(event.LoginCodeRequested)
+	cfg := config.Get()
+	loginURL := fmt.Sprintf("%s/%s/link/%s", cfg.Server.URL, cfg.Login.URL, *ev.Flow.LinkID)
+	msg, err := email.NewLoginCode(ev.Identity.Email, email.Locale(ev.Identity, ev.Flow.Client), ev.Identity, ev.Code, loginURL)
+	if err != nil {
+		return err
+	}
+	return s.ob.Enqueue(ctx, fmt.Sprintf("login_code:%s", *ev.Flow.LinkID), *msg)
+}
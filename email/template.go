@@ -0,0 +1,226 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	htmlTemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	textTemplate "text/template"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"golang.org/x/text/language"
+)
+
+// Names of the templates that every locale may provide. The default locale must provide all of them
+const (
+	welcomeTemplate      = "welcome"
+	verificationTemplate = "verification"
+	recoveryTemplate     = "recovery"
+	loginCodeTemplate    = "login_code"
+)
+
+var templateNames = []string{welcomeTemplate, verificationTemplate, recoveryTemplate, loginCodeTemplate}
+
+// embedded holds the default templates that ship with the binary
+//
+//go:embed templates
+var embedded embed.FS
+
+// templates defines the parsed subject, plain-text and html templates of a single email
+//
+// For a template named `welcome` in the `en` locale, the following files are expected:
+// - en/welcome.subject.tmpl
+// - en/welcome.txt.tmpl
+// - en/welcome.html.tmpl
+type templates struct {
+	subject *textTemplate.Template
+	text    *textTemplate.Template
+	html    *htmlTemplate.Template
+}
+
+// message defines a rendered email
+type message struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// renderer renders emails in the locale that best matches the one requested
+type renderer struct {
+	// locales are ordered the same as the tags that matcher was created with. The default locale is always first
+	locales []string
+	matcher language.Matcher
+	// templates are keyed by locale then by name
+	templates map[string]map[string]*templates
+}
+
+// newRenderer parses the embedded templates and, if dir is provided, the templates on disk. Files on disk take
+// precedence over the embedded defaults so that any of them can be overridden individually
+func newRenderer(dir string, defaultLocale string) (*renderer, error) {
+	defaults, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to load embedded email templates")
+	}
+	sources := []fs.FS{defaults}
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to load email templates from %s", dir)
+		}
+		sources = append([]fs.FS{os.DirFS(dir)}, sources...)
+	}
+
+	r := &renderer{
+		locales:   []string{defaultLocale},
+		templates: map[string]map[string]*templates{},
+	}
+	for _, locale := range discoverLocales(sources) {
+		parsed := map[string]*templates{}
+		for _, name := range templateNames {
+			t, err := parseTemplates(sources, locale, name)
+			// Locales other than the default are allowed to only translate some of the emails
+			if errors.Is(err, fs.ErrNotExist) && locale != defaultLocale {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			parsed[name] = t
+		}
+		if len(parsed) == 0 {
+			continue
+		}
+		r.templates[locale] = parsed
+		if locale != defaultLocale {
+			r.locales = append(r.locales, locale)
+		}
+	}
+	if _, ok := r.templates[defaultLocale]; !ok {
+		return nil, internal.NewErrorf(internal.ErrorCodeInternal, "No email templates found for the default locale: %s", defaultLocale)
+	}
+
+	tags := make([]language.Tag, 0, len(r.locales))
+	for _, locale := range r.locales {
+		tag, err := language.Parse(locale)
+		if err != nil {
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Invalid email template locale: %s", locale)
+		}
+		tags = append(tags, tag)
+	}
+	r.matcher = language.NewMatcher(tags)
+	return r, nil
+}
+
+// render renders a template in the locale that best matches the one requested
+func (r *renderer) render(name string, locale string, data map[string]interface{}) (*message, error) {
+	t, ok := r.templates[r.match(locale)][name]
+	if !ok {
+		t, ok = r.templates[r.locales[0]][name]
+	}
+	if !ok {
+		return nil, internal.NewErrorf(internal.ErrorCodeInternal, "Invalid email template provided: %s", name)
+	}
+	return t.render(data)
+}
+
+// match finds the supported locale that best matches the one requested. If none match then the default locale is
+// returned
+func (r *renderer) match(locale string) string {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return r.locales[0]
+	}
+	_, idx, confidence := r.matcher.Match(tag)
+	if confidence == language.No {
+		return r.locales[0]
+	}
+	return r.locales[idx]
+}
+
+// discoverLocales lists every locale directory found in the sources provided
+func discoverLocales(sources []fs.FS) []string {
+	found := map[string]bool{}
+	for _, source := range sources {
+		entries, err := fs.ReadDir(source, ".")
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				found[entry.Name()] = true
+			}
+		}
+	}
+	locales := make([]string, 0, len(found))
+	for locale := range found {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// readTemplate reads a file from the first source that has it
+func readTemplate(sources []fs.FS, name string) (string, error) {
+	for _, source := range sources {
+		b, err := fs.ReadFile(source, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	return "", fs.ErrNotExist
+}
+
+// parseTemplates parses the subject, plain-text and html templates of an email
+func parseTemplates(sources []fs.FS, locale string, name string) (*templates, error) {
+	base := path.Join(locale, name)
+	subject, err := readTemplate(sources, base+".subject.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	text, err := readTemplate(sources, base+".txt.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	html, err := readTemplate(sources, base+".html.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &templates{}
+	if t.subject, err = textTemplate.New(name).Parse(subject); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to parse subject template for %s", base)
+	}
+	if t.text, err = textTemplate.New(name).Parse(text); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to parse plain-text template for %s", base)
+	}
+	if t.html, err = htmlTemplate.New(name).Parse(html); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to parse html template for %s", base)
+	}
+	return t, nil
+}
+
+// render executes every template with the data provided
+func (t *templates) render(data map[string]interface{}) (*message, error) {
+	var subject, text, html bytes.Buffer
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to render subject template")
+	}
+	if err := t.text.Execute(&text, data); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to render plain-text template")
+	}
+	if err := t.html.Execute(&html, data); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to render html template")
+	}
+	return &message{
+		Subject: string(bytes.TrimSpace(subject.Bytes())),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
@@ -1,14 +1,23 @@
 package email
 
-import "github.com/RagOfJoes/mylo/user/identity"
-
 // Base types
 //
 
+// Client renders emails from local templates then sends them through the configured provider. Every email is rendered in
+// the locale provided, falling back to the default locale when no templates exist for it
 type Client interface {
-	SendWelcome(to string, user identity.Identity, verificationURL string) error
-	SendVerification(to string, user identity.Identity, verificationURL string) error
-	SendRecovery(to []string, recoveryURL string) error
+	// Send renders then delivers a message. The key uniquely identifies the message so that duplicate deliveries can be
+	// detected downstream
+	Send(key string, msg Message) error
+}
+
+// Message defines an email that has yet to be rendered. Messages only hold plain data so that they can be persisted and
+// delivered at a later time
+type Message struct {
+	Template string                 `json:"template"`
+	Locale   string                 `json:"locale"`
+	To       []*Email               `json:"to"`
+	Data     map[string]interface{} `json:"data"`
 }
 
 // A majority of Sendgrid's types
@@ -26,6 +35,7 @@ type Payload struct {
 	From             Email              `json:"from"`
 	Subject          string             `json:"subject,omitempty"`
 	Personalizations []*Personalization `json:"personalizations,omitempty"`
+	Content          []*Content         `json:"content,omitempty"`
 	Attachments      []*Attachment      `json:"attachments,omitempty"`
 	TemplateID       string             `json:"template_id,omitempty"`
 	Sections         map[string]string  `json:"sections,omitempty"`
@@ -55,6 +65,12 @@ type Personalization struct {
 	SendAt              int                    `json:"send_at,omitempty"`
 }
 
+// Content defines the body of an email in a single mime type
+type Content struct {
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
 // Attachment holds attachment information
 type Attachment struct {
 	Content     string `json:"content,omitempty"`
@@ -1,55 +1,29 @@
 package email
 
 import (
-	"encoding/json"
-
 	"github.com/RagOfJoes/mylo/internal"
-	"github.com/RagOfJoes/mylo/internal/config"
 	"github.com/RagOfJoes/mylo/internal/validate"
 	"github.com/RagOfJoes/mylo/user/identity"
-	"github.com/sendgrid/sendgrid-go"
 )
 
-// SendWelcome sends a welcome email to new user
-func (c *client) SendWelcome(to string, user identity.Identity, verificationURL string) error {
+// NewWelcome creates a welcome email for a new user
+func NewWelcome(to string, locale string, user identity.Identity, verificationURL string) (*Message, error) {
 	// Check `to` is a valid email
 	if err := validate.Var(to, "email"); err != nil {
-		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Value, %s, provided for the argument `to` must be a valid email.", to)
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Value, %s, provided for the argument `to` must be a valid email.", to)
 	}
-	// Build payload
-	cfg := config.Get()
-	pay := Payload{
-		From: Email{
-			Name:  cfg.SendGrid.SenderName,
-			Email: cfg.SendGrid.SenderEmail,
-		},
-		TemplateID: cfg.SendGrid.WelcomeTemplateID,
-		Personalizations: []*Personalization{
+	return &Message{
+		Template: welcomeTemplate,
+		Locale:   locale,
+		To: []*Email{
 			{
-				To: []*Email{
-					{
-						Email: to,
-						Name:  user.FirstName,
-					},
-				},
-				DynamicTemplateData: map[string]interface{}{
-					"ApplicationName": cfg.Name,
-					"FirstName":       user.FirstName,
-					"VerificationURL": verificationURL,
-				},
+				Email: to,
+				Name:  user.FirstName,
 			},
 		},
-	}
-	body, err := json.Marshal(pay)
-	if err != nil {
-		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to marshal payload")
-	}
-	// Make request to SendGrid
-	request := sendgrid.GetRequest(c.apiKey, "/v3/mail/send", c.host)
-	request.Method = "POST"
-	request.Body = body
-	if _, err := sendgrid.API(request); err != nil {
-		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to send email")
-	}
-	return nil
+		Data: map[string]interface{}{
+			"FirstName":       user.FirstName,
+			"VerificationURL": verificationURL,
+		},
+	}, nil
 }
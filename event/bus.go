@@ -0,0 +1,70 @@
+package event
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/RagOfJoes/mylo/internal"
+)
+
+type bus struct {
+	mu         sync.RWMutex
+	handlers   map[Topic][]Handler
+	background map[Topic][]Handler
+}
+
+// NewBus creates an in-process event bus
+func NewBus() Bus {
+	return &bus{
+		handlers:   map[Topic][]Handler{},
+		background: map[Topic][]Handler{},
+	}
+}
+
+func (b *bus) Subscribe(topic Topic, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[topic] = append(b.handlers[topic], h)
+}
+
+func (b *bus) SubscribeAsync(topic Topic, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.background[topic] = append(b.background[topic], h)
+}
+
+func (b *bus) Publish(ctx context.Context, e Event) error {
+	b.mu.RLock()
+	syncHandlers := b.handlers[e.Topic()]
+	asyncHandlers := b.background[e.Topic()]
+	b.mu.RUnlock()
+
+	for _, h := range syncHandlers {
+		if err := h(ctx, e); err != nil {
+			return err
+		}
+	}
+	if len(asyncHandlers) == 0 {
+		return nil
+	}
+	internal.AfterCommit(ctx, func() {
+		for _, h := range asyncHandlers {
+			go runAsync(h, e)
+		}
+	})
+	return nil
+}
+
+// runAsync runs a handler detached from the publisher's context since the request that published the event has most
+// likely already finished
+func runAsync(h Handler, e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Recovered from panic in %s handler: %v", e.Topic(), r)
+		}
+	}()
+	if err := h(context.Background(), e); err != nil {
+		log.Printf("Failed to handle %s event: %v", e.Topic(), err)
+	}
+}
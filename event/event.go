@@ -0,0 +1,146 @@
+package event
+
+import (
+	"context"
+
+	"github.com/RagOfJoes/mylo/flow/login"
+	"github.com/RagOfJoes/mylo/flow/recovery"
+	"github.com/RagOfJoes/mylo/flow/verification"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/user/contact"
+	"github.com/RagOfJoes/mylo/user/credential"
+	"github.com/RagOfJoes/mylo/user/identity"
+	"github.com/gofrs/uuid"
+)
+
+// Topic identifies a kind of event
+type Topic string
+
+const (
+	TopicIdentityRegistered    Topic = "identity.registered"
+	TopicIdentityDeleted       Topic = "identity.deleted"
+	TopicLoginSucceeded        Topic = "login.succeeded"
+	TopicLoginFailed           Topic = "login.failed"
+	TopicLoginCodeRequested    Topic = "login.code_requested"
+	TopicSessionRevoked        Topic = "session.revoked"
+	TopicRecoveryRequested     Topic = "recovery.requested"
+	TopicRecoveryCompleted     Topic = "recovery.completed"
+	TopicVerificationRequested Topic = "verification.requested"
+	TopicContactVerified       Topic = "contact.verified"
+	TopicPasswordChanged       Topic = "password.changed"
+)
+
+// Event defines a change that has occurred in the domain
+type Event interface {
+	// Topic identifies the kind of event
+	Topic() Topic
+}
+
+// Handler reacts to an event. Handlers should type assert the event to the type that matches the topic that they
+// subscribed to
+type Handler func(ctx context.Context, e Event) error
+
+// Bus delivers published events to the handlers that have subscribed to their topic
+type Bus interface {
+	// Subscribe registers a handler that runs as part of Publish. Errors are returned to the publisher so, when publishing
+	// within a transaction, a failing handler will roll it back
+	Subscribe(topic Topic, h Handler)
+	// SubscribeAsync registers a handler that runs in the background once the event has been published. If the event was
+	// published within a transaction then the handler will only run once it has been committed
+	SubscribeAsync(topic Topic, h Handler)
+	// Publish delivers an event to every handler subscribed to its topic
+	Publish(ctx context.Context, e Event) error
+}
+
+// IdentityRegistered is published when a new identity has been created
+type IdentityRegistered struct {
+	Identity   identity.Identity
+	Client     internal.Client
+	RequestURL string
+}
+
+// IdentityDeleted is published when an identity has been deleted
+type IdentityDeleted struct {
+	IdentityID uuid.UUID
+	// Permanent is false when the identity has only been soft deleted
+	Permanent bool
+}
+
+// LoginSucceeded is published when a login flow has been completed
+type LoginSucceeded struct {
+	Identity identity.Identity
+	Method   credential.CredentialType
+	Client   internal.Client
+}
+
+// LoginFailed is published when an attempt to login has been rejected
+type LoginFailed struct {
+	// Identifier defines the identifier that was attempted. This'll be empty when the attempt was made on a flow that has
+	// already been tied to an identity
+	Identifier string
+	// IdentityID defines the identity that was attempted, if one was found
+	IdentityID *uuid.UUID
+	Method     credential.CredentialType
+	Client     internal.Client
+	Reason     string
+}
+
+// LoginCodeRequested is published when a one-time code has been generated for a login flow
+type LoginCodeRequested struct {
+	Identity identity.Identity
+	Flow     login.Flow
+	// Code defines the plain one-time code. This must never be persisted
+	Code string
+}
+
+// SessionRevoked is published when one or more sessions have been destroyed
+type SessionRevoked struct {
+	IdentityID *uuid.UUID
+	SessionIDs []uuid.UUID
+}
+
+// RecoveryRequested is published when a recovery flow has been moved to `LinkPending`
+type RecoveryRequested struct {
+	Identity identity.Identity
+	Flow     recovery.Flow
+}
+
+// RecoveryCompleted is published when an identity has been recovered
+type RecoveryCompleted struct {
+	IdentityID uuid.UUID
+	Client     internal.Client
+}
+
+// VerificationRequested is published when a verification flow has been moved to `LinkPending`
+type VerificationRequested struct {
+	Identity identity.Identity
+	Contact  contact.Contact
+	Flow     verification.Flow
+	// Welcome is true when the flow was created for an identity that has just registered
+	Welcome bool
+}
+
+// ContactVerified is published when a contact has been verified
+type ContactVerified struct {
+	Identity identity.Identity
+	Contact  contact.Contact
+	Client   internal.Client
+}
+
+// PasswordChanged is published when an identity's password has been updated
+type PasswordChanged struct {
+	IdentityID uuid.UUID
+	Client     internal.Client
+}
+
+func (IdentityRegistered) Topic() Topic    { return TopicIdentityRegistered }
+func (IdentityDeleted) Topic() Topic       { return TopicIdentityDeleted }
+func (LoginSucceeded) Topic() Topic        { return TopicLoginSucceeded }
+func (LoginFailed) Topic() Topic           { return TopicLoginFailed }
+func (LoginCodeRequested) Topic() Topic    { return TopicLoginCodeRequested }
+func (SessionRevoked) Topic() Topic        { return TopicSessionRevoked }
+func (RecoveryRequested) Topic() Topic     { return TopicRecoveryRequested }
+func (RecoveryCompleted) Topic() Topic     { return TopicRecoveryCompleted }
+func (VerificationRequested) Topic() Topic { return TopicVerificationRequested }
+func (ContactVerified) Topic() Topic       { return TopicContactVerified }
+func (PasswordChanged) Topic() Topic       { return TopicPasswordChanged }
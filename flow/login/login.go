@@ -2,22 +2,42 @@ package login
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"math/big"
 	"time"
 
+	"github.com/RagOfJoes/mylo/flow/oidc"
 	"github.com/RagOfJoes/mylo/internal"
 	"github.com/RagOfJoes/mylo/internal/config"
 	"github.com/RagOfJoes/mylo/internal/validate"
 	"github.com/RagOfJoes/mylo/pkg/nanoid"
 	"github.com/RagOfJoes/mylo/ui/form"
 	"github.com/RagOfJoes/mylo/ui/node"
+	"github.com/RagOfJoes/mylo/user/credential"
 	"github.com/RagOfJoes/mylo/user/identity"
 	"github.com/gofrs/uuid"
 )
 
 var (
-	ErrInvalidPaylod = errors.New("Invalid identifier or password provided")
+	ErrInvalidPaylod         = errors.New("Invalid identifier or password provided")
+	ErrInvalidCodePaylod     = errors.New("Invalid authentication code provided")
+	ErrIdentityLocked        = errors.New("Account has been locked due to too many failed login attempts. Try again later or reset your password to unlock account")
+	ErrPasswordResetRequired = errors.New("Your password must be reset before you can login with it. Check your email for a recovery link")
+
+	ErrInvalidIdentifierPaylod = errors.New("Invalid identifier provided")
+	ErrPasswordlessDisabled    = errors.New("Passwordless login is not enabled")
+
+	ErrReauthenticationMismatch = errors.New("You must login with the account that you're currently logged in as")
+)
+
+const (
+	// codeLength is the number of digits in a one-time code
+	codeLength = 6
 )
 
 type Status string
@@ -25,6 +45,10 @@ type Status string
 const (
 	// Pending occurs when login flow is awaiting first factor ie. Password, Passwordless code
 	Pending Status = "Pending"
+	// CodePending occurs when a one-time code and magic link have been sent to the User's email
+	CodePending Status = "CodePending"
+	// SecondFactorPending occurs when first factor was successful and the User has a second factor setup ie. TOTP
+	SecondFactorPending Status = "SecondFactorPending"
 	// Complete occurs when login has completed successfully
 	Complete Status = "Complete"
 )
@@ -35,14 +59,38 @@ type Flow struct {
 	// relevant data from urls path or query. This can also be used to find locate
 	// or security issues.
 	RequestURL string `json:"-" gorm:"not null" validate:"required"`
+	// Client defines the client that initiated the flow
+	Client internal.Client `json:"-" gorm:"embedded;embeddedPrefix:client_"`
 	// Status defines the current state of the flow
 	Status Status `json:"status" gorm:"not null" validate:"required"`
 	// FlowID defines the unique identifier that user's will use to access the flow
 	FlowID string `json:"flow_id" gorm:"not null;uniqueIndex" validate:"required"`
 	// ExpiresAt defines the time when this flow will no longer be valid
 	ExpiresAt time.Time `json:"expires_at" gorm:"index;not null" validate:"required"`
+	// Refresh defines whether the flow re-authenticates an already authenticated session. Completing a refresh flow
+	// upgrades the existing session ie. to aal2 or a recent authenticated_at rather than creating a new one
+	Refresh bool `json:"refresh" gorm:"not null;default:false"`
 	// Form defines additional information required to continue with the flow
 	Form *form.Form `json:"form" gorm:"type:json" validate:"required_unless=Status Complete"`
+	// PasswordlessForm defines the form used to request a one-time code. This'll only be applicable when `Status` is `Pending`
+	// and passwordless login is enabled
+	PasswordlessForm *form.Form `json:"passwordless_form,omitempty" gorm:"type:json;default:null"`
+
+	// IdentityID defines the user that the flow is for. This'll only be applicable when `Status` is either `CodePending` or
+	// `SecondFactorPending`, or when the flow is a refresh flow in which case it's the identity of the session being
+	// refreshed from the start. A `CodePending` flow has none when no code could be sent for the identifier provided
+	IdentityID *uuid.UUID `json:"-" gorm:"type:uuid;index" validate:"required_if=Status SecondFactorPending"`
+	// FirstFactor defines the credential method that the User passed first factor with
+	FirstFactor credential.CredentialType `json:"-" gorm:"default:null"`
+
+	// Code defines the hashed one-time code. This'll only be applicable when `Status` is `CodePending`
+	Code string `json:"-" gorm:"default:null"`
+	// CodeAttempts defines the number of times the one-time code has been attempted
+	CodeAttempts int `json:"-" gorm:"not null;default:0"`
+	// CodeSentAt defines the time when the one-time code was sent
+	CodeSentAt *time.Time `json:"-" gorm:"index;default:null"`
+	// LinkID defines the unique identifier used in the magic link. This'll only be applicable when `Status` is `CodePending`
+	LinkID *string `json:"-" gorm:"uniqueIndex;default:null"`
 }
 
 // Payload defines the data required to complete the flow
@@ -53,6 +101,24 @@ type Payload struct {
 	Password string `json:"password" form:"password" binding:"required" validate:"required,min=6,max=128"`
 }
 
+// PasswordlessPayload defines the data required to request a one-time code
+type PasswordlessPayload struct {
+	// Identifier can either be email or username of user
+	Identifier string `json:"identifier" form:"identifier" binding:"required" validate:"required,min=1,max=128"`
+}
+
+// CodePayload defines the data required to complete the flow when the Status is `CodePending`
+type CodePayload struct {
+	// Code is the one-time code that was sent to the User's email
+	Code string `json:"code" form:"code" binding:"required" validate:"required,numeric,len=6"`
+}
+
+// SecondFactorPayload defines the data required to complete the flow when the Status is `SecondFactorPending`
+type SecondFactorPayload struct {
+	// Code is the code generated by the User's authenticator app
+	Code string `json:"code" form:"code" binding:"required" validate:"required,numeric,len=6"`
+}
+
 // Repository defines the interface for repository implementations
 type Repository interface {
 	// Create creates a new flow
@@ -61,20 +127,47 @@ type Repository interface {
 	Get(ctx context.Context, id string) (*Flow, error)
 	// Get retrieves a flow via FlowID
 	GetByFlowID(ctx context.Context, flowID string) (*Flow, error)
+	// GetByLinkID retrieves a flow via LinkID
+	GetByLinkID(ctx context.Context, linkID string) (*Flow, error)
+	// GetLastCodeSent retrieves the latest flow that sent a one-time code to an identity
+	GetLastCodeSent(ctx context.Context, identityID uuid.UUID) (*Flow, error)
 	// Update updates a flow
 	Update(ctx context.Context, updateFlow Flow) (*Flow, error)
+	// IncrementCodeAttempts atomically uses up one of a `CodePending` flow's code attempts. False is returned when the
+	// flow has no attempts left, or is no longer waiting on a code, so that concurrent guesses can't exceed max
+	IncrementCodeAttempts(ctx context.Context, id uuid.UUID, max int) (bool, error)
 	// Deletes deletes a flow via ID
 	Delete(ctx context.Context, id uuid.UUID) error
+	// DeleteExpired deletes up to limit flows that either expired or were finished before the time provided and returns
+	// how many were deleted
+	DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error)
 }
 
 // Services defines the interface for service implementations
 type Service interface {
-	// New creates a new login flow
-	New(ctx context.Context, requestURL string) (*Flow, error)
+	// New creates a new login flow. When refresh is set, the flow re-authenticates the already authenticated session of
+	// that identity and can only be completed by it
+	New(ctx context.Context, requestURL string, client internal.Client, refresh *uuid.UUID) (*Flow, error)
 	// Find does exactly that
 	Find(ctx context.Context, flowID string) (*Flow, error)
-	// Submit completes the flow
-	Submit(ctx context.Context, flow Flow, payload Payload) (*identity.Identity, error)
+	// Submit either completes the flow or, if the User has a second factor setup, moves the flow to `SecondFactorPending`.
+	// Identity will only be returned when the flow has been completed
+	Submit(ctx context.Context, flow Flow, payload Payload) (*Flow, *identity.Identity, error)
+	// SubmitSecondFactor requires the `SecondFactorPending` status and the `SecondFactorPayload` to complete the flow
+	SubmitSecondFactor(ctx context.Context, flow Flow, payload SecondFactorPayload) (*identity.Identity, error)
+	// RequestCode moves the flow to `CodePending` and publishes a LoginCodeRequested event with the one-time code and magic link for the User.
+	// The flow is moved to `CodePending` even when no code is sent so that the response never reveals whether an account exists
+	RequestCode(ctx context.Context, flow Flow, payload PasswordlessPayload) (*Flow, error)
+	// SubmitCode requires the `CodePending` status and the `CodePayload` to either complete the flow or move it to `SecondFactorPending`.
+	// Identity will only be returned when the flow has been completed
+	SubmitCode(ctx context.Context, flow Flow, payload CodePayload) (*Flow, *identity.Identity, error)
+	// FindByLinkID finds a flow with a `CodePending` status via its magic link
+	FindByLinkID(ctx context.Context, linkID string) (*Flow, error)
+	// SubmitLink either completes the flow or moves it to `SecondFactorPending`. Identity will only be returned when the flow has been completed
+	SubmitLink(ctx context.Context, flow Flow) (*Flow, *identity.Identity, error)
+	// SubmitOIDC passes first factor for an identity that an OIDC provider has authenticated then either completes the flow
+	// or moves it to `SecondFactorPending`. Identity will only be returned when the flow has been completed
+	SubmitOIDC(ctx context.Context, flow Flow, identityID uuid.UUID) (*Flow, *identity.Identity, error)
 }
 
 // TableName overrides GORM's table name
@@ -84,7 +177,7 @@ func (Flow) TableName() string {
 
 // Form creates a form for login
 func Form(action string) form.Form {
-	return form.Form{
+	f := form.Form{
 		Action: action,
 		Method: form.POST,
 		Nodes: node.Nodes{
@@ -110,10 +203,75 @@ func Form(action string) form.Form {
 			},
 		},
 	}
+	// Render a link for every configured OIDC provider
+	f.Nodes = append(f.Nodes, oidc.Nodes()...)
+	return f
+}
+
+// PasswordlessForm creates a form for requesting a one-time code
+func PasswordlessForm(action string) form.Form {
+	return form.Form{
+		Action: action,
+		Method: form.POST,
+		Nodes: node.Nodes{
+			{
+				Type:  node.Input,
+				Group: node.Code,
+				Attributes: &node.InputAttribute{
+					Required: true,
+					Type:     "text",
+					Name:     "identifier",
+					Label:    "Email or username",
+				},
+			},
+		},
+	}
+}
+
+// CodeForm creates a form for flow with CodePending status
+func CodeForm(action string) form.Form {
+	return form.Form{
+		Action: action,
+		Method: form.POST,
+		Nodes: node.Nodes{
+			{
+				Type:  node.Input,
+				Group: node.Code,
+				Attributes: &node.InputAttribute{
+					Required: true,
+					Type:     "text",
+					Name:     "code",
+					Pattern:  "[0-9]{6}",
+					Label:    "Code",
+				},
+			},
+		},
+	}
 }
 
-// New creates a new flow
-func New(requestURL string) (*Flow, error) {
+// TOTPForm creates a form for flow with SecondFactorPending status
+func TOTPForm(action string) form.Form {
+	return form.Form{
+		Action: action,
+		Method: form.POST,
+		Nodes: node.Nodes{
+			{
+				Type:  node.Input,
+				Group: node.TOTP,
+				Attributes: &node.InputAttribute{
+					Required: true,
+					Type:     "text",
+					Name:     "code",
+					Pattern:  "[0-9]{6}",
+					Label:    "Authentication code",
+				},
+			},
+		},
+	}
+}
+
+// New creates a new flow. When refresh is set, the flow will be a refresh flow for that identity
+func New(requestURL string, client internal.Client, refresh *uuid.UUID) (*Flow, error) {
 	flowID, err := nanoid.New()
 	if err != nil {
 		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "%v", internal.ErrFailedNanoID)
@@ -123,13 +281,35 @@ func New(requestURL string) (*Flow, error) {
 	expire := time.Now().Add(cfg.Login.Lifetime)
 	action := fmt.Sprintf("%s/%s/%s", cfg.Server.URL, cfg.Login.URL, flowID)
 	form := Form(action)
-	return &Flow{
+	newFlow := &Flow{
 		FlowID:     flowID,
 		Status:     Pending,
 		Form:       &form,
 		ExpiresAt:  expire,
+		Refresh:    refresh != nil,
+		IdentityID: refresh,
 		RequestURL: requestURL,
-	}, nil
+		Client:     client,
+	}
+	if cfg.Login.Passwordless {
+		passwordlessForm := PasswordlessForm(fmt.Sprintf("%s/code", action))
+		newFlow.PasswordlessForm = &passwordlessForm
+	}
+	return newFlow, nil
+}
+
+// NewCode generates a new one-time code along with its hash
+func NewCode() (string, string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < codeLength; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", "", internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate one-time code")
+	}
+	code := fmt.Sprintf("%0*d", codeLength, n.Int64())
+	return code, hashCode(code), nil
 }
 
 // Valid checks the validity of flow, if the flow is expired or completed we also return error
@@ -140,11 +320,83 @@ func (f *Flow) Valid() error {
 	if f.Status == Complete || f.ExpiresAt.Before(time.Now()) {
 		return internal.NewErrorf(internal.ErrorCodeInternal, "%v", internal.ErrInvalidExpiredFlow)
 	}
+	if f.Status == SecondFactorPending && f.IdentityID == nil {
+		return internal.NewErrorf(internal.ErrorCodeInternal, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if f.Status == CodePending && (f.Code == "" || f.LinkID == nil) {
+		return internal.NewErrorf(internal.ErrorCodeInternal, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	return nil
+}
+
+// CodePending updates flow to CodePending status. identityID is nil when no code was sent, in which case the flow looks
+// the same but can never be completed
+func (f *Flow) CodePending(identityID *uuid.UUID, hashedCode string) error {
+	if f.Status != Pending {
+		return internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	linkID, err := nanoid.New()
+	if err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "%v", internal.ErrFailedNanoID)
+	}
+
+	cfg := config.Get()
+	now := time.Now()
+	f.Status = CodePending
+	f.IdentityID = identityID
+	f.Code = hashedCode
+	f.CodeAttempts = 0
+	f.CodeSentAt = &now
+	f.LinkID = &linkID
+	action := fmt.Sprintf("%s/%s/%s", cfg.Server.URL, cfg.Login.URL, f.FlowID)
+	form := CodeForm(action)
+	f.Form = &form
+	f.PasswordlessForm = nil
+	return nil
+}
+
+// CompareCode checks the provided code against the flow's hashed one-time code
+func (f *Flow) CompareCode(code string) bool {
+	if f.Code == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(f.Code), []byte(hashCode(code))) == 1
+}
+
+// SecondFactorPending updates flow to SecondFactorPending status
+func (f *Flow) SecondFactorPending(identityID uuid.UUID, firstFactor credential.CredentialType) error {
+	if f.Status != Pending && f.Status != CodePending {
+		return internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", internal.ErrInvalidExpiredFlow)
+	}
+
+	cfg := config.Get()
+	f.Status = SecondFactorPending
+	f.IdentityID = &identityID
+	f.FirstFactor = firstFactor
+	action := fmt.Sprintf("%s/%s/%s", cfg.Server.URL, cfg.Login.URL, f.FlowID)
+	form := TOTPForm(action)
+	f.Form = &form
+	f.PasswordlessForm = nil
+	f.clearCode()
 	return nil
 }
 
 // Complete updates flow to Complete status
 func (f *Flow) Complete() {
 	f.Form = nil
+	f.PasswordlessForm = nil
 	f.Status = Complete
+	f.clearCode()
+}
+
+// clearCode makes sure that the one-time code and magic link can't be used again
+func (f *Flow) clearCode() {
+	f.Code = ""
+	f.LinkID = nil
+}
+
+// hashCode hashes a one-time code so that it's never stored in plain text
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
 }
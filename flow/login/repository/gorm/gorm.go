@@ -2,8 +2,10 @@ package gorm
 
 import (
 	"context"
+	"time"
 
 	"github.com/RagOfJoes/mylo/flow/login"
+	"github.com/RagOfJoes/mylo/persistence"
 	"github.com/gofrs/uuid"
 	"gorm.io/gorm"
 )
@@ -18,7 +20,7 @@ func NewGormLoginRepository(d *gorm.DB) login.Repository {
 
 func (g *gormLoginRepository) Create(ctx context.Context, newFlow login.Flow) (*login.Flow, error) {
 	created := newFlow
-	if err := g.DB.Create(&created).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Create(&created).Error; err != nil {
 		return nil, err
 	}
 	return &created, nil
@@ -26,7 +28,7 @@ func (g *gormLoginRepository) Create(ctx context.Context, newFlow login.Flow) (*
 
 func (g *gormLoginRepository) Get(ctx context.Context, id string) (*login.Flow, error) {
 	var found login.Flow
-	if err := g.DB.First(&found, "id = ?", id).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).First(&found, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &found, nil
@@ -34,7 +36,23 @@ func (g *gormLoginRepository) Get(ctx context.Context, id string) (*login.Flow,
 
 func (g *gormLoginRepository) GetByFlowID(ctx context.Context, flowID string) (*login.Flow, error) {
 	var found login.Flow
-	if err := g.DB.First(&found, "flow_id = ?", flowID).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).First(&found, "flow_id = ?", flowID).Error; err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+func (g *gormLoginRepository) GetByLinkID(ctx context.Context, linkID string) (*login.Flow, error) {
+	var found login.Flow
+	if err := persistence.Conn(ctx, g.DB).First(&found, "link_id = ?", linkID).Error; err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+func (g *gormLoginRepository) GetLastCodeSent(ctx context.Context, identityID uuid.UUID) (*login.Flow, error) {
+	var found login.Flow
+	if err := persistence.Conn(ctx, g.DB).Where("identity_id = ? AND code_sent_at IS NOT NULL", identityID).Order("code_sent_at desc").First(&found).Error; err != nil {
 		return nil, err
 	}
 	return &found, nil
@@ -42,15 +60,29 @@ func (g *gormLoginRepository) GetByFlowID(ctx context.Context, flowID string) (*
 
 func (g *gormLoginRepository) Update(ctx context.Context, updateFlow login.Flow) (*login.Flow, error) {
 	updated := updateFlow
-	if err := g.DB.Save(&updated).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Save(&updated).Error; err != nil {
 		return nil, err
 	}
 	return &updated, nil
 }
 
+func (g *gormLoginRepository) IncrementCodeAttempts(ctx context.Context, id uuid.UUID, max int) (bool, error) {
+	res := persistence.Conn(ctx, g.DB).Model(&login.Flow{}).
+		Where("id = ? AND status = ? AND code_attempts < ?", id, login.CodePending, max).
+		Update("code_attempts", gorm.Expr("code_attempts + 1"))
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
 func (g *gormLoginRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	if err := g.DB.Where("id = ?", id.String()).Delete(login.Flow{}).Error; err != nil && err != gorm.ErrRecordNotFound {
+	if err := persistence.Conn(ctx, g.DB).Where("id = ?", id.String()).Delete(login.Flow{}).Error; err != nil && err != gorm.ErrRecordNotFound {
 		return err
 	}
 	return nil
 }
+
+func (g *gormLoginRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	return persistence.DeleteBatch(persistence.Conn(ctx, g.DB), &login.Flow{}, limit, "expires_at < ? OR (status = ? AND updated_at < ?)", before, login.Complete, before)
+}
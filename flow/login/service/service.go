@@ -2,33 +2,41 @@ package service
 
 import (
 	"context"
+	"time"
 
+	"github.com/RagOfJoes/mylo/event"
 	"github.com/RagOfJoes/mylo/flow/login"
 	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
 	"github.com/RagOfJoes/mylo/internal/validate"
 	"github.com/RagOfJoes/mylo/user/contact"
 	"github.com/RagOfJoes/mylo/user/credential"
 	"github.com/RagOfJoes/mylo/user/identity"
+	"github.com/gofrs/uuid"
 )
 
 type service struct {
 	r   login.Repository
+	tx  internal.Transactor
+	eb  event.Bus
 	cos contact.Service
 	cs  credential.Service
 	is  identity.Service
 }
 
-func NewLoginService(r login.Repository, cos contact.Service, cs credential.Service, is identity.Service) login.Service {
+func NewLoginService(r login.Repository, tx internal.Transactor, eb event.Bus, cos contact.Service, cs credential.Service, is identity.Service) login.Service {
 	return &service{
 		r:   r,
+		tx:  tx,
+		eb:  eb,
 		cs:  cs,
 		is:  is,
 		cos: cos,
 	}
 }
 
-func (s *service) New(ctx context.Context, requestURL string) (*login.Flow, error) {
-	newFlow, err := login.New(requestURL)
+func (s *service) New(ctx context.Context, requestURL string, client internal.Client, refresh *uuid.UUID) (*login.Flow, error) {
+	newFlow, err := login.New(requestURL, client, refresh)
 	if err != nil {
 		return nil, err
 	}
@@ -55,28 +63,307 @@ func (s *service) Find(ctx context.Context, flowID string) (*login.Flow, error)
 }
 
 // TODO: Add delay to mitigate time attacks
-func (s *service) Submit(ctx context.Context, flow login.Flow, payload login.Payload) (*identity.Identity, error) {
+func (s *service) Submit(ctx context.Context, flow login.Flow, payload login.Payload) (*login.Flow, *identity.Identity, error) {
 	if err := flow.Valid(); err != nil {
-		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+		return nil, nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if flow.Status != login.Pending {
+		return nil, nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
 	}
 	if err := validate.Check(payload); err != nil {
-		return nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", login.ErrInvalidPaylod)
+		return nil, nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", login.ErrInvalidPaylod)
 	}
 	// Retrieve identity based on identifier provided
 	id, err := s.is.Find(ctx, payload.Identifier)
 	if err != nil {
-		return nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", login.ErrInvalidPaylod)
+		if err := s.eb.Publish(ctx, event.LoginFailed{
+			Identifier: payload.Identifier,
+			Method:     credential.Password,
+			Client:     flow.Client,
+			Reason:     login.ErrInvalidPaylod.Error(),
+		}); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", login.ErrInvalidPaylod)
 	}
 	// Use retrieved identity ID to then retrieve
 	// the hashed password credential then decode it
 	// and compare provided password attempt
 	if err := s.cs.ComparePassword(ctx, id.ID, payload.Password); err != nil {
-		return nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", login.ErrInvalidPaylod)
+		return nil, nil, s.failLogin(ctx, flow, *id, credential.Password, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", login.ErrInvalidPaylod))
+	}
+	// Only reveal that the identity is locked once the password has been proven
+	if id.Locked() {
+		return nil, nil, internal.WrapErrorf(login.ErrIdentityLocked, internal.ErrorCodeForbidden, "%v", login.ErrIdentityLocked)
+	}
+	// Only reveal that a reset is required once the password has been proven
+	if id.PasswordResetRequired {
+		return nil, nil, internal.NewErrorf(internal.ErrorCodeForbidden, "%v", login.ErrPasswordResetRequired)
+	}
+	return s.passFirstFactor(ctx, flow, *id, credential.Password)
+}
+
+func (s *service) SubmitSecondFactor(ctx context.Context, flow login.Flow, payload login.SecondFactorPayload) (*identity.Identity, error) {
+	if err := flow.Valid(); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if flow.Status != login.SecondFactorPending {
+		return nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if err := validate.Check(payload); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", login.ErrInvalidCodePaylod)
+	}
+	id, err := s.is.Find(ctx, flow.IdentityID.String())
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if err := s.cs.CompareTOTP(ctx, id.ID, payload.Code); err != nil {
+		return nil, s.failLogin(ctx, flow, *id, credential.TOTP, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", login.ErrInvalidCodePaylod))
+	}
+	if id.Locked() {
+		return nil, internal.WrapErrorf(login.ErrIdentityLocked, internal.ErrorCodeForbidden, "%v", login.ErrIdentityLocked)
+	}
+	// Reset failed login attempts
+	if err := s.is.Unlock(ctx, id.ID); err != nil {
+		return nil, err
 	}
 	// Complete the flow
 	flow.Complete()
-	if _, err := s.r.Update(ctx, flow); err != nil {
-		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update login flow: %s", flow.ID)
+	if _, err := s.complete(ctx, flow, *id, credential.TOTP); err != nil {
+		return nil, err
 	}
 	return id, nil
 }
+
+func (s *service) RequestCode(ctx context.Context, flow login.Flow, payload login.PasswordlessPayload) (*login.Flow, error) {
+	cfg := config.Get()
+	if !cfg.Login.Passwordless {
+		return nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", login.ErrPasswordlessDisabled)
+	}
+	if err := flow.Valid(); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if flow.Status != login.Pending {
+		return nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if err := validate.Check(payload); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", login.ErrInvalidIdentifierPaylod)
+	}
+	// Whether the identifier belongs to an account, or one that's locked or was recently sent a code, is never
+	// revealed. The flow moves to `CodePending` either way but only an identity that a code was sent to is attached
+	// to it. Codes for a refresh flow are only ever sent to the User that the session belongs to
+	var identityID *uuid.UUID
+	id, err := s.is.Find(ctx, payload.Identifier)
+	if err == nil && belongsTo(flow, *id) && !s.recentlySent(ctx, id.ID) {
+		identityID = &id.ID
+	}
+	code, hashed, err := login.NewCode()
+	if err != nil {
+		return nil, err
+	}
+	if err := flow.CodePending(identityID, hashed); err != nil {
+		return nil, err
+	}
+	// Publish alongside the update so that the code is only ever sent for a flow that was saved
+	var updated *login.Flow
+	if err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		u, err := s.r.Update(ctx, flow)
+		if err != nil {
+			return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update login flow: %s", flow.ID)
+		}
+		updated = u
+		if identityID == nil {
+			return nil
+		}
+		return s.eb.Publish(ctx, event.LoginCodeRequested{
+			Identity: *id,
+			Flow:     *u,
+			Code:     code,
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (s *service) SubmitCode(ctx context.Context, flow login.Flow, payload login.CodePayload) (*login.Flow, *identity.Identity, error) {
+	cfg := config.Get()
+	if err := flow.Valid(); err != nil {
+		return nil, nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if flow.Status != login.CodePending || flow.CodeAttempts >= cfg.Login.MaxCodeAttempts {
+		return nil, nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if err := validate.Check(payload); err != nil {
+		return nil, nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", login.ErrInvalidCodePaylod)
+	}
+	// A flow that no code was sent for behaves exactly like one with a wrong code
+	var id *identity.Identity
+	if flow.IdentityID != nil {
+		if found, err := s.is.Find(ctx, flow.IdentityID.String()); err == nil {
+			id = found
+		}
+	}
+	// Every submission uses up an attempt before the code is compared so that concurrent guesses can't go beyond the
+	// limit and brute force the code
+	ok, err := s.r.IncrementCodeAttempts(ctx, flow.ID, cfg.Login.MaxCodeAttempts)
+	if err != nil {
+		return nil, nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update login flow: %s", flow.ID)
+	}
+	if !ok {
+		return nil, nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	flow.CodeAttempts++
+	if id == nil || !flow.CompareCode(payload.Code) {
+		err := internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", login.ErrInvalidCodePaylod)
+		if id == nil {
+			return nil, nil, err
+		}
+		return nil, nil, s.failLogin(ctx, flow, *id, credential.Code, err)
+	}
+	if id.Locked() {
+		return nil, nil, internal.WrapErrorf(login.ErrIdentityLocked, internal.ErrorCodeForbidden, "%v", login.ErrIdentityLocked)
+	}
+	return s.passFirstFactor(ctx, flow, *id, credential.Code)
+}
+
+func (s *service) FindByLinkID(ctx context.Context, linkID string) (*login.Flow, error) {
+	if linkID == "" {
+		return nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+
+	flow, err := s.r.GetByLinkID(ctx, linkID)
+	if err != nil || flow == nil {
+		return nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if err := flow.Valid(); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if flow.Status != login.CodePending {
+		return nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	return flow, nil
+}
+
+func (s *service) SubmitLink(ctx context.Context, flow login.Flow) (*login.Flow, *identity.Identity, error) {
+	if err := flow.Valid(); err != nil {
+		return nil, nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if flow.Status != login.CodePending || flow.IdentityID == nil {
+		return nil, nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	id, err := s.is.Find(ctx, flow.IdentityID.String())
+	if err != nil {
+		return nil, nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if id.Locked() {
+		return nil, nil, internal.WrapErrorf(login.ErrIdentityLocked, internal.ErrorCodeForbidden, "%v", login.ErrIdentityLocked)
+	}
+	return s.passFirstFactor(ctx, flow, *id, credential.Link)
+}
+
+func (s *service) SubmitOIDC(ctx context.Context, flow login.Flow, identityID uuid.UUID) (*login.Flow, *identity.Identity, error) {
+	if err := flow.Valid(); err != nil {
+		return nil, nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if flow.Status != login.Pending {
+		return nil, nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	id, err := s.is.Find(ctx, identityID.String())
+	if err != nil {
+		return nil, nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if id.Locked() {
+		return nil, nil, internal.WrapErrorf(login.ErrIdentityLocked, internal.ErrorCodeForbidden, "%v", login.ErrIdentityLocked)
+	}
+	if id.PasswordResetRequired {
+		return nil, nil, internal.NewErrorf(internal.ErrorCodeForbidden, "%v", login.ErrPasswordResetRequired)
+	}
+	return s.passFirstFactor(ctx, flow, *id, credential.OIDC)
+}
+
+// passFirstFactor either completes the flow or, if the User has an authenticator app setup, moves the flow to `SecondFactorPending`
+func (s *service) passFirstFactor(ctx context.Context, flow login.Flow, id identity.Identity, method credential.CredentialType) (*login.Flow, *identity.Identity, error) {
+	// A refresh flow must be completed by the User that the session belongs
+	// to, which is checked before anything is published on their behalf
+	if !belongsTo(flow, id) {
+		return nil, nil, internal.NewErrorf(internal.ErrorCodeForbidden, "%v", login.ErrReauthenticationMismatch)
+	}
+	// If the User has an authenticator app setup then
+	// they'll need to pass second factor before the
+	// flow can be completed
+	if s.cs.HasTOTP(ctx, id.ID) {
+		if err := flow.SecondFactorPending(id.ID, method); err != nil {
+			return nil, nil, err
+		}
+		updated, err := s.r.Update(ctx, flow)
+		if err != nil {
+			return nil, nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update login flow: %s", flow.ID)
+		}
+		return updated, nil, nil
+	}
+	// Reset failed login attempts
+	if err := s.is.Unlock(ctx, id.ID); err != nil {
+		return nil, nil, err
+	}
+	// Complete the flow
+	flow.Complete()
+	updated, err := s.complete(ctx, flow, id, method)
+	if err != nil {
+		return nil, nil, err
+	}
+	return updated, &id, nil
+}
+
+// complete saves a completed flow and publishes LoginSucceeded in the same transaction
+func (s *service) complete(ctx context.Context, flow login.Flow, id identity.Identity, method credential.CredentialType) (*login.Flow, error) {
+	var updated *login.Flow
+	if err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		u, err := s.r.Update(ctx, flow)
+		if err != nil {
+			return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update login flow: %s", flow.ID)
+		}
+		updated = u
+		return s.eb.Publish(ctx, event.LoginSucceeded{
+			Identity: id,
+			Method:   method,
+			Client:   u.Client,
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// failLogin records a failed login attempt for the identity. The original error is always returned so that whether
+// an identity exists or is locked is never revealed to someone that couldn't prove it
+func (s *service) failLogin(ctx context.Context, flow login.Flow, id identity.Identity, method credential.CredentialType, err error) error {
+	if _, failErr := s.is.FailLogin(ctx, id.ID); failErr != nil {
+		return failErr
+	}
+	if pubErr := s.eb.Publish(ctx, event.LoginFailed{
+		IdentityID: &id.ID,
+		Method:     method,
+		Client:     flow.Client,
+		Reason:     err.Error(),
+	}); pubErr != nil {
+		return pubErr
+	}
+	return err
+}
+
+// recentlySent checks whether a code was sent to the identity within the configured interval
+func (s *service) recentlySent(ctx context.Context, identityID uuid.UUID) bool {
+	cfg := config.Get()
+	last, err := s.r.GetLastCodeSent(ctx, identityID)
+	return err == nil && last.CodeSentAt != nil && time.Since(*last.CodeSentAt) < cfg.Login.CodeInterval
+}
+
+// belongsTo checks whether the identity is allowed to complete the flow. Only refresh flows are tied to an identity
+// before first factor has been passed
+func belongsTo(flow login.Flow, id identity.Identity) bool {
+	if !flow.Refresh {
+		return true
+	}
+	return flow.IdentityID != nil && *flow.IdentityID == id.ID
+}
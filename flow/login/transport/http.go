@@ -1,18 +1,43 @@
 package transport
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"html/template"
+	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/RagOfJoes/mylo/flow/login"
 	"github.com/RagOfJoes/mylo/internal"
 	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/session"
 	sessionHttp "github.com/RagOfJoes/mylo/session/transport"
 	"github.com/RagOfJoes/mylo/transport"
 	"github.com/RagOfJoes/mylo/user/credential"
+	"github.com/RagOfJoes/mylo/user/identity"
 	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
 )
 
+// confirmLinkPage posts back to the magic link it's served from
+var confirmLinkPage = template.Must(template.New("confirm_link").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Login</title>
+</head>
+<body>
+<form method="post" action="{{.}}">
+<p>Continue logging in to your account?</p>
+<button type="submit">Login</button>
+</form>
+</body>
+</html>
+`))
+
 type Http struct {
 	sh sessionHttp.Http
 	s  login.Service
@@ -30,6 +55,9 @@ func NewLoginHttp(sh sessionHttp.Http, s login.Service, r *gin.Engine) {
 		group.GET("/", h.initFlow())
 		group.GET("/:flow_id", h.getFlow())
 		group.POST("/:flow_id", h.submitFlow())
+		group.POST("/:flow_id/code", h.requestCode())
+		group.GET("/link/:link_id", h.confirmLink())
+		group.POST("/link/:link_id", h.submitLink())
 	}
 }
 
@@ -37,11 +65,17 @@ func (h *Http) initFlow() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
 		sess, _ := h.sh.SessionOrNewAndSetCookie(ctx, c.Request, c.Writer, false)
-		if sess != nil && sess.Authenticated() {
-			c.Error(internal.NewErrorf(internal.ErrorCodeForbidden, "%v", internal.ErrAlreadyAuthenticated))
+		// Authenticated sessions can only start a refresh flow to step up or re-authenticate
+		refresh, _ := strconv.ParseBool(c.Query("refresh"))
+		if err := checkSession(sess, refresh); err != nil {
+			c.Error(err)
 			return
 		}
-		newFlow, err := h.s.New(ctx, transport.RequestURL(c.Request))
+		var identityID *uuid.UUID
+		if refresh {
+			identityID = sess.IdentityID
+		}
+		newFlow, err := h.s.New(ctx, transport.RequestURL(c.Request), transport.Client(c.Request), identityID)
 		if err != nil {
 			c.Error(err)
 			return
@@ -57,16 +91,17 @@ func (h *Http) initFlow() gin.HandlerFunc {
 func (h *Http) getFlow() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
-		if _, err := h.sh.Session(ctx, c.Request, c.Writer, true); err == nil {
-			c.Error(internal.NewErrorf(internal.ErrorCodeForbidden, "%v", internal.ErrAlreadyAuthenticated))
-			return
-		}
+		sess, _ := h.sh.Session(ctx, c.Request, c.Writer, false)
 		flowID := c.Param("flow_id")
 		flow, err := h.s.Find(ctx, flowID)
 		if err != nil {
 			c.Error(err)
 			return
 		}
+		if err := checkSession(sess, flow.Refresh); err != nil {
+			c.Error(err)
+			return
+		}
 
 		c.JSON(http.StatusOK, transport.HttpResponse{
 			Success: true,
@@ -79,10 +114,6 @@ func (h *Http) submitFlow() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
 		sess, _ := h.sh.SessionOrNewAndSetCookie(ctx, c.Request, c.Writer, false)
-		if sess != nil && sess.Authenticated() {
-			c.Error(internal.NewErrorf(internal.ErrorCodeForbidden, "%v", internal.ErrAlreadyAuthenticated))
-			return
-		}
 		// Validate flow id
 		flowID := c.Param("flow_id")
 		flow, err := h.s.Find(ctx, flowID)
@@ -90,31 +121,237 @@ func (h *Http) submitFlow() gin.HandlerFunc {
 			c.Error(err)
 			return
 		}
-		// Check to see if required payload was provided
-		var payload login.Payload
+		if err := checkSession(sess, flow.Refresh); err != nil {
+			c.Error(err)
+			return
+		}
+		switch flow.Status {
+		case login.Pending:
+			// Check to see if required payload was provided
+			var payload login.Payload
+			if err := c.ShouldBind(&payload); err != nil {
+				c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", login.ErrInvalidPaylod))
+				return
+			}
+			submitted, user, err := h.s.Submit(ctx, *flow, payload)
+			if err != nil {
+				h.lockSession(c, sess, err)
+				c.Error(err)
+				return
+			}
+			// User has a second factor setup so send the
+			// updated flow back for them to continue
+			if submitted.Status == login.SecondFactorPending {
+				c.JSON(http.StatusOK, transport.HttpResponse{
+					Success: true,
+					Payload: submitted,
+				})
+				return
+			}
+			// Authenticate session with password credential method
+			if err := authenticate(c, sess, *flow, *user, credential.Password); err != nil {
+				c.Error(err)
+				return
+			}
+		case login.CodePending:
+			var payload login.CodePayload
+			if err := c.ShouldBind(&payload); err != nil {
+				c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", login.ErrInvalidCodePaylod))
+				return
+			}
+			submitted, user, err := h.s.SubmitCode(ctx, *flow, payload)
+			if err != nil {
+				h.lockSession(c, sess, err)
+				c.Error(err)
+				return
+			}
+			if submitted.Status == login.SecondFactorPending {
+				c.JSON(http.StatusOK, transport.HttpResponse{
+					Success: true,
+					Payload: submitted,
+				})
+				return
+			}
+			// Authenticate session with code credential method
+			if err := authenticate(c, sess, *flow, *user, credential.Code); err != nil {
+				c.Error(err)
+				return
+			}
+		case login.SecondFactorPending:
+			var payload login.SecondFactorPayload
+			if err := c.ShouldBind(&payload); err != nil {
+				c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", login.ErrInvalidCodePaylod))
+				return
+			}
+			user, err := h.s.SubmitSecondFactor(ctx, *flow, payload)
+			if err != nil {
+				h.lockSession(c, sess, err)
+				c.Error(err)
+				return
+			}
+			// Authenticate session with both first factor and totp credential methods
+			firstFactor := flow.FirstFactor
+			if firstFactor == "" {
+				firstFactor = credential.Password
+			}
+			if err := authenticate(c, sess, *flow, *user, firstFactor, credential.TOTP); err != nil {
+				c.Error(err)
+				return
+			}
+		default:
+			c.Error(internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow))
+			return
+		}
+		// Save session
+		if sess, err = h.sh.Upsert(ctx, *sess); err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Payload: sess,
+		})
+	}
+}
+
+func (h *Http) requestCode() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, _ := h.sh.SessionOrNewAndSetCookie(ctx, c.Request, c.Writer, false)
+		flow, err := h.s.Find(ctx, c.Param("flow_id"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if err := checkSession(sess, flow.Refresh); err != nil {
+			c.Error(err)
+			return
+		}
+		var payload login.PasswordlessPayload
 		if err := c.ShouldBind(&payload); err != nil {
-			c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", login.ErrInvalidPaylod))
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", login.ErrInvalidIdentifierPaylod))
+			return
+		}
+		submitted, err := h.s.RequestCode(ctx, *flow, payload)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Payload: submitted,
+		})
+	}
+}
+
+// confirmLink asks the User to confirm that they want to login before the magic link is used. Email clients and link
+// scanners fetch links on their own so a GET must never consume the flow
+func (h *Http) confirmLink() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if _, err := h.s.FindByLinkID(ctx, c.Param("link_id")); err != nil {
+			c.Error(err)
+			return
+		}
+		var page bytes.Buffer
+		if err := confirmLinkPage.Execute(&page, c.Request.URL.Path); err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to render magic link confirmation"))
 			return
 		}
-		user, err := h.s.Submit(ctx, *flow, payload)
+		c.Header("Cache-Control", "no-store")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+	}
+}
+
+func (h *Http) submitLink() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.Get()
+		ctx := c.Request.Context()
+		sess, err := h.sh.SessionOrNewAndSetCookie(ctx, c.Request, c.Writer, false)
 		if err != nil {
 			c.Error(err)
 			return
 		}
-		// Authenticate session with password credential method
-		if err := sess.Authenticate(*user, credential.Password); err != nil {
+		flow, err := h.s.FindByLinkID(ctx, c.Param("link_id"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if err := checkSession(sess, flow.Refresh); err != nil {
+			c.Error(err)
+			return
+		}
+		submitted, user, err := h.s.SubmitLink(ctx, *flow)
+		if err != nil {
+			h.lockSession(c, sess, err)
+			c.Error(err)
+			return
+		}
+		if submitted.Status == login.SecondFactorPending {
+			if cfg.Login.ReturnURL != "" {
+				c.Redirect(http.StatusSeeOther, transport.WithQuery(cfg.Login.ReturnURL, "flow_id", submitted.FlowID))
+				return
+			}
+			c.JSON(http.StatusOK, transport.HttpResponse{
+				Success: true,
+				Payload: submitted,
+			})
+			return
+		}
+		// Authenticate session with link credential method
+		if err := authenticate(c, sess, *flow, *user, credential.Link); err != nil {
 			c.Error(err)
 			return
 		}
-		// Save session
 		if sess, err = h.sh.Upsert(ctx, *sess); err != nil {
 			c.Error(err)
 			return
 		}
 
+		if cfg.Login.ReturnURL != "" {
+			c.Redirect(http.StatusSeeOther, cfg.Login.ReturnURL)
+			return
+		}
 		c.JSON(http.StatusOK, transport.HttpResponse{
 			Success: true,
 			Payload: sess,
 		})
 	}
 }
+
+// checkSession makes sure that the session is allowed to use a flow. Refresh flows are only for authenticated sessions,
+// which they upgrade rather than replace, while every other flow is only for sessions that aren't authenticated
+func checkSession(sess *session.Session, refresh bool) error {
+	authenticated := sess != nil && sess.Authenticated()
+	if refresh && !authenticated {
+		return internal.NewErrorf(internal.ErrorCodeUnauthorized, "%v", internal.ErrUnauthorized)
+	}
+	if !refresh && authenticated {
+		return internal.NewErrorf(internal.ErrorCodeForbidden, "%v", internal.ErrAlreadyAuthenticated)
+	}
+	return nil
+}
+
+// authenticate authenticates the session with the credential methods that the User completed the flow with. Refresh
+// flows must be completed by the User that the session belongs to
+func authenticate(c *gin.Context, sess *session.Session, flow login.Flow, user identity.Identity, methods ...credential.CredentialType) error {
+	if flow.Refresh && !sess.BelongsTo(user.ID) {
+		return internal.NewErrorf(internal.ErrorCodeForbidden, "%v", login.ErrReauthenticationMismatch)
+	}
+	sess.Unlock()
+	return sess.Authenticate(user, transport.Client(c.Request), methods...)
+}
+
+// lockSession moves the session to a Locked state if the identity was locked by the failed attempt
+func (h *Http) lockSession(c *gin.Context, sess *session.Session, err error) {
+	if sess == nil || !errors.Is(err, login.ErrIdentityLocked) {
+		return
+	}
+	sess.Lockout()
+	if _, err := h.sh.Upsert(c.Request.Context(), *sess); err != nil {
+		// TODO: Capture Error Here
+		log.Print(err)
+	}
+}
@@ -0,0 +1,207 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/internal/validate"
+	"github.com/RagOfJoes/mylo/pkg/nanoid"
+	"github.com/RagOfJoes/mylo/session"
+	"github.com/RagOfJoes/mylo/ui/node"
+	"github.com/RagOfJoes/mylo/user/identity"
+	"github.com/gofrs/uuid"
+)
+
+var (
+	ErrInvalidProvider  = errors.New("Invalid provider provided")
+	ErrInvalidCallback  = errors.New("Failed to authenticate with provider")
+	ErrEmailNotProvided = errors.New("Provider did not share an email address")
+	ErrIdentityExists   = errors.New("An account with this email already exists. Log in to your account first to link this provider")
+	ErrProviderLinked   = errors.New("This provider account is already linked to another account")
+)
+
+type Status string
+
+const (
+	// Pending occurs when the User has been redirected to the provider and the flow is awaiting the callback
+	Pending Status = "Pending"
+	// Complete occurs when the callback has completed successfully
+	Complete Status = "Complete"
+)
+
+type Flow struct {
+	internal.Base
+	// RequestURL defines the url that initiated flow. This can be used to pass any
+	// relevant data from urls path or query. This can also be used to find locate
+	// or security issues.
+	RequestURL string `json:"-" gorm:"not null" validate:"required"`
+	// Client defines the client that initiated the flow
+	Client internal.Client `json:"-" gorm:"embedded;embeddedPrefix:client_"`
+	// Status defines the current state of the flow
+	Status Status `json:"status" gorm:"not null" validate:"required"`
+	// FlowID defines the unique identifier that will be passed to the provider as the state parameter
+	FlowID string `json:"flow_id" gorm:"not null;uniqueIndex" validate:"required"`
+	// Provider defines the ID of the provider that the User is authenticating with
+	Provider string `json:"provider" gorm:"not null" validate:"required"`
+	// Nonce defines the value that must be present in the ID token returned by the provider
+	Nonce string `json:"-" gorm:"not null" validate:"required"`
+	// Verifier defines the PKCE code verifier
+	Verifier string `json:"-" gorm:"not null" validate:"required"`
+	// ExpiresAt defines the time when this flow will no longer be valid
+	ExpiresAt time.Time `json:"expires_at" gorm:"index;not null" validate:"required"`
+
+	// SessionID defines the session that initiated the flow. The callback must be made with the same session
+	SessionID uuid.UUID `json:"-" gorm:"type:uuid;index;not null" validate:"required"`
+	// IdentityID defines the identity that the provider will be linked to. This is only set when an authenticated session
+	// initiated the flow
+	IdentityID *uuid.UUID `json:"-" gorm:"type:uuid;default:null"`
+}
+
+// CallbackPayload defines the data returned by the provider
+type CallbackPayload struct {
+	// State is the FlowID that was passed to the provider
+	State string `json:"state" form:"state" binding:"required" validate:"required"`
+	// Code is the authorization code that'll be exchanged for tokens
+	Code string `json:"code" form:"code" binding:"required" validate:"required"`
+}
+
+// Claims defines the claims that will be retrieved from the provider's ID token
+type Claims struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	Picture       string `json:"picture"`
+	Locale        string `json:"locale"`
+}
+
+// Repository defines the interface for repository implementations
+type Repository interface {
+	// Create creates a new flow
+	Create(ctx context.Context, newFlow Flow) (*Flow, error)
+	// GetByFlowID retrieves a flow via FlowID
+	GetByFlowID(ctx context.Context, flowID string) (*Flow, error)
+	// Update updates a flow
+	Update(ctx context.Context, updateFlow Flow) (*Flow, error)
+	// Delete deletes a flow via ID
+	Delete(ctx context.Context, id uuid.UUID) error
+	// DeleteExpired deletes up to limit flows that either expired or were finished before the time provided and returns
+	// how many were deleted
+	DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// Service defines the interface for service implementations
+type Service interface {
+	// New creates a new flow and returns the provider's URL that the User must be redirected to
+	New(ctx context.Context, provider string, sessionID uuid.UUID, requestURL string, client internal.Client) (*Flow, string, error)
+	// Find retrieves a flow via FlowID
+	Find(ctx context.Context, flowID string) (*Flow, error)
+	// Link creates a new flow that'll link a provider to the session's identity and returns the provider's URL that the
+	// User must be redirected to. The session must have been authenticated recently
+	Link(ctx context.Context, provider string, sess session.Session, requestURL string, client internal.Client) (*Flow, string, error)
+	// Submit exchanges the code with the provider then returns the identity that the provider has been linked to. If the flow
+	// wasn't initiated to link an identity, a new one will be registered when the provider hasn't been linked yet
+	Submit(ctx context.Context, flow Flow, sessionID uuid.UUID, payload CallbackPayload) (*identity.Identity, error)
+}
+
+// TableName overrides GORM's table name
+func (Flow) TableName() string {
+	return "oidc_flows"
+}
+
+// Nodes creates a link node for every configured provider
+func Nodes() node.Nodes {
+	cfg := config.Get()
+	nodes := node.Nodes{}
+	for _, provider := range cfg.OIDC.Providers {
+		label := provider.Label
+		if label == "" {
+			label = fmt.Sprintf("Sign in with %s", provider.ID)
+		}
+		nodes = append(nodes, &node.Node{
+			Type:  node.Link,
+			Group: node.OIDC,
+			Attributes: &node.LinkAttribute{
+				Name:  provider.ID,
+				Label: label,
+				Value: fmt.Sprintf("%s/%s/%s", cfg.Server.URL, cfg.OIDC.URL, provider.ID),
+			},
+		})
+	}
+	return nodes
+}
+
+// Provider retrieves a configured provider via ID
+func Provider(id string) (*config.OIDCProvider, error) {
+	cfg := config.Get()
+	for _, provider := range cfg.OIDC.Providers {
+		if provider.ID == id {
+			p := provider
+			return &p, nil
+		}
+	}
+	return nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", ErrInvalidProvider)
+}
+
+// New creates a new flow
+func New(provider string, sessionID uuid.UUID, identityID *uuid.UUID, requestURL string, client internal.Client) (*Flow, error) {
+	flowID, err := nanoid.New()
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "%v", internal.ErrFailedNanoID)
+	}
+	nonce, err := nanoid.New()
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "%v", internal.ErrFailedNanoID)
+	}
+	// See: https://datatracker.ietf.org/doc/html/rfc7636#section-4.1
+	verifier, err := nanoid.New(64)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "%v", internal.ErrFailedNanoID)
+	}
+
+	cfg := config.Get()
+	return &Flow{
+		FlowID:     flowID,
+		Nonce:      nonce,
+		Verifier:   verifier,
+		Status:     Pending,
+		Provider:   provider,
+		SessionID:  sessionID,
+		IdentityID: identityID,
+		RequestURL: requestURL,
+		Client:     client,
+		ExpiresAt:  time.Now().Add(cfg.OIDC.Lifetime),
+	}, nil
+}
+
+// RedirectURL returns the callback URL for a provider
+func RedirectURL(provider string) string {
+	cfg := config.Get()
+	return fmt.Sprintf("%s/%s/%s/callback", cfg.Server.URL, cfg.OIDC.URL, provider)
+}
+
+// Valid checks the validity of flow, if the flow is expired or completed we also return error
+func (f *Flow) Valid() error {
+	if err := validate.Check(f); err != nil {
+		return internal.NewErrorf(internal.ErrorCodeInternal, "%v", err)
+	}
+	if f.Status == Complete || f.ExpiresAt.Before(time.Now()) {
+		return internal.NewErrorf(internal.ErrorCodeInternal, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	return nil
+}
+
+// Linking checks whether the flow was initiated to link a provider to an identity
+func (f *Flow) Linking() bool {
+	return f.IdentityID != nil
+}
+
+// Complete updates flow to Complete status
+func (f *Flow) Complete() {
+	f.Status = Complete
+}
@@ -0,0 +1,54 @@
+package gorm
+
+import (
+	"context"
+	"time"
+
+	"github.com/RagOfJoes/mylo/flow/oidc"
+	"github.com/RagOfJoes/mylo/persistence"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type gormOIDCRepository struct {
+	DB *gorm.DB
+}
+
+func NewGormOIDCRepository(d *gorm.DB) oidc.Repository {
+	return &gormOIDCRepository{DB: d}
+}
+
+func (g *gormOIDCRepository) Create(ctx context.Context, newFlow oidc.Flow) (*oidc.Flow, error) {
+	created := newFlow
+	if err := persistence.Conn(ctx, g.DB).Create(&created).Error; err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (g *gormOIDCRepository) GetByFlowID(ctx context.Context, flowID string) (*oidc.Flow, error) {
+	var found oidc.Flow
+	if err := persistence.Conn(ctx, g.DB).First(&found, "flow_id = ?", flowID).Error; err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+func (g *gormOIDCRepository) Update(ctx context.Context, updateFlow oidc.Flow) (*oidc.Flow, error) {
+	updated := updateFlow
+	if err := persistence.Conn(ctx, g.DB).Save(&updated).Error; err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (g *gormOIDCRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := persistence.Conn(ctx, g.DB).Where("id = ?", id.String()).Delete(oidc.Flow{}).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	return nil
+}
+
+func (g *gormOIDCRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	return persistence.DeleteBatch(persistence.Conn(ctx, g.DB), &oidc.Flow{}, limit, "expires_at < ? OR (status = ? AND updated_at < ?)", before, oidc.Complete, before)
+}
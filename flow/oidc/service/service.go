@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/RagOfJoes/mylo/event"
+	"github.com/RagOfJoes/mylo/flow/oidc"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/internal/validate"
+	"github.com/RagOfJoes/mylo/session"
+	"github.com/RagOfJoes/mylo/user/contact"
+	"github.com/RagOfJoes/mylo/user/credential"
+	"github.com/RagOfJoes/mylo/user/identity"
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gofrs/uuid"
+	"golang.org/x/oauth2"
+)
+
+// provider defines the discovered configuration of an OpenID Connect provider
+type provider struct {
+	oauth    oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+type service struct {
+	r   oidc.Repository
+	eb  event.Bus
+	cos contact.Service
+	cs  credential.Service
+	is  identity.Service
+
+	mu        sync.Mutex
+	providers map[string]*provider
+}
+
+func NewOIDCService(r oidc.Repository, eb event.Bus, cos contact.Service, cs credential.Service, is identity.Service) oidc.Service {
+	return &service{
+		r:   r,
+		eb:  eb,
+		cs:  cs,
+		is:  is,
+		cos: cos,
+
+		providers: map[string]*provider{},
+	}
+}
+
+func (s *service) New(ctx context.Context, providerID string, sessionID uuid.UUID, requestURL string, client internal.Client) (*oidc.Flow, string, error) {
+	return s.create(ctx, providerID, sessionID, nil, requestURL, client)
+}
+
+func (s *service) Link(ctx context.Context, providerID string, sess session.Session, requestURL string, client internal.Client) (*oidc.Flow, string, error) {
+	if !sess.Authenticated() {
+		return nil, "", internal.NewErrorf(internal.ErrorCodeUnauthorized, "%v", internal.ErrUnauthorized)
+	}
+	// Linking a provider lets it log in to the identity so, like any other
+	// credential, only a recently authenticated session can do it
+	cfg := config.Get()
+	min := session.AAL1
+	if s.cs.HasTOTP(ctx, *sess.IdentityID) {
+		min = session.AAL2
+	}
+	if err := sess.Satisfies(min, cfg.Settings.PrivilegedLifetime); err != nil {
+		return nil, "", err
+	}
+	return s.create(ctx, providerID, sess.ID, sess.IdentityID, requestURL, client)
+}
+
+// create creates a new flow and builds the provider's URL for it
+func (s *service) create(ctx context.Context, providerID string, sessionID uuid.UUID, identityID *uuid.UUID, requestURL string, client internal.Client) (*oidc.Flow, string, error) {
+	p, err := s.provider(providerID)
+	if err != nil {
+		return nil, "", err
+	}
+	newFlow, err := oidc.New(providerID, sessionID, identityID, requestURL, client)
+	if err != nil {
+		return nil, "", err
+	}
+	created, err := s.r.Create(ctx, *newFlow)
+	if err != nil {
+		return nil, "", internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to create new oidc flow")
+	}
+	// See: https://datatracker.ietf.org/doc/html/rfc7636#section-4.2
+	challenge := sha256.Sum256([]byte(created.Verifier))
+	url := p.oauth.AuthCodeURL(created.FlowID,
+		gooidc.Nonce(created.Nonce),
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+	return created, url, nil
+}
+
+func (s *service) Find(ctx context.Context, flowID string) (*oidc.Flow, error) {
+	if flowID == "" {
+		return nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+
+	flow, err := s.r.GetByFlowID(ctx, flowID)
+	if err != nil || flow == nil {
+		return nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if err := flow.Valid(); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	return flow, nil
+}
+
+func (s *service) Submit(ctx context.Context, flow oidc.Flow, sessionID uuid.UUID, payload oidc.CallbackPayload) (*identity.Identity, error) {
+	if err := flow.Valid(); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if err := validate.Check(payload); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", oidc.ErrInvalidCallback)
+	}
+	// Make sure the callback is for this flow and that it's
+	// being made by the same session that initiated it
+	if payload.State != flow.FlowID || flow.SessionID != sessionID {
+		return nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	claims, err := s.exchange(ctx, flow, payload.Code)
+	if err != nil {
+		return nil, err
+	}
+	// Complete the flow
+	flow.Complete()
+	if _, err := s.r.Update(ctx, flow); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update oidc flow: %s", flow.ID)
+	}
+
+	if flow.Linking() {
+		return s.link(ctx, flow, *claims)
+	}
+	return s.identify(ctx, flow, *claims)
+}
+
+// link links the provider to the identity that initiated the flow
+func (s *service) link(ctx context.Context, flow oidc.Flow, claims oidc.Claims) (*identity.Identity, error) {
+	user, err := s.is.Find(ctx, flow.IdentityID.String())
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	// A subject can only ever be linked to a single identity
+	if found, err := s.cs.FindOIDC(ctx, flow.Provider, claims.Subject); err == nil {
+		if found.IdentityID != user.ID {
+			return nil, internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", oidc.ErrProviderLinked)
+		}
+		return user, nil
+	}
+	if _, err := s.cs.CreateOIDC(ctx, user.ID, flow.Provider, claims.Subject); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// identify finds the identity that the claims belong to, registering one if needed
+func (s *service) identify(ctx context.Context, flow oidc.Flow, claims oidc.Claims) (*identity.Identity, error) {
+	// Check if provider has already been linked to an identity
+	if found, err := s.cs.FindOIDC(ctx, flow.Provider, claims.Subject); err == nil {
+		user, err := s.is.Find(ctx, found.IdentityID.String())
+		if err != nil {
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", oidc.ErrInvalidCallback)
+		}
+		return user, nil
+	}
+	if claims.Email == "" {
+		return nil, internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", oidc.ErrEmailNotProvided)
+	}
+	// Never link an identity just because the provider shares its email. Whoever
+	// controls the provider account must prove that they own the identity by
+	// logging in to it first and linking the provider from there
+	if _, err := s.is.Find(ctx, claims.Email); err == nil {
+		return nil, internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", oidc.ErrIdentityExists)
+	}
+	// Prefer the locale provided by the provider over the one from the client's browser
+	if claims.Locale == "" {
+		claims.Locale = flow.Client.Locale
+	}
+	return s.register(ctx, flow, claims)
+}
+
+// register creates a new identity from the claims provided by a provider
+func (s *service) register(ctx context.Context, flow oidc.Flow, claims oidc.Claims) (*identity.Identity, error) {
+	newUser, err := s.is.Create(ctx, identity.Identity{
+		Email:     claims.Email,
+		Avatar:    claims.Picture,
+		FirstName: claims.GivenName,
+		LastName:  claims.FamilyName,
+		Locale:    claims.Locale,
+	}, claims.Email, "")
+	if err != nil {
+		return nil, err
+	}
+
+	newContact := contact.Contact{
+		IdentityID: newUser.ID,
+		State:      contact.Sent,
+		Value:      claims.Email,
+	}
+	if claims.EmailVerified {
+		now := time.Now()
+		newContact.Verified = true
+		newContact.VerifiedAt = &now
+		newContact.State = contact.Completed
+	}
+	vc, err := s.cos.Add(ctx, newContact)
+	if err != nil {
+		s.is.Delete(ctx, newUser.ID.String(), true)
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", oidc.ErrInvalidCallback)
+	}
+	newUser.Contacts = append(newUser.Contacts, vc...)
+
+	cr, err := s.cs.CreateOIDC(ctx, newUser.ID, flow.Provider, claims.Subject)
+	if err != nil {
+		s.is.Delete(ctx, newUser.ID.String(), true)
+		return nil, err
+	}
+	newUser.Credentials = append(newUser.Credentials, *cr)
+	// Since the identity has already been created, a failing subscriber shouldn't fail the registration
+	if err := s.eb.Publish(ctx, event.IdentityRegistered{
+		Identity:   *newUser,
+		Client:     flow.Client,
+		RequestURL: flow.RequestURL,
+	}); err != nil {
+		log.Print(err)
+	}
+	return newUser, nil
+}
+
+// exchange exchanges the authorization code for tokens then validates and returns the ID token's claims
+func (s *service) exchange(ctx context.Context, flow oidc.Flow, code string) (*oidc.Claims, error) {
+	p, err := s.provider(flow.Provider)
+	if err != nil {
+		return nil, err
+	}
+	token, err := p.oauth.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", flow.Verifier))
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", oidc.ErrInvalidCallback)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", oidc.ErrInvalidCallback)
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", oidc.ErrInvalidCallback)
+	}
+	if idToken.Nonce != flow.Nonce {
+		return nil, internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", oidc.ErrInvalidCallback)
+	}
+	var claims oidc.Claims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", oidc.ErrInvalidCallback)
+	}
+	claims.Subject = idToken.Subject
+	return &claims, nil
+}
+
+// provider lazily discovers and caches a configured provider
+func (s *service) provider(id string) (*provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.providers[id]; ok {
+		return p, nil
+	}
+
+	conf, err := oidc.Provider(id)
+	if err != nil {
+		return nil, err
+	}
+	// Use a background context since the provider will hold on to it
+	// when fetching signing keys later on
+	discovered, err := gooidc.NewProvider(context.Background(), conf.Issuer)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to discover oidc provider: %s", id)
+	}
+	scopes := conf.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{gooidc.ScopeOpenID, "email", "profile"}
+	}
+	p := &provider{
+		oauth: oauth2.Config{
+			ClientID:     conf.ClientID,
+			ClientSecret: conf.ClientSecret,
+			Endpoint:     discovered.Endpoint(),
+			RedirectURL:  oidc.RedirectURL(id),
+			Scopes:       scopes,
+		},
+		verifier: discovered.Verifier(&gooidc.Config{
+			ClientID: conf.ClientID,
+		}),
+	}
+	s.providers[id] = p
+	return p, nil
+}
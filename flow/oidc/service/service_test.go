@@ -0,0 +1,442 @@
+package service_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RagOfJoes/mylo/event"
+	"github.com/RagOfJoes/mylo/flow/login"
+	loginService "github.com/RagOfJoes/mylo/flow/login/service"
+	"github.com/RagOfJoes/mylo/flow/oidc"
+	"github.com/RagOfJoes/mylo/flow/oidc/service"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config/configtest"
+	"github.com/RagOfJoes/mylo/persistence/memory"
+	"github.com/RagOfJoes/mylo/pkg/totp"
+	"github.com/RagOfJoes/mylo/session"
+	contactService "github.com/RagOfJoes/mylo/user/contact/service"
+	"github.com/RagOfJoes/mylo/user/credential"
+	credentialService "github.com/RagOfJoes/mylo/user/credential/service"
+	"github.com/RagOfJoes/mylo/user/identity"
+	identityService "github.com/RagOfJoes/mylo/user/identity/service"
+	"github.com/gofrs/uuid"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const (
+	clientID     = "mylo"
+	clientSecret = "secret"
+)
+
+// grant is what the stand-in remembers about an authorization request until its code is exchanged
+type grant struct {
+	challenge string
+	nonce     string
+	claims    oidc.Claims
+}
+
+// standIn is a local OpenID Connect provider. It implements discovery, a JWKS and the token endpoint with PKCE, while
+// authorization is done by the test through authorize
+type standIn struct {
+	t      *testing.T
+	srv    *httptest.Server
+	signer jose.Signer
+
+	mu     sync.Mutex
+	grants map[string]grant
+	// nonce overrides the nonce of the issued ID tokens when set
+	nonce string
+}
+
+func newStandIn(t *testing.T) *standIn {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, (&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "standin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &standIn{t: t, signer: signer, grants: map[string]grant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                s.srv.URL,
+			"authorization_endpoint":                s.srv.URL + "/authorize",
+			"token_endpoint":                        s.srv.URL + "/token",
+			"jwks_uri":                              s.srv.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "standin", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/token", s.token)
+	s.srv = httptest.NewServer(mux)
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+// authorize plays the part of the User signing in at the provider and returns the code that the provider would've
+// redirected back with
+func (s *standIn) authorize(authURL string, claims oidc.Claims) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("client_id") != clientID || q.Get("code_challenge_method") != "S256" {
+		s.t.Fatalf("expected an authorization request with PKCE, got %s", authURL)
+	}
+	code := uuid.Must(uuid.NewV4()).String()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.grants[code] = grant{
+		challenge: q.Get("code_challenge"),
+		nonce:     q.Get("nonce"),
+		claims:    claims,
+	}
+	return code
+}
+
+func (s *standIn) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+	// Clients can authenticate with either client_secret_basic or client_secret_post
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if id != clientID || secret != clientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	g, ok := s.grants[r.PostForm.Get("code")]
+	delete(s.grants, r.PostForm.Get("code"))
+	nonce := s.nonce
+	s.mu.Unlock()
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != g.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+	if nonce == "" {
+		nonce = g.nonce
+	}
+
+	now := time.Now()
+	idToken, err := jwt.Signed(s.signer).Claims(jwt.Claims{
+		Issuer:   s.srv.URL,
+		Subject:  g.claims.Subject,
+		Audience: jwt.Audience{clientID},
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(time.Minute)),
+	}).Claims(map[string]interface{}{
+		"nonce":          nonce,
+		"email":          g.claims.Email,
+		"email_verified": g.claims.EmailVerified,
+		"given_name":     g.claims.GivenName,
+		"family_name":    g.claims.FamilyName,
+	}).CompactSerialize()
+	if err != nil {
+		s.t.Error(err)
+		http.Error(w, `{"error":"server_error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     idToken,
+	})
+}
+
+type fixture struct {
+	p  *standIn
+	s  oidc.Service
+	ls login.Service
+	cs credential.Service
+	is identity.Service
+}
+
+func newFixture(t *testing.T) *fixture {
+	p := newStandIn(t)
+	configtest.Setup(t, map[string]interface{}{
+		"credential.argon.memory":     1024,
+		"credential.argon.iterations": 1,
+		"oidc.providers": []map[string]interface{}{
+			{"id": "standin", "issuer": p.srv.URL, "clientid": clientID, "clientsecret": clientSecret},
+		},
+	})
+	store := memory.NewStore()
+	bus := event.NewBus()
+	cos := contactService.NewContactService(memory.NewMemoryContactRepository(store))
+	cs := credentialService.NewCredentialService(memory.NewMemoryCredentialRepository(store))
+	is := identityService.NewIdentityService(memory.NewMemoryIdentityRepository(store), bus)
+	return &fixture{
+		p:  p,
+		s:  service.NewOIDCService(memory.NewMemoryOIDCRepository(store), bus, cos, cs, is),
+		ls: loginService.NewLoginService(memory.NewMemoryLoginRepository(store), memory.NewMemoryTransactor(store), bus, cos, cs, is),
+		cs: cs,
+		is: is,
+	}
+}
+
+// login goes through the whole flow for a session and returns the result of the callback
+func (f *fixture) login(t *testing.T, sessionID uuid.UUID, claims oidc.Claims) (*identity.Identity, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	flow, authURL, err := f.s.New(ctx, "standin", sessionID, "http://localhost", internal.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := f.p.authorize(authURL, claims)
+	return f.s.Submit(ctx, *flow, sessionID, oidc.CallbackPayload{State: flow.FlowID, Code: code})
+}
+
+// link goes through the whole flow for an authenticated session and returns the result of the callback
+func (f *fixture) link(t *testing.T, sess session.Session, claims oidc.Claims) (*identity.Identity, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	flow, authURL, err := f.s.Link(ctx, "standin", sess, "http://localhost", internal.Client{})
+	if err != nil {
+		return nil, err
+	}
+	code := f.p.authorize(authURL, claims)
+	return f.s.Submit(ctx, *flow, sess.ID, oidc.CallbackPayload{State: flow.FlowID, Code: code})
+}
+
+// errorCode retrieves the code of an internal error
+func errorCode(err error) internal.ErrorCode {
+	var e *internal.Error
+	if !errors.As(err, &e) {
+		return ""
+	}
+	return e.Code()
+}
+
+func TestSubmit(t *testing.T) {
+	f := newFixture(t)
+	sessionID := uuid.Must(uuid.NewV4())
+	claims := oidc.Claims{
+		Subject:       "jane",
+		Email:         "jane@example.com",
+		EmailVerified: true,
+		GivenName:     "Jane",
+		FamilyName:    "Doe",
+	}
+
+	registered, err := f.login(t, sessionID, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registered.Email != "jane@example.com" || registered.FirstName != "Jane" {
+		t.Errorf("expected an identity to be registered from the claims, got %+v", registered)
+	}
+	if len(registered.Contacts) != 1 || !registered.Contacts[0].Verified {
+		t.Errorf("expected the verified email to be added as a verified contact, got %+v", registered.Contacts)
+	}
+
+	// Logging in again finds the identity that the subject was linked to, even if the email has since changed
+	claims.Email = "jane.doe@example.com"
+	found, err := f.login(t, sessionID, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != registered.ID {
+		t.Errorf("expected identity %s, got %s", registered.ID, found.ID)
+	}
+}
+
+func TestSubmitExistingEmail(t *testing.T) {
+	f := newFixture(t)
+	sessionID := uuid.Must(uuid.NewV4())
+	ctx := context.Background()
+	existing, err := f.is.Create(ctx, identity.Identity{Email: "jane@example.com", FirstName: "Jane", LastName: "Doe"}, "jane", "correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.cs.CreatePassword(ctx, existing.ID, "correct horse battery staple", []credential.Identifier{
+		{Type: "email", Value: "jane@example.com"},
+		{Type: "username", Value: "jane"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sharing an email, even a verified one, doesn't prove ownership of the identity
+	for _, verified := range []bool{false, true} {
+		if _, err := f.login(t, sessionID, oidc.Claims{Subject: "jane", Email: "jane@example.com", EmailVerified: verified}); err == nil || err.Error() != oidc.ErrIdentityExists.Error() {
+			t.Fatalf("expected the existing identity to not be linked, got %v", err)
+		}
+	}
+	if _, err := f.cs.FindOIDC(ctx, "standin", "jane"); err == nil {
+		t.Fatal("expected the provider to not be linked")
+	}
+
+	// Only a session that recently logged in to the identity can link it
+	stale, err := session.NewAuthenticated(*existing, internal.Client{}, credential.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticatedAt := stale.AuthenticatedAt.Add(-time.Hour)
+	stale.AuthenticatedAt = &authenticatedAt
+	if _, err := f.link(t, *stale, oidc.Claims{Subject: "jane"}); errorCode(err) != internal.ErrorCodeForbidden {
+		t.Fatalf("expected a stale session to be refused, got %v", err)
+	}
+	fresh, err := session.NewAuthenticated(*existing, internal.Client{}, credential.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linked, err := f.link(t, *fresh, oidc.Claims{Subject: "jane"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linked.ID != existing.ID {
+		t.Errorf("expected the provider to be linked to identity %s, got %s", existing.ID, linked.ID)
+	}
+	found, err := f.login(t, sessionID, oidc.Claims{Subject: "jane", Email: "jane@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != existing.ID {
+		t.Errorf("expected identity %s, got %s", existing.ID, found.ID)
+	}
+
+	// A subject can't be linked to a second identity
+	other, err := f.login(t, sessionID, oidc.Claims{Subject: "john", Email: "john@example.com", GivenName: "John", FamilyName: "Doe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSess, err := session.NewAuthenticated(*other, internal.Client{}, credential.OIDC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.link(t, *otherSess, oidc.Claims{Subject: "jane"}); err == nil || err.Error() != oidc.ErrProviderLinked.Error() {
+		t.Errorf("expected a subject that's linked to another identity to be refused, got %v", err)
+	}
+}
+
+func TestSubmitLogin(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	user, err := f.login(t, uuid.Must(uuid.NewV4()), oidc.Claims{Subject: "jane", Email: "jane@example.com", GivenName: "Jane", FamilyName: "Doe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	submit := func() (*login.Flow, *identity.Identity, error) {
+		flow, err := f.ls.New(ctx, "http://localhost", internal.Client{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f.ls.SubmitOIDC(ctx, *flow, user.ID)
+	}
+
+	completed, found, err := submit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completed.Status != login.Complete || found == nil || found.ID != user.ID {
+		t.Errorf("expected the flow to be completed, got %s", completed.Status)
+	}
+
+	// A provider only ever passes first factor
+	enrollment, err := f.cs.CreateTOTP(ctx, user.ID, user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.Generate(enrollment.Secret, totp.Counter(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.cs.ConfirmTOTP(ctx, user.ID, code); err != nil {
+		t.Fatal(err)
+	}
+	pending, found, err := submit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Status != login.SecondFactorPending || found != nil {
+		t.Errorf("expected the flow to wait for second factor, got %s", pending.Status)
+	}
+
+	if err := f.is.RequirePasswordReset(ctx, user.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := submit(); errorCode(err) != internal.ErrorCodeForbidden {
+		t.Errorf("expected an identity that must reset its password to be refused, got %v", err)
+	}
+	if err := f.is.RequirePasswordReset(ctx, user.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := f.is.FailLogin(ctx, user.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := submit(); !errors.Is(err, login.ErrIdentityLocked) {
+		t.Errorf("expected a locked identity to be refused, got %v", err)
+	}
+}
+
+func TestSubmitInvalid(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	sessionID := uuid.Must(uuid.NewV4())
+	claims := oidc.Claims{Subject: "jane", Email: "jane@example.com", EmailVerified: true}
+
+	t.Run("other session", func(t *testing.T) {
+		flow, authURL, err := f.s.New(ctx, "standin", sessionID, "http://localhost", internal.Client{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := f.p.authorize(authURL, claims)
+		if _, err := f.s.Submit(ctx, *flow, uuid.Must(uuid.NewV4()), oidc.CallbackPayload{State: flow.FlowID, Code: code}); errorCode(err) != internal.ErrorCodeNotFound {
+			t.Errorf("expected a callback from another session to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		flow, _, err := f.s.New(ctx, "standin", sessionID, "http://localhost", internal.Client{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.s.Submit(ctx, *flow, sessionID, oidc.CallbackPayload{State: flow.FlowID, Code: "wrong"}); errorCode(err) != internal.ErrorCodeInvalidArgument {
+			t.Errorf("expected an unknown code to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("replayed ID token", func(t *testing.T) {
+		f.p.mu.Lock()
+		f.p.nonce = "replayed"
+		f.p.mu.Unlock()
+		defer func() {
+			f.p.mu.Lock()
+			f.p.nonce = ""
+			f.p.mu.Unlock()
+		}()
+		if _, err := f.login(t, sessionID, claims); errorCode(err) != internal.ErrorCodeInvalidArgument {
+			t.Errorf("expected an ID token with another flow's nonce to be rejected, got %v", err)
+		}
+	})
+}
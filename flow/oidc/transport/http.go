@@ -0,0 +1,165 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/RagOfJoes/mylo/flow/login"
+	"github.com/RagOfJoes/mylo/flow/oidc"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/session"
+	sessionHttp "github.com/RagOfJoes/mylo/session/transport"
+	"github.com/RagOfJoes/mylo/transport"
+	"github.com/RagOfJoes/mylo/user/credential"
+	"github.com/gin-gonic/gin"
+)
+
+type Http struct {
+	sh sessionHttp.Http
+	s  oidc.Service
+	ls login.Service
+}
+
+func NewOIDCHttp(sh sessionHttp.Http, s oidc.Service, ls login.Service, r *gin.Engine) {
+	cfg := config.Get()
+	h := &Http{
+		sh: sh,
+		s:  s,
+		ls: ls,
+	}
+
+	group := r.Group(fmt.Sprintf("/%s", cfg.OIDC.URL))
+	{
+		group.GET("/:provider", h.initFlow())
+		group.GET("/:provider/callback", h.callback())
+	}
+}
+
+func (h *Http) initFlow() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.sh.SessionOrNewAndSetCookie(ctx, c.Request, c.Writer, false)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		// Authenticated sessions link the provider to their identity instead of logging in with it
+		var url string
+		if sess.Authenticated() {
+			_, url, err = h.s.Link(ctx, c.Param("provider"), *sess, transport.RequestURL(c.Request), transport.Client(c.Request))
+		} else {
+			_, url, err = h.s.New(ctx, c.Param("provider"), sess.ID, transport.RequestURL(c.Request), transport.Client(c.Request))
+		}
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Redirect(http.StatusFound, url)
+	}
+}
+
+func (h *Http) callback() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.sh.SessionOrNewAndSetCookie(ctx, c.Request, c.Writer, false)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		// Check to see if required payload was provided
+		var payload oidc.CallbackPayload
+		if err := c.ShouldBindQuery(&payload); err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", oidc.ErrInvalidCallback))
+			return
+		}
+		flow, err := h.s.Find(ctx, payload.State)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if flow.Provider != c.Param("provider") {
+			c.Error(internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow))
+			return
+		}
+		if flow.Linking() {
+			h.link(c, sess, *flow, payload)
+			return
+		}
+		if sess.Authenticated() {
+			c.Error(internal.NewErrorf(internal.ErrorCodeForbidden, "%v", internal.ErrAlreadyAuthenticated))
+			return
+		}
+		user, err := h.s.Submit(ctx, *flow, sess.ID, payload)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		// The provider only passes first factor so the rest of the
+		// login is left to a login flow
+		loginFlow, err := h.ls.New(ctx, flow.RequestURL, flow.Client, nil)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		submitted, user, err := h.ls.SubmitOIDC(ctx, *loginFlow, user.ID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		cfg := config.Get()
+		// User has a second factor setup so send the login
+		// flow back for them to continue
+		if submitted.Status == login.SecondFactorPending {
+			if cfg.OIDC.ReturnURL != "" {
+				c.Redirect(http.StatusFound, transport.WithQuery(cfg.OIDC.ReturnURL, "flow_id", submitted.FlowID))
+				return
+			}
+			c.JSON(http.StatusOK, transport.HttpResponse{
+				Success: true,
+				Payload: submitted,
+			})
+			return
+		}
+		// Authenticate session with oidc credential method
+		sess.Unlock()
+		if err := sess.Authenticate(*user, transport.Client(c.Request), credential.OIDC); err != nil {
+			c.Error(err)
+			return
+		}
+		h.respond(c, sess)
+	}
+}
+
+// link finishes a flow that links the provider to the identity that the session belongs to
+func (h *Http) link(c *gin.Context, sess *session.Session, flow oidc.Flow, payload oidc.CallbackPayload) {
+	if !sess.Authenticated() || !sess.BelongsTo(*flow.IdentityID) {
+		c.Error(internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow))
+		return
+	}
+	if _, err := h.s.Submit(c.Request.Context(), flow, sess.ID, payload); err != nil {
+		c.Error(err)
+		return
+	}
+	h.respond(c, sess)
+}
+
+// respond saves the session then either redirects to the configured ReturnURL or returns the session
+func (h *Http) respond(c *gin.Context, sess *session.Session) {
+	sess, err := h.sh.Upsert(c.Request.Context(), *sess)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	cfg := config.Get()
+	if cfg.OIDC.ReturnURL != "" {
+		c.Redirect(http.StatusFound, cfg.OIDC.ReturnURL)
+		return
+	}
+	c.JSON(http.StatusOK, transport.HttpResponse{
+		Success: true,
+		Payload: sess,
+	})
+}
@@ -40,6 +40,8 @@ type Flow struct {
 	// relevant data from urls path or query. This can also be used to find locate
 	// or security issues.
 	RequestURL string `json:"-" gorm:"not null" validate:"required"`
+	// Client defines the client that initiated the flow
+	Client internal.Client `json:"-" gorm:"embedded;embeddedPrefix:client_"`
 	// Status defines the current state of the flow
 	Status Status `json:"status" gorm:"not null" validate:"required"`
 	// FlowID defines the unique identifier that user's will use to access the flow
@@ -81,15 +83,18 @@ type Repository interface {
 	Update(ctx context.Context, updateFlow Flow) (*Flow, error)
 	// Delete deletes a flow via ID
 	Delete(ctx context.Context, id uuid.UUID) error
+	// DeleteExpired deletes up to limit flows that either expired or were finished before the time provided and returns
+	// how many were deleted
+	DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error)
 }
 
 // Service defines
 type Service interface {
 	// New creates a new flow
-	New(ctx context.Context, requestURL string) (*Flow, error)
+	New(ctx context.Context, requestURL string, client internal.Client) (*Flow, error)
 	// Find retrieves flow via FlowID or RecoverID
 	Find(ctx context.Context, id string) (*Flow, error)
-	// SubmitIdentifier requires the `IdentifierPending` status and the `IdentifierPayload` to move the flow to the next step. An email should also be sent to all backup contacts in transport implementation
+	// SubmitIdentifier requires the `IdentifierPending` status and the `IdentifierPayload` to move the flow to the next step. On success, a RecoveryRequested event is published so that the backup contacts can be emailed
 	SubmitIdentifier(ctx context.Context, flow Flow, payload IdentifierPayload) (*Flow, error)
 	// SubmitUpdatePassword completes the flow
 	SubmitUpdatePassword(ctx context.Context, flow Flow, payload SubmitPayload) (*Flow, error)
@@ -151,7 +156,7 @@ func RecoverForm(action string) form.Form {
 }
 
 // New creates a new flow with IdentifierPending status
-func New(requestURL string) (*Flow, error) {
+func New(requestURL string, client internal.Client) (*Flow, error) {
 	flowID, err := nanoid.New()
 	if err != nil {
 		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate nano id")
@@ -169,6 +174,7 @@ func New(requestURL string) (*Flow, error) {
 		ExpiresAt:  expire,
 		RecoverID:  recoverID,
 		RequestURL: requestURL,
+		Client:     client,
 		Status:     IdentifierPending,
 
 		Form: &form,
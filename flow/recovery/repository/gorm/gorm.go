@@ -2,8 +2,10 @@ package gorm
 
 import (
 	"context"
+	"time"
 
 	"github.com/RagOfJoes/mylo/flow/recovery"
+	"github.com/RagOfJoes/mylo/persistence"
 	"github.com/gofrs/uuid"
 	"gorm.io/gorm"
 )
@@ -18,7 +20,7 @@ func NewGormRecoveryRepository(d *gorm.DB) recovery.Repository {
 
 func (g *gormRecoveryRepository) Create(ctx context.Context, newFlow recovery.Flow) (*recovery.Flow, error) {
 	clone := newFlow
-	if err := g.DB.Create(&clone).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Create(&clone).Error; err != nil {
 		return nil, err
 	}
 	return &clone, nil
@@ -26,7 +28,7 @@ func (g *gormRecoveryRepository) Create(ctx context.Context, newFlow recovery.Fl
 
 func (g *gormRecoveryRepository) Get(ctx context.Context, id uuid.UUID) (*recovery.Flow, error) {
 	var flow recovery.Flow
-	if err := g.DB.First(&flow, "id = ?", id).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).First(&flow, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &flow, nil
@@ -34,7 +36,7 @@ func (g *gormRecoveryRepository) Get(ctx context.Context, id uuid.UUID) (*recove
 
 func (g *gormRecoveryRepository) GetByFlowIDOrRecoverID(ctx context.Context, id string) (*recovery.Flow, error) {
 	var flow recovery.Flow
-	if err := g.DB.Where("flow_id = ?", id).Or("recover_id = ?", id).Find(&flow).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Where("flow_id = ?", id).Or("recover_id = ?", id).Find(&flow).Error; err != nil {
 		return nil, err
 	}
 	return &flow, nil
@@ -42,7 +44,7 @@ func (g *gormRecoveryRepository) GetByFlowIDOrRecoverID(ctx context.Context, id
 
 func (g *gormRecoveryRepository) GetByIdentityID(ctx context.Context, identityID uuid.UUID) (*recovery.Flow, error) {
 	var flow recovery.Flow
-	if err := g.DB.First(&flow, "identity_id = ?", identityID).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).First(&flow, "identity_id = ?", identityID).Error; err != nil {
 		return nil, err
 	}
 	return &flow, nil
@@ -50,15 +52,19 @@ func (g *gormRecoveryRepository) GetByIdentityID(ctx context.Context, identityID
 
 func (g *gormRecoveryRepository) Update(ctx context.Context, updateFlow recovery.Flow) (*recovery.Flow, error) {
 	clone := updateFlow
-	if err := g.DB.Save(&clone).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Save(&clone).Error; err != nil {
 		return nil, err
 	}
 	return &clone, nil
 }
 
 func (g *gormRecoveryRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	if err := g.DB.Where("id = ?", id).Delete(recovery.Flow{}).Error; err != nil && err != gorm.ErrRecordNotFound {
+	if err := persistence.Conn(ctx, g.DB).Where("id = ?", id).Delete(recovery.Flow{}).Error; err != nil && err != gorm.ErrRecordNotFound {
 		return err
 	}
 	return nil
 }
+
+func (g *gormRecoveryRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	return persistence.DeleteBatch(persistence.Conn(ctx, g.DB), &recovery.Flow{}, limit, "expires_at < ? OR (status IN ? AND updated_at < ?)", before, []recovery.Status{recovery.Complete, recovery.Fail}, before)
+}
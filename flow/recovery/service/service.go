@@ -4,29 +4,37 @@ import (
 	"context"
 	"log"
 
+	"github.com/RagOfJoes/mylo/event"
 	"github.com/RagOfJoes/mylo/flow/recovery"
 	"github.com/RagOfJoes/mylo/internal"
 	"github.com/RagOfJoes/mylo/internal/validate"
 	"github.com/RagOfJoes/mylo/user/contact"
 	"github.com/RagOfJoes/mylo/user/credential"
+	"github.com/RagOfJoes/mylo/user/identity"
 )
 
 type service struct {
 	r   recovery.Repository
+	tx  internal.Transactor
+	eb  event.Bus
 	cs  credential.Service
 	cos contact.Service
+	is  identity.Service
 }
 
-func NewRecoveryService(r recovery.Repository, cs credential.Service, cos contact.Service) recovery.Service {
+func NewRecoveryService(r recovery.Repository, tx internal.Transactor, eb event.Bus, cs credential.Service, cos contact.Service, is identity.Service) recovery.Service {
 	return &service{
 		r:   r,
+		tx:  tx,
+		eb:  eb,
 		cs:  cs,
+		is:  is,
 		cos: cos,
 	}
 }
 
-func (s *service) New(ctx context.Context, requestURL string) (*recovery.Flow, error) {
-	newFlow, err := recovery.New(requestURL)
+func (s *service) New(ctx context.Context, requestURL string, client internal.Client) (*recovery.Flow, error) {
+	newFlow, err := recovery.New(requestURL, client)
 	if err != nil {
 		return nil, err
 	}
@@ -83,13 +91,28 @@ func (s *service) SubmitIdentifier(ctx context.Context, flow recovery.Flow, payl
 		// Wrap error with internal code
 		return nil, internal.NewErrorf(internal.ErrorCodeInternal, "%v", err)
 	}
+	user, err := s.is.Find(ctx, credential.IdentityID.String())
+	if err != nil {
+		return nil, err
+	}
 	// Update flow to LinkPending
 	if err := flow.LinkPending(credential.IdentityID); err != nil {
 		return nil, err
 	}
-	updated, err := s.r.Update(ctx, flow)
-	if err != nil {
-		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update recovery flow: %s", flow.ID)
+	// Publish alongside the update so that subscribers only ever act on a flow that was saved
+	var updated *recovery.Flow
+	if err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		u, err := s.r.Update(ctx, flow)
+		if err != nil {
+			return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update recovery flow: %s", flow.ID)
+		}
+		updated = u
+		return s.eb.Publish(ctx, event.RecoveryRequested{
+			Identity: *user,
+			Flow:     *u,
+		})
+	}); err != nil {
+		return nil, err
 	}
 	return updated, nil
 }
@@ -110,11 +133,28 @@ func (s *service) SubmitUpdatePassword(ctx context.Context, flow recovery.Flow,
 	if err != nil {
 		return nil, err
 	}
+	// Recovering an account also removes any lock or forced reset that was placed on it
+	if err := s.is.Unlock(ctx, *flow.IdentityID); err != nil {
+		return nil, err
+	}
+	if err := s.is.RequirePasswordReset(ctx, *flow.IdentityID, false); err != nil {
+		return nil, err
+	}
 	// Complete flow
 	flow.Complete()
-	updated, err := s.r.Update(ctx, flow)
-	if err != nil {
-		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update recovery flow: %s", flow.ID)
+	var updated *recovery.Flow
+	if err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		u, err := s.r.Update(ctx, flow)
+		if err != nil {
+			return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update recovery flow: %s", flow.ID)
+		}
+		updated = u
+		if err := s.eb.Publish(ctx, event.PasswordChanged{IdentityID: *u.IdentityID, Client: u.Client}); err != nil {
+			return err
+		}
+		return s.eb.Publish(ctx, event.RecoveryCompleted{IdentityID: *u.IdentityID, Client: u.Client})
+	}); err != nil {
+		return nil, err
 	}
 	return updated, nil
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/RagOfJoes/mylo/flow/oidc"
 	"github.com/RagOfJoes/mylo/internal"
 	"github.com/RagOfJoes/mylo/internal/config"
 	"github.com/RagOfJoes/mylo/internal/validate"
@@ -36,6 +37,8 @@ type Flow struct {
 	// relevant data from urls path or query. This can also be used to find locate
 	// or security issues.
 	RequestURL string `json:"-" gorm:"not null" validate:"required"`
+	// Client defines the client that initiated the flow
+	Client internal.Client `json:"-" gorm:"embedded;embeddedPrefix:client_"`
 	// Status defines the current state of the flow
 	Status Status `json:"status" gorm:"not null" validate:"required"`
 	// FlowID defines the unique identifier that user's will use to access the flow
@@ -72,12 +75,15 @@ type Repository interface {
 	Update(ctx context.Context, updateFlow Flow) (*Flow, error)
 	// Delete deletes a flow via ID
 	Delete(ctx context.Context, id uuid.UUID) error
+	// DeleteExpired deletes up to limit flows that either expired or were finished before the time provided and returns
+	// how many were deleted
+	DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error)
 }
 
 // Service defines the interface for service implementations
 type Service interface {
 	// New creates a new registration flow
-	New(ctx context.Context, requestURL string) (*Flow, error)
+	New(ctx context.Context, requestURL string, client internal.Client) (*Flow, error)
 	// Find does exactly that
 	Find(ctx context.Context, flowID string) (*Flow, error)
 	// Submit completes the flow
@@ -91,7 +97,7 @@ func (Flow) TableName() string {
 
 // Form creates a form for registration
 func Form(action string) form.Form {
-	return form.Form{
+	f := form.Form{
 		Action: action,
 		Method: form.POST,
 		Nodes: node.Nodes{
@@ -145,10 +151,13 @@ func Form(action string) form.Form {
 			},
 		},
 	}
+	// Render a link for every configured OIDC provider
+	f.Nodes = append(f.Nodes, oidc.Nodes()...)
+	return f
 }
 
 // New creates a new Flow
-func New(requestURL string) (*Flow, error) {
+func New(requestURL string, client internal.Client) (*Flow, error) {
 	flowID, err := nanoid.New()
 	if err != nil {
 		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate nano id")
@@ -164,6 +173,7 @@ func New(requestURL string) (*Flow, error) {
 		Form:       &form,
 		ExpiresAt:  expire,
 		RequestURL: requestURL,
+		Client:     client,
 	}, nil
 }
 
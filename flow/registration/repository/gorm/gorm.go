@@ -2,8 +2,10 @@ package gorm
 
 import (
 	"context"
+	"time"
 
 	"github.com/RagOfJoes/mylo/flow/registration"
+	"github.com/RagOfJoes/mylo/persistence"
 	"github.com/gofrs/uuid"
 	"gorm.io/gorm"
 )
@@ -18,7 +20,7 @@ func NewGormRegistrationRepository(d *gorm.DB) registration.Repository {
 
 func (g *gormRegistrationRepository) Create(ctx context.Context, newFlow registration.Flow) (*registration.Flow, error) {
 	created := newFlow
-	if err := g.DB.Create(&created).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Create(&created).Error; err != nil {
 		return nil, err
 	}
 	return &created, nil
@@ -26,7 +28,7 @@ func (g *gormRegistrationRepository) Create(ctx context.Context, newFlow registr
 
 func (g *gormRegistrationRepository) Get(ctx context.Context, id string) (*registration.Flow, error) {
 	var flow registration.Flow
-	if err := g.DB.First(&flow, "id = ?", id).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).First(&flow, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &flow, nil
@@ -34,7 +36,7 @@ func (g *gormRegistrationRepository) Get(ctx context.Context, id string) (*regis
 
 func (g *gormRegistrationRepository) GetByFlowID(ctx context.Context, flowID string) (*registration.Flow, error) {
 	var flow registration.Flow
-	if err := g.DB.First(&flow, "flow_id = ?", flowID).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).First(&flow, "flow_id = ?", flowID).Error; err != nil {
 		return nil, err
 	}
 	return &flow, nil
@@ -42,15 +44,19 @@ func (g *gormRegistrationRepository) GetByFlowID(ctx context.Context, flowID str
 
 func (g *gormRegistrationRepository) Update(ctx context.Context, updateFlow registration.Flow) (*registration.Flow, error) {
 	updated := updateFlow
-	if err := g.DB.Save(&updated).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Save(&updated).Error; err != nil {
 		return nil, err
 	}
 	return &updated, nil
 }
 
 func (g *gormRegistrationRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	if err := g.DB.Where("id = ?", id.String()).Delete(registration.Flow{}).Error; err != nil && err != gorm.ErrRecordNotFound {
+	if err := persistence.Conn(ctx, g.DB).Where("id = ?", id.String()).Delete(registration.Flow{}).Error; err != nil && err != gorm.ErrRecordNotFound {
 		return err
 	}
 	return nil
 }
+
+func (g *gormRegistrationRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	return persistence.DeleteBatch(persistence.Conn(ctx, g.DB), &registration.Flow{}, limit, "expires_at < ? OR (status = ? AND updated_at < ?)", before, registration.Complete, before)
+}
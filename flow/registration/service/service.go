@@ -2,34 +2,40 @@ package service
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/RagOfJoes/mylo/event"
 	"github.com/RagOfJoes/mylo/flow/registration"
 	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
 	"github.com/RagOfJoes/mylo/internal/validate"
 	"github.com/RagOfJoes/mylo/user/contact"
 	"github.com/RagOfJoes/mylo/user/credential"
 	"github.com/RagOfJoes/mylo/user/identity"
-	"golang.org/x/sync/errgroup"
 )
 
 type service struct {
 	r   registration.Repository
+	tx  internal.Transactor
+	eb  event.Bus
 	cos contact.Service
 	cs  credential.Service
 	is  identity.Service
 }
 
-func NewRegistrationService(r registration.Repository, cos contact.Service, cs credential.Service, is identity.Service) registration.Service {
+func NewRegistrationService(r registration.Repository, tx internal.Transactor, eb event.Bus, cos contact.Service, cs credential.Service, is identity.Service) registration.Service {
 	return &service{
 		r:   r,
+		tx:  tx,
+		eb:  eb,
 		cs:  cs,
 		is:  is,
 		cos: cos,
 	}
 }
 
-func (s *service) New(ctx context.Context, requestURL string) (*registration.Flow, error) {
-	newFlow, err := registration.New(requestURL)
+func (s *service) New(ctx context.Context, requestURL string, client internal.Client) (*registration.Flow, error) {
+	newFlow, err := registration.New(requestURL, client)
 	if err != nil {
 		return nil, err
 	}
@@ -68,21 +74,19 @@ func (s *service) Submit(ctx context.Context, flow registration.Flow, payload re
 		Email:     payload.Email,
 		FirstName: payload.FirstName,
 		LastName:  payload.LastName,
+		Locale:    flow.Client.Locale,
 	}
-	// Create new identity
-	newUser, err := s.is.Create(ctx, tempIdentity, payload.Username, payload.Password)
-	if err != nil {
-		return nil, err
-	}
-
-	// Run multiple actions concurrently
-	// - Use Contact Service to create new contact for user
-	// - Use Credential Service to create new password credential for user
-	var eg errgroup.Group
-	eg.Go(func() error {
+	// The identity, its contact and credential, the completed flow and the IdentityRegistered event are saved together so
+	// that a failure at any step, including a subscriber that's handled synchronously, leaves nothing behind
+	var newUser *identity.Identity
+	if err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		created, err := s.is.Create(ctx, tempIdentity, payload.Username, payload.Password)
+		if err != nil {
+			return err
+		}
 		vc, err := s.cos.Add(ctx, []contact.Contact{
 			{
-				IdentityID: newUser.ID,
+				IdentityID: created.ID,
 				State:      contact.Sent,
 				Value:      payload.Email,
 			},
@@ -90,12 +94,8 @@ func (s *service) Submit(ctx context.Context, flow registration.Flow, payload re
 		if err != nil {
 			return internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", registration.ErrInvalidPaylod)
 		}
-		// Append new contact to instantiated identity
-		newUser.Contacts = append(newUser.Contacts, vc...)
-		return nil
-	})
-	eg.Go(func() error {
-		cr, err := s.cs.CreatePassword(ctx, newUser.ID, payload.Password, []credential.Identifier{
+		created.Contacts = append(created.Contacts, vc...)
+		cr, err := s.cs.CreatePassword(ctx, created.ID, payload.Password, []credential.Identifier{
 			{
 				Type:  "email",
 				Value: payload.Email,
@@ -108,20 +108,24 @@ func (s *service) Submit(ctx context.Context, flow registration.Flow, payload re
 		if err != nil {
 			return err
 		}
-		// Append new credential to instantited identity
-		newUser.Credentials = append(newUser.Credentials, *cr)
+		created.Credentials = append(created.Credentials, *cr)
+		// Complete the flow
+		flow.Complete()
+		if _, err := s.r.Update(ctx, flow); err != nil {
+			return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update registration flow: %s", flow.ID)
+		}
+		cfg := config.Get()
+		if err := s.eb.Publish(ctx, event.IdentityRegistered{
+			Identity:   *created,
+			Client:     flow.Client,
+			RequestURL: fmt.Sprintf("/%s/%s", cfg.Registration.URL, flow.FlowID),
+		}); err != nil {
+			return err
+		}
+		newUser = created
 		return nil
-	})
-	// Check if any of the concurrent actions error'd out and if so
-	// perform a cascade delete on the user
-	if err := eg.Wait(); err != nil {
-		s.is.Delete(ctx, newUser.ID.String(), true)
+	}); err != nil {
 		return nil, err
 	}
-	// Complete the flow
-	flow.Complete()
-	if _, err := s.r.Update(ctx, flow); err != nil {
-		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update registration flow: %s", flow.ID)
-	}
 	return newUser, nil
 }
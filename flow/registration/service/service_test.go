@@ -0,0 +1,76 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/RagOfJoes/mylo/event"
+	"github.com/RagOfJoes/mylo/flow/registration"
+	"github.com/RagOfJoes/mylo/flow/registration/service"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config/configtest"
+	"github.com/RagOfJoes/mylo/persistence/memory"
+	contactService "github.com/RagOfJoes/mylo/user/contact/service"
+	credentialService "github.com/RagOfJoes/mylo/user/credential/service"
+	identityService "github.com/RagOfJoes/mylo/user/identity/service"
+)
+
+func TestSubmitRollback(t *testing.T) {
+	configtest.Setup(t, map[string]interface{}{
+		"credential.argon.memory":     1024,
+		"credential.argon.iterations": 1,
+	})
+	store := memory.NewStore()
+	bus := event.NewBus()
+	ir := memory.NewMemoryIdentityRepository(store)
+	s := service.NewRegistrationService(
+		memory.NewMemoryRegistrationRepository(store),
+		memory.NewMemoryTransactor(store),
+		bus,
+		contactService.NewContactService(memory.NewMemoryContactRepository(store)),
+		credentialService.NewCredentialService(memory.NewMemoryCredentialRepository(store)),
+		identityService.NewIdentityService(ir, bus),
+	)
+	ctx := context.Background()
+	payload := registration.Payload{
+		Email:     "jane@example.com",
+		Username:  "jane",
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Password:  "correct horse battery staple",
+	}
+
+	// A subscriber that fails undoes the whole registration
+	failing := errors.New("subscriber failed")
+	fail := true
+	bus.Subscribe(event.TopicIdentityRegistered, func(ctx context.Context, e event.Event) error {
+		if fail {
+			return failing
+		}
+		return nil
+	})
+	flow, err := s.New(ctx, "http://localhost", internal.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Submit(ctx, *flow, payload); !errors.Is(err, failing) {
+		t.Fatalf("expected the subscriber's error, got %v", err)
+	}
+	if _, err := ir.GetWithIdentifier(ctx, "jane", false); err == nil {
+		t.Fatal("expected the identity to be rolled back")
+	}
+	if found, err := s.Find(ctx, flow.FlowID); err != nil || found.Status == registration.Complete {
+		t.Fatalf("expected the flow to still be usable, got %v", err)
+	}
+
+	// Nothing is left behind that would stop the User from trying again
+	fail = false
+	registered, err := s.Submit(ctx, *flow, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(registered.Contacts) != 1 || len(registered.Credentials) != 1 {
+		t.Errorf("expected the contact and credential to be created, got %+v", registered)
+	}
+}
@@ -2,35 +2,27 @@ package transport
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 
-	"github.com/RagOfJoes/mylo/email"
 	"github.com/RagOfJoes/mylo/flow/recovery"
 	"github.com/RagOfJoes/mylo/flow/registration"
-	"github.com/RagOfJoes/mylo/flow/verification"
 	"github.com/RagOfJoes/mylo/internal"
 	"github.com/RagOfJoes/mylo/internal/config"
 	sessionHttp "github.com/RagOfJoes/mylo/session/transport"
 	"github.com/RagOfJoes/mylo/transport"
 	"github.com/RagOfJoes/mylo/user/credential"
-	"github.com/RagOfJoes/mylo/user/identity"
 	"github.com/gin-gonic/gin"
 )
 
 type Http struct {
-	e  email.Client
 	sh sessionHttp.Http
 	s  registration.Service
-	vs verification.Service
 }
 
-func NewRegistrationHttp(e email.Client, sh sessionHttp.Http, s registration.Service, vs verification.Service, r *gin.Engine) {
+func NewRegistrationHttp(sh sessionHttp.Http, s registration.Service, r *gin.Engine) {
 	cfg := config.Get()
 	h := &Http{
-		e:  e,
 		sh: sh,
-		vs: vs,
 		s:  s,
 	}
 
@@ -50,7 +42,7 @@ func (h *Http) initFlow() gin.HandlerFunc {
 			c.Error(internal.NewErrorf(internal.ErrorCodeForbidden, "%v", registration.ErrAlreadyAuthenticated))
 			return
 		}
-		newFlow, err := h.s.New(ctx, transport.RequestURL(c.Request))
+		newFlow, err := h.s.New(ctx, transport.RequestURL(c.Request), transport.Client(c.Request))
 		if err != nil {
 			c.Error(err)
 			return
@@ -112,7 +104,7 @@ func (h *Http) submitFlow() gin.HandlerFunc {
 			return
 		}
 		// Authenticate session with password credential method
-		if err := sess.Authenticate(*user, credential.Password); err != nil {
+		if err := sess.Authenticate(*user, transport.Client(c.Request), credential.Password); err != nil {
 			c.Error(err)
 			return
 		}
@@ -122,24 +114,6 @@ func (h *Http) submitFlow() gin.HandlerFunc {
 			return
 		}
 
-		// Create a new verification flow in the background
-		// TODO: Look to add some dependency for callbacks on certain events
-		go func(user identity.Identity) {
-			vf, err := h.vs.NewDefault(ctx, user, user.Contacts[0], fmt.Sprintf("/registration/%s", flowID))
-			if err != nil {
-				// TODO: Capture error
-				log.Print(err)
-				return
-			}
-			cfg := config.Get()
-			url := fmt.Sprintf("%s/%s/%s", cfg.Server.URL, cfg.Verification.URL, vf.FlowID)
-			if err := h.e.SendWelcome(user.Contacts[0].Value, user, url); err != nil {
-				// TODO: Capture error
-				log.Print(err)
-				return
-			}
-		}(*user)
-
 		c.JSON(http.StatusCreated, transport.HttpResponse{
 			Success: true,
 			Payload: sess,
@@ -0,0 +1,63 @@
+package gorm
+
+import (
+	"context"
+	"time"
+
+	"github.com/RagOfJoes/mylo/flow/settings"
+	"github.com/RagOfJoes/mylo/persistence"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type gormSettingsRepository struct {
+	DB *gorm.DB
+}
+
+func NewGormSettingsRepository(d *gorm.DB) settings.Repository {
+	return &gormSettingsRepository{DB: d}
+}
+
+func (g *gormSettingsRepository) Create(ctx context.Context, newFlow settings.Flow) (*settings.Flow, error) {
+	created := newFlow
+	if err := persistence.Conn(ctx, g.DB).Create(&created).Error; err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (g *gormSettingsRepository) Get(ctx context.Context, id uuid.UUID) (*settings.Flow, error) {
+	var found settings.Flow
+	if err := persistence.Conn(ctx, g.DB).First(&found, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+func (g *gormSettingsRepository) GetByFlowID(ctx context.Context, flowID string) (*settings.Flow, error) {
+	var found settings.Flow
+	if err := persistence.Conn(ctx, g.DB).First(&found, "flow_id = ?", flowID).Error; err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+func (g *gormSettingsRepository) Update(ctx context.Context, updateFlow settings.Flow) (*settings.Flow, error) {
+	updated := updateFlow
+	if err := persistence.Conn(ctx, g.DB).Save(&updated).Error; err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (g *gormSettingsRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := persistence.Conn(ctx, g.DB).Where("id = ?", id.String()).Delete(settings.Flow{}).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	return nil
+}
+
+func (g *gormSettingsRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	// Settings flows are never finished, they can be used to make changes until they expire
+	return persistence.DeleteBatch(persistence.Conn(ctx, g.DB), &settings.Flow{}, limit, "expires_at < ?", before)
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/RagOfJoes/mylo/event"
+	"github.com/RagOfJoes/mylo/flow/settings"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/validate"
+	"github.com/RagOfJoes/mylo/user/credential"
+	"github.com/RagOfJoes/mylo/user/identity"
+)
+
+type service struct {
+	r  settings.Repository
+	eb event.Bus
+	cs credential.Service
+	is identity.Service
+}
+
+func NewSettingsService(r settings.Repository, eb event.Bus, cs credential.Service, is identity.Service) settings.Service {
+	return &service{
+		r:  r,
+		eb: eb,
+		cs: cs,
+		is: is,
+	}
+}
+
+func (s *service) New(ctx context.Context, identity identity.Identity, authenticatedAt time.Time, requestURL string, client internal.Client) (*settings.Flow, error) {
+	var newFlow *settings.Flow
+	var err error
+	if settings.Privileged(authenticatedAt) {
+		newFlow, err = settings.New(requestURL, client, identity)
+	} else {
+		newFlow, err = settings.NewSessionWarn(requestURL, client, identity)
+	}
+	if err != nil {
+		return nil, err
+	}
+	created, err := s.r.Create(ctx, *newFlow)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to create new settings flow")
+	}
+	return created, nil
+}
+
+func (s *service) Find(ctx context.Context, flowID string, identity identity.Identity) (*settings.Flow, error) {
+	if flowID == "" {
+		return nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+
+	flow, err := s.r.GetByFlowID(ctx, flowID)
+	if err != nil || flow == nil {
+		return nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if err := flow.Valid(); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if !flow.BelongsTo(identity.ID) {
+		return nil, internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	return flow, nil
+}
+
+func (s *service) SubmitSessionWarn(ctx context.Context, flow settings.Flow, identity identity.Identity, payload settings.SessionWarnPayload) (*settings.Flow, error) {
+	if err := s.valid(flow, identity, settings.SessionWarn); err != nil {
+		return nil, err
+	}
+	if err := validate.Check(payload); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", settings.ErrInvalidPassword)
+	}
+
+	// Use the stored identity since the session's copy won't reflect attempts made elsewhere
+	id, err := s.is.Find(ctx, flow.IdentityID.String())
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if id.Locked() {
+		return nil, internal.WrapErrorf(settings.ErrIdentityLocked, internal.ErrorCodeForbidden, "%v", settings.ErrIdentityLocked)
+	}
+	// Check if password is correct. Failed attempts count towards the same lockout as logins do
+	// since a session could otherwise be used to guess the password without ever being locked
+	if err := s.cs.ComparePassword(ctx, id.ID, payload.Password); err != nil {
+		return nil, s.failPassword(ctx, flow, *id, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", settings.ErrInvalidPassword))
+	}
+	// Reset failed attempts
+	if err := s.is.Unlock(ctx, id.ID); err != nil {
+		return nil, err
+	}
+	// Flow is now privileged
+	flow.Pending(identity)
+	updated, err := s.r.Update(ctx, flow)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update settings flow: %s", flow.ID)
+	}
+	return updated, nil
+}
+
+func (s *service) SubmitProfile(ctx context.Context, flow settings.Flow, identity identity.Identity, payload settings.ProfilePayload) (*settings.Flow, *identity.Identity, error) {
+	if err := s.valid(flow, identity, settings.Pending); err != nil {
+		return nil, nil, err
+	}
+	if err := validate.Check(payload); err != nil {
+		return nil, nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", settings.ErrInvalidProfilePayload)
+	}
+
+	identity.Avatar = payload.Avatar
+	identity.FirstName = payload.FirstName
+	identity.LastName = payload.LastName
+	updatedIdentity, err := s.is.Update(ctx, identity)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Rebuild forms with updated profile
+	flow.Pending(*updatedIdentity)
+	updated, err := s.r.Update(ctx, flow)
+	if err != nil {
+		return nil, nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update settings flow: %s", flow.ID)
+	}
+	return updated, updatedIdentity, nil
+}
+
+func (s *service) SubmitPassword(ctx context.Context, flow settings.Flow, identity identity.Identity, payload settings.PasswordPayload) (*settings.Flow, error) {
+	if err := s.valid(flow, identity, settings.Pending); err != nil {
+		return nil, err
+	}
+	if err := validate.Check(payload); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", settings.ErrInvalidPasswordPayload)
+	}
+
+	if _, err := s.cs.UpdatePassword(ctx, identity.ID, payload.Password); err != nil {
+		return nil, err
+	}
+	if err := s.is.RequirePasswordReset(ctx, identity.ID, false); err != nil {
+		return nil, err
+	}
+	if err := s.eb.Publish(ctx, event.PasswordChanged{
+		IdentityID: identity.ID,
+		Client:     flow.Client,
+	}); err != nil {
+		return nil, err
+	}
+	// Rebuild forms so that no values are carried over
+	flow.Pending(identity)
+	updated, err := s.r.Update(ctx, flow)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update settings flow: %s", flow.ID)
+	}
+	return updated, nil
+}
+
+// valid checks that the flow is valid, belongs to the identity and has the expected status
+func (s *service) valid(flow settings.Flow, identity identity.Identity, status settings.Status) error {
+	if err := flow.Valid(); err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	if !flow.BelongsTo(identity.ID) || flow.Status != status {
+		return internal.NewErrorf(internal.ErrorCodeNotFound, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	return nil
+}
+
+// failPassword records a failed password attempt for the identity. If the attempt locked the identity then the flow is
+// refused with ErrIdentityLocked, otherwise the original error is returned
+func (s *service) failPassword(ctx context.Context, flow settings.Flow, id identity.Identity, err error) error {
+	updated, failErr := s.is.FailLogin(ctx, id.ID)
+	if failErr != nil {
+		return failErr
+	}
+	if pubErr := s.eb.Publish(ctx, event.LoginFailed{
+		IdentityID: &id.ID,
+		Method:     credential.Password,
+		Client:     flow.Client,
+		Reason:     err.Error(),
+	}); pubErr != nil {
+		return pubErr
+	}
+	if updated.Locked() {
+		return internal.WrapErrorf(settings.ErrIdentityLocked, internal.ErrorCodeForbidden, "%v", settings.ErrIdentityLocked)
+	}
+	return err
+}
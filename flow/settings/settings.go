@@ -0,0 +1,273 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/internal/validate"
+	"github.com/RagOfJoes/mylo/pkg/nanoid"
+	"github.com/RagOfJoes/mylo/ui/form"
+	"github.com/RagOfJoes/mylo/ui/node"
+	"github.com/RagOfJoes/mylo/user/identity"
+	"github.com/gofrs/uuid"
+)
+
+var (
+	ErrInvalidPassword        = errors.New("Invalid password provided")
+	ErrInvalidProfilePayload  = errors.New("Invalid first name, last name or avatar provided")
+	ErrInvalidPasswordPayload = errors.New("Invalid new password provided")
+	ErrIdentityLocked         = errors.New("Account has been locked due to too many failed password attempts. Try again later or reset your password to unlock account")
+)
+
+type Status string
+
+const (
+	// SessionWarn occurs when the user's session is no longer privileged. This requires the
+	// user to perform a soft login by requiring them to input their password
+	SessionWarn Status = "SessionWarn"
+	// Pending occurs when the flow is privileged and is awaiting changes to one of its sections
+	Pending Status = "Pending"
+)
+
+type Flow struct {
+	internal.Base
+	// RequestURL defines the url that initiated flow. This can be used to pass any
+	// relevant data from urls path or query. This can also be used to find locate
+	// or security issues.
+	RequestURL string `json:"-" gorm:"not null" validate:"required"`
+	// Client defines the client that initiated the flow
+	Client internal.Client `json:"-" gorm:"embedded;embeddedPrefix:client_"`
+	// Status defines the current state of the flow
+	Status Status `json:"status" gorm:"not null" validate:"required"`
+	// FlowID defines the unique identifier that user's will use to access the flow
+	FlowID string `json:"flow_id" gorm:"not null;uniqueIndex" validate:"required"`
+	// ExpiresAt defines the time when this flow will no longer be valid
+	ExpiresAt time.Time `json:"expires_at" gorm:"index;not null" validate:"required"`
+
+	// Form defines the form that the User must complete when Status is `SessionWarn`
+	Form *form.Form `json:"form,omitempty" gorm:"type:json;default:null" validate:"required_if=Status SessionWarn"`
+	// ProfileForm defines the form used to update the User's profile
+	ProfileForm *form.Form `json:"profile_form,omitempty" gorm:"type:json;default:null" validate:"required_if=Status Pending"`
+	// PasswordForm defines the form used to update the User's password
+	PasswordForm *form.Form `json:"password_form,omitempty" gorm:"type:json;default:null" validate:"required_if=Status Pending"`
+
+	// IdentityID defines the user that this flow belongs to
+	IdentityID uuid.UUID `json:"-" gorm:"type:uuid;index;not null" validate:"required"`
+}
+
+// SessionWarnPayload defines the form that will be rendered
+// when a User's session is no longer privileged
+type SessionWarnPayload struct {
+	// Password should be provided by the user
+	Password string `json:"password" form:"password" binding:"required" validate:"required,min=6,max=128"`
+}
+
+// ProfilePayload defines the data required to update the profile section
+type ProfilePayload struct {
+	// Avatar is a url to the User's avatar
+	Avatar string `json:"avatar" form:"avatar" validate:"omitempty,max=1024,url"`
+	// FirstName is what it is
+	FirstName string `json:"first_name" form:"first_name" validate:"omitempty,max=64,alphanumunicode"`
+	// LastName is what it is
+	LastName string `json:"last_name" form:"last_name" validate:"omitempty,max=64,alphanumunicode"`
+}
+
+// PasswordPayload defines the data required to update the password section
+type PasswordPayload struct {
+	// Password is the new password
+	Password string `json:"password" form:"password" binding:"required" validate:"required,min=6,max=128"`
+}
+
+// Repository defines the interface for repository implementations
+type Repository interface {
+	// Create creates a new flow
+	Create(ctx context.Context, newFlow Flow) (*Flow, error)
+	// Get retrieves a flow via ID
+	Get(ctx context.Context, id uuid.UUID) (*Flow, error)
+	// GetByFlowID retrieves a flow via FlowID
+	GetByFlowID(ctx context.Context, flowID string) (*Flow, error)
+	// Update updates a flow
+	Update(ctx context.Context, updateFlow Flow) (*Flow, error)
+	// Delete deletes a flow via ID
+	Delete(ctx context.Context, id uuid.UUID) error
+	// DeleteExpired deletes up to limit flows that either expired or were finished before the time provided and returns
+	// how many were deleted
+	DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// Service defines the interface for service implementations
+type Service interface {
+	// New creates a new flow. If the session is no longer privileged then the flow will have a Status of SessionWarn
+	New(ctx context.Context, identity identity.Identity, authenticatedAt time.Time, requestURL string, client internal.Client) (*Flow, error)
+	// Find does exactly that
+	Find(ctx context.Context, flowID string, identity identity.Identity) (*Flow, error)
+	// SubmitSessionWarn requires the `SessionWarn` status and the `SessionWarnPayload` to move the flow to `Pending`
+	SubmitSessionWarn(ctx context.Context, flow Flow, identity identity.Identity, payload SessionWarnPayload) (*Flow, error)
+	// SubmitProfile updates the User's profile
+	SubmitProfile(ctx context.Context, flow Flow, identity identity.Identity, payload ProfilePayload) (*Flow, *identity.Identity, error)
+	// SubmitPassword updates the User's password
+	SubmitPassword(ctx context.Context, flow Flow, identity identity.Identity, payload PasswordPayload) (*Flow, error)
+}
+
+// TableName overrides GORM's table name
+func (Flow) TableName() string {
+	return "settings"
+}
+
+// SessionWarnForm creates a form for flow with SessionWarn status
+func SessionWarnForm(action string) form.Form {
+	return form.Form{
+		Action: action,
+		Method: form.POST,
+		Nodes: node.Nodes{
+			&node.Node{
+				Type:  node.Input,
+				Group: node.Default,
+				Attributes: &node.InputAttribute{
+					Required: true,
+					Name:     "password",
+					Type:     "password",
+					Label:    "Password",
+				},
+			},
+		},
+	}
+}
+
+// ProfileForm creates a form for the profile section that's prefilled with the User's current profile
+func ProfileForm(action string, identity identity.Identity) form.Form {
+	return form.Form{
+		Action: action,
+		Method: form.POST,
+		Nodes: node.Nodes{
+			{
+				Type:  node.Input,
+				Group: node.Profile,
+				Attributes: &node.InputAttribute{
+					Type:  "text",
+					Name:  "first_name",
+					Label: "First Name",
+					Value: identity.FirstName,
+				},
+			},
+			{
+				Type:  node.Input,
+				Group: node.Profile,
+				Attributes: &node.InputAttribute{
+					Type:  "text",
+					Name:  "last_name",
+					Label: "Last Name",
+					Value: identity.LastName,
+				},
+			},
+			{
+				Type:  node.Input,
+				Group: node.Profile,
+				Attributes: &node.InputAttribute{
+					Type:  "url",
+					Name:  "avatar",
+					Label: "Avatar",
+					Value: identity.Avatar,
+				},
+			},
+		},
+	}
+}
+
+// PasswordForm creates a form for the password section
+func PasswordForm(action string) form.Form {
+	return form.Form{
+		Action: action,
+		Method: form.POST,
+		Nodes: node.Nodes{
+			{
+				Type:  node.Input,
+				Group: node.Password,
+				Attributes: &node.InputAttribute{
+					Required: true,
+					Type:     "password",
+					Name:     "password",
+					Label:    "New Password",
+				},
+			},
+		},
+	}
+}
+
+// Privileged checks whether a session that authenticated at the provided time can update settings without
+// re-entering its password
+func Privileged(authenticatedAt time.Time) bool {
+	cfg := config.Get()
+	return time.Since(authenticatedAt) < cfg.Settings.PrivilegedLifetime
+}
+
+// New creates a new flow with Pending status
+func New(requestURL string, client internal.Client, identity identity.Identity) (*Flow, error) {
+	flowID, err := nanoid.New()
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate nano id")
+	}
+
+	cfg := config.Get()
+	newFlow := &Flow{
+		FlowID:     flowID,
+		Status:     Pending,
+		RequestURL: requestURL,
+		Client:     client,
+		ExpiresAt:  time.Now().Add(cfg.Settings.Lifetime),
+
+		IdentityID: identity.ID,
+	}
+	newFlow.Pending(identity)
+	return newFlow, nil
+}
+
+// NewSessionWarn creates a new flow with SessionWarn status
+func NewSessionWarn(requestURL string, client internal.Client, identity identity.Identity) (*Flow, error) {
+	newFlow, err := New(requestURL, client, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := config.Get()
+	action := fmt.Sprintf("%s/%s/%s", cfg.Server.URL, cfg.Settings.URL, newFlow.FlowID)
+	form := SessionWarnForm(action)
+	newFlow.Form = &form
+	newFlow.ProfileForm = nil
+	newFlow.PasswordForm = nil
+	newFlow.Status = SessionWarn
+	return newFlow, nil
+}
+
+// Valid checks the validity of the flow
+func (f *Flow) Valid() error {
+	if err := validate.Check(f); err != nil {
+		return internal.NewErrorf(internal.ErrorCodeInternal, "%v", err)
+	}
+	if f.ExpiresAt.Before(time.Now()) {
+		return internal.NewErrorf(internal.ErrorCodeInternal, "%v", internal.ErrInvalidExpiredFlow)
+	}
+	return nil
+}
+
+// BelongsTo checks if flow belongs to user
+func (f *Flow) BelongsTo(identityID uuid.UUID) bool {
+	return f.IdentityID == identityID
+}
+
+// Pending updates flow to Pending status and builds forms for every section using the User's current profile
+func (f *Flow) Pending(identity identity.Identity) {
+	cfg := config.Get()
+	action := fmt.Sprintf("%s/%s/%s", cfg.Server.URL, cfg.Settings.URL, f.FlowID)
+	profileForm := ProfileForm(fmt.Sprintf("%s/profile", action), identity)
+	passwordForm := PasswordForm(fmt.Sprintf("%s/password", action))
+
+	f.Form = nil
+	f.Status = Pending
+	f.ProfileForm = &profileForm
+	f.PasswordForm = &passwordForm
+}
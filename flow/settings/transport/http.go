@@ -0,0 +1,195 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/RagOfJoes/mylo/flow/settings"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/session"
+	sessionHttp "github.com/RagOfJoes/mylo/session/transport"
+	"github.com/RagOfJoes/mylo/transport"
+	"github.com/gin-gonic/gin"
+)
+
+type Http struct {
+	sh sessionHttp.Http
+	s  settings.Service
+}
+
+func NewSettingsHttp(sh sessionHttp.Http, s settings.Service, r *gin.Engine) {
+	cfg := config.Get()
+	h := &Http{
+		sh: sh,
+		s:  s,
+	}
+
+	group := r.Group(fmt.Sprintf("/%s", cfg.Settings.URL))
+	{
+		group.GET("/", h.initFlow())
+		group.GET("/:flow_id", h.getFlow())
+		group.POST("/:flow_id", h.submitSessionWarn())
+		group.POST("/:flow_id/profile", h.submitProfile())
+		group.POST("/:flow_id/password", h.submitPassword())
+	}
+}
+
+func (h *Http) initFlow() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.session(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		newFlow, err := h.s.New(ctx, *sess.Identity, *sess.AuthenticatedAt, transport.RequestURL(c.Request), transport.Client(c.Request))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, transport.HttpResponse{
+			Success: true,
+			Payload: newFlow,
+		})
+	}
+}
+
+func (h *Http) getFlow() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.session(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		flow, err := h.s.Find(ctx, c.Param("flow_id"), *sess.Identity)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Payload: flow,
+		})
+	}
+}
+
+func (h *Http) submitSessionWarn() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.session(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		flow, err := h.s.Find(ctx, c.Param("flow_id"), *sess.Identity)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		var payload settings.SessionWarnPayload
+		if err := c.ShouldBind(&payload); err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", settings.ErrInvalidPassword))
+			return
+		}
+		submitted, err := h.s.SubmitSessionWarn(ctx, *flow, *sess.Identity, payload)
+		if err != nil {
+			h.lockSession(c, sess, err)
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Payload: submitted,
+		})
+	}
+}
+
+func (h *Http) submitProfile() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.session(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		flow, err := h.s.Find(ctx, c.Param("flow_id"), *sess.Identity)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		var payload settings.ProfilePayload
+		if err := c.ShouldBind(&payload); err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", settings.ErrInvalidProfilePayload))
+			return
+		}
+		submitted, _, err := h.s.SubmitProfile(ctx, *flow, *sess.Identity, payload)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Payload: submitted,
+		})
+	}
+}
+
+func (h *Http) submitPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.session(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		flow, err := h.s.Find(ctx, c.Param("flow_id"), *sess.Identity)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		var payload settings.PasswordPayload
+		if err := c.ShouldBind(&payload); err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", settings.ErrInvalidPasswordPayload))
+			return
+		}
+		submitted, err := h.s.SubmitPassword(ctx, *flow, *sess.Identity, payload)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Payload: submitted,
+		})
+	}
+}
+
+// session retrieves the authenticated session for the request
+func (h *Http) session(c *gin.Context) (*session.Session, error) {
+	sess, err := h.sh.Session(c.Request.Context(), c.Request, c.Writer, true)
+	if err != nil || sess == nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeUnauthorized, "%v", internal.ErrUnauthorized)
+	}
+	return sess, nil
+}
+
+// lockSession moves the session to a Locked state if the identity was locked by the failed attempt
+func (h *Http) lockSession(c *gin.Context, sess *session.Session, err error) {
+	if !errors.Is(err, settings.ErrIdentityLocked) {
+		return
+	}
+	sess.Lockout()
+	if _, err := h.sh.Upsert(c.Request.Context(), *sess); err != nil {
+		// TODO: Capture Error Here
+		log.Print(err)
+	}
+}
@@ -2,8 +2,10 @@ package gorm
 
 import (
 	"context"
+	"time"
 
 	"github.com/RagOfJoes/mylo/flow/verification"
+	"github.com/RagOfJoes/mylo/persistence"
 	"github.com/gofrs/uuid"
 	"gorm.io/gorm"
 )
@@ -18,7 +20,7 @@ func NewGormVerificationRepository(d *gorm.DB) verification.Repository {
 
 func (g *gormVerificationRepository) Create(ctx context.Context, newFlow verification.Flow) (*verification.Flow, error) {
 	created := newFlow
-	if err := g.DB.Create(&created).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Create(&created).Error; err != nil {
 		return nil, err
 	}
 	return &created, nil
@@ -26,7 +28,7 @@ func (g *gormVerificationRepository) Create(ctx context.Context, newFlow verific
 
 func (g *gormVerificationRepository) Get(ctx context.Context, id uuid.UUID) (*verification.Flow, error) {
 	var flow verification.Flow
-	if err := g.DB.First(ctx, &flow, "id = ?", flow).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).First(ctx, &flow, "id = ?", flow).Error; err != nil {
 		return nil, err
 	}
 	return &flow, nil
@@ -34,7 +36,7 @@ func (g *gormVerificationRepository) Get(ctx context.Context, id uuid.UUID) (*ve
 
 func (g *gormVerificationRepository) GetByFlowIDOrVerifyID(ctx context.Context, id string) (*verification.Flow, error) {
 	var flow verification.Flow
-	if err := g.DB.Where("flow_id = ?", id).Or("verify_id = ?", id).Find(&flow).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Where("flow_id = ?", id).Or("verify_id = ?", id).Find(&flow).Error; err != nil {
 		return nil, err
 	}
 	return &flow, nil
@@ -42,7 +44,7 @@ func (g *gormVerificationRepository) GetByFlowIDOrVerifyID(ctx context.Context,
 
 func (g *gormVerificationRepository) GetByContactID(ctx context.Context, contactID uuid.UUID) (*verification.Flow, error) {
 	var flow verification.Flow
-	if err := g.DB.First(&flow, "contact_id = ?", contactID).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).First(&flow, "contact_id = ?", contactID).Error; err != nil {
 		return nil, err
 	}
 	return &flow, nil
@@ -50,15 +52,19 @@ func (g *gormVerificationRepository) GetByContactID(ctx context.Context, contact
 
 func (g *gormVerificationRepository) Update(ctx context.Context, updateFlow verification.Flow) (*verification.Flow, error) {
 	updated := updateFlow
-	if err := g.DB.Save(&updated).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Save(&updated).Error; err != nil {
 		return nil, err
 	}
 	return &updated, nil
 }
 
 func (g *gormVerificationRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	if err := g.DB.Where("id = ?", id.String()).Delete(verification.Flow{}).Error; err != nil && err != gorm.ErrRecordNotFound {
+	if err := persistence.Conn(ctx, g.DB).Where("id = ?", id.String()).Delete(verification.Flow{}).Error; err != nil && err != gorm.ErrRecordNotFound {
 		return err
 	}
 	return nil
 }
+
+func (g *gormVerificationRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	return persistence.DeleteBatch(persistence.Conn(ctx, g.DB), &verification.Flow{}, limit, "expires_at < ? OR (status = ? AND updated_at < ?)", before, verification.Complete, before)
+}
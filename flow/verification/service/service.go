@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/RagOfJoes/mylo/event"
 	"github.com/RagOfJoes/mylo/flow/verification"
 	"github.com/RagOfJoes/mylo/internal"
 	"github.com/RagOfJoes/mylo/internal/validate"
@@ -14,46 +15,40 @@ import (
 
 type service struct {
 	r   verification.Repository
+	tx  internal.Transactor
+	eb  event.Bus
 	cos contact.Service
 	cs  credential.Service
 	is  identity.Service
 }
 
-func NewVerificationService(r verification.Repository, cos contact.Service, cs credential.Service, is identity.Service) verification.Service {
+func NewVerificationService(r verification.Repository, tx internal.Transactor, eb event.Bus, cos contact.Service, cs credential.Service, is identity.Service) verification.Service {
 	return &service{
 		r:   r,
+		tx:  tx,
+		eb:  eb,
 		cos: cos,
 		cs:  cs,
 		is:  is,
 	}
 }
 
-func (s *service) NewDefault(ctx context.Context, identity identity.Identity, contact contact.Contact, requestURL string) (*verification.Flow, error) {
-	if !isValidContact(contact, identity) {
-		return nil, internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", verification.ErrInvalidContact)
-	}
-	if existing := s.getExistingFlow(ctx, contact); existing != nil {
-		return existing, nil
-	}
-	newFlow, err := verification.NewLinkPending(requestURL, contact.ID, identity.ID)
-	if err != nil {
-		return nil, err
-	}
-	created, err := s.r.Create(ctx, *newFlow)
-	if err != nil {
-		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to create new verification flow")
-	}
-	return created, nil
+func (s *service) NewDefault(ctx context.Context, identity identity.Identity, contact contact.Contact, requestURL string, client internal.Client) (*verification.Flow, error) {
+	return s.newLinkPending(ctx, identity, contact, requestURL, client, false)
 }
 
-func (s *service) NewSessionWarn(ctx context.Context, identity identity.Identity, contact contact.Contact, requestURL string) (*verification.Flow, error) {
+func (s *service) NewWelcome(ctx context.Context, identity identity.Identity, contact contact.Contact, requestURL string, client internal.Client) (*verification.Flow, error) {
+	return s.newLinkPending(ctx, identity, contact, requestURL, client, true)
+}
+
+func (s *service) NewSessionWarn(ctx context.Context, identity identity.Identity, contact contact.Contact, requestURL string, client internal.Client) (*verification.Flow, error) {
 	if !isValidContact(contact, identity) {
 		return nil, internal.NewErrorf(internal.ErrorCodeInternal, "%v", verification.ErrInvalidContact)
 	}
 	if existing := s.getExistingFlow(ctx, contact); existing != nil {
 		return existing, nil
 	}
-	newFlow, err := verification.NewSessionWarn(requestURL, contact.ID, identity.ID)
+	newFlow, err := verification.NewSessionWarn(requestURL, client, contact.ID, identity.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -111,9 +106,28 @@ func (s *service) SubmitSessionWarn(ctx context.Context, flow verification.Flow,
 	if err := flow.Next(); err != nil {
 		return nil, err
 	}
-	updated, err := s.r.Update(ctx, flow)
-	if err != nil {
-		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update verification flow: %s", flow.ID)
+	var updated *verification.Flow
+	if err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		u, err := s.r.Update(ctx, flow)
+		if err != nil {
+			return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update verification flow: %s", flow.ID)
+		}
+		updated = u
+		if u.Status != verification.LinkPending {
+			return nil
+		}
+		for _, c := range identity.Contacts {
+			if c.ID == u.ContactID {
+				return s.eb.Publish(ctx, event.VerificationRequested{
+					Identity: identity,
+					Contact:  c,
+					Flow:     *u,
+				})
+			}
+		}
+		return internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", verification.ErrInvalidContact)
+	}); err != nil {
+		return nil, err
 	}
 	return updated, nil
 }
@@ -144,10 +158,62 @@ func (s *service) Verify(ctx context.Context, flow verification.Flow, identity i
 	if err := flow.Next(); err != nil {
 		return nil, err
 	}
-	verified, err := s.r.Update(ctx, flow)
-	// TODO: Revert contacts on error
-	if err != nil {
+	var verified *verification.Flow
+	if err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		v, err := s.r.Update(ctx, flow)
+		// TODO: Revert contacts on error
+		if err != nil {
+			return err
+		}
+		verified = v
+		for _, c := range identity.Contacts {
+			if c.ID == v.ContactID {
+				return s.eb.Publish(ctx, event.ContactVerified{
+					Identity: identity,
+					Contact:  c,
+					Client:   v.Client,
+				})
+			}
+		}
+		return nil
+	}); err != nil {
 		return nil, err
 	}
 	return verified, nil
 }
+
+// newLinkPending creates a `LinkPending` flow, or reuses the contact's existing one, and publishes VerificationRequested
+// in the same transaction
+func (s *service) newLinkPending(ctx context.Context, identity identity.Identity, contact contact.Contact, requestURL string, client internal.Client, welcome bool) (*verification.Flow, error) {
+	if !isValidContact(contact, identity) {
+		return nil, internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", verification.ErrInvalidContact)
+	}
+	var created *verification.Flow
+	if err := s.tx.Transaction(ctx, func(ctx context.Context) error {
+		if existing := s.getExistingFlow(ctx, contact); existing != nil {
+			created = existing
+		} else {
+			newFlow, err := verification.NewLinkPending(requestURL, client, contact.ID, identity.ID)
+			if err != nil {
+				return err
+			}
+			c, err := s.r.Create(ctx, *newFlow)
+			if err != nil {
+				return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to create new verification flow")
+			}
+			created = c
+		}
+		if created.Status != verification.LinkPending {
+			return nil
+		}
+		return s.eb.Publish(ctx, event.VerificationRequested{
+			Identity: identity,
+			Contact:  contact,
+			Flow:     *created,
+			Welcome:  welcome,
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
@@ -0,0 +1,24 @@
+package subscriber
+
+import (
+	"context"
+
+	"github.com/RagOfJoes/mylo/event"
+	"github.com/RagOfJoes/mylo/flow/verification"
+)
+
+// Register subscribes the handlers that start verification flows in response to other events
+func Register(bus event.Bus, s verification.Service) {
+	// Welcome new identities by verifying their first unverified contact
+	bus.Subscribe(event.TopicIdentityRegistered, func(ctx context.Context, e event.Event) error {
+		ev := e.(event.IdentityRegistered)
+		for _, c := range ev.Identity.Contacts {
+			if c.Verified {
+				continue
+			}
+			_, err := s.NewWelcome(ctx, ev.Identity, c, ev.RequestURL, ev.Client)
+			return err
+		}
+		return nil
+	})
+}
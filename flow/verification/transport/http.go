@@ -2,11 +2,9 @@ package transport
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"time"
 
-	"github.com/RagOfJoes/mylo/email"
 	"github.com/RagOfJoes/mylo/flow/verification"
 	"github.com/RagOfJoes/mylo/internal"
 	"github.com/RagOfJoes/mylo/internal/config"
@@ -18,22 +16,20 @@ import (
 )
 
 type Http struct {
-	e  email.Client
 	sh sessionHttp.Http
 	s  verification.Service
 }
 
-func NewVerificationHttp(e email.Client, sh sessionHttp.Http, s verification.Service, r *gin.Engine) {
+func NewVerificationHttp(sh sessionHttp.Http, s verification.Service, r *gin.Engine) {
 	cfg := config.Get()
 	h := &Http{
-		e:  e,
 		sh: sh,
 		s:  s,
 	}
 
 	group := r.Group(fmt.Sprintf("/%s", cfg.Verification.URL))
 	{
-		group.GET("/:contact_id", h.initFlow())
+		group.GET("/:contact_id", sh.Protected(), h.initFlow())
 		group.GET("/retrieve/:id", h.getFlow())
 		group.POST("/:id", h.verifyFlow())
 	}
@@ -63,7 +59,7 @@ func (h *Http) initFlow() gin.HandlerFunc {
 		requestURL := transport.RequestURL(c.Request)
 		halfLife := sess.ExpiresAt.Sub(*sess.AuthenticatedAt) / 2
 		if time.Since(*sess.AuthenticatedAt) >= halfLife {
-			newFlow, err := h.s.NewSessionWarn(ctx, *sess.Identity, foundContact, requestURL)
+			newFlow, err := h.s.NewSessionWarn(ctx, *sess.Identity, foundContact, requestURL, transport.Client(c.Request))
 			if err != nil {
 				c.Error(err)
 				return
@@ -75,21 +71,12 @@ func (h *Http) initFlow() gin.HandlerFunc {
 			return
 		}
 
-		newFlow, err := h.s.NewDefault(ctx, *sess.Identity, foundContact, requestURL)
+		newFlow, err := h.s.NewDefault(ctx, *sess.Identity, foundContact, requestURL, transport.Client(c.Request))
 		if err != nil {
 			c.Error(err)
 			return
 		}
 
-		// Send verification email in the background
-		// TODO: Look to add some dependency for callbacks on certain events
-		go func(identity identity.Identity, flow verification.Flow, contact contact.Contact) {
-			// TODO: Capture error here
-			if err := h.sendEmail(identity, flow, contact); err != nil {
-				log.Print("Failed to send verification email: ", err)
-			}
-		}(*sess.Identity, *newFlow, foundContact)
-
 		c.JSON(http.StatusOK, transport.HttpResponse{
 			Success: true,
 			Payload: newFlow,
@@ -154,24 +141,6 @@ func (h *Http) verifyFlow() gin.HandlerFunc {
 				return
 			}
 
-			// If status was updated then send email in the background
-			go func(i identity.Identity, f verification.Flow) {
-				if submittedFlow.Status == verification.LinkPending {
-					var foundContact contact.Contact
-					if got := getContact(*sess.Identity, flow.ContactID.String()); got != nil {
-						foundContact = *got
-					} else {
-						// TODO: Capture error here
-						return
-					}
-
-					// TODO: Capture error here
-					if err := h.sendEmail(i, f, foundContact); err != nil {
-						log.Print("Failed to send verification email: ", err)
-					}
-				}
-			}(*sess.Identity, *submittedFlow)
-
 			c.JSON(http.StatusOK, transport.HttpResponse{
 				Success: true,
 				Payload: submittedFlow,
@@ -203,9 +172,3 @@ func getContact(identity identity.Identity, contactID string) *contact.Contact {
 	}
 	return foundContact
 }
-
-func (h *Http) sendEmail(identity identity.Identity, flow verification.Flow, contact contact.Contact) error {
-	cfg := config.Get()
-	url := fmt.Sprintf("%s/%s/%s", cfg.Server.URL, cfg.Verification.URL, flow.VerifyID)
-	return h.e.SendVerification(contact.Value, identity, url)
-}
@@ -42,6 +42,8 @@ type Flow struct {
 	// relevant data from urls path or query. This can also be used to find locate
 	// or security issues.
 	RequestURL string `json:"-" gorm:"not null" validate:"required"`
+	// Client defines the client that initiated the flow
+	Client internal.Client `json:"-" gorm:"embedded;embeddedPrefix:client_"`
 	// Status defines the current state of the flow
 	Status Status `json:"status" gorm:"not null" validate:"required"`
 	// FlowID defines the unique identifier that user's will use to access the flow
@@ -81,18 +83,24 @@ type Repository interface {
 	Update(ctx context.Context, updateFlow Flow) (*Flow, error)
 	// Delete deletes a flow via ID
 	Delete(ctx context.Context, id uuid.UUID) error
+	// DeleteExpired deletes up to limit flows that either expired or were finished before the time provided and returns
+	// how many were deleted
+	DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error)
 }
 
 // Service defines the interface for service implementations
 type Service interface {
-	// NewDefault creates a new flow with a Status of LinkPending
-	NewDefault(ctx context.Context, identity identity.Identity, contact contact.Contact, requestURL string) (*Flow, error)
+	// NewDefault creates a new flow with a Status of LinkPending and publishes a VerificationRequested event
+	NewDefault(ctx context.Context, identity identity.Identity, contact contact.Contact, requestURL string, client internal.Client) (*Flow, error)
+	// NewWelcome creates a new flow with a Status of LinkPending and publishes a VerificationRequested event flagged as a welcome. This should be called when
+	// an identity has just been registered
+	NewWelcome(ctx context.Context, identity identity.Identity, contact contact.Contact, requestURL string, client internal.Client) (*Flow, error)
 	// NewSessionWarn creates a new flow with a Status of SessionWarn. This should be called when User's session
 	// has passed its half-life
-	NewSessionWarn(ctx context.Context, identity identity.Identity, contact contact.Contact, requestURL string) (*Flow, error)
+	NewSessionWarn(ctx context.Context, identity identity.Identity, contact contact.Contact, requestURL string, client internal.Client) (*Flow, error)
 	// Find does exactly that
 	Find(ctx context.Context, flowID string, identity identity.Identity) (*Flow, error)
-	// SubmitSessionWarn requires the `SessionWarn` status and the `SessionWarnPayload` to move the flow to the next step. On success, the transport should send an email to selected contact
+	// SubmitSessionWarn requires the `SessionWarn` status and the `SessionWarnPayload` to move the flow to the next step. On success, a VerificationRequested event is published for the selected contact
 	SubmitSessionWarn(ctx context.Context, flow Flow, identity identity.Identity, payload SessionWarnPayload) (*Flow, error)
 	// Verify either completes the flow or moves to next status
 	Verify(ctx context.Context, flow Flow, identity identity.Identity) (*Flow, error)
@@ -124,7 +132,7 @@ func PasswordForm(action string) form.Form {
 }
 
 // NewLinkPending creates a new flow with LinkPending status
-func NewLinkPending(requestURL string, contactID uuid.UUID, identityID uuid.UUID) (*Flow, error) {
+func NewLinkPending(requestURL string, client internal.Client, contactID uuid.UUID, identityID uuid.UUID) (*Flow, error) {
 	// Create new FlowID
 	flowID, err := nanoid.New()
 	if err != nil {
@@ -141,6 +149,7 @@ func NewLinkPending(requestURL string, contactID uuid.UUID, identityID uuid.UUID
 		FlowID:     flowID,
 		VerifyID:   verifyID,
 		RequestURL: requestURL,
+		Client:     client,
 		Status:     LinkPending,
 		ExpiresAt:  time.Now().Add(cfg.Verification.Lifetime),
 
@@ -151,8 +160,8 @@ func NewLinkPending(requestURL string, contactID uuid.UUID, identityID uuid.UUID
 }
 
 // NewSessionWarn creates a new flow with SessionWarn status
-func NewSessionWarn(requestURL string, contactID uuid.UUID, identityID uuid.UUID) (*Flow, error) {
-	newFlow, err := NewLinkPending(requestURL, contactID, identityID)
+func NewSessionWarn(requestURL string, client internal.Client, contactID uuid.UUID, identityID uuid.UUID) (*Flow, error) {
+	newFlow, err := NewLinkPending(requestURL, client, contactID, identityID)
 	if err != nil {
 		return nil, err
 	}
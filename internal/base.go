@@ -9,15 +9,39 @@ import (
 
 // Base defines the base model for domain objects
 type Base struct {
-	ID        uuid.UUID  `json:"id" gorm:"primaryKey;type:uuid;default:uuid_generate_v4()" validate:"required"`
+	ID        uuid.UUID  `json:"id" gorm:"primaryKey;type:uuid" validate:"required"`
 	CreatedAt time.Time  `json:"created_at" gorm:"index;not null;default:current_timestamp" validate:"required"`
 	UpdatedAt *time.Time `json:"updated_at,omitempty" gorm:"index;default:null"`
 }
 
 // BaseSoftDelete defines the base model with soft delete functionality for domain objects
 type BaseSoftDelete struct {
-	ID        uuid.UUID      `json:"id" gorm:"primaryKey;type:uuid;default:uuid_generate_v4()" validate:"required"`
+	ID        uuid.UUID      `json:"id" gorm:"primaryKey;type:uuid" validate:"required"`
 	CreatedAt time.Time      `json:"created_at" gorm:"index;not null;default:current_timestamp" validate:"required"`
 	UpdatedAt *time.Time     `json:"updated_at,omitempty" gorm:"index;default:null"`
 	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index;default:null"`
 }
+
+// BeforeCreate generates an ID for models that weren't given one since not every database can generate them
+func (b *Base) BeforeCreate(tx *gorm.DB) error {
+	return GenerateID(&b.ID)
+}
+
+// BeforeCreate generates an ID for models that weren't given one since not every database can generate them
+func (b *BaseSoftDelete) BeforeCreate(tx *gorm.DB) error {
+	return GenerateID(&b.ID)
+}
+
+// GenerateID sets id to a new UUID v4 if it hasn't been set already. Models that don't embed a base model can call
+// this from their own BeforeCreate hook
+func GenerateID(id *uuid.UUID) error {
+	if *id != uuid.Nil {
+		return nil
+	}
+	generated, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+	*id = generated
+	return nil
+}
@@ -0,0 +1,13 @@
+package internal
+
+// Client defines the metadata of the client that made a request. This can be used to find locate or security issues
+type Client struct {
+	// IP defines the ip address of the client
+	IP string `json:"ip" gorm:"size:64"`
+	// UserAgent defines the raw User-Agent header of the client
+	UserAgent string `json:"-" gorm:"size:512"`
+	// Device defines a human-readable summary of the client's device ie. Chrome on macOS
+	Device string `json:"device" gorm:"size:128"`
+	// Locale defines the client's preferred locale taken from the Accept-Language header ie. en-US
+	Locale string `json:"-" gorm:"size:35"`
+}
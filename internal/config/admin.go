@@ -0,0 +1,45 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+type AdminKey struct {
+	// Name identifies who the key was issued to
+	//
+	// Example: support-dashboard
+	Name string `validate:"required"`
+	// Key is sent in the `X-Api-Key` header or as a bearer token
+	Key string `validate:"required,min=32"`
+}
+
+type Admin struct {
+	// Port of the admin server. This must be different from Server.Port since the admin API runs on its own listener
+	//
+	// Default: 4434
+	Port int `validate:"required"`
+	// Host of the admin server. The admin API should never be publicly reachable
+	//
+	// Default: 127.0.0.1
+	Host string `validate:"required"`
+	// Keys that are allowed to access the admin API. The admin API is disabled when empty
+	Keys []AdminKey `validate:"dive"`
+}
+
+func setupAdmin(conf *Configuration) error {
+	if len(conf.Admin.Keys) == 0 {
+		return nil
+	}
+	if conf.Admin.Port == conf.Server.Port {
+		return errors.New("Admin port must be different from the server's port")
+	}
+	names := map[string]bool{}
+	for _, k := range conf.Admin.Keys {
+		if names[k.Name] {
+			return fmt.Errorf("Admin key name %s must be unique", k.Name)
+		}
+		names[k.Name] = true
+	}
+	return nil
+}
@@ -30,23 +30,34 @@ type Configuration struct {
 	//
 	//
 
+	OIDC         OIDC
 	Login        Login
 	Recovery     Recovery
+	Settings     Settings
 	Registration Registration
 	Verification Verification
 
 	// Essentials
 	//
 
+	Admin      Admin
+	Email      Email
+	OAuth2     OAuth2
 	Server     Server
+	Token      Token
 	Session    Session
+	Webhook    Webhook
+	Janitor    Janitor
 	Database   Database
 	Credential Credential
 
 	// 3rd party
 	//
 
-	SendGrid SendGrid
+	// SendGrid is only required when Email.Provider is sendgrid
+	SendGrid SendGrid `validate:"-"`
+	// Redis is only required when Session.Store is redis
+	Redis Redis `validate:"-"`
 }
 
 var c Configuration
@@ -62,10 +73,18 @@ func Setup(filename string, filetype string, filepath string) error {
 		//
 		//
 
-		Login: Login{
-			URL:      "login",
+		OIDC: OIDC{
+			URL:      "oidc",
 			Lifetime: time.Minute * 10,
 		},
+		Login: Login{
+			URL:             "login",
+			Lifetime:        time.Minute * 10,
+			MaxAttempts:     5,
+			LockoutDuration: time.Minute * 15,
+			MaxCodeAttempts: 3,
+			CodeInterval:    time.Minute,
+		},
 		Recovery: Recovery{
 			URL:      "recovery",
 			Lifetime: time.Minute * 10,
@@ -74,6 +93,11 @@ func Setup(filename string, filetype string, filepath string) error {
 			URL:      "registration",
 			Lifetime: time.Minute * 10,
 		},
+		Settings: Settings{
+			URL:                "settings",
+			Lifetime:           time.Minute * 10,
+			PrivilegedLifetime: time.Minute * 15,
+		},
 		Verification: Verification{
 			URL:      "verification",
 			Lifetime: time.Minute * 10,
@@ -83,9 +107,64 @@ func Setup(filename string, filetype string, filepath string) error {
 		//
 		//
 
+		Admin: Admin{
+			Port: 4434,
+			Host: "127.0.0.1",
+		},
+		Email: Email{
+			Provider:      SendGridProvider,
+			DefaultLocale: "en",
+			Outbox: Outbox{
+				Interval:    time.Second * 5,
+				BatchSize:   50,
+				MaxAttempts: 8,
+				Backoff:     time.Second * 30,
+				MaxBackoff:  time.Hour,
+				Lease:       time.Minute,
+			},
+			SMTP: SMTP{
+				Port:       587,
+				Encryption: SMTPStartTLS,
+			},
+		},
+		OAuth2: OAuth2{
+			Lifetime:             time.Minute * 10,
+			CodeLifetime:         time.Minute,
+			RefreshTokenLifetime: time.Hour * 720,
+		},
+		Token: Token{
+			Lifetime: time.Minute * 15,
+			Rotation: time.Hour * 720,
+		},
+		Webhook: Webhook{
+			Timeout:     time.Second * 10,
+			Interval:    time.Second * 5,
+			BatchSize:   50,
+			MaxAttempts: 10,
+			Backoff:     time.Second * 30,
+			MaxBackoff:  time.Hour * 6,
+			Lease:       time.Minute,
+		},
+		Janitor: Janitor{
+			Enabled:   true,
+			Interval:  time.Hour,
+			BatchSize: 1000,
+			Retention: Retention{
+				Logins:        time.Hour * 24,
+				Registrations: time.Hour * 24,
+				Recoveries:    time.Hour * 24,
+				Verifications: time.Hour * 24,
+				Settings:      time.Hour * 24,
+				OIDC:          time.Hour * 24,
+				Sessions:      time.Hour * 168,
+			},
+		},
 		Session: Session{
+			Store: GormStore,
 			// 2 hours
-			Lifetime: time.Hour * 336,
+			Lifetime:        time.Hour * 336,
+			SlidingInterval: time.Hour,
+			RequiredAAL:     "aal1",
 			Cookie: Cookie{
 				Path:     "",
 				Domain:   "",
@@ -123,6 +202,13 @@ func Setup(filename string, filetype string, filepath string) error {
 				HostsProxyHeaders: []string{"X-Forwarded-Hosts"},
 			},
 		},
+
+		// 3rd party
+		//
+
+		Redis: Redis{
+			Prefix: "mylo:",
+		},
 	}
 
 	viper.SetConfigName(filename)
@@ -144,6 +230,21 @@ func Setup(filename string, filetype string, filepath string) error {
 	if err := setupServer(&c); err != nil {
 		return err
 	}
+	if err := setupSession(&c); err != nil {
+		return err
+	}
+	if err := setupEmail(&c); err != nil {
+		return err
+	}
+	if err := setupWebhook(&c); err != nil {
+		return err
+	}
+	if err := setupAdmin(&c); err != nil {
+		return err
+	}
+	if err := setupToken(&c); err != nil {
+		return err
+	}
 	return nil
 }
 
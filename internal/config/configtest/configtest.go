@@ -0,0 +1,49 @@
+// Package configtest loads a configuration for tests
+package configtest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/spf13/viper"
+)
+
+// base is the least amount of configuration that passes validation
+const base = `
+name: mylo
+database:
+  driver: memory
+server:
+  url: localhost
+session:
+  lifetime: 2h
+  slidinginterval: 10m
+  cookie:
+    secrets: [abcdefghijklmnopqrstuvwxyz012345]
+email:
+  provider: sendgrid
+sendgrid:
+  apikey: test
+  sendername: mylo
+  senderemail: mylo@example.com
+`
+
+// Setup loads the base configuration with overrides applied on top. Overrides are keyed by their path, ie.
+// "login.maxattempts"
+func Setup(t testing.TB, overrides map[string]interface{}) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "mylo.yaml"), []byte(base), 0600); err != nil {
+		t.Fatal(err)
+	}
+	viper.Reset()
+	for key, value := range overrides {
+		viper.Set(key, value)
+	}
+	if err := config.Setup("mylo", "yaml", dir); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -6,23 +6,26 @@ type Database struct {
 	// Required configurations
 	//
 
-	// Driver defines the type of database.
-	Driver string `validate:"required,oneof='mysql' 'postgres'"`
-	// Name of the database.
-	Name string `validate:"required"`
-	// Username required to access database.
-	Username string `validate:"required"`
-	// Password required to access database.
-	Password string `validate:"required"`
-	// Host for the database.
-	Host string `validate:"required"`
-	// Port for the database.
-	Port int `validate:"required"`
+	// Driver defines the type of database. sqlite is meant for local development and tests while memory keeps
+	// everything in the process and loses it on shutdown, which is only meant for demos.
+	Driver string `validate:"required,oneof='mysql' 'postgres' 'sqlite' 'memory'"`
+	// Name of the database. For sqlite, this is the path to the database file or :memory: for an in-memory database.
+	// Not applicable to memory.
+	Name string `validate:"required_unless=Driver memory"`
+	// Username required to access database. Not applicable to sqlite or memory.
+	Username string `validate:"required_unless=Driver sqlite Driver memory"`
+	// Password required to access database. Not applicable to sqlite or memory.
+	Password string `validate:"required_unless=Driver sqlite Driver memory"`
+	// Host for the database. Not applicable to sqlite or memory.
+	Host string `validate:"required_unless=Driver sqlite Driver memory"`
+	// Port for the database. Not applicable to sqlite or memory.
+	Port int `validate:"required_unless=Driver sqlite Driver memory"`
 
 	// Optional configuration
 	//
 
-	// AutoMigrate will auto migrate models in startup.
+	// AutoMigrate applies pending migrations on startup. When disabled, mylo refuses to start until they've been applied
+	// with mylo migrate up. SQLite doesn't have versioned migrations so its tables are created from the models instead.
 	//
 	// Default: true
 	AutoMigrate bool
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal/validate"
+)
+
+// EmailProvider defines the backend that will be used to send emails
+type EmailProvider string
+
+const (
+	SendGridProvider EmailProvider = "sendgrid"
+	SMTPProvider     EmailProvider = "smtp"
+)
+
+// SMTPEncryption defines how the connection to the SMTP server will be secured
+type SMTPEncryption string
+
+const (
+	// SMTPNone sends everything in plaintext. This should only ever be used with local sinks ie. MailHog
+	SMTPNone SMTPEncryption = "none"
+	// SMTPStartTLS upgrades a plaintext connection with the STARTTLS command
+	SMTPStartTLS SMTPEncryption = "starttls"
+	// SMTPTLS uses implicit TLS from the start of the connection
+	SMTPTLS SMTPEncryption = "tls"
+)
+
+type SMTP struct {
+	// Host of the SMTP server
+	//
+	// Example: smtp.gmail.com
+	Host string `validate:"required"`
+	// Port of the SMTP server
+	//
+	// Default: 587
+	Port int `validate:"required"`
+	// Username used to authenticate with the SMTP server. If empty, authentication will be skipped
+	Username string
+	// Password used to authenticate with the SMTP server
+	Password string
+	// Encryption controls how the connection will be secured
+	//
+	// Default: starttls
+	Encryption SMTPEncryption `validate:"oneof='none' 'starttls' 'tls'"`
+	// InsecureSkipVerify disables verification of the server's certificate chain and host name
+	//
+	// Default: false
+	InsecureSkipVerify bool
+
+	SenderName  string `validate:"required"`
+	SenderEmail string `validate:"required,email"`
+}
+
+type Outbox struct {
+	// Interval is how often the outbox is checked for emails that are due
+	//
+	// Default: 5s
+	Interval time.Duration `validate:"required"`
+	// BatchSize is the maximum number of emails that are delivered per interval
+	//
+	// Default: 50
+	BatchSize int `validate:"min=1"`
+	// MaxAttempts is the number of times delivery is attempted before an email is dead-lettered
+	//
+	// Default: 8
+	MaxAttempts int `validate:"min=1"`
+	// Backoff is the delay before the first retry. The delay doubles after every failed attempt
+	//
+	// Default: 30s
+	Backoff time.Duration `validate:"required"`
+	// MaxBackoff caps the delay between retries
+	//
+	// Default: 1h
+	MaxBackoff time.Duration `validate:"required"`
+	// Lease is how long an email is hidden from other instances while it's being delivered
+	//
+	// Default: 1m
+	Lease time.Duration `validate:"required"`
+}
+
+type Email struct {
+	// Provider selects the backend that will be used to send emails
+	//
+	// Default: sendgrid
+	Provider EmailProvider `validate:"oneof='sendgrid' 'smtp'"`
+	// Templates is an optional directory of templates that take precedence over the ones embedded in the binary.
+	// Templates are organized by locale ie. `<Templates>/es/welcome.subject.tmpl` and any file that isn't found on disk
+	// falls back to the embedded default
+	Templates string
+	// DefaultLocale is the locale used when neither the identity nor the client has a locale with templates
+	//
+	// Default: en
+	DefaultLocale string `validate:"required"`
+	// Outbox configures the background delivery of emails
+	Outbox Outbox
+	// SMTP is only required when Provider is smtp
+	SMTP SMTP `validate:"-"`
+}
+
+func setupEmail(conf *Configuration) error {
+	// Only validate the configuration of the selected provider
+	switch conf.Email.Provider {
+	case SMTPProvider:
+		return validate.Check(conf.Email.SMTP)
+	default:
+		return validate.Check(conf.SendGrid)
+	}
+}
@@ -11,6 +11,31 @@ type Login struct {
 	//
 	// Default: 10m
 	Lifetime time.Duration
+	// MaxAttempts is the number of consecutive failed login attempts before an identity is locked. If 0, identities will never be locked
+	//
+	// Default: 5
+	MaxAttempts int `validate:"min=0"`
+	// LockoutDuration is how long an identity will remain locked for. If 0, the identity will remain locked until the User recovers their account
+	//
+	// Default: 15m
+	LockoutDuration time.Duration
+	// Passwordless enables logging in with a one-time code or magic link that'll be sent to the User's email
+	//
+	// Default: false
+	Passwordless bool
+	// MaxCodeAttempts is the number of times a one-time code can be attempted before the flow is no longer valid
+	//
+	// Default: 3
+	MaxCodeAttempts int `validate:"min=1"`
+	// CodeInterval is the minimum amount of time between one-time codes being sent to the same User
+	//
+	// Default: 1m
+	CodeInterval time.Duration
+	// ReturnURL is where the User will be redirected to after confirming a magic link. If a second factor is required, the
+	// flow's ID is appended as the `flow_id` query param. If empty, the session or flow will be returned as JSON
+	//
+	// Example: https://example.com/dashboard
+	ReturnURL string `validate:"omitempty,url"`
 }
 
 type Registration struct {
@@ -45,3 +70,61 @@ type Recovery struct {
 	// Default: 10m
 	Lifetime time.Duration
 }
+
+type Settings struct {
+	// URL for flow
+	//
+	// Default: settings
+	URL string
+	// Lifetime of flow
+	//
+	// Default: 10m
+	Lifetime time.Duration
+	// PrivilegedLifetime is how long after authenticating a session is allowed to update settings without
+	// re-entering its password
+	//
+	// Default: 15m
+	PrivilegedLifetime time.Duration
+}
+
+type OIDCProvider struct {
+	// ID is the unique identifier of the provider. This is used in URLs and stored alongside credentials
+	//
+	// Example: google
+	ID string `validate:"required,alphanum"`
+	// Label is the text that'll be rendered for the provider
+	//
+	// Default: Sign in with {ID}
+	Label string
+	// Issuer is the OpenID Connect issuer URL that will be used for discovery
+	//
+	// Example: https://accounts.google.com
+	Issuer string `validate:"required,url"`
+	// ClientID provided by the provider
+	ClientID string `validate:"required"`
+	// ClientSecret provided by the provider
+	ClientSecret string `validate:"required"`
+	// Scopes that'll be requested from the provider
+	//
+	// Default: openid, email, profile
+	Scopes []string
+}
+
+type OIDC struct {
+	// URL for flow
+	//
+	// Default: oidc
+	URL string
+	// Lifetime of flow
+	//
+	// Default: 10m
+	Lifetime time.Duration
+	// ReturnURL is where the User will be redirected to after a successful callback. If the User still needs to pass
+	// second factor, the login flow's ID will be added as the flow_id query param. If empty, the session or login flow
+	// will be returned as JSON
+	//
+	// Example: https://example.com/dashboard
+	ReturnURL string
+	// Providers that Users will be able to authenticate with
+	Providers []OIDCProvider `validate:"dive"`
+}
@@ -0,0 +1,52 @@
+package config
+
+import "time"
+
+type Retention struct {
+	// Logins is how long login flows are kept after they've expired or been completed
+	//
+	// Default: 24h
+	Logins time.Duration `validate:"min=0"`
+	// Registrations is how long registration flows are kept after they've expired or been completed
+	//
+	// Default: 24h
+	Registrations time.Duration `validate:"min=0"`
+	// Recoveries is how long recovery flows are kept after they've expired, failed or been completed
+	//
+	// Default: 24h
+	Recoveries time.Duration `validate:"min=0"`
+	// Verifications is how long verification flows are kept after they've expired or been completed
+	//
+	// Default: 24h
+	Verifications time.Duration `validate:"min=0"`
+	// Settings is how long settings flows are kept after they've expired
+	//
+	// Default: 24h
+	Settings time.Duration `validate:"min=0"`
+	// OIDC is how long oidc flows are kept after they've expired or been completed
+	//
+	// Default: 24h
+	OIDC time.Duration `validate:"min=0"`
+	// Sessions is how long sessions are kept after they've expired. Sessions in Redis are removed as soon as they expire
+	//
+	// Default: 168h
+	Sessions time.Duration `validate:"min=0"`
+}
+
+type Janitor struct {
+	// Enabled purges expired rows in the background while the server is running. Disable this when `mylo janitor` is
+	// scheduled some other way ie. a cron job
+	//
+	// Default: true
+	Enabled bool
+	// Interval is how often expired rows are purged
+	//
+	// Default: 1h
+	Interval time.Duration `validate:"required"`
+	// BatchSize is the maximum number of rows that are deleted at a time. Smaller batches hold locks for less time
+	//
+	// Default: 1000
+	BatchSize int `validate:"min=1"`
+	// Retention defines how long rows are kept for, per table, once they're no longer usable
+	Retention Retention
+}
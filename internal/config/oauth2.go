@@ -0,0 +1,34 @@
+package config
+
+import "time"
+
+type OAuth2 struct {
+	// LoginURL is where Users that aren't authenticated are sent to login. The authorization request is appended as the
+	// `return_to` query param, which the UI should redirect back to once the login flow has completed. If empty, clients
+	// will receive a `login_required` error instead
+	//
+	// Example: https://example.com/login
+	LoginURL string `validate:"omitempty,url"`
+	// ConsentURL is where Users are sent to grant a client access to their account. The `consent_challenge` query param
+	// is appended. If empty, clients that require consent will receive a `consent_required` error instead
+	//
+	// Example: https://example.com/consent
+	ConsentURL string `validate:"omitempty,url"`
+	// Lifetime of an authorization request. This includes the time it takes to login and consent
+	//
+	// Default: 10m
+	Lifetime time.Duration `validate:"required"`
+	// CodeLifetime is how long an authorization code can be exchanged for
+	//
+	// Default: 1m
+	CodeLifetime time.Duration `validate:"required"`
+	// Audience is the `aud` claim of access tokens issued to clients, ie. the resource servers that clients call. This
+	// must not overlap with Token.Audience so that a client's token can't be used against first-party APIs
+	//
+	// Default: the client's ID
+	Audience []string
+	// RefreshTokenLifetime is how long a refresh token can be used for. Refresh tokens are rotated on every use
+	//
+	// Default: 720h
+	RefreshTokenLifetime time.Duration `validate:"required"`
+}
@@ -0,0 +1,22 @@
+package config
+
+type Redis struct {
+	// Addr of the Redis server in the form of host:port
+	Addr string `validate:"required,hostname_port"`
+	// Username required to access Redis. Only applicable when Redis has ACLs setup
+	Username string
+	// Password required to access Redis
+	Password string
+	// DB to select after connecting
+	//
+	// Default: 0
+	DB int
+	// TLS will connect to Redis over TLS
+	//
+	// Default: false
+	TLS bool
+	// Prefix is prepended to every key so that a Redis server can be shared
+	//
+	// Default: mylo:
+	Prefix string
+}
@@ -56,6 +56,12 @@ type Server struct {
 	// Middleware configurations
 	//
 
+	// TrustedProxies are the IPs or CIDRs of the proxies in front of the server. X-Forwarded-For is only honored when a
+	// request comes from one of them, otherwise the peer's address is used as the client's IP.
+	//
+	// Example: ["10.0.0.0/8", "127.0.0.1"]
+	// Default: []
+	TrustedProxies []string `validate:"dive,cidr|ip"`
 	// RPS is rate per second. If 0, RateLimiterMiddleware will be disabled.
 	//
 	// Default: 100
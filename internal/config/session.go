@@ -1,8 +1,21 @@
 package config
 
 import (
+	"errors"
 	"net/http"
 	"time"
+
+	"github.com/RagOfJoes/mylo/internal/validate"
+)
+
+// SessionStore defines the backend that sessions will be stored in
+type SessionStore string
+
+const (
+	// GormStore stores sessions in the database alongside everything else
+	GormStore SessionStore = "gorm"
+	// RedisStore stores sessions in Redis and lets Redis expire them
+	RedisStore SessionStore = "redis"
 )
 
 // Cookie option fields
@@ -41,9 +54,48 @@ type Cookie struct {
 
 // Session config
 type Session struct {
-	// Lifetime controls how long a session can be valid for
+	// Store defines where sessions are stored. When redis, the Redis configuration is required
+	//
+	// Default: gorm
+	Store SessionStore `validate:"required,oneof='gorm' 'redis'"`
+	// Lifetime controls how long a session can be valid for. When Sliding is enabled this is how long a session can be
+	// idle for instead
 	//
 	// Default: 336h (2 weeks)
 	Lifetime time.Duration `validate:"required"`
-	Cookie   Cookie
+	// Sliding extends the expiry of a session whenever it's used
+	//
+	// Default: false
+	Sliding bool
+	// SlidingInterval is the minimum amount that an expiry must move before it's written. This keeps an active session
+	// from being written on every request
+	//
+	// Default: 1h
+	SlidingInterval time.Duration
+	// MaxLifetime caps how long a session can be valid for since it was authenticated, no matter how many times it's been
+	// extended. Must be longer than Lifetime. If 0, sessions can be extended indefinitely
+	//
+	// Default: 0
+	MaxLifetime time.Duration
+	// RequiredAAL is the minimum assurance level that protected resources ie. `/me` and the verification flow require.
+	// When aal2, identities without a second factor won't be able to access them until they've set one up
+	//
+	// Default: aal1
+	RequiredAAL string `validate:"required,oneof='aal1' 'aal2'"`
+	// MaxAuthenticationAge is how recently a session must have authenticated to access protected resources. Older
+	// sessions have to re-authenticate with a refresh login flow. If 0, any authenticated session is accepted
+	//
+	// Default: 0
+	MaxAuthenticationAge time.Duration
+	Cookie               Cookie
+}
+
+func setupSession(conf *Configuration) error {
+	if conf.Session.MaxLifetime != 0 && conf.Session.MaxLifetime < conf.Session.Lifetime {
+		return errors.New("Session max lifetime must be longer than the session lifetime")
+	}
+	if conf.Session.Store == RedisStore {
+		return validate.Check(conf.Redis)
+	}
+	return nil
 }
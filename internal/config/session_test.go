@@ -0,0 +1,25 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/internal/config/configtest"
+)
+
+func TestSessionMaxLifetime(t *testing.T) {
+	// Sessions that live longer than a month must still be valid when MaxLifetime is left unset
+	configtest.Setup(t, map[string]interface{}{"session.lifetime": "2160h"})
+	if max := config.Get().Session.MaxLifetime; max != 0 {
+		t.Errorf("expected MaxLifetime to be disabled by default, got %s", max)
+	}
+
+	configtest.Setup(t, map[string]interface{}{
+		"session.lifetime":    "2h",
+		"session.maxlifetime": "24h",
+	})
+	if max := config.Get().Session.MaxLifetime; max != 24*time.Hour {
+		t.Errorf("expected MaxLifetime of 24h, got %s", max)
+	}
+}
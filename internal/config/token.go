@@ -0,0 +1,47 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type Token struct {
+	// Issuer is the `iss` claim of every token. Verifiers should check this
+	//
+	// Default: Server.URL
+	Issuer string
+	// Audience is the `aud` claim of every access token issued to a session. Access tokens that are issued to OAuth2
+	// clients are signed with the same keys but have their own audience, a `client_id` claim and an `at+jwt` typ header.
+	// APIs that accept these tokens must reject tokens that carry a `client_id` or any other audience, since ID tokens
+	// share the `JWT` typ header and only differ by their audience
+	//
+	// Example: [api.example.com]
+	Audience []string
+	// Lifetime of an access token. Tokens can't be revoked so this should be kept short
+	//
+	// Default: 15m
+	Lifetime time.Duration `validate:"required"`
+	// Rotation is how long a signing key is used before a new one is generated. Retired keys are still published until
+	// every token they've signed has expired
+	//
+	// Default: 720h
+	Rotation time.Duration `validate:"required"`
+}
+
+func setupToken(conf *Configuration) error {
+	if conf.Token.Issuer == "" {
+		conf.Token.Issuer = conf.Server.URL
+	}
+	if conf.Token.Rotation <= conf.Token.Lifetime {
+		return errors.New("Token rotation must be longer than the token lifetime")
+	}
+	for _, a := range conf.OAuth2.Audience {
+		for _, b := range conf.Token.Audience {
+			if a == b {
+				return fmt.Errorf("OAuth2 audience %s must not be a token audience", a)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type WebhookEndpoint struct {
+	// Name uniquely identifies the endpoint. This is recorded on every delivery so it should stay the same when the URL
+	// or secret is rotated
+	//
+	// Example: billing
+	Name string `validate:"required,max=64"`
+	// URL that payloads will be POSTed to
+	//
+	// Example: https://billing.example.com/hooks/mylo
+	URL string `validate:"required,url"`
+	// Secret is used to sign every payload with HMAC-SHA256. The signature is sent in the `X-Mylo-Signature` header
+	Secret string `validate:"required,min=16"`
+	// Events the endpoint is subscribed to. If empty, the endpoint will receive every event
+	//
+	// Example: [identity.registered, identity.deleted]
+	Events []string `validate:"dive,oneof='identity.registered' 'contact.verified' 'password.changed' 'identity.deleted'"`
+}
+
+type Webhook struct {
+	// Endpoints that'll receive events. Webhooks are disabled when empty
+	Endpoints []WebhookEndpoint `validate:"dive"`
+	// Timeout is how long to wait for an endpoint to respond before the attempt is considered failed
+	//
+	// Default: 10s
+	Timeout time.Duration `validate:"required"`
+	// Interval is how often the delivery log is checked for deliveries that are due
+	//
+	// Default: 5s
+	Interval time.Duration `validate:"required"`
+	// BatchSize is the maximum number of deliveries that are attempted per interval
+	//
+	// Default: 50
+	BatchSize int `validate:"min=1"`
+	// MaxAttempts is the number of times delivery is attempted before it's dead-lettered
+	//
+	// Default: 10
+	MaxAttempts int `validate:"min=1"`
+	// Backoff is the delay before the first retry. The delay doubles after every failed attempt
+	//
+	// Default: 30s
+	Backoff time.Duration `validate:"required"`
+	// MaxBackoff caps the delay between retries
+	//
+	// Default: 6h
+	MaxBackoff time.Duration `validate:"required"`
+	// Lease is how long a delivery is hidden from other instances while it's being attempted. This should be longer than
+	// Timeout
+	//
+	// Default: 1m
+	Lease time.Duration `validate:"required"`
+}
+
+func setupWebhook(conf *Configuration) error {
+	names := map[string]bool{}
+	for _, e := range conf.Webhook.Endpoints {
+		if names[e.Name] {
+			return fmt.Errorf("Webhook endpoint name %s must be unique", e.Name)
+		}
+		names[e.Name] = true
+	}
+	if conf.Webhook.Lease <= conf.Webhook.Timeout {
+		return errors.New("Webhook lease must be longer than the timeout")
+	}
+	return nil
+}
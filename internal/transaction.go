@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"context"
+	"sync"
+)
+
+// Transactor runs multiple repository calls atomically. Repositories that support it will pick up the transaction from
+// the context that is passed to fn
+type Transactor interface {
+	// Transaction runs fn in a transaction that is committed when fn returns nil and rolled back otherwise. Calls that are
+	// nested within another transaction will join the outer one
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// commitHooksKey is the context key that holds the functions waiting on a transaction to be committed
+type commitHooksKey struct{}
+
+type commitHooks struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+// WithCommitHooks returns a context that collects the functions passed to AfterCommit along with a function that runs
+// them. Transactor implementations should call run once, and only if, the transaction has been committed
+func WithCommitHooks(ctx context.Context) (context.Context, func()) {
+	hooks := &commitHooks{}
+	run := func() {
+		hooks.mu.Lock()
+		fns := hooks.fns
+		hooks.fns = nil
+		hooks.mu.Unlock()
+		for _, fn := range fns {
+			fn()
+		}
+	}
+	return context.WithValue(ctx, commitHooksKey{}, hooks), run
+}
+
+// AfterCommit defers fn until the transaction that ctx is a part of has been committed. If the transaction is rolled
+// back then fn is never run. If ctx isn't a part of a transaction then fn is run immediately
+func AfterCommit(ctx context.Context, fn func()) {
+	hooks, ok := ctx.Value(commitHooksKey{}).(*commitHooks)
+	if !ok {
+		fn()
+		return
+	}
+	hooks.mu.Lock()
+	hooks.fns = append(hooks.fns, fn)
+	hooks.mu.Unlock()
+}
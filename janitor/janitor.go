@@ -0,0 +1,36 @@
+package janitor
+
+import (
+	"context"
+	"time"
+)
+
+// Repository is implemented by every repository that has rows that expire ie. flows and sessions
+type Repository interface {
+	// DeleteExpired deletes up to limit rows that expired before the time provided and returns how many were deleted
+	DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// Table defines a table that the janitor keeps clean
+type Table struct {
+	// Name is used to report how many rows were purged ie. logins
+	Name string
+	// Retention is how long rows are kept after they've expired
+	Retention time.Duration
+	// Repository deletes the expired rows
+	Repository Repository
+}
+
+// Result defines how many rows were purged from a table
+type Result struct {
+	Table   string `json:"table"`
+	Deleted int64  `json:"deleted"`
+}
+
+type Service interface {
+	// Purge deletes every row that's past its retention, a batch at a time, and returns how many rows were deleted from
+	// each table. Tables that were purged before an error occurred are still returned
+	Purge(ctx context.Context) ([]Result, error)
+	// Run purges on the configured interval until ctx is done
+	Run(ctx context.Context)
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/janitor"
+)
+
+// Metrics are published with expvar so they can be scraped from /debug/vars on the admin server
+var (
+	// purged counts the rows that have been purged from each table
+	purged = expvar.NewMap("janitor_rows_purged")
+	// runs counts how many times Purge has finished, successfully or not
+	runs = expvar.NewInt("janitor_runs")
+	// failures counts how many times Purge has failed
+	failures = expvar.NewInt("janitor_failures")
+)
+
+type service struct {
+	cfg    config.Janitor
+	tables []janitor.Table
+}
+
+// NewJanitorService creates a service that purges expired rows from tables
+func NewJanitorService(cfg config.Janitor, tables ...janitor.Table) janitor.Service {
+	return &service{
+		cfg:    cfg,
+		tables: tables,
+	}
+}
+
+func (s *service) Purge(ctx context.Context) ([]janitor.Result, error) {
+	defer runs.Add(1)
+
+	now := time.Now()
+	results := make([]janitor.Result, 0, len(s.tables))
+	for _, t := range s.tables {
+		result := janitor.Result{Table: t.Name}
+		before := now.Add(-t.Retention)
+		// Keep deleting until a partial batch is returned so that no single delete holds a lock for long
+		for {
+			deleted, err := t.Repository.DeleteExpired(ctx, before, s.cfg.BatchSize)
+			result.Deleted += deleted
+			purged.Add(t.Name, deleted)
+			if err != nil {
+				failures.Add(1)
+				return append(results, result), internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to purge expired %s", t.Name)
+			}
+			if deleted < int64(s.cfg.BatchSize) {
+				break
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (s *service) Run(ctx context.Context) {
+	if !s.cfg.Enabled {
+		return
+	}
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		results, err := s.Purge(ctx)
+		for _, r := range results {
+			if r.Deleted > 0 {
+				log.Printf("Purged %d expired %s", r.Deleted, r.Table)
+			}
+		}
+		if err != nil {
+			log.Print(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
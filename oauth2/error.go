@@ -0,0 +1,90 @@
+package oauth2
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Error codes defined by RFC 6749 and OpenID Connect
+const (
+	CodeInvalidRequest          = "invalid_request"
+	CodeInvalidClient           = "invalid_client"
+	CodeInvalidGrant            = "invalid_grant"
+	CodeInvalidScope            = "invalid_scope"
+	CodeInvalidToken            = "invalid_token"
+	CodeInsufficientScope       = "insufficient_scope"
+	CodeUnauthorizedClient      = "unauthorized_client"
+	CodeUnsupportedGrantType    = "unsupported_grant_type"
+	CodeUnsupportedResponseType = "unsupported_response_type"
+	CodeAccessDenied            = "access_denied"
+	CodeLoginRequired           = "login_required"
+	CodeConsentRequired         = "consent_required"
+)
+
+// Error is a protocol error that's returned to clients as is, rather than through ErrorMiddleware, since clients
+// expect the format defined by RFC 6749
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// NewError creates a protocol error
+func NewError(code string, description string) *Error {
+	return &Error{
+		Code:        code,
+		Description: description,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// Status retrieves the HTTP status that the error should be responded with
+func (e *Error) Status() int {
+	switch e.Code {
+	case CodeInvalidClient, CodeInvalidToken:
+		return http.StatusUnauthorized
+	case CodeInsufficientScope:
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// RedirectURL builds the url that sends the error back to the client's redirect uri
+func (e *Error) RedirectURL(redirectURI string, state string) string {
+	params := url.Values{}
+	params.Set("error", e.Code)
+	if e.Description != "" {
+		params.Set("error_description", e.Description)
+	}
+	if state != "" {
+		params.Set("state", state)
+	}
+	return WithQuery(redirectURI, params)
+}
+
+// WithQuery appends params to the query of rawURL, keeping any params that are already there
+func WithQuery(rawURL string, params url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		sep := "?"
+		if strings.Contains(rawURL, "?") {
+			sep = "&"
+		}
+		return rawURL + sep + params.Encode()
+	}
+	query := u.Query()
+	for k, vs := range params {
+		for _, v := range vs {
+			query.Add(k, v)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
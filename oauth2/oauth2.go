@@ -0,0 +1,391 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/session"
+	"github.com/gofrs/uuid"
+)
+
+var (
+	ErrClientNotFound        = errors.New("Client not found")
+	ErrInvalidClientID       = errors.New("Invalid client id provided")
+	ErrInvalidClientPayload  = errors.New("Invalid client payload provided")
+	ErrInvalidRedirectURI    = errors.New("Redirect uri has not been registered for this client")
+	ErrInvalidAuthorization  = errors.New("Invalid authorization request provided")
+	ErrInvalidConsentPayload = errors.New("Invalid consent payload provided")
+	ErrInvalidConsentRequest = errors.New("Invalid or expired consent request")
+	ErrInvalidTokenPayload   = errors.New("Invalid token request provided")
+)
+
+// Scopes that clients can request
+const (
+	ScopeOpenID        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopeOfflineAccess = "offline_access"
+)
+
+// SupportedScopes lists every scope that clients can be registered with
+var SupportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeOfflineAccess}
+
+// Grant types
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// CodeChallengeS256 is the only PKCE method that's supported
+const CodeChallengeS256 = "S256"
+
+// Status defines the current state of an authorization request
+type Status string
+
+const (
+	// Pending occurs when the User has yet to consent
+	Pending Status = "Pending"
+	// Accepted occurs when a code has been issued for the request
+	Accepted Status = "Accepted"
+	// Rejected occurs when the User has denied the client access
+	Rejected Status = "Rejected"
+	// Exchanged occurs when the code has been exchanged for tokens. A request can only be exchanged once
+	Exchanged Status = "Exchanged"
+)
+
+// Client defines an application that Users can authorize to access their account
+type Client struct {
+	internal.Base
+	// Name is shown to Users when they're asked to consent
+	Name string `json:"name" gorm:"size:128;not null" validate:"required,max=128"`
+	// Secret is the hashed client secret. Public clients, ie. single page and native apps, don't have one
+	Secret string `json:"-" gorm:"default:null"`
+	// Public clients can't keep a secret so they're only authenticated with PKCE
+	Public bool `json:"public" gorm:"not null;default:false"`
+	// RedirectURIs are the only uris that Users can be redirected back to. These are matched exactly
+	RedirectURIs Strings `json:"redirect_uris" gorm:"type:json;not null" validate:"required,min=1,dive,url"`
+	// Scopes are the scopes that the client is allowed to request
+	Scopes Strings `json:"scopes" gorm:"type:json;not null" validate:"dive,oneof='openid' 'profile' 'email' 'offline_access'"`
+	// SkipConsent should only be set for first party clients. Users won't be asked to consent to them
+	SkipConsent bool `json:"skip_consent" gorm:"not null;default:false"`
+}
+
+// Consent defines the scopes that a User has granted a client
+type Consent struct {
+	internal.Base
+	IdentityID uuid.UUID `json:"-" gorm:"type:uuid;not null;uniqueIndex:idx_consent_identity_client"`
+	ClientID   uuid.UUID `json:"client_id" gorm:"type:uuid;not null;uniqueIndex:idx_consent_identity_client"`
+	// Scope is the space delimited list of scopes that were granted
+	Scope string `json:"scope" gorm:"size:512;not null"`
+}
+
+// Request defines an authorization request. The request is created once the User has logged in and lives on as the
+// authorization code after the User has consented
+type Request struct {
+	internal.Base
+	// Challenge identifies the request while the User is consenting
+	Challenge string `json:"-" gorm:"not null;uniqueIndex" validate:"required"`
+	// Status defines the current state of the request
+	Status Status `json:"-" gorm:"not null" validate:"required"`
+	// Code defines the hashed authorization code. This'll only be applicable once the request has been accepted
+	Code *string `json:"-" gorm:"uniqueIndex;default:null"`
+	// ExpiresAt defines when the request, or its code, can no longer be used
+	ExpiresAt time.Time `json:"-" gorm:"index;not null" validate:"required"`
+
+	ClientID   uuid.UUID `json:"-" gorm:"type:uuid;index;not null" validate:"required"`
+	IdentityID uuid.UUID `json:"-" gorm:"type:uuid;index;not null" validate:"required"`
+	SessionID  uuid.UUID `json:"-" gorm:"type:uuid;not null" validate:"required"`
+	// AuthTime defines when the session was authenticated
+	AuthTime time.Time `json:"-" gorm:"not null" validate:"required"`
+	// AMR defines the credential methods that were used to authenticate the session
+	AMR Strings `json:"-" gorm:"type:json;default:null"`
+
+	RedirectURI   string `json:"-" gorm:"size:2048;not null" validate:"required"`
+	Scope         string `json:"-" gorm:"size:512"`
+	State         string `json:"-" gorm:"size:1024"`
+	Nonce         string `json:"-" gorm:"size:1024"`
+	CodeChallenge string `json:"-" gorm:"size:128;not null" validate:"required"`
+}
+
+// RefreshToken defines a refresh token that was issued to a client. Refresh tokens are rotated on every use and every
+// token that descended from the same authorization request shares a family so that a stolen token that's replayed
+// revokes all of them
+type RefreshToken struct {
+	internal.Base
+	// Token defines the hashed refresh token
+	Token    string    `json:"-" gorm:"not null;uniqueIndex" validate:"required"`
+	FamilyID uuid.UUID `json:"-" gorm:"type:uuid;index;not null" validate:"required"`
+
+	ClientID   uuid.UUID `json:"-" gorm:"type:uuid;index;not null" validate:"required"`
+	IdentityID uuid.UUID `json:"-" gorm:"type:uuid;index;not null" validate:"required"`
+	SessionID  uuid.UUID `json:"-" gorm:"type:uuid;not null" validate:"required"`
+	AuthTime   time.Time `json:"-" gorm:"not null" validate:"required"`
+	AMR        Strings   `json:"-" gorm:"type:json;default:null"`
+	Scope      string    `json:"-" gorm:"size:512"`
+
+	ExpiresAt time.Time  `json:"-" gorm:"index;not null" validate:"required"`
+	RevokedAt *time.Time `json:"-" gorm:"index;default:null"`
+}
+
+// ClientPayload defines the data required to register a client
+type ClientPayload struct {
+	Name         string   `json:"name" form:"name" binding:"required" validate:"required,max=128"`
+	Public       bool     `json:"public" form:"public"`
+	RedirectURIs []string `json:"redirect_uris" form:"redirect_uris" binding:"required" validate:"required,min=1,dive,url"`
+	Scopes       []string `json:"scopes" form:"scopes" validate:"dive,oneof='openid' 'profile' 'email' 'offline_access'"`
+	SkipConsent  bool     `json:"skip_consent" form:"skip_consent"`
+}
+
+// RegisteredClient is returned once when a client is registered. This is the only time that the secret is available
+type RegisteredClient struct {
+	Client
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// AuthorizePayload defines the query params of an authorization request
+type AuthorizePayload struct {
+	ResponseType        string `form:"response_type"`
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	Nonce               string `form:"nonce"`
+	Prompt              string `form:"prompt"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+}
+
+// ConsentRequest describes what the User is being asked to consent to
+type ConsentRequest struct {
+	Challenge  string    `json:"challenge"`
+	ClientID   uuid.UUID `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// ConsentPayload defines the User's answer to a consent request
+type ConsentPayload struct {
+	// Accept grants the client the requested scopes
+	Accept bool `json:"accept" form:"accept"`
+	// Remember skips consent the next time that the client requests the same scopes
+	Remember bool `json:"remember" form:"remember"`
+}
+
+// Redirect defines where the User agent should be sent to next
+type Redirect struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// TokenPayload defines the data of a token request. Client credentials can also be provided with basic auth
+type TokenPayload struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// RevokePayload defines the data of a revocation request. Client credentials can also be provided with basic auth
+type RevokePayload struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// TokenResponse defines the response of a successful token request
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// Metadata defines the OpenID Connect discovery document
+type Metadata struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+type Repository interface {
+	// CreateClient creates a new client
+	CreateClient(ctx context.Context, newClient Client) (*Client, error)
+	// GetClient retrieves a client via ID
+	GetClient(ctx context.Context, id uuid.UUID) (*Client, error)
+	// GetAllClients retrieves every client
+	GetAllClients(ctx context.Context) ([]Client, error)
+	// DeleteClient deletes a client along with its consents and refresh tokens
+	DeleteClient(ctx context.Context, id uuid.UUID) error
+
+	// GetConsent retrieves the consent that an identity has given a client
+	GetConsent(ctx context.Context, identityID uuid.UUID, clientID uuid.UUID) (*Consent, error)
+	// UpsertConsent creates or replaces the consent that an identity has given a client
+	UpsertConsent(ctx context.Context, consent Consent) (*Consent, error)
+
+	// CreateRequest creates a new authorization request
+	CreateRequest(ctx context.Context, newRequest Request) (*Request, error)
+	// GetRequestByChallenge retrieves an authorization request via Challenge
+	GetRequestByChallenge(ctx context.Context, challenge string) (*Request, error)
+	// GetRequestByCode retrieves an authorization request via its hashed Code
+	GetRequestByCode(ctx context.Context, code string) (*Request, error)
+	// UpdateRequest updates an authorization request
+	UpdateRequest(ctx context.Context, updateRequest Request) (*Request, error)
+	// TransitionRequest moves an authorization request from one status to another. This fails if the request is no
+	// longer in the from status so that concurrent requests can't both succeed
+	TransitionRequest(ctx context.Context, id uuid.UUID, from Status, to Status) error
+
+	// CreateRefreshToken creates a new refresh token
+	CreateRefreshToken(ctx context.Context, newToken RefreshToken) (*RefreshToken, error)
+	// GetRefreshToken retrieves a refresh token via its hashed Token
+	GetRefreshToken(ctx context.Context, token string) (*RefreshToken, error)
+	// RevokeRefreshToken revokes a single refresh token. This fails if the token has already been revoked
+	RevokeRefreshToken(ctx context.Context, id uuid.UUID) error
+	// RevokeFamily revokes every refresh token in a family
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
+}
+
+type Service interface {
+	// NewClient registers a client. The secret, if any, is only ever returned here
+	NewClient(ctx context.Context, payload ClientPayload) (*RegisteredClient, error)
+	// FindClient finds a client via ID
+	FindClient(ctx context.Context, id string) (*Client, error)
+	// FindAllClients finds every client
+	FindAllClients(ctx context.Context) ([]Client, error)
+	// DeleteClient deletes a client, revoking everything that was issued to it
+	DeleteClient(ctx context.Context, id string) error
+
+	// Authorize handles an authorization request for the session, which may be nil, and returns where the User agent
+	// should be redirected to. That's either the login UI, the consent UI, or back to the client. An error is only
+	// returned when the client or redirect uri is invalid since the User can't be safely redirected back to the client
+	Authorize(ctx context.Context, payload AuthorizePayload, requestURL string, sess *session.Session) (string, error)
+	// FindConsent finds the consent request that the identity is being asked to answer
+	FindConsent(ctx context.Context, challenge string, identityID uuid.UUID) (*ConsentRequest, error)
+	// SubmitConsent answers a consent request and returns where the User agent should be redirected to
+	SubmitConsent(ctx context.Context, challenge string, identityID uuid.UUID, payload ConsentPayload) (string, error)
+
+	// Exchange handles a token request. Protocol errors are returned as an *Error
+	Exchange(ctx context.Context, payload TokenPayload) (*TokenResponse, error)
+	// Revoke revokes a refresh token, and every token in its family. Unknown tokens are ignored
+	Revoke(ctx context.Context, payload RevokePayload) error
+	// UserInfo retrieves the claims, that the access token's scopes allow, about its subject
+	UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error)
+	// Metadata builds the discovery document
+	Metadata() Metadata
+}
+
+// Strings is a list of strings that's stored as JSON
+type Strings []string
+
+// Scan implements the Scanner interface.
+func (s *Strings) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	v := fmt.Sprintf("%s", value)
+	if len(v) == 0 {
+		return nil
+	}
+	return json.Unmarshal([]byte(v), s)
+}
+
+// Value implements the driver Valuer interface.
+func (s Strings) Value() (driver.Value, error) {
+	value, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(value), nil
+}
+
+// Contains checks whether v is in the list
+func (s Strings) Contains(v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// RandomToken generates a url safe token with 256 bits of entropy. Codes, refresh tokens and client secrets are all
+// generated with this
+func RandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash hashes a token before it's stored. Tokens have enough entropy that a fast hash is sufficient
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifySecret checks secret against the client's hashed secret in constant time
+func (c *Client) VerifySecret(secret string) bool {
+	if c.Secret == "" || secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(Hash(secret)), []byte(c.Secret)) == 1
+}
+
+// VerifyCodeChallenge checks a PKCE code verifier against the S256 challenge that was sent with the authorization
+// request
+func VerifyCodeChallenge(challenge string, verifier string) bool {
+	if challenge == "" || len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// TableName overrides GORM's table name
+func (Client) TableName() string {
+	return "oauth2_clients"
+}
+
+// TableName overrides GORM's table name
+func (Consent) TableName() string {
+	return "oauth2_consents"
+}
+
+// TableName overrides GORM's table name
+func (Request) TableName() string {
+	return "oauth2_requests"
+}
+
+// TableName overrides GORM's table name
+func (RefreshToken) TableName() string {
+	return "oauth2_refresh_tokens"
+}
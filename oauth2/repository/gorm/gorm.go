@@ -0,0 +1,162 @@
+package gorm
+
+import (
+	"context"
+	"time"
+
+	"github.com/RagOfJoes/mylo/oauth2"
+	"github.com/RagOfJoes/mylo/persistence"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormOAuth2Repository struct {
+	DB *gorm.DB
+}
+
+func NewGormOAuth2Repository(d *gorm.DB) oauth2.Repository {
+	return &gormOAuth2Repository{DB: d}
+}
+
+func (g *gormOAuth2Repository) CreateClient(ctx context.Context, newClient oauth2.Client) (*oauth2.Client, error) {
+	clone := newClient
+	if err := persistence.Conn(ctx, g.DB).Create(&clone).Error; err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
+
+func (g *gormOAuth2Repository) GetClient(ctx context.Context, id uuid.UUID) (*oauth2.Client, error) {
+	var found oauth2.Client
+	if err := persistence.Conn(ctx, g.DB).First(&found, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+func (g *gormOAuth2Repository) GetAllClients(ctx context.Context) ([]oauth2.Client, error) {
+	found := []oauth2.Client{}
+	if err := persistence.Conn(ctx, g.DB).Order("created_at desc").Find(&found).Error; err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+func (g *gormOAuth2Repository) DeleteClient(ctx context.Context, id uuid.UUID) error {
+	return persistence.Conn(ctx, g.DB).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&oauth2.RefreshToken{}, "client_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&oauth2.Request{}, "client_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&oauth2.Consent{}, "client_id = ?", id).Error; err != nil {
+			return err
+		}
+		res := tx.Delete(&oauth2.Client{}, "id = ?", id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (g *gormOAuth2Repository) GetConsent(ctx context.Context, identityID uuid.UUID, clientID uuid.UUID) (*oauth2.Consent, error) {
+	var found oauth2.Consent
+	if err := persistence.Conn(ctx, g.DB).First(&found, "identity_id = ? AND client_id = ?", identityID, clientID).Error; err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+func (g *gormOAuth2Repository) UpsertConsent(ctx context.Context, consent oauth2.Consent) (*oauth2.Consent, error) {
+	clone := consent
+	if err := persistence.Conn(ctx, g.DB).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "identity_id"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scope", "updated_at"}),
+	}).Create(&clone).Error; err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
+
+func (g *gormOAuth2Repository) CreateRequest(ctx context.Context, newRequest oauth2.Request) (*oauth2.Request, error) {
+	clone := newRequest
+	if err := persistence.Conn(ctx, g.DB).Create(&clone).Error; err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
+
+func (g *gormOAuth2Repository) GetRequestByChallenge(ctx context.Context, challenge string) (*oauth2.Request, error) {
+	var found oauth2.Request
+	if err := persistence.Conn(ctx, g.DB).First(&found, "challenge = ?", challenge).Error; err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+func (g *gormOAuth2Repository) GetRequestByCode(ctx context.Context, code string) (*oauth2.Request, error) {
+	var found oauth2.Request
+	if err := persistence.Conn(ctx, g.DB).First(&found, "code = ?", code).Error; err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+func (g *gormOAuth2Repository) UpdateRequest(ctx context.Context, updateRequest oauth2.Request) (*oauth2.Request, error) {
+	updated := updateRequest
+	if err := persistence.Conn(ctx, g.DB).Save(&updated).Error; err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (g *gormOAuth2Repository) TransitionRequest(ctx context.Context, id uuid.UUID, from oauth2.Status, to oauth2.Status) error {
+	res := persistence.Conn(ctx, g.DB).Model(&oauth2.Request{}).Where("id = ? AND status = ?", id, from).Updates(map[string]interface{}{
+		"status":     to,
+		"updated_at": time.Now(),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (g *gormOAuth2Repository) CreateRefreshToken(ctx context.Context, newToken oauth2.RefreshToken) (*oauth2.RefreshToken, error) {
+	clone := newToken
+	if err := persistence.Conn(ctx, g.DB).Create(&clone).Error; err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
+
+func (g *gormOAuth2Repository) GetRefreshToken(ctx context.Context, token string) (*oauth2.RefreshToken, error) {
+	var found oauth2.RefreshToken
+	if err := persistence.Conn(ctx, g.DB).First(&found, "token = ?", token).Error; err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+func (g *gormOAuth2Repository) RevokeRefreshToken(ctx context.Context, id uuid.UUID) error {
+	res := persistence.Conn(ctx, g.DB).Model(&oauth2.RefreshToken{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (g *gormOAuth2Repository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	return persistence.Conn(ctx, g.DB).Model(&oauth2.RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", familyID).Update("revoked_at", time.Now()).Error
+}
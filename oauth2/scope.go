@@ -0,0 +1,36 @@
+package oauth2
+
+import "strings"
+
+// ParseScope splits a space delimited scope, dropping duplicates
+func ParseScope(scope string) []string {
+	var scopes []string
+	seen := map[string]bool{}
+	for _, s := range strings.Fields(scope) {
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// HasScope checks whether a space delimited scope contains s
+func HasScope(scope string, s string) bool {
+	for _, e := range strings.Fields(scope) {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// CoversScope checks whether every scope in requested is also in granted
+func CoversScope(granted string, requested string) bool {
+	for _, s := range strings.Fields(requested) {
+		if !HasScope(granted, s) {
+			return false
+		}
+	}
+	return true
+}
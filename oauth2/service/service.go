@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/internal/validate"
+	"github.com/RagOfJoes/mylo/oauth2"
+	"github.com/RagOfJoes/mylo/pkg/nanoid"
+	"github.com/RagOfJoes/mylo/session"
+	"github.com/RagOfJoes/mylo/token"
+	"github.com/RagOfJoes/mylo/user/identity"
+	"github.com/gofrs/uuid"
+)
+
+type service struct {
+	r  oauth2.Repository
+	ts token.Service
+	ss session.Service
+	is identity.Service
+}
+
+func NewOAuth2Service(r oauth2.Repository, ts token.Service, ss session.Service, is identity.Service) oauth2.Service {
+	return &service{
+		r:  r,
+		ts: ts,
+		ss: ss,
+		is: is,
+	}
+}
+
+func (s *service) NewClient(ctx context.Context, payload oauth2.ClientPayload) (*oauth2.RegisteredClient, error) {
+	if err := validate.Check(payload); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", oauth2.ErrInvalidClientPayload)
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate uuid")
+	}
+	newClient := oauth2.Client{
+		Base: internal.Base{
+			ID:        id,
+			CreatedAt: time.Now(),
+		},
+		Name:         payload.Name,
+		Public:       payload.Public,
+		RedirectURIs: payload.RedirectURIs,
+		Scopes:       oauth2.ParseScope(strings.Join(payload.Scopes, " ")),
+		SkipConsent:  payload.SkipConsent,
+	}
+	if newClient.Scopes == nil {
+		newClient.Scopes = oauth2.Strings{}
+	}
+	// Public clients are authenticated with PKCE alone
+	var secret string
+	if !payload.Public {
+		secret, err = oauth2.RandomToken()
+		if err != nil {
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate client secret")
+		}
+		newClient.Secret = oauth2.Hash(secret)
+	}
+	created, err := s.r.CreateClient(ctx, newClient)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to create client")
+	}
+	return &oauth2.RegisteredClient{
+		Client:       *created,
+		ClientSecret: secret,
+	}, nil
+}
+
+func (s *service) FindClient(ctx context.Context, id string) (*oauth2.Client, error) {
+	uid, err := uuid.FromString(id)
+	if err != nil || uid == uuid.Nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", oauth2.ErrInvalidClientID)
+	}
+	found, err := s.r.GetClient(ctx, uid)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", oauth2.ErrClientNotFound)
+	}
+	return found, nil
+}
+
+func (s *service) FindAllClients(ctx context.Context) ([]oauth2.Client, error) {
+	found, err := s.r.GetAllClients(ctx)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to retrieve clients")
+	}
+	return found, nil
+}
+
+func (s *service) DeleteClient(ctx context.Context, id string) error {
+	found, err := s.FindClient(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.r.DeleteClient(ctx, found.ID); err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to delete client: %s", found.ID)
+	}
+	return nil
+}
+
+func (s *service) Authorize(ctx context.Context, payload oauth2.AuthorizePayload, requestURL string, sess *session.Session) (string, error) {
+	// Until the redirect uri has been validated, errors can't be sent back to the client
+	client, err := s.FindClient(ctx, payload.ClientID)
+	if err != nil {
+		return "", internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", oauth2.ErrInvalidClientID)
+	}
+	redirectURI := payload.RedirectURI
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if !client.RedirectURIs.Contains(redirectURI) {
+		return "", internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", oauth2.ErrInvalidRedirectURI)
+	}
+
+	if payload.ResponseType != "code" {
+		return oauth2.NewError(oauth2.CodeUnsupportedResponseType, "Only the authorization code flow is supported").RedirectURL(redirectURI, payload.State), nil
+	}
+	if payload.CodeChallenge == "" || payload.CodeChallengeMethod != oauth2.CodeChallengeS256 {
+		return oauth2.NewError(oauth2.CodeInvalidRequest, "PKCE with the S256 method is required").RedirectURL(redirectURI, payload.State), nil
+	}
+	scopes := oauth2.ParseScope(payload.Scope)
+	for _, scope := range scopes {
+		if !client.Scopes.Contains(scope) {
+			return oauth2.NewError(oauth2.CodeInvalidScope, "Client is not allowed to request scope: "+scope).RedirectURL(redirectURI, payload.State), nil
+		}
+	}
+	prompts := oauth2.ParseScope(payload.Prompt)
+	promptNone := oauth2.Strings(prompts).Contains("none")
+
+	cfg := config.Get()
+	// Send the User to login, after which the UI should bring them back here to pick up where they left off
+	if sess == nil || !sess.Authenticated() {
+		if promptNone || cfg.OAuth2.LoginURL == "" {
+			return oauth2.NewError(oauth2.CodeLoginRequired, "").RedirectURL(redirectURI, payload.State), nil
+		}
+		return oauth2.WithQuery(cfg.OAuth2.LoginURL, url.Values{
+			"return_to": []string{cfg.Server.URL + requestURL},
+		}), nil
+	}
+
+	challenge, err := nanoid.New()
+	if err != nil {
+		return "", internal.WrapErrorf(err, internal.ErrorCodeInternal, "%v", internal.ErrFailedNanoID)
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate uuid")
+	}
+	var amr oauth2.Strings
+	for _, m := range sess.CredentialMethods {
+		if method := string(m.Method); !amr.Contains(method) {
+			amr = append(amr, method)
+		}
+	}
+	now := time.Now()
+	newRequest := oauth2.Request{
+		Base: internal.Base{
+			ID:        id,
+			CreatedAt: now,
+		},
+		Challenge:     challenge,
+		Status:        oauth2.Pending,
+		ExpiresAt:     now.Add(cfg.OAuth2.Lifetime),
+		ClientID:      client.ID,
+		IdentityID:    *sess.IdentityID,
+		SessionID:     sess.ID,
+		AuthTime:      *sess.AuthenticatedAt,
+		AMR:           amr,
+		RedirectURI:   redirectURI,
+		Scope:         strings.Join(scopes, " "),
+		State:         payload.State,
+		Nonce:         payload.Nonce,
+		CodeChallenge: payload.CodeChallenge,
+	}
+	if err := validate.Check(newRequest); err != nil {
+		return "", internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", oauth2.ErrInvalidAuthorization)
+	}
+	created, err := s.r.CreateRequest(ctx, newRequest)
+	if err != nil {
+		return "", internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to create authorization request")
+	}
+
+	// Skip consent when the client is trusted or the User has already granted every scope requested
+	consented := client.SkipConsent
+	if !consented && !oauth2.Strings(prompts).Contains("consent") {
+		if found, err := s.r.GetConsent(ctx, created.IdentityID, client.ID); err == nil && oauth2.CoversScope(found.Scope, created.Scope) {
+			consented = true
+		}
+	}
+	if consented {
+		return s.accept(ctx, *created)
+	}
+	if promptNone || cfg.OAuth2.ConsentURL == "" {
+		return oauth2.NewError(oauth2.CodeConsentRequired, "").RedirectURL(redirectURI, payload.State), nil
+	}
+	return oauth2.WithQuery(cfg.OAuth2.ConsentURL, url.Values{
+		"consent_challenge": []string{created.Challenge},
+	}), nil
+}
+
+func (s *service) FindConsent(ctx context.Context, challenge string, identityID uuid.UUID) (*oauth2.ConsentRequest, error) {
+	found, err := s.pendingRequest(ctx, challenge, identityID)
+	if err != nil {
+		return nil, err
+	}
+	client, err := s.r.GetClient(ctx, found.ClientID)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", oauth2.ErrInvalidConsentRequest)
+	}
+	return &oauth2.ConsentRequest{
+		Challenge:  found.Challenge,
+		ClientID:   client.ID,
+		ClientName: client.Name,
+		Scopes:     oauth2.ParseScope(found.Scope),
+		ExpiresAt:  found.ExpiresAt,
+	}, nil
+}
+
+func (s *service) SubmitConsent(ctx context.Context, challenge string, identityID uuid.UUID, payload oauth2.ConsentPayload) (string, error) {
+	found, err := s.pendingRequest(ctx, challenge, identityID)
+	if err != nil {
+		return "", err
+	}
+	if !payload.Accept {
+		if err := s.r.TransitionRequest(ctx, found.ID, oauth2.Pending, oauth2.Rejected); err != nil {
+			return "", internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", oauth2.ErrInvalidConsentRequest)
+		}
+		return oauth2.NewError(oauth2.CodeAccessDenied, "The User denied the request").RedirectURL(found.RedirectURI, found.State), nil
+	}
+	if payload.Remember {
+		scope := found.Scope
+		// Keep whatever was granted before so that asking for fewer scopes doesn't take any away
+		if existing, err := s.r.GetConsent(ctx, identityID, found.ClientID); err == nil {
+			scope = strings.Join(oauth2.ParseScope(existing.Scope+" "+found.Scope), " ")
+		}
+		id, err := uuid.NewV4()
+		if err != nil {
+			return "", internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate uuid")
+		}
+		now := time.Now()
+		if _, err := s.r.UpsertConsent(ctx, oauth2.Consent{
+			Base: internal.Base{
+				ID:        id,
+				CreatedAt: now,
+				UpdatedAt: &now,
+			},
+			IdentityID: identityID,
+			ClientID:   found.ClientID,
+			Scope:      scope,
+		}); err != nil {
+			return "", internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to store consent")
+		}
+	}
+	return s.accept(ctx, *found)
+}
+
+func (s *service) Metadata() oauth2.Metadata {
+	cfg := config.Get()
+	base := cfg.Server.URL
+	return oauth2.Metadata{
+		Issuer:                            cfg.Token.Issuer,
+		AuthorizationEndpoint:             base + "/oauth2/authorize",
+		TokenEndpoint:                     base + "/oauth2/token",
+		UserinfoEndpoint:                  base + "/userinfo",
+		RevocationEndpoint:                base + "/oauth2/revoke",
+		JWKSURI:                           base + "/.well-known/jwks.json",
+		ScopesSupported:                   oauth2.SupportedScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{oauth2.GrantAuthorizationCode, oauth2.GrantRefreshToken},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{string(token.Algorithm)},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{oauth2.CodeChallengeS256},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "amr", "sid", "name", "given_name", "family_name", "picture", "locale", "email", "email_verified"},
+	}
+}
+
+// pendingRequest retrieves an authorization request that's still waiting on the identity's consent
+func (s *service) pendingRequest(ctx context.Context, challenge string, identityID uuid.UUID) (*oauth2.Request, error) {
+	found, err := s.r.GetRequestByChallenge(ctx, challenge)
+	if err != nil || found.Status != oauth2.Pending || found.IdentityID != identityID || found.ExpiresAt.Before(time.Now()) {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", oauth2.ErrInvalidConsentRequest)
+	}
+	return found, nil
+}
+
+// accept issues an authorization code for the request and builds the url that sends it back to the client
+func (s *service) accept(ctx context.Context, req oauth2.Request) (string, error) {
+	code, err := oauth2.RandomToken()
+	if err != nil {
+		return "", internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate authorization code")
+	}
+	hashed := oauth2.Hash(code)
+	now := time.Now()
+	req.Status = oauth2.Accepted
+	req.Code = &hashed
+	req.ExpiresAt = now.Add(config.Get().OAuth2.CodeLifetime)
+	req.UpdatedAt = &now
+	if _, err := s.r.UpdateRequest(ctx, req); err != nil {
+		return "", internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update authorization request")
+	}
+
+	params := url.Values{}
+	params.Set("code", code)
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return oauth2.WithQuery(req.RedirectURI, params), nil
+}
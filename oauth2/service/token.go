@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/oauth2"
+	"github.com/RagOfJoes/mylo/token"
+	"github.com/RagOfJoes/mylo/user/contact"
+	"github.com/gofrs/uuid"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// grant is what a set of tokens is issued for, whether that's an authorization request or a refresh token
+type grant struct {
+	familyID   uuid.UUID
+	clientID   uuid.UUID
+	identityID uuid.UUID
+	sessionID  uuid.UUID
+	authTime   time.Time
+	amr        []string
+	// granted is the scope that the User granted, which refresh tokens keep, while scope is what the access token gets
+	granted string
+	scope   string
+	nonce   string
+}
+
+// idTokenClaims defines the claims of an ID token that aren't already part of token.Claims
+type idTokenClaims struct {
+	Nonce string `json:"nonce,omitempty"`
+}
+
+func (s *service) Exchange(ctx context.Context, payload oauth2.TokenPayload) (*oauth2.TokenResponse, error) {
+	client, err := s.authenticateClient(ctx, payload.ClientID, payload.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	switch payload.GrantType {
+	case oauth2.GrantAuthorizationCode:
+		return s.exchangeCode(ctx, *client, payload)
+	case oauth2.GrantRefreshToken:
+		return s.exchangeRefreshToken(ctx, *client, payload)
+	default:
+		return nil, oauth2.NewError(oauth2.CodeUnsupportedGrantType, "")
+	}
+}
+
+func (s *service) Revoke(ctx context.Context, payload oauth2.RevokePayload) error {
+	client, err := s.authenticateClient(ctx, payload.ClientID, payload.ClientSecret)
+	if err != nil {
+		return err
+	}
+	if payload.Token == "" {
+		return oauth2.NewError(oauth2.CodeInvalidRequest, "token is required")
+	}
+	// Access tokens are self-contained and short lived so only refresh tokens can be revoked. Unknown tokens, and tokens
+	// that belong to other clients, are ignored as required by RFC 7009
+	found, err := s.r.GetRefreshToken(ctx, oauth2.Hash(payload.Token))
+	if err != nil || found.ClientID != client.ID {
+		return nil
+	}
+	if err := s.r.RevokeFamily(ctx, found.FamilyID); err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to revoke refresh token")
+	}
+	return nil
+}
+
+func (s *service) UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error) {
+	claims, err := s.ts.VerifyClient(ctx, accessToken)
+	if err != nil {
+		return nil, oauth2.NewError(oauth2.CodeInvalidToken, "")
+	}
+	if !oauth2.HasScope(claims.Scope, oauth2.ScopeOpenID) {
+		return nil, oauth2.NewError(oauth2.CodeInsufficientScope, "The openid scope is required")
+	}
+	found, err := s.is.Find(ctx, claims.Subject)
+	if err != nil {
+		return nil, oauth2.NewError(oauth2.CodeInvalidToken, "")
+	}
+
+	info := map[string]interface{}{
+		"sub": found.ID.String(),
+	}
+	if oauth2.HasScope(claims.Scope, oauth2.ScopeProfile) {
+		info["name"] = strings.TrimSpace(found.FirstName + " " + found.LastName)
+		info["given_name"] = found.FirstName
+		info["family_name"] = found.LastName
+		info["picture"] = found.Avatar
+		info["locale"] = found.Locale
+		updatedAt := found.CreatedAt
+		if found.UpdatedAt != nil {
+			updatedAt = *found.UpdatedAt
+		}
+		info["updated_at"] = updatedAt.Unix()
+	}
+	if oauth2.HasScope(claims.Scope, oauth2.ScopeEmail) {
+		verified := false
+		for _, c := range found.Contacts {
+			if strings.EqualFold(c.Value, found.Email) && c.Verified && c.State == contact.Completed {
+				verified = true
+			}
+		}
+		info["email"] = found.Email
+		info["email_verified"] = verified
+	}
+	return info, nil
+}
+
+// authenticateClient authenticates the client making a token or revocation request. Confidential clients must provide
+// their secret while public clients are only identified
+func (s *service) authenticateClient(ctx context.Context, clientID string, secret string) (*oauth2.Client, error) {
+	client, err := s.FindClient(ctx, clientID)
+	if err != nil {
+		return nil, oauth2.NewError(oauth2.CodeInvalidClient, "")
+	}
+	if !client.Public && !client.VerifySecret(secret) {
+		return nil, oauth2.NewError(oauth2.CodeInvalidClient, "")
+	}
+	return client, nil
+}
+
+func (s *service) exchangeCode(ctx context.Context, client oauth2.Client, payload oauth2.TokenPayload) (*oauth2.TokenResponse, error) {
+	invalid := oauth2.NewError(oauth2.CodeInvalidGrant, "Invalid or expired authorization code")
+	if payload.Code == "" {
+		return nil, invalid
+	}
+	found, err := s.r.GetRequestByCode(ctx, oauth2.Hash(payload.Code))
+	if err != nil || found.ClientID != client.ID {
+		return nil, invalid
+	}
+	// A code that's used twice has most likely been intercepted so revoke everything that was issued with it
+	if found.Status == oauth2.Exchanged {
+		if err := s.r.RevokeFamily(ctx, found.ID); err != nil {
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to revoke refresh tokens")
+		}
+		return nil, invalid
+	}
+	if found.Status != oauth2.Accepted || found.ExpiresAt.Before(time.Now()) || found.RedirectURI != payload.RedirectURI {
+		return nil, invalid
+	}
+	if !oauth2.VerifyCodeChallenge(found.CodeChallenge, payload.CodeVerifier) {
+		return nil, oauth2.NewError(oauth2.CodeInvalidGrant, "Invalid code verifier")
+	}
+	// This fails if another request exchanged the same code first
+	if err := s.r.TransitionRequest(ctx, found.ID, oauth2.Accepted, oauth2.Exchanged); err != nil {
+		return nil, invalid
+	}
+
+	return s.issue(ctx, grant{
+		familyID:   found.ID,
+		clientID:   client.ID,
+		identityID: found.IdentityID,
+		sessionID:  found.SessionID,
+		authTime:   found.AuthTime,
+		amr:        found.AMR,
+		granted:    found.Scope,
+		scope:      found.Scope,
+		nonce:      found.Nonce,
+	}, true)
+}
+
+func (s *service) exchangeRefreshToken(ctx context.Context, client oauth2.Client, payload oauth2.TokenPayload) (*oauth2.TokenResponse, error) {
+	invalid := oauth2.NewError(oauth2.CodeInvalidGrant, "Invalid or expired refresh token")
+	if payload.RefreshToken == "" {
+		return nil, invalid
+	}
+	found, err := s.r.GetRefreshToken(ctx, oauth2.Hash(payload.RefreshToken))
+	if err != nil || found.ClientID != client.ID {
+		return nil, invalid
+	}
+	// Refresh tokens are rotated on every use so a revoked token that's used again has most likely been stolen
+	if found.RevokedAt != nil {
+		if err := s.r.RevokeFamily(ctx, found.FamilyID); err != nil {
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to revoke refresh tokens")
+		}
+		return nil, invalid
+	}
+	if found.ExpiresAt.Before(time.Now()) {
+		return nil, invalid
+	}
+	// A refresh can narrow the scope of the access token but never widen it
+	scope := found.Scope
+	if payload.Scope != "" {
+		if !oauth2.CoversScope(found.Scope, payload.Scope) {
+			return nil, oauth2.NewError(oauth2.CodeInvalidScope, "")
+		}
+		scope = strings.Join(oauth2.ParseScope(payload.Scope), " ")
+	}
+	// Make sure the identity hasn't been deleted since the token was issued
+	if _, err := s.is.Find(ctx, found.IdentityID.String()); err != nil {
+		return nil, invalid
+	}
+	// Refresh tokens don't outlive the session they were issued for so logging out, or having the session revoked, ends
+	// the client's access as well
+	sess, err := s.ss.FindByID(ctx, found.SessionID)
+	if err != nil || !sess.Authenticated() || !sess.BelongsTo(found.IdentityID) {
+		if err := s.r.RevokeFamily(ctx, found.FamilyID); err != nil {
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to revoke refresh tokens")
+		}
+		return nil, invalid
+	}
+	if err := s.r.RevokeRefreshToken(ctx, found.ID); err != nil {
+		// Another request rotated the token first
+		if err := s.r.RevokeFamily(ctx, found.FamilyID); err != nil {
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to revoke refresh tokens")
+		}
+		return nil, invalid
+	}
+
+	return s.issue(ctx, grant{
+		familyID:   found.FamilyID,
+		clientID:   client.ID,
+		identityID: found.IdentityID,
+		sessionID:  found.SessionID,
+		authTime:   found.AuthTime,
+		amr:        found.AMR,
+		granted:    found.Scope,
+		scope:      scope,
+	}, false)
+}
+
+// issue issues an access token for the grant, along with an ID token and a refresh token when the scopes allow it. An
+// ID token is only issued for authorization codes
+func (s *service) issue(ctx context.Context, g grant, withIDToken bool) (*oauth2.TokenResponse, error) {
+	cfg := config.Get()
+	now := time.Now()
+	expiry := now.Add(cfg.Token.Lifetime)
+
+	jti, err := uuid.NewV4()
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate uuid")
+	}
+	// Access tokens are kept apart from the ones issued to sessions so that a client can't use its token against
+	// first-party APIs
+	audience := jwt.Audience(cfg.OAuth2.Audience)
+	if len(audience) == 0 {
+		audience = jwt.Audience{g.clientID.String()}
+	}
+	accessClaims := token.Claims{
+		Claims: jwt.Claims{
+			ID:        jti.String(),
+			Issuer:    cfg.Token.Issuer,
+			Subject:   g.identityID.String(),
+			Audience:  audience,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Expiry:    jwt.NewNumericDate(expiry),
+		},
+		SessionID: g.sessionID.String(),
+		AMR:       g.amr,
+		AuthTime:  g.authTime.Unix(),
+		Scope:     g.scope,
+		ClientID:  g.clientID.String(),
+	}
+	accessToken, err := s.ts.SignWithType(ctx, token.TypeAccessToken, accessClaims)
+	if err != nil {
+		return nil, err
+	}
+	res := &oauth2.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(cfg.Token.Lifetime.Seconds()),
+		Scope:       g.scope,
+	}
+
+	if withIDToken && oauth2.HasScope(g.scope, oauth2.ScopeOpenID) {
+		idClaims := accessClaims
+		idClaims.ID = ""
+		idClaims.NotBefore = nil
+		idClaims.Audience = jwt.Audience{g.clientID.String()}
+		idClaims.Scope = ""
+		idClaims.ClientID = ""
+		idToken, err := s.ts.Sign(ctx, idClaims, idTokenClaims{Nonce: g.nonce})
+		if err != nil {
+			return nil, err
+		}
+		res.IDToken = idToken
+	}
+
+	if oauth2.HasScope(g.granted, oauth2.ScopeOfflineAccess) {
+		refreshToken, err := oauth2.RandomToken()
+		if err != nil {
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate refresh token")
+		}
+		id, err := uuid.NewV4()
+		if err != nil {
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate uuid")
+		}
+		if _, err := s.r.CreateRefreshToken(ctx, oauth2.RefreshToken{
+			Base: internal.Base{
+				ID:        id,
+				CreatedAt: now,
+			},
+			Token:      oauth2.Hash(refreshToken),
+			FamilyID:   g.familyID,
+			ClientID:   g.clientID,
+			IdentityID: g.identityID,
+			SessionID:  g.sessionID,
+			AuthTime:   g.authTime,
+			AMR:        g.amr,
+			Scope:      g.granted,
+			ExpiresAt:  now.Add(cfg.OAuth2.RefreshTokenLifetime),
+		}); err != nil {
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to create refresh token")
+		}
+		res.RefreshToken = refreshToken
+	}
+	return res, nil
+}
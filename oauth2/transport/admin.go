@@ -0,0 +1,93 @@
+package transport
+
+import (
+	"net/http"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/oauth2"
+	"github.com/RagOfJoes/mylo/transport"
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHttp struct {
+	s oauth2.Service
+}
+
+// NewClientAdminHttp attaches the routes that register and manage clients. These should only ever be attached to the
+// admin server's router, behind APIKeyMiddleware
+func NewClientAdminHttp(s oauth2.Service, r *gin.Engine) {
+	h := &AdminHttp{
+		s: s,
+	}
+
+	group := r.Group("/clients")
+	{
+		group.GET("/", h.list())
+		group.POST("/", h.create())
+		group.GET("/:id", h.get())
+		group.DELETE("/:id", h.delete())
+	}
+}
+
+func (h *AdminHttp) list() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		found, err := h.s.FindAllClients(c.Request.Context())
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Payload: found,
+		})
+	}
+}
+
+func (h *AdminHttp) create() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload oauth2.ClientPayload
+		if err := c.ShouldBind(&payload); err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", oauth2.ErrInvalidClientPayload))
+			return
+		}
+		created, err := h.s.NewClient(c.Request.Context(), payload)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, transport.HttpResponse{
+			Success: true,
+			Payload: created,
+		})
+	}
+}
+
+func (h *AdminHttp) get() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		found, err := h.s.FindClient(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Payload: found,
+		})
+	}
+}
+
+func (h *AdminHttp) delete() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := h.s.DeleteClient(c.Request.Context(), c.Param("id")); err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+		})
+	}
+}
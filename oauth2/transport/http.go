@@ -0,0 +1,192 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/oauth2"
+	sessionHttp "github.com/RagOfJoes/mylo/session/transport"
+	"github.com/RagOfJoes/mylo/transport"
+	"github.com/gin-gonic/gin"
+)
+
+type Http struct {
+	sh sessionHttp.Http
+	s  oauth2.Service
+}
+
+func NewOAuth2Http(sh sessionHttp.Http, s oauth2.Service, r *gin.Engine) {
+	h := &Http{
+		sh: sh,
+		s:  s,
+	}
+
+	group := r.Group("/oauth2")
+	{
+		group.GET("/authorize", h.authorize())
+		group.GET("/consent/:challenge", h.getConsent())
+		group.POST("/consent/:challenge", h.submitConsent())
+		group.POST("/token", h.token())
+		group.POST("/revoke", h.revoke())
+	}
+	r.GET("/userinfo", h.userinfo())
+	r.POST("/userinfo", h.userinfo())
+	r.GET("/.well-known/openid-configuration", h.discovery())
+}
+
+func (h *Http) authorize() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		// Users that aren't logged in are sent to login so a missing session isn't an error here
+		sess, _ := h.sh.Session(ctx, c.Request, c.Writer, false)
+		var payload oauth2.AuthorizePayload
+		if err := c.ShouldBindQuery(&payload); err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", oauth2.ErrInvalidAuthorization))
+			return
+		}
+		redirect, err := h.s.Authorize(ctx, payload, transport.RequestURL(c.Request), sess)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Redirect(http.StatusFound, redirect)
+	}
+}
+
+func (h *Http) getConsent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.sh.Session(ctx, c.Request, c.Writer, true)
+		if err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeUnauthorized, "%v", internal.ErrUnauthorized))
+			return
+		}
+		found, err := h.s.FindConsent(ctx, c.Param("challenge"), *sess.IdentityID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Payload: found,
+		})
+	}
+}
+
+func (h *Http) submitConsent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.sh.Session(ctx, c.Request, c.Writer, true)
+		if err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeUnauthorized, "%v", internal.ErrUnauthorized))
+			return
+		}
+		var payload oauth2.ConsentPayload
+		if err := c.ShouldBind(&payload); err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", oauth2.ErrInvalidConsentPayload))
+			return
+		}
+		redirect, err := h.s.SubmitConsent(ctx, c.Param("challenge"), *sess.IdentityID, payload)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Payload: oauth2.Redirect{
+				RedirectTo: redirect,
+			},
+		})
+	}
+}
+
+func (h *Http) token() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload oauth2.TokenPayload
+		if err := c.ShouldBind(&payload); err != nil {
+			respondError(c, oauth2.NewError(oauth2.CodeInvalidRequest, oauth2.ErrInvalidTokenPayload.Error()))
+			return
+		}
+		if id, secret, ok := c.Request.BasicAuth(); ok {
+			payload.ClientID = id
+			payload.ClientSecret = secret
+		}
+		issued, err := h.s.Exchange(c.Request.Context(), payload)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.Header("Pragma", "no-cache")
+		c.JSON(http.StatusOK, issued)
+	}
+}
+
+func (h *Http) revoke() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload oauth2.RevokePayload
+		if err := c.ShouldBind(&payload); err != nil {
+			respondError(c, oauth2.NewError(oauth2.CodeInvalidRequest, ""))
+			return
+		}
+		if id, secret, ok := c.Request.BasicAuth(); ok {
+			payload.ClientID = id
+			payload.ClientSecret = secret
+		}
+		if err := h.s.Revoke(c.Request.Context(), payload); err != nil {
+			respondError(c, err)
+			return
+		}
+
+		c.Status(http.StatusOK)
+	}
+}
+
+func (h *Http) userinfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if raw == "" || raw == c.GetHeader("Authorization") {
+			respondError(c, oauth2.NewError(oauth2.CodeInvalidToken, ""))
+			return
+		}
+		info, err := h.s.UserInfo(c.Request.Context(), raw)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, info)
+	}
+}
+
+func (h *Http) discovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, h.s.Metadata())
+	}
+}
+
+// respondError responds with the format defined by RFC 6749 rather than letting ErrorMiddleware handle it since that's
+// what clients expect. Anything that isn't a protocol error is handed off to ErrorMiddleware as usual
+func respondError(c *gin.Context, err error) {
+	var oauthErr *oauth2.Error
+	if !errors.As(err, &oauthErr) {
+		c.Error(err)
+		return
+	}
+	switch oauthErr.Code {
+	case oauth2.CodeInvalidClient:
+		c.Header("WWW-Authenticate", `Basic realm="oauth2"`)
+	case oauth2.CodeInvalidToken, oauth2.CodeInsufficientScope:
+		c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="%s"`, oauthErr.Code))
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(oauthErr.Status(), oauthErr)
+}
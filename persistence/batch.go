@@ -0,0 +1,21 @@
+package persistence
+
+import (
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+// DeleteBatch deletes up to limit rows of model that match the query and returns how many were deleted. The IDs are
+// selected first since MySQL can't limit a subquery of the table that's being deleted from, which also keeps each
+// delete short enough to not hold locks for long
+func DeleteBatch(db *gorm.DB, model interface{}, limit int, query interface{}, args ...interface{}) (int64, error) {
+	var ids []uuid.UUID
+	if err := db.Model(model).Where(query, args...).Limit(limit).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	res := db.Where("id IN ?", ids).Delete(model)
+	return res.RowsAffected, res.Error
+}
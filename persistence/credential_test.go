@@ -0,0 +1,89 @@
+package persistence_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/persistence/memory"
+	"github.com/RagOfJoes/mylo/user/credential"
+	credentialGorm "github.com/RagOfJoes/mylo/user/credential/repository/gorm"
+	"github.com/RagOfJoes/mylo/user/identity"
+	identityGorm "github.com/RagOfJoes/mylo/user/identity/repository/gorm"
+	"github.com/gofrs/uuid"
+)
+
+func TestAdvanceCounter(t *testing.T) {
+	for name, newRepositories := range map[string]func(t *testing.T) (identity.Repository, credential.Repository){
+		"memory": func(t *testing.T) (identity.Repository, credential.Repository) {
+			store := memory.NewStore()
+			return memory.NewMemoryIdentityRepository(store), memory.NewMemoryCredentialRepository(store)
+		},
+		"gorm": func(t *testing.T) (identity.Repository, credential.Repository) {
+			db := newSQLite(t)
+			return identityGorm.NewGormUserRepository(db), credentialGorm.NewGormCredentialRepository(db)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ir, r := newRepositories(t)
+			ctx := context.Background()
+
+			id := uuid.Must(uuid.NewV4())
+			if _, err := ir.Create(ctx, identity.Identity{
+				BaseSoftDelete: internal.BaseSoftDelete{ID: id, CreatedAt: time.Now()},
+				Email:          "jane@example.com",
+			}); err != nil {
+				t.Fatal(err)
+			}
+			cred, err := r.Create(ctx, credential.Credential{
+				ID:          uuid.Must(uuid.NewV4()),
+				CreatedAt:   time.Now(),
+				Type:        credential.TOTP,
+				IdentityID:  id,
+				Values:      `{"secret":"secret","confirmed":true}`,
+				LastCounter: 10,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Concurrent requests with the same code must only ever have one of them accepted
+			var mu sync.Mutex
+			var wg sync.WaitGroup
+			accepted := 0
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ok, err := r.AdvanceCounter(ctx, cred.ID, 11)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if ok {
+						mu.Lock()
+						accepted++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+			if accepted != 1 {
+				t.Errorf("expected the code to be accepted once, got %d", accepted)
+			}
+			found, err := r.GetWithIdentityID(ctx, credential.TOTP, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found.LastCounter != 11 {
+				t.Errorf("expected the counter to be 11, got %d", found.LastCounter)
+			}
+
+			if ok, err := r.AdvanceCounter(ctx, cred.ID, 9); ok || err != nil {
+				t.Errorf("expected an older code to be rejected, got %t, %v", ok, err)
+			}
+		})
+	}
+}
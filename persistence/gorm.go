@@ -4,22 +4,73 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/RagOfJoes/mylo/audit"
+	"github.com/RagOfJoes/mylo/email/outbox"
 	"github.com/RagOfJoes/mylo/flow/login"
+	"github.com/RagOfJoes/mylo/flow/oidc"
 	"github.com/RagOfJoes/mylo/flow/recovery"
 	"github.com/RagOfJoes/mylo/flow/registration"
+	"github.com/RagOfJoes/mylo/flow/settings"
 	"github.com/RagOfJoes/mylo/flow/verification"
 	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/oauth2"
 	"github.com/RagOfJoes/mylo/session"
+	"github.com/RagOfJoes/mylo/token"
 	"github.com/RagOfJoes/mylo/user/contact"
 	"github.com/RagOfJoes/mylo/user/credential"
 	"github.com/RagOfJoes/mylo/user/identity"
+	"github.com/RagOfJoes/mylo/webhook"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-func NewGorm() (db *gorm.DB, err error) {
+// NewGorm connects to the configured database and makes sure that its schema is up to date. Pending migrations are
+// applied when AutoMigrate is enabled, otherwise NewGorm refuses to continue until they've been applied with mylo
+// migrate up
+func NewGorm() (*gorm.DB, error) {
+	cfg := config.Get()
+
+	db, err := OpenGorm()
+	if err != nil {
+		return nil, err
+	}
+	// SQLite is only meant for local development and tests so it's kept in sync with the models instead of having its
+	// own set of migrations
+	if cfg.Database.Driver == "sqlite" {
+		if cfg.Database.AutoMigrate {
+			if err := autoMigrate(db); err != nil {
+				CloseGorm(db)
+				return nil, err
+			}
+		}
+		return db, nil
+	}
+	if cfg.Database.AutoMigrate {
+		if _, err := MigrateUp(db); err != nil {
+			CloseGorm(db)
+			return nil, err
+		}
+	}
+	if err := CheckSchema(db); err != nil {
+		CloseGorm(db)
+		return nil, err
+	}
+	return db, nil
+}
+
+// CloseGorm closes the connection pool that db was opened with
+func CloseGorm(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// OpenGorm connects to the configured database without touching its schema
+func OpenGorm() (db *gorm.DB, err error) {
 	cfg := config.Get()
 
 	driver := cfg.Database.Driver
@@ -28,7 +79,6 @@ func NewGorm() (db *gorm.DB, err error) {
 	host := cfg.Database.Host
 	port := cfg.Database.Port
 	name := cfg.Database.Name
-	autoMigrate := cfg.Database.AutoMigrate
 
 	isDev := cfg.Environment == config.Development
 
@@ -53,29 +103,39 @@ func NewGorm() (db *gorm.DB, err error) {
 		if err != nil {
 			return nil, err
 		}
-	default:
-		return nil, errors.New("invalid database driver provided")
-	}
-
-	if autoMigrate {
-		if err := migrate(db); err != nil {
+	case "sqlite":
+		db, err = gorm.Open(openSQLite(name), &gc)
+		if err != nil {
 			return nil, err
 		}
+	default:
+		return nil, errors.New("invalid database driver provided")
 	}
 
 	return db, nil
 }
 
-func migrate(db *gorm.DB) error {
+// autoMigrate creates or updates every table to match its model
+func autoMigrate(db *gorm.DB) error {
 	return db.AutoMigrate(
 		&session.Session{},
 		&identity.Identity{},
 		&contact.Contact{},
 		&credential.Identifier{},
 		&credential.Credential{},
+		&outbox.Message{},
+		&webhook.Delivery{},
+		&audit.Entry{},
+		&token.Key{},
+		&oauth2.Client{},
+		&oauth2.Consent{},
+		&oauth2.Request{},
+		&oauth2.RefreshToken{},
 
 		&login.Flow{},
+		&oidc.Flow{},
 		&recovery.Flow{},
+		&settings.Flow{},
 		&verification.Flow{},
 		&registration.Flow{},
 	)
@@ -0,0 +1,72 @@
+package persistence_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/persistence/memory"
+	"github.com/RagOfJoes/mylo/user/identity"
+	identityGorm "github.com/RagOfJoes/mylo/user/identity/repository/gorm"
+	"github.com/gofrs/uuid"
+)
+
+func TestFailLogin(t *testing.T) {
+	for name, newRepository := range map[string]func(t *testing.T) identity.Repository{
+		"memory": func(t *testing.T) identity.Repository {
+			return memory.NewMemoryIdentityRepository(memory.NewStore())
+		},
+		"gorm": func(t *testing.T) identity.Repository {
+			return identityGorm.NewGormUserRepository(newSQLite(t))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := newRepository(t)
+			ctx := context.Background()
+
+			id := uuid.Must(uuid.NewV4())
+			if _, err := r.Create(ctx, identity.Identity{
+				BaseSoftDelete: internal.BaseSoftDelete{ID: id, CreatedAt: time.Now()},
+				Email:          "jane@example.com",
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			// Concurrent failures must all be counted and lock the identity exactly once
+			const max = 3
+			var wg sync.WaitGroup
+			for i := 0; i < max*2; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := r.FailLogin(ctx, id, max, nil); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			found, err := r.Get(ctx, id, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found.FailedLogins != max*2 {
+				t.Errorf("expected %d failed logins, got %d", max*2, found.FailedLogins)
+			}
+			if found.LockedAt == nil {
+				t.Fatal("expected identity to be locked")
+			}
+
+			// Once the lock has cooled down counting starts again
+			cooledDown := time.Now().Add(time.Minute)
+			updated, err := r.FailLogin(ctx, id, max, &cooledDown)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updated.FailedLogins != 1 || updated.LockedAt != nil {
+				t.Errorf("expected the lock to be lifted after 1 failed login, got %d failed logins and lock %v", updated.FailedLogins, updated.LockedAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package persistence_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/RagOfJoes/mylo/flow/login"
+	loginGorm "github.com/RagOfJoes/mylo/flow/login/repository/gorm"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/persistence/memory"
+	"github.com/gofrs/uuid"
+)
+
+func TestIncrementCodeAttempts(t *testing.T) {
+	for name, newRepository := range map[string]func(t *testing.T) login.Repository{
+		"memory": func(t *testing.T) login.Repository {
+			return memory.NewMemoryLoginRepository(memory.NewStore())
+		},
+		"gorm": func(t *testing.T) login.Repository {
+			return loginGorm.NewGormLoginRepository(newSQLite(t))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := newRepository(t)
+			ctx := context.Background()
+
+			flow, err := login.New("http://localhost", internal.Client{}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			flow.ID = uuid.Must(uuid.NewV4())
+			identityID := uuid.Must(uuid.NewV4())
+			if err := flow.CodePending(&identityID, "hashed"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.Create(ctx, *flow); err != nil {
+				t.Fatal(err)
+			}
+
+			// Concurrent submissions must never use up more attempts than allowed
+			const max = 3
+			var mu sync.Mutex
+			var wg sync.WaitGroup
+			allowed := 0
+			for i := 0; i < max*3; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ok, err := r.IncrementCodeAttempts(ctx, flow.ID, max)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if ok {
+						mu.Lock()
+						allowed++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+			if allowed != max {
+				t.Errorf("expected %d attempts to be allowed, got %d", max, allowed)
+			}
+			found, err := r.Get(ctx, flow.ID.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found.CodeAttempts != max {
+				t.Errorf("expected %d code attempts, got %d", max, found.CodeAttempts)
+			}
+
+			if ok, err := r.IncrementCodeAttempts(ctx, uuid.Must(uuid.NewV4()), max); ok || err != nil {
+				t.Errorf("expected a missing flow to have no attempts left, got %t, %v", ok, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/RagOfJoes/mylo/audit"
+	"github.com/gofrs/uuid"
+)
+
+type memoryAuditRepository struct {
+	s *Store
+}
+
+func NewMemoryAuditRepository(s *Store) audit.Repository {
+	return &memoryAuditRepository{s: s}
+}
+
+func (m *memoryAuditRepository) Create(ctx context.Context, newEntries ...audit.Entry) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.s.atomic(ctx, func(ctx context.Context) error {
+		for _, e := range newEntries {
+			if _, err := m.s.insert(ctx, entries, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *memoryAuditRepository) GetAllActor(ctx context.Context, actorID uuid.UUID, offset int, limit int) ([]audit.Entry, int64, error) {
+	m.s.mu.RLock()
+	defer m.s.mu.RUnlock()
+
+	matched := m.s.filter(entries, func(row interface{}) bool {
+		e := row.(*audit.Entry)
+		return e.ActorID != nil && *e.ActorID == actorID
+	})
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].(*audit.Entry).CreatedAt.After(matched[j].(*audit.Entry).CreatedAt)
+	})
+
+	found := []audit.Entry{}
+	for _, row := range page(matched, offset, limit) {
+		found = append(found, *row.(*audit.Entry))
+	}
+	return found, int64(len(matched)), nil
+}
@@ -0,0 +1,405 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/RagOfJoes/mylo/flow/login"
+	"github.com/RagOfJoes/mylo/flow/oidc"
+	"github.com/RagOfJoes/mylo/flow/recovery"
+	"github.com/RagOfJoes/mylo/flow/registration"
+	"github.com/RagOfJoes/mylo/flow/settings"
+	"github.com/RagOfJoes/mylo/flow/verification"
+	"github.com/gofrs/uuid"
+)
+
+type memoryLoginRepository struct {
+	s *Store
+}
+
+type memoryRegistrationRepository struct {
+	s *Store
+}
+
+type memoryRecoveryRepository struct {
+	s *Store
+}
+
+type memoryVerificationRepository struct {
+	s *Store
+}
+
+type memorySettingsRepository struct {
+	s *Store
+}
+
+type memoryOIDCRepository struct {
+	s *Store
+}
+
+func NewMemoryLoginRepository(s *Store) login.Repository {
+	return &memoryLoginRepository{s: s}
+}
+
+func NewMemoryRegistrationRepository(s *Store) registration.Repository {
+	return &memoryRegistrationRepository{s: s}
+}
+
+func NewMemoryRecoveryRepository(s *Store) recovery.Repository {
+	return &memoryRecoveryRepository{s: s}
+}
+
+func NewMemoryVerificationRepository(s *Store) verification.Repository {
+	return &memoryVerificationRepository{s: s}
+}
+
+func NewMemorySettingsRepository(s *Store) settings.Repository {
+	return &memorySettingsRepository{s: s}
+}
+
+func NewMemoryOIDCRepository(s *Store) oidc.Repository {
+	return &memoryOIDCRepository{s: s}
+}
+
+func (m *memoryLoginRepository) Create(ctx context.Context, newFlow login.Flow) (*login.Flow, error) {
+	created, err := m.s.create(ctx, logins, newFlow)
+	if err != nil {
+		return nil, err
+	}
+	return created.(*login.Flow), nil
+}
+
+func (m *memoryLoginRepository) Get(ctx context.Context, id string) (*login.Flow, error) {
+	found, err := m.s.find(logins, uuid.FromStringOrNil(id))
+	if err != nil {
+		return nil, err
+	}
+	return found.(*login.Flow), nil
+}
+
+func (m *memoryLoginRepository) GetByFlowID(ctx context.Context, flowID string) (*login.Flow, error) {
+	found, err := m.s.findFirst(logins, func(row interface{}) bool {
+		return row.(*login.Flow).FlowID == flowID
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found.(*login.Flow), nil
+}
+
+func (m *memoryLoginRepository) GetByLinkID(ctx context.Context, linkID string) (*login.Flow, error) {
+	found, err := m.s.findFirst(logins, func(row interface{}) bool {
+		f := row.(*login.Flow)
+		return f.LinkID != nil && *f.LinkID == linkID
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found.(*login.Flow), nil
+}
+
+func (m *memoryLoginRepository) GetLastCodeSent(ctx context.Context, identityID uuid.UUID) (*login.Flow, error) {
+	m.s.mu.RLock()
+	defer m.s.mu.RUnlock()
+
+	found := m.s.filter(logins, func(row interface{}) bool {
+		f := row.(*login.Flow)
+		return f.IdentityID != nil && *f.IdentityID == identityID && f.CodeSentAt != nil
+	})
+	if len(found) == 0 {
+		return nil, ErrNotFound
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].(*login.Flow).CodeSentAt.After(*found[j].(*login.Flow).CodeSentAt)
+	})
+	return found[0].(*login.Flow), nil
+}
+
+func (m *memoryLoginRepository) Update(ctx context.Context, updateFlow login.Flow) (*login.Flow, error) {
+	updated, err := m.s.update(ctx, logins, updateFlow)
+	if err != nil {
+		return nil, err
+	}
+	return updated.(*login.Flow), nil
+}
+
+func (m *memoryLoginRepository) IncrementCodeAttempts(ctx context.Context, id uuid.UUID, max int) (bool, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	found, ok := m.s.get(logins, id)
+	if !ok {
+		return false, nil
+	}
+	flow := found.(*login.Flow)
+	if flow.Status != login.CodePending || flow.CodeAttempts >= max {
+		return false, nil
+	}
+	flow.CodeAttempts++
+	if _, err := m.s.save(ctx, logins, flow); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *memoryLoginRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	m.s.delete(ctx, logins, id)
+	return nil
+}
+
+func (m *memoryLoginRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	return m.s.deleteWhere(ctx, logins, limit, func(row interface{}) bool {
+		f := row.(*login.Flow)
+		return f.ExpiresAt.Before(before) || f.Status == login.Complete && finishedBefore(f.UpdatedAt, before)
+	}), nil
+}
+
+func (m *memoryRegistrationRepository) Create(ctx context.Context, newFlow registration.Flow) (*registration.Flow, error) {
+	created, err := m.s.create(ctx, registrations, newFlow)
+	if err != nil {
+		return nil, err
+	}
+	return created.(*registration.Flow), nil
+}
+
+func (m *memoryRegistrationRepository) Get(ctx context.Context, id string) (*registration.Flow, error) {
+	found, err := m.s.find(registrations, uuid.FromStringOrNil(id))
+	if err != nil {
+		return nil, err
+	}
+	return found.(*registration.Flow), nil
+}
+
+func (m *memoryRegistrationRepository) GetByFlowID(ctx context.Context, flowID string) (*registration.Flow, error) {
+	found, err := m.s.findFirst(registrations, func(row interface{}) bool {
+		return row.(*registration.Flow).FlowID == flowID
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found.(*registration.Flow), nil
+}
+
+func (m *memoryRegistrationRepository) Update(ctx context.Context, updateFlow registration.Flow) (*registration.Flow, error) {
+	updated, err := m.s.update(ctx, registrations, updateFlow)
+	if err != nil {
+		return nil, err
+	}
+	return updated.(*registration.Flow), nil
+}
+
+func (m *memoryRegistrationRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	m.s.delete(ctx, registrations, id)
+	return nil
+}
+
+func (m *memoryRegistrationRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	return m.s.deleteWhere(ctx, registrations, limit, func(row interface{}) bool {
+		f := row.(*registration.Flow)
+		return f.ExpiresAt.Before(before) || f.Status == registration.Complete && finishedBefore(f.UpdatedAt, before)
+	}), nil
+}
+
+func (m *memoryRecoveryRepository) Create(ctx context.Context, newFlow recovery.Flow) (*recovery.Flow, error) {
+	created, err := m.s.create(ctx, recoveries, newFlow)
+	if err != nil {
+		return nil, err
+	}
+	return created.(*recovery.Flow), nil
+}
+
+func (m *memoryRecoveryRepository) Get(ctx context.Context, id uuid.UUID) (*recovery.Flow, error) {
+	found, err := m.s.find(recoveries, id)
+	if err != nil {
+		return nil, err
+	}
+	return found.(*recovery.Flow), nil
+}
+
+func (m *memoryRecoveryRepository) GetByFlowIDOrRecoverID(ctx context.Context, id string) (*recovery.Flow, error) {
+	found, err := m.s.findFirst(recoveries, func(row interface{}) bool {
+		f := row.(*recovery.Flow)
+		return f.FlowID == id || f.RecoverID == id
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found.(*recovery.Flow), nil
+}
+
+func (m *memoryRecoveryRepository) GetByIdentityID(ctx context.Context, identityID uuid.UUID) (*recovery.Flow, error) {
+	found, err := m.s.findFirst(recoveries, func(row interface{}) bool {
+		f := row.(*recovery.Flow)
+		return f.IdentityID != nil && *f.IdentityID == identityID
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found.(*recovery.Flow), nil
+}
+
+func (m *memoryRecoveryRepository) Update(ctx context.Context, updateFlow recovery.Flow) (*recovery.Flow, error) {
+	updated, err := m.s.update(ctx, recoveries, updateFlow)
+	if err != nil {
+		return nil, err
+	}
+	return updated.(*recovery.Flow), nil
+}
+
+func (m *memoryRecoveryRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	m.s.delete(ctx, recoveries, id)
+	return nil
+}
+
+func (m *memoryRecoveryRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	return m.s.deleteWhere(ctx, recoveries, limit, func(row interface{}) bool {
+		f := row.(*recovery.Flow)
+		return f.ExpiresAt.Before(before) || (f.Status == recovery.Complete || f.Status == recovery.Fail) && finishedBefore(f.UpdatedAt, before)
+	}), nil
+}
+
+func (m *memoryVerificationRepository) Create(ctx context.Context, newFlow verification.Flow) (*verification.Flow, error) {
+	created, err := m.s.create(ctx, verifications, newFlow)
+	if err != nil {
+		return nil, err
+	}
+	return created.(*verification.Flow), nil
+}
+
+func (m *memoryVerificationRepository) Get(ctx context.Context, id uuid.UUID) (*verification.Flow, error) {
+	found, err := m.s.find(verifications, id)
+	if err != nil {
+		return nil, err
+	}
+	return found.(*verification.Flow), nil
+}
+
+func (m *memoryVerificationRepository) GetByFlowIDOrVerifyID(ctx context.Context, id string) (*verification.Flow, error) {
+	found, err := m.s.findFirst(verifications, func(row interface{}) bool {
+		f := row.(*verification.Flow)
+		return f.FlowID == id || f.VerifyID == id
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found.(*verification.Flow), nil
+}
+
+func (m *memoryVerificationRepository) GetByContactID(ctx context.Context, contactID uuid.UUID) (*verification.Flow, error) {
+	found, err := m.s.findFirst(verifications, func(row interface{}) bool {
+		return row.(*verification.Flow).ContactID == contactID
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found.(*verification.Flow), nil
+}
+
+func (m *memoryVerificationRepository) Update(ctx context.Context, updateFlow verification.Flow) (*verification.Flow, error) {
+	updated, err := m.s.update(ctx, verifications, updateFlow)
+	if err != nil {
+		return nil, err
+	}
+	return updated.(*verification.Flow), nil
+}
+
+func (m *memoryVerificationRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	m.s.delete(ctx, verifications, id)
+	return nil
+}
+
+func (m *memoryVerificationRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	return m.s.deleteWhere(ctx, verifications, limit, func(row interface{}) bool {
+		f := row.(*verification.Flow)
+		return f.ExpiresAt.Before(before) || f.Status == verification.Complete && finishedBefore(f.UpdatedAt, before)
+	}), nil
+}
+
+func (m *memorySettingsRepository) Create(ctx context.Context, newFlow settings.Flow) (*settings.Flow, error) {
+	created, err := m.s.create(ctx, settingsFlows, newFlow)
+	if err != nil {
+		return nil, err
+	}
+	return created.(*settings.Flow), nil
+}
+
+func (m *memorySettingsRepository) Get(ctx context.Context, id uuid.UUID) (*settings.Flow, error) {
+	found, err := m.s.find(settingsFlows, id)
+	if err != nil {
+		return nil, err
+	}
+	return found.(*settings.Flow), nil
+}
+
+func (m *memorySettingsRepository) GetByFlowID(ctx context.Context, flowID string) (*settings.Flow, error) {
+	found, err := m.s.findFirst(settingsFlows, func(row interface{}) bool {
+		return row.(*settings.Flow).FlowID == flowID
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found.(*settings.Flow), nil
+}
+
+func (m *memorySettingsRepository) Update(ctx context.Context, updateFlow settings.Flow) (*settings.Flow, error) {
+	updated, err := m.s.update(ctx, settingsFlows, updateFlow)
+	if err != nil {
+		return nil, err
+	}
+	return updated.(*settings.Flow), nil
+}
+
+func (m *memorySettingsRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	m.s.delete(ctx, settingsFlows, id)
+	return nil
+}
+
+func (m *memorySettingsRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	return m.s.deleteWhere(ctx, settingsFlows, limit, func(row interface{}) bool {
+		return row.(*settings.Flow).ExpiresAt.Before(before)
+	}), nil
+}
+
+func (m *memoryOIDCRepository) Create(ctx context.Context, newFlow oidc.Flow) (*oidc.Flow, error) {
+	created, err := m.s.create(ctx, oidcs, newFlow)
+	if err != nil {
+		return nil, err
+	}
+	return created.(*oidc.Flow), nil
+}
+
+func (m *memoryOIDCRepository) GetByFlowID(ctx context.Context, flowID string) (*oidc.Flow, error) {
+	found, err := m.s.findFirst(oidcs, func(row interface{}) bool {
+		return row.(*oidc.Flow).FlowID == flowID
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found.(*oidc.Flow), nil
+}
+
+func (m *memoryOIDCRepository) Update(ctx context.Context, updateFlow oidc.Flow) (*oidc.Flow, error) {
+	updated, err := m.s.update(ctx, oidcs, updateFlow)
+	if err != nil {
+		return nil, err
+	}
+	return updated.(*oidc.Flow), nil
+}
+
+func (m *memoryOIDCRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	m.s.delete(ctx, oidcs, id)
+	return nil
+}
+
+func (m *memoryOIDCRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	return m.s.deleteWhere(ctx, oidcs, limit, func(row interface{}) bool {
+		f := row.(*oidc.Flow)
+		return f.ExpiresAt.Before(before) || f.Status == oidc.Complete && finishedBefore(f.UpdatedAt, before)
+	}), nil
+}
+
+// finishedBefore checks whether a flow was last updated, which is when it was finished, before the time provided
+func finishedBefore(updatedAt *time.Time, before time.Time) bool {
+	return updatedAt != nil && updatedAt.Before(before)
+}
@@ -0,0 +1,430 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound is the same error that the gorm repositories return so that callers can't tell them apart
+	ErrNotFound = gorm.ErrRecordNotFound
+	// ErrDuplicateKey occurs when a row would violate one of the unique indexes that the models define
+	ErrDuplicateKey = errors.New("Duplicate key violates unique constraint")
+)
+
+// Tables that the Store holds
+const (
+	sessions      = "sessions"
+	identities    = "identities"
+	contacts      = "contacts"
+	credentials   = "credentials"
+	identifiers   = "identifiers"
+	logins        = "logins"
+	registrations = "registrations"
+	recoveries    = "recoveries"
+	verifications = "verifications"
+	settingsFlows = "settings"
+	oidcs         = "oidc"
+	messages      = "email_outbox"
+	deliveries    = "webhook_deliveries"
+	entries       = "audit_entries"
+	keys          = "signing_keys"
+	clients       = "oauth2_clients"
+	consents      = "oauth2_consents"
+	requests      = "oauth2_requests"
+	refreshTokens = "oauth2_refresh_tokens"
+)
+
+// uniqueKey extracts the value of a unique index from a row. Rows that don't have a value, ie. a nil pointer, aren't
+// a part of the index
+type uniqueKey func(row interface{}) (string, bool)
+
+type table struct {
+	rows   map[uuid.UUID]interface{}
+	unique []uniqueKey
+}
+
+// Store holds every table in memory. Rows are copied on the way in and on the way out so that callers can never share
+// state with the Store or with each other, just like they wouldn't with a database
+type Store struct {
+	mu     sync.RWMutex
+	tables map[string]*table
+	// txMu serializes transactions so that one that's rolled back can't undo what another has written
+	txMu sync.Mutex
+}
+
+// tx records how to undo every write that was made as a part of a transaction
+type tx struct {
+	undo []func()
+}
+
+// txKey is the context key that holds the current transaction
+type txKey struct{}
+
+type memoryTransactor struct {
+	s *Store
+}
+
+// NewStore creates an empty Store with every table that the repositories need
+func NewStore() *Store {
+	s := &Store{
+		tables: map[string]*table{},
+	}
+	for _, name := range []string{identities, contacts, credentials, identifiers, sessions, logins, registrations, recoveries, verifications, settingsFlows, oidcs, messages, deliveries, entries, keys, clients, consents, requests, refreshTokens} {
+		s.tables[name] = &table{
+			rows: map[uuid.UUID]interface{}{},
+		}
+	}
+	s.tables[identities].unique = []uniqueKey{field("Email")}
+	s.tables[contacts].unique = []uniqueKey{field("Value")}
+	s.tables[identifiers].unique = []uniqueKey{field("Value")}
+	s.tables[sessions].unique = []uniqueKey{field("Token")}
+	s.tables[logins].unique = []uniqueKey{field("FlowID"), field("LinkID")}
+	s.tables[registrations].unique = []uniqueKey{field("FlowID")}
+	s.tables[recoveries].unique = []uniqueKey{field("FlowID"), field("RecoverID")}
+	s.tables[verifications].unique = []uniqueKey{field("FlowID"), field("VerifyID")}
+	s.tables[settingsFlows].unique = []uniqueKey{field("FlowID")}
+	s.tables[oidcs].unique = []uniqueKey{field("FlowID")}
+	s.tables[messages].unique = []uniqueKey{field("IdempotencyKey")}
+	s.tables[consents].unique = []uniqueKey{field("IdentityID", "ClientID")}
+	s.tables[requests].unique = []uniqueKey{field("Challenge"), field("Code")}
+	s.tables[refreshTokens].unique = []uniqueKey{field("Token")}
+	return s
+}
+
+// NewMemoryTransactor runs transactions against the Store. Writes made by a transaction that fails are undone, and
+// only one transaction runs at a time
+func NewMemoryTransactor(s *Store) internal.Transactor {
+	return &memoryTransactor{s: s}
+}
+
+func (m *memoryTransactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*tx); ok {
+		return fn(ctx)
+	}
+	m.s.txMu.Lock()
+	defer m.s.txMu.Unlock()
+
+	ctx, runHooks := internal.WithCommitHooks(ctx)
+	t := &tx{}
+	if err := fn(context.WithValue(ctx, txKey{}, t)); err != nil {
+		m.s.mu.Lock()
+		for i := len(t.undo) - 1; i >= 0; i-- {
+			t.undo[i]()
+		}
+		m.s.mu.Unlock()
+		return err
+	}
+	runHooks()
+	return nil
+}
+
+// atomic runs fn so that either all or none of its writes are kept, which is what gorm does for a single statement
+// that writes associations. The caller must hold the write lock
+func (s *Store) atomic(ctx context.Context, fn func(ctx context.Context) error) error {
+	outer, _ := ctx.Value(txKey{}).(*tx)
+	t := &tx{}
+	if err := fn(context.WithValue(ctx, txKey{}, t)); err != nil {
+		for i := len(t.undo) - 1; i >= 0; i-- {
+			t.undo[i]()
+		}
+		return err
+	}
+	if outer != nil {
+		outer.undo = append(outer.undo, t.undo...)
+	}
+	return nil
+}
+
+// create inserts a row while holding the write lock
+func (s *Store) create(ctx context.Context, name string, row interface{}) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.insert(ctx, name, row)
+}
+
+// update saves a row while holding the write lock
+func (s *Store) update(ctx context.Context, name string, row interface{}) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.save(ctx, name, row)
+}
+
+// delete removes a row, if it exists, while holding the write lock
+func (s *Store) delete(ctx context.Context, name string, id uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(ctx, name, id)
+}
+
+// find retrieves a row via ID while holding the read lock
+func (s *Store) find(name string, id uuid.UUID) (interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row, ok := s.get(name, id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return row, nil
+}
+
+// findFirst retrieves the first row that matches while holding the read lock
+func (s *Store) findFirst(name string, match func(row interface{}) bool) (interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row, ok := s.first(name, match)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return row, nil
+}
+
+// deleteWhere removes up to limit rows that match, while holding the write lock, and returns how many were removed
+func (s *Store) deleteWhere(ctx context.Context, name string, limit int, match func(row interface{}) bool) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, row := range s.tables[name].rows {
+		if deleted >= int64(limit) {
+			break
+		}
+		if match(row) && s.remove(ctx, name, id) {
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// insert adds a new row to a table. Like gorm, a missing ID and timestamps are filled in. The caller must hold the
+// write lock
+func (s *Store) insert(ctx context.Context, name string, row interface{}) (interface{}, error) {
+	row = clone(row)
+	prepare(row, true)
+	id := idOf(row)
+	if _, ok := s.tables[name].rows[id]; ok {
+		return nil, ErrDuplicateKey
+	}
+	if err := s.checkUnique(name, id, row); err != nil {
+		return nil, err
+	}
+	s.set(ctx, name, id, row)
+	return clone(row), nil
+}
+
+// save inserts or replaces a row, which mirrors gorm's Save. The caller must hold the write lock
+func (s *Store) save(ctx context.Context, name string, row interface{}) (interface{}, error) {
+	row = clone(row)
+	prepare(row, false)
+	id := idOf(row)
+	if err := s.checkUnique(name, id, row); err != nil {
+		return nil, err
+	}
+	s.set(ctx, name, id, row)
+	return clone(row), nil
+}
+
+// remove deletes a row if it exists. The caller must hold the write lock
+func (s *Store) remove(ctx context.Context, name string, id uuid.UUID) bool {
+	if _, ok := s.tables[name].rows[id]; !ok {
+		return false
+	}
+	s.set(ctx, name, id, nil)
+	return true
+}
+
+// set replaces the row with id, or deletes it if row is nil, while recording how to undo it if ctx is a part of a
+// transaction. The caller must hold the write lock
+func (s *Store) set(ctx context.Context, name string, id uuid.UUID, row interface{}) {
+	rows := s.tables[name].rows
+	if t, ok := ctx.Value(txKey{}).(*tx); ok {
+		previous, existed := rows[id]
+		t.undo = append(t.undo, func() {
+			if existed {
+				rows[id] = previous
+			} else {
+				delete(rows, id)
+			}
+		})
+	}
+	if row == nil {
+		delete(rows, id)
+		return
+	}
+	rows[id] = row
+}
+
+// get retrieves a copy of the row with id. The caller must hold at least the read lock
+func (s *Store) get(name string, id uuid.UUID) (interface{}, bool) {
+	row, ok := s.tables[name].rows[id]
+	if !ok {
+		return nil, false
+	}
+	return clone(row), true
+}
+
+// first retrieves a copy of the row that matches, ordered by ID like gorm's First. The caller must hold at least the
+// read lock
+func (s *Store) first(name string, match func(row interface{}) bool) (interface{}, bool) {
+	found := s.filter(name, match)
+	if len(found) == 0 {
+		return nil, false
+	}
+	sort.Slice(found, func(i, j int) bool {
+		a, b := idOf(found[i]), idOf(found[j])
+		return a.String() < b.String()
+	})
+	return found[0], true
+}
+
+// filter retrieves a copy of every row that matches in no particular order. The caller must hold at least the read lock
+func (s *Store) filter(name string, match func(row interface{}) bool) []interface{} {
+	var found []interface{}
+	for _, row := range s.tables[name].rows {
+		if match == nil || match(row) {
+			found = append(found, clone(row))
+		}
+	}
+	return found
+}
+
+// checkUnique makes sure that row doesn't share a unique index with any other row in the table
+func (s *Store) checkUnique(name string, id uuid.UUID, row interface{}) error {
+	t := s.tables[name]
+	for _, key := range t.unique {
+		value, ok := key(row)
+		if !ok {
+			continue
+		}
+		for otherID, other := range t.rows {
+			if otherID == id {
+				continue
+			}
+			if otherValue, ok := key(other); ok && otherValue == value {
+				return ErrDuplicateKey
+			}
+		}
+	}
+	return nil
+}
+
+// field creates a uniqueKey out of one or more fields
+func field(names ...string) uniqueKey {
+	return func(row interface{}) (string, bool) {
+		v := reflect.Indirect(reflect.ValueOf(row))
+		var key string
+		for _, name := range names {
+			f := v.FieldByName(name)
+			if f.Kind() == reflect.Ptr {
+				if f.IsNil() {
+					return "", false
+				}
+				f = f.Elem()
+			}
+			key += "\x00" + toString(f)
+		}
+		return key, true
+	}
+}
+
+func toString(v reflect.Value) string {
+	if s, ok := v.Interface().(interface{ String() string }); ok {
+		return s.String()
+	}
+	return v.String()
+}
+
+// idOf retrieves the ID of a row
+func idOf(row interface{}) uuid.UUID {
+	return reflect.Indirect(reflect.ValueOf(row)).FieldByName("ID").Interface().(uuid.UUID)
+}
+
+// prepare fills in what gorm would have on a create or a save ie. the ID, CreatedAt and UpdatedAt. row must be a pointer
+func prepare(row interface{}, create bool) {
+	v := reflect.ValueOf(row).Elem()
+	now := time.Now()
+	if id, ok := v.FieldByName("ID").Addr().Interface().(*uuid.UUID); ok && *id == uuid.Nil {
+		*id, _ = uuid.NewV4()
+	}
+	if f := v.FieldByName("CreatedAt"); f.IsValid() {
+		if createdAt, ok := f.Addr().Interface().(*time.Time); ok && createdAt.IsZero() {
+			*createdAt = now
+		}
+	}
+	if f := v.FieldByName("UpdatedAt"); f.IsValid() {
+		if updatedAt, ok := f.Addr().Interface().(**time.Time); ok && (!create || *updatedAt == nil) {
+			*updatedAt = &now
+		}
+	}
+}
+
+// clone deep copies a row and always returns a pointer to the copy, whether row was a pointer or not
+func clone(row interface{}) interface{} {
+	src := reflect.Indirect(reflect.ValueOf(row))
+	dst := reflect.New(src.Type())
+	deepCopy(dst.Elem(), src)
+	return dst.Interface()
+}
+
+func deepCopy(dst reflect.Value, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		copied := reflect.New(src.Elem().Type())
+		deepCopy(copied.Elem(), src.Elem())
+		dst.Set(copied)
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		copied := reflect.New(src.Elem().Type()).Elem()
+		deepCopy(copied, src.Elem())
+		dst.Set(copied)
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		copied := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(copied.Index(i), src.Index(i))
+		}
+		dst.Set(copied)
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		copied := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			value := reflect.New(iter.Value().Type()).Elem()
+			deepCopy(value, iter.Value())
+			copied.SetMapIndex(iter.Key(), value)
+		}
+		dst.Set(copied)
+	case reflect.Struct:
+		// Unexported fields, ie. the ones in time.Time, are copied as is
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				deepCopy(dst.Field(i), src.Field(i))
+			}
+		}
+	default:
+		dst.Set(src)
+	}
+}
@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/RagOfJoes/mylo/oauth2"
+	"github.com/gofrs/uuid"
+)
+
+type memoryOAuth2Repository struct {
+	s *Store
+}
+
+func NewMemoryOAuth2Repository(s *Store) oauth2.Repository {
+	return &memoryOAuth2Repository{s: s}
+}
+
+func (m *memoryOAuth2Repository) CreateClient(ctx context.Context, newClient oauth2.Client) (*oauth2.Client, error) {
+	created, err := m.s.create(ctx, clients, newClient)
+	if err != nil {
+		return nil, err
+	}
+	return created.(*oauth2.Client), nil
+}
+
+func (m *memoryOAuth2Repository) GetClient(ctx context.Context, id uuid.UUID) (*oauth2.Client, error) {
+	found, err := m.s.find(clients, id)
+	if err != nil {
+		return nil, err
+	}
+	return found.(*oauth2.Client), nil
+}
+
+func (m *memoryOAuth2Repository) GetAllClients(ctx context.Context) ([]oauth2.Client, error) {
+	m.s.mu.RLock()
+	defer m.s.mu.RUnlock()
+
+	matched := m.s.filter(clients, nil)
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].(*oauth2.Client).CreatedAt.After(matched[j].(*oauth2.Client).CreatedAt)
+	})
+	found := []oauth2.Client{}
+	for _, row := range matched {
+		found = append(found, *row.(*oauth2.Client))
+	}
+	return found, nil
+}
+
+func (m *memoryOAuth2Repository) DeleteClient(ctx context.Context, id uuid.UUID) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.get(clients, id); !ok {
+		return ErrNotFound
+	}
+	return m.s.atomic(ctx, func(ctx context.Context) error {
+		for _, row := range m.s.filter(refreshTokens, func(row interface{}) bool {
+			return row.(*oauth2.RefreshToken).ClientID == id
+		}) {
+			m.s.remove(ctx, refreshTokens, row.(*oauth2.RefreshToken).ID)
+		}
+		for _, row := range m.s.filter(requests, func(row interface{}) bool {
+			return row.(*oauth2.Request).ClientID == id
+		}) {
+			m.s.remove(ctx, requests, row.(*oauth2.Request).ID)
+		}
+		for _, row := range m.s.filter(consents, func(row interface{}) bool {
+			return row.(*oauth2.Consent).ClientID == id
+		}) {
+			m.s.remove(ctx, consents, row.(*oauth2.Consent).ID)
+		}
+		m.s.remove(ctx, clients, id)
+		return nil
+	})
+}
+
+func (m *memoryOAuth2Repository) GetConsent(ctx context.Context, identityID uuid.UUID, clientID uuid.UUID) (*oauth2.Consent, error) {
+	found, err := m.s.findFirst(consents, func(row interface{}) bool {
+		c := row.(*oauth2.Consent)
+		return c.IdentityID == identityID && c.ClientID == clientID
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found.(*oauth2.Consent), nil
+}
+
+func (m *memoryOAuth2Repository) UpsertConsent(ctx context.Context, consent oauth2.Consent) (*oauth2.Consent, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	existing, ok := m.s.first(consents, func(row interface{}) bool {
+		c := row.(*oauth2.Consent)
+		return c.IdentityID == consent.IdentityID && c.ClientID == consent.ClientID
+	})
+	if !ok {
+		created, err := m.s.insert(ctx, consents, consent)
+		if err != nil {
+			return nil, err
+		}
+		return created.(*oauth2.Consent), nil
+	}
+	// Like the conflict clause that the gorm repository uses, only the scope of an existing consent is replaced
+	updated := existing.(*oauth2.Consent)
+	updated.Scope = consent.Scope
+	if _, err := m.s.save(ctx, consents, updated); err != nil {
+		return nil, err
+	}
+	upserted := consent
+	upserted.UpdatedAt = updated.UpdatedAt
+	return &upserted, nil
+}
+
+func (m *memoryOAuth2Repository) CreateRequest(ctx context.Context, newRequest oauth2.Request) (*oauth2.Request, error) {
+	created, err := m.s.create(ctx, requests, newRequest)
+	if err != nil {
+		return nil, err
+	}
+	return created.(*oauth2.Request), nil
+}
+
+func (m *memoryOAuth2Repository) GetRequestByChallenge(ctx context.Context, challenge string) (*oauth2.Request, error) {
+	found, err := m.s.findFirst(requests, func(row interface{}) bool {
+		return row.(*oauth2.Request).Challenge == challenge
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found.(*oauth2.Request), nil
+}
+
+func (m *memoryOAuth2Repository) GetRequestByCode(ctx context.Context, code string) (*oauth2.Request, error) {
+	found, err := m.s.findFirst(requests, func(row interface{}) bool {
+		r := row.(*oauth2.Request)
+		return r.Code != nil && *r.Code == code
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found.(*oauth2.Request), nil
+}
+
+func (m *memoryOAuth2Repository) UpdateRequest(ctx context.Context, updateRequest oauth2.Request) (*oauth2.Request, error) {
+	updated, err := m.s.update(ctx, requests, updateRequest)
+	if err != nil {
+		return nil, err
+	}
+	return updated.(*oauth2.Request), nil
+}
+
+func (m *memoryOAuth2Repository) TransitionRequest(ctx context.Context, id uuid.UUID, from oauth2.Status, to oauth2.Status) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	row, ok := m.s.get(requests, id)
+	if !ok || row.(*oauth2.Request).Status != from {
+		return ErrNotFound
+	}
+	found := row.(*oauth2.Request)
+	found.Status = to
+	_, err := m.s.save(ctx, requests, found)
+	return err
+}
+
+func (m *memoryOAuth2Repository) CreateRefreshToken(ctx context.Context, newToken oauth2.RefreshToken) (*oauth2.RefreshToken, error) {
+	created, err := m.s.create(ctx, refreshTokens, newToken)
+	if err != nil {
+		return nil, err
+	}
+	return created.(*oauth2.RefreshToken), nil
+}
+
+func (m *memoryOAuth2Repository) GetRefreshToken(ctx context.Context, token string) (*oauth2.RefreshToken, error) {
+	found, err := m.s.findFirst(refreshTokens, func(row interface{}) bool {
+		return row.(*oauth2.RefreshToken).Token == token
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found.(*oauth2.RefreshToken), nil
+}
+
+func (m *memoryOAuth2Repository) RevokeRefreshToken(ctx context.Context, id uuid.UUID) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	row, ok := m.s.get(refreshTokens, id)
+	if !ok || row.(*oauth2.RefreshToken).RevokedAt != nil {
+		return ErrNotFound
+	}
+	found := row.(*oauth2.RefreshToken)
+	now := time.Now()
+	found.RevokedAt = &now
+	_, err := m.s.save(ctx, refreshTokens, found)
+	return err
+}
+
+func (m *memoryOAuth2Repository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	now := time.Now()
+	return m.s.atomic(ctx, func(ctx context.Context) error {
+		for _, row := range m.s.filter(refreshTokens, func(row interface{}) bool {
+			t := row.(*oauth2.RefreshToken)
+			return t.FamilyID == familyID && t.RevokedAt == nil
+		}) {
+			found := row.(*oauth2.RefreshToken)
+			found.RevokedAt = &now
+			if _, err := m.s.save(ctx, refreshTokens, found); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
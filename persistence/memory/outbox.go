@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/RagOfJoes/mylo/email/outbox"
+)
+
+type memoryOutboxRepository struct {
+	s *Store
+}
+
+func NewMemoryOutboxRepository(s *Store) outbox.Repository {
+	return &memoryOutboxRepository{s: s}
+}
+
+func (m *memoryOutboxRepository) Create(ctx context.Context, newMessage outbox.Message) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	// Queueing a message with an idempotency key that already exists is a no-op
+	if _, ok := m.s.first(messages, func(row interface{}) bool {
+		return row.(*outbox.Message).IdempotencyKey == newMessage.IdempotencyKey
+	}); ok {
+		return nil
+	}
+	_, err := m.s.insert(ctx, messages, newMessage)
+	return err
+}
+
+func (m *memoryOutboxRepository) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]outbox.Message, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	due := m.s.filter(messages, func(row interface{}) bool {
+		msg := row.(*outbox.Message)
+		return msg.Status == outbox.Pending && !msg.NextAttemptAt.After(now)
+	})
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].(*outbox.Message).NextAttemptAt.Before(due[j].(*outbox.Message).NextAttemptAt)
+	})
+	claimed := []outbox.Message{}
+	for _, row := range page(due, 0, limit) {
+		msg := row.(*outbox.Message)
+		claimed = append(claimed, *msg)
+		// Only what's stored is pushed back, the messages are returned as they were when they were claimed
+		msg.NextAttemptAt = now.Add(lease)
+		if _, err := m.s.save(ctx, messages, msg); err != nil {
+			return nil, err
+		}
+	}
+	return claimed, nil
+}
+
+func (m *memoryOutboxRepository) Update(ctx context.Context, updateMessage outbox.Message) (*outbox.Message, error) {
+	updated, err := m.s.update(ctx, messages, updateMessage)
+	if err != nil {
+		return nil, err
+	}
+	return updated.(*outbox.Message), nil
+}
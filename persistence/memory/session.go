@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/session"
+	"github.com/gofrs/uuid"
+)
+
+type memorySessionRepository struct {
+	s *Store
+}
+
+func NewMemorySessionRepository(s *Store) session.Repository {
+	return &memorySessionRepository{s: s}
+}
+
+func (m *memorySessionRepository) Create(ctx context.Context, newSession session.Session) (*session.Session, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	row := newSession
+	row.Identity = nil
+	if _, err := m.s.insert(ctx, sessions, row); err != nil {
+		return nil, err
+	}
+	created := newSession
+	return &created, nil
+}
+
+func (m *memorySessionRepository) Get(ctx context.Context, id uuid.UUID) (*session.Session, error) {
+	m.s.mu.RLock()
+	defer m.s.mu.RUnlock()
+
+	found, ok := m.s.get(sessions, id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return m.withIdentity(found.(*session.Session))
+}
+
+func (m *memorySessionRepository) GetByToken(ctx context.Context, token string) (*session.Session, error) {
+	m.s.mu.RLock()
+	defer m.s.mu.RUnlock()
+
+	found, ok := m.s.first(sessions, func(row interface{}) bool {
+		return row.(*session.Session).Token == token
+	})
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return m.withIdentity(found.(*session.Session))
+}
+
+func (m *memorySessionRepository) Update(ctx context.Context, updateSession session.Session) (*session.Session, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	// Make sure we're not accidentally updating the Identity
+	row := updateSession
+	row.Identity = nil
+	if _, err := m.s.save(ctx, sessions, row); err != nil {
+		return nil, err
+	}
+	updated := updateSession
+	return &updated, nil
+}
+
+func (m *memorySessionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	m.s.remove(ctx, sessions, id)
+	return nil
+}
+
+func (m *memorySessionRepository) GetAllIdentity(ctx context.Context, identityID uuid.UUID) ([]session.Session, error) {
+	m.s.mu.RLock()
+	defer m.s.mu.RUnlock()
+
+	found := []session.Session{}
+	for _, row := range m.s.filter(sessions, func(row interface{}) bool {
+		s := row.(*session.Session)
+		return s.IdentityID != nil && *s.IdentityID == identityID
+	}) {
+		found = append(found, *row.(*session.Session))
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		a, b := found[i].AuthenticatedAt, found[j].AuthenticatedAt
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.After(*b)
+	})
+	return found, nil
+}
+
+func (m *memorySessionRepository) DeleteAllIdentity(ctx context.Context, identityID uuid.UUID) error {
+	return m.deleteAllIdentity(ctx, identityID, uuid.Nil)
+}
+
+func (m *memorySessionRepository) DeleteAllIdentityExcept(ctx context.Context, identityID uuid.UUID, id uuid.UUID) error {
+	return m.deleteAllIdentity(ctx, identityID, id)
+}
+
+// withIdentity attaches the identity, if any, that the session belongs to. The caller must hold at least the read lock
+func (m *memorySessionRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	created := before.Add(-config.Get().Session.Lifetime)
+	return m.s.deleteWhere(ctx, sessions, limit, func(row interface{}) bool {
+		s := row.(*session.Session)
+		if s.ExpiresAt == nil {
+			return s.CreatedAt.Before(created)
+		}
+		return s.ExpiresAt.Before(before)
+	}), nil
+}
+
+func (m *memorySessionRepository) withIdentity(found *session.Session) (*session.Session, error) {
+	if found.IdentityID == nil {
+		return found, nil
+	}
+	user, err := getIdentity(m.s, *found.IdentityID, false)
+	if err != nil {
+		return nil, err
+	}
+	found.Identity = user
+	return found, nil
+}
+
+// deleteAllIdentity deletes every session that belongs to an identity except for except, which can be uuid.Nil
+func (m *memorySessionRepository) deleteAllIdentity(ctx context.Context, identityID uuid.UUID, except uuid.UUID) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, row := range m.s.filter(sessions, func(row interface{}) bool {
+		s := row.(*session.Session)
+		return s.IdentityID != nil && *s.IdentityID == identityID && s.ID != except
+	}) {
+		m.s.remove(ctx, sessions, row.(*session.Session).ID)
+	}
+	return nil
+}
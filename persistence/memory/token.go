@@ -0,0 +1,44 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/RagOfJoes/mylo/token"
+)
+
+type memoryTokenRepository struct {
+	s *Store
+}
+
+func NewMemoryTokenRepository(s *Store) token.Repository {
+	return &memoryTokenRepository{s: s}
+}
+
+func (m *memoryTokenRepository) Create(ctx context.Context, newKey token.Key) (*token.Key, error) {
+	created, err := m.s.create(ctx, keys, newKey)
+	if err != nil {
+		return nil, err
+	}
+	return created.(*token.Key), nil
+}
+
+func (m *memoryTokenRepository) GetAllUnexpired(ctx context.Context) ([]token.Key, error) {
+	m.s.mu.RLock()
+	defer m.s.mu.RUnlock()
+
+	now := time.Now()
+	matched := m.s.filter(keys, func(row interface{}) bool {
+		return row.(*token.Key).ExpiresAt.After(now)
+	})
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].(*token.Key).CreatedAt.After(matched[j].(*token.Key).CreatedAt)
+	})
+
+	found := []token.Key{}
+	for _, row := range matched {
+		found = append(found, *row.(*token.Key))
+	}
+	return found, nil
+}
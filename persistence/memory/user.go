@@ -0,0 +1,548 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RagOfJoes/mylo/session"
+	"github.com/RagOfJoes/mylo/user/contact"
+	"github.com/RagOfJoes/mylo/user/credential"
+	"github.com/RagOfJoes/mylo/user/identity"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+type memoryIdentityRepository struct {
+	s *Store
+}
+
+type memoryContactRepository struct {
+	s *Store
+}
+
+type memoryCredentialRepository struct {
+	s *Store
+}
+
+func NewMemoryIdentityRepository(s *Store) identity.Repository {
+	return &memoryIdentityRepository{s: s}
+}
+
+func NewMemoryContactRepository(s *Store) contact.Repository {
+	return &memoryContactRepository{s: s}
+}
+
+func NewMemoryCredentialRepository(s *Store) credential.Repository {
+	return &memoryCredentialRepository{s: s}
+}
+
+func (m *memoryIdentityRepository) Create(ctx context.Context, newIdentity identity.Identity) (*identity.Identity, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	var created *identity.Identity
+	// Like gorm, the contacts and credentials of a new identity are created along with it
+	if err := m.s.atomic(ctx, func(ctx context.Context) error {
+		row := newIdentity
+		row.Contacts = nil
+		row.Credentials = nil
+		inserted, err := m.s.insert(ctx, identities, row)
+		if err != nil {
+			return err
+		}
+		created = inserted.(*identity.Identity)
+		for _, c := range newIdentity.Contacts {
+			c.IdentityID = created.ID
+			insertedContact, err := m.s.insert(ctx, contacts, c)
+			if err != nil {
+				return err
+			}
+			created.Contacts = append(created.Contacts, *insertedContact.(*contact.Contact))
+		}
+		for _, c := range newIdentity.Credentials {
+			c.IdentityID = created.ID
+			insertedCredential, err := insertCredential(ctx, m.s, c)
+			if err != nil {
+				return err
+			}
+			created.Credentials = append(created.Credentials, *insertedCredential)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (m *memoryIdentityRepository) Get(ctx context.Context, id uuid.UUID, critical bool) (*identity.Identity, error) {
+	m.s.mu.RLock()
+	defer m.s.mu.RUnlock()
+
+	return getIdentity(m.s, id, critical)
+}
+
+func (m *memoryIdentityRepository) GetWithIdentifier(ctx context.Context, identifier string, critical bool) (*identity.Identity, error) {
+	m.s.mu.RLock()
+	defer m.s.mu.RUnlock()
+
+	// First check the credentials to make sure that the identifier provided is valid
+	found, ok := m.s.first(identifiers, func(row interface{}) bool {
+		return strings.EqualFold(row.(*credential.Identifier).Value, identifier)
+	})
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cred, ok := m.s.get(credentials, found.(*credential.Identifier).CredentialID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return getIdentity(m.s, cred.(*credential.Credential).IdentityID, critical)
+}
+
+func (m *memoryIdentityRepository) Update(ctx context.Context, updateIdentity identity.Identity) (*identity.Identity, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	// Make sure we're not accidentally updating any associations
+	row := updateIdentity
+	row.Contacts = nil
+	row.Credentials = nil
+	saved, err := m.s.save(ctx, identities, row)
+	if err != nil {
+		return nil, err
+	}
+	updated := saved.(*identity.Identity)
+	updated.Contacts = updateIdentity.Contacts
+	updated.Credentials = updateIdentity.Credentials
+	return updated, nil
+}
+
+func (m *memoryIdentityRepository) FailLogin(ctx context.Context, id uuid.UUID, max int, cooledDown *time.Time) (*identity.Identity, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	row, ok := m.s.get(identities, id)
+	if !ok || row.(*identity.Identity).DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+	found := row.(*identity.Identity)
+	if found.LockedAt != nil && cooledDown != nil && !found.LockedAt.After(*cooledDown) {
+		found.LockedAt = nil
+		found.FailedLogins = 0
+	}
+	found.FailedLogins++
+	if max > 0 && found.LockedAt == nil && found.FailedLogins >= max {
+		now := time.Now()
+		found.LockedAt = &now
+	}
+	if _, err := m.s.save(ctx, identities, found); err != nil {
+		return nil, err
+	}
+	return getIdentity(m.s, id, false)
+}
+
+func (m *memoryIdentityRepository) Delete(ctx context.Context, id uuid.UUID, permanent bool) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	row, ok := m.s.get(identities, id)
+	if !ok {
+		return nil
+	}
+	found := row.(*identity.Identity)
+	if !permanent {
+		if found.DeletedAt.Valid {
+			return nil
+		}
+		found.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		_, err := m.s.save(ctx, identities, found)
+		return err
+	}
+	// Associations are only removed when permanently deleting so that a soft deleted identity can be restored
+	return m.s.atomic(ctx, func(ctx context.Context) error {
+		for _, c := range m.s.filter(contacts, func(row interface{}) bool {
+			return row.(*contact.Contact).IdentityID == id
+		}) {
+			m.s.remove(ctx, contacts, c.(*contact.Contact).ID)
+		}
+		for _, c := range m.s.filter(credentials, func(row interface{}) bool {
+			return row.(*credential.Credential).IdentityID == id
+		}) {
+			deleteCredential(ctx, m.s, c.(*credential.Credential).ID)
+		}
+		for _, row := range m.s.filter(sessions, func(row interface{}) bool {
+			s := row.(*session.Session)
+			return s.IdentityID != nil && *s.IdentityID == id
+		}) {
+			m.s.remove(ctx, sessions, row.(*session.Session).ID)
+		}
+		m.s.remove(ctx, identities, id)
+		return nil
+	})
+}
+
+func (m *memoryIdentityRepository) List(ctx context.Context, filter identity.Filter, offset int, limit int) ([]identity.Identity, int64, error) {
+	m.s.mu.RLock()
+	defer m.s.mu.RUnlock()
+
+	search := strings.ToLower(filter.Search)
+	matched := m.s.filter(identities, func(row interface{}) bool {
+		i := row.(*identity.Identity)
+		if i.DeletedAt.Valid != filter.Deleted {
+			return false
+		}
+		if search == "" {
+			return true
+		}
+		for _, value := range []string{i.Email, i.FirstName, i.LastName} {
+			if strings.Contains(strings.ToLower(value), search) {
+				return true
+			}
+		}
+		return hasIdentifier(m.s, i.ID, search)
+	})
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].(*identity.Identity).CreatedAt.After(matched[j].(*identity.Identity).CreatedAt)
+	})
+
+	total := int64(len(matched))
+	found := []identity.Identity{}
+	for _, row := range page(matched, offset, limit) {
+		i := row.(*identity.Identity)
+		i.Contacts = identityContacts(m.s, i.ID)
+		found = append(found, *i)
+	}
+	return found, total, nil
+}
+
+func (m *memoryIdentityRepository) Restore(ctx context.Context, id uuid.UUID) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	row, ok := m.s.get(identities, id)
+	if !ok || !row.(*identity.Identity).DeletedAt.Valid {
+		return ErrNotFound
+	}
+	found := row.(*identity.Identity)
+	found.DeletedAt = gorm.DeletedAt{}
+	_, err := m.s.save(ctx, identities, found)
+	return err
+}
+
+func (m *memoryContactRepository) Create(ctx context.Context, newContacts ...contact.Contact) ([]contact.Contact, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	created := make([]contact.Contact, 0, len(newContacts))
+	if err := m.s.atomic(ctx, func(ctx context.Context) error {
+		for _, c := range newContacts {
+			inserted, err := m.s.insert(ctx, contacts, c)
+			if err != nil {
+				return err
+			}
+			created = append(created, *inserted.(*contact.Contact))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (m *memoryContactRepository) Update(ctx context.Context, updateContact contact.Contact) (*contact.Contact, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	saved, err := m.s.save(ctx, contacts, updateContact)
+	if err != nil {
+		return nil, err
+	}
+	return saved.(*contact.Contact), nil
+}
+
+func (m *memoryContactRepository) Get(ctx context.Context, contactID uuid.UUID) (*contact.Contact, error) {
+	m.s.mu.RLock()
+	defer m.s.mu.RUnlock()
+
+	found, ok := m.s.get(contacts, contactID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return found.(*contact.Contact), nil
+}
+
+func (m *memoryContactRepository) GetByValue(ctx context.Context, value string) (*contact.Contact, error) {
+	m.s.mu.RLock()
+	defer m.s.mu.RUnlock()
+
+	found, ok := m.s.first(contacts, func(row interface{}) bool {
+		return strings.EqualFold(row.(*contact.Contact).Value, value)
+	})
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return found.(*contact.Contact), nil
+}
+
+func (m *memoryContactRepository) Delete(ctx context.Context, contactID uuid.UUID) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	m.s.remove(ctx, contacts, contactID)
+	return nil
+}
+
+func (m *memoryContactRepository) DeleteAllUser(ctx context.Context, identityID uuid.UUID) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, c := range m.s.filter(contacts, func(row interface{}) bool {
+		return row.(*contact.Contact).IdentityID == identityID
+	}) {
+		m.s.remove(ctx, contacts, c.(*contact.Contact).ID)
+	}
+	return nil
+}
+
+func (m *memoryCredentialRepository) Create(ctx context.Context, newCredential credential.Credential) (*credential.Credential, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	var created *credential.Credential
+	if err := m.s.atomic(ctx, func(ctx context.Context) error {
+		var err error
+		created, err = insertCredential(ctx, m.s, newCredential)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (m *memoryCredentialRepository) GetIdentifier(ctx context.Context, identifier string) (*credential.Identifier, error) {
+	m.s.mu.RLock()
+	defer m.s.mu.RUnlock()
+
+	found, ok := m.s.first(identifiers, func(row interface{}) bool {
+		return strings.EqualFold(row.(*credential.Identifier).Value, identifier)
+	})
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return found.(*credential.Identifier), nil
+}
+
+func (m *memoryCredentialRepository) GetWithIdentifier(ctx context.Context, credentialType credential.CredentialType, identifier string) (*credential.Credential, error) {
+	m.s.mu.RLock()
+	defer m.s.mu.RUnlock()
+
+	found, ok := m.s.first(identifiers, func(row interface{}) bool {
+		return strings.EqualFold(row.(*credential.Identifier).Value, identifier)
+	})
+	if !ok {
+		return nil, ErrNotFound
+	}
+	row, ok := m.s.get(credentials, found.(*credential.Identifier).CredentialID)
+	if !ok || row.(*credential.Credential).Type != credentialType {
+		return nil, ErrNotFound
+	}
+	cred := row.(*credential.Credential)
+	cred.Identifiers = credentialIdentifiers(m.s, cred.ID)
+	return cred, nil
+}
+
+func (m *memoryCredentialRepository) GetWithIdentityID(ctx context.Context, credentialType credential.CredentialType, identityID uuid.UUID) (*credential.Credential, error) {
+	m.s.mu.RLock()
+	defer m.s.mu.RUnlock()
+
+	row, ok := m.s.first(credentials, func(row interface{}) bool {
+		c := row.(*credential.Credential)
+		return c.Type == credentialType && c.IdentityID == identityID
+	})
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cred := row.(*credential.Credential)
+	cred.Identifiers = credentialIdentifiers(m.s, cred.ID)
+	return cred, nil
+}
+
+func (m *memoryCredentialRepository) GetAllIdentity(ctx context.Context, identityID uuid.UUID) ([]credential.Credential, error) {
+	m.s.mu.RLock()
+	defer m.s.mu.RUnlock()
+
+	found := []credential.Credential{}
+	for _, row := range m.s.filter(credentials, func(row interface{}) bool {
+		return row.(*credential.Credential).IdentityID == identityID
+	}) {
+		cred := row.(*credential.Credential)
+		cred.Identifiers = credentialIdentifiers(m.s, cred.ID)
+		found = append(found, *cred)
+	}
+	return found, nil
+}
+
+func (m *memoryCredentialRepository) Update(ctx context.Context, updateCredential credential.Credential) (*credential.Credential, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	var updated *credential.Credential
+	if err := m.s.atomic(ctx, func(ctx context.Context) error {
+		row := updateCredential
+		row.Identifiers = nil
+		saved, err := m.s.save(ctx, credentials, row)
+		if err != nil {
+			return err
+		}
+		updated = saved.(*credential.Credential)
+		// Like gorm's Save, identifiers that don't exist yet are created while existing ones are left as is
+		for _, i := range updateCredential.Identifiers {
+			i.CredentialID = updated.ID
+			if existing, ok := m.s.get(identifiers, i.ID); ok {
+				updated.Identifiers = append(updated.Identifiers, *existing.(*credential.Identifier))
+				continue
+			}
+			inserted, err := m.s.insert(ctx, identifiers, i)
+			if err != nil {
+				return err
+			}
+			updated.Identifiers = append(updated.Identifiers, *inserted.(*credential.Identifier))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (m *memoryCredentialRepository) AdvanceCounter(ctx context.Context, id uuid.UUID, counter int64) (bool, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	found, ok := m.s.get(credentials, id)
+	if !ok {
+		return false, nil
+	}
+	cred := found.(*credential.Credential)
+	if cred.LastCounter >= counter {
+		return false, nil
+	}
+	cred.LastCounter = counter
+	if _, err := m.s.save(ctx, credentials, cred); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *memoryCredentialRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.s.atomic(ctx, func(ctx context.Context) error {
+		deleteCredential(ctx, m.s, id)
+		return nil
+	})
+}
+
+// getIdentity retrieves an identity that hasn't been soft deleted along with its contacts and, if critical, its
+// credentials. The caller must hold at least the read lock
+func getIdentity(s *Store, id uuid.UUID, critical bool) (*identity.Identity, error) {
+	row, ok := s.get(identities, id)
+	if !ok || row.(*identity.Identity).DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+	found := row.(*identity.Identity)
+	found.Contacts = identityContacts(s, id)
+	if critical {
+		found.Credentials = []credential.Credential{}
+		for _, c := range s.filter(credentials, func(row interface{}) bool {
+			return row.(*credential.Credential).IdentityID == id
+		}) {
+			found.Credentials = append(found.Credentials, *c.(*credential.Credential))
+		}
+	}
+	return found, nil
+}
+
+// identityContacts retrieves the contacts of an identity. The caller must hold at least the read lock
+func identityContacts(s *Store, identityID uuid.UUID) []contact.Contact {
+	found := []contact.Contact{}
+	for _, row := range s.filter(contacts, func(row interface{}) bool {
+		return row.(*contact.Contact).IdentityID == identityID
+	}) {
+		found = append(found, *row.(*contact.Contact))
+	}
+	return found
+}
+
+// credentialIdentifiers retrieves the identifiers of a credential. The caller must hold at least the read lock
+func credentialIdentifiers(s *Store, credentialID uuid.UUID) []credential.Identifier {
+	found := []credential.Identifier{}
+	for _, row := range s.filter(identifiers, func(row interface{}) bool {
+		return row.(*credential.Identifier).CredentialID == credentialID
+	}) {
+		found = append(found, *row.(*credential.Identifier))
+	}
+	return found
+}
+
+// hasIdentifier checks whether any of an identity's identifiers contain search. The caller must hold at least the read
+// lock
+func hasIdentifier(s *Store, identityID uuid.UUID, search string) bool {
+	for _, row := range s.filter(credentials, func(row interface{}) bool {
+		return row.(*credential.Credential).IdentityID == identityID
+	}) {
+		for _, i := range credentialIdentifiers(s, row.(*credential.Credential).ID) {
+			if strings.Contains(strings.ToLower(i.Value), search) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// insertCredential creates a credential along with its identifiers. The caller must hold the write lock
+func insertCredential(ctx context.Context, s *Store, newCredential credential.Credential) (*credential.Credential, error) {
+	row := newCredential
+	row.Identifiers = nil
+	inserted, err := s.insert(ctx, credentials, row)
+	if err != nil {
+		return nil, err
+	}
+	created := inserted.(*credential.Credential)
+	for _, i := range newCredential.Identifiers {
+		i.CredentialID = created.ID
+		insertedIdentifier, err := s.insert(ctx, identifiers, i)
+		if err != nil {
+			return nil, err
+		}
+		created.Identifiers = append(created.Identifiers, *insertedIdentifier.(*credential.Identifier))
+	}
+	return created, nil
+}
+
+// deleteCredential deletes a credential along with its identifiers. The caller must hold the write lock
+func deleteCredential(ctx context.Context, s *Store, id uuid.UUID) {
+	for _, i := range s.filter(identifiers, func(row interface{}) bool {
+		return row.(*credential.Identifier).CredentialID == id
+	}) {
+		s.remove(ctx, identifiers, i.(*credential.Identifier).ID)
+	}
+	s.remove(ctx, credentials, id)
+}
+
+// page applies an offset and limit to rows. A limit that isn't positive means there's no limit
+func page(rows []interface{}, offset int, limit int) []interface{} {
+	if offset > 0 {
+		if offset >= len(rows) {
+			return nil
+		}
+		rows = rows[offset:]
+	}
+	if limit > 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows
+}
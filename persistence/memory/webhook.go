@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/RagOfJoes/mylo/webhook"
+)
+
+type memoryWebhookRepository struct {
+	s *Store
+}
+
+func NewMemoryWebhookRepository(s *Store) webhook.Repository {
+	return &memoryWebhookRepository{s: s}
+}
+
+func (m *memoryWebhookRepository) Create(ctx context.Context, newDeliveries ...webhook.Delivery) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.s.atomic(ctx, func(ctx context.Context) error {
+		for _, d := range newDeliveries {
+			if _, err := m.s.insert(ctx, deliveries, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *memoryWebhookRepository) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]webhook.Delivery, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	due := m.s.filter(deliveries, func(row interface{}) bool {
+		d := row.(*webhook.Delivery)
+		return d.Status == webhook.Pending && !d.NextAttemptAt.After(now)
+	})
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].(*webhook.Delivery).NextAttemptAt.Before(due[j].(*webhook.Delivery).NextAttemptAt)
+	})
+	claimed := []webhook.Delivery{}
+	for _, row := range page(due, 0, limit) {
+		d := row.(*webhook.Delivery)
+		claimed = append(claimed, *d)
+		d.NextAttemptAt = now.Add(lease)
+		if _, err := m.s.save(ctx, deliveries, d); err != nil {
+			return nil, err
+		}
+	}
+	return claimed, nil
+}
+
+func (m *memoryWebhookRepository) Update(ctx context.Context, updateDelivery webhook.Delivery) (*webhook.Delivery, error) {
+	updated, err := m.s.update(ctx, deliveries, updateDelivery)
+	if err != nil {
+		return nil, err
+	}
+	return updated.(*webhook.Delivery), nil
+}
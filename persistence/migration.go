@@ -0,0 +1,355 @@
+package persistence
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSchemaBehind     = errors.New("Database schema is behind. Run mylo migrate up or enable AutoMigrate")
+	ErrNoMigrations     = errors.New("Versioned migrations are only available for mysql and postgres")
+	ErrInvalidMigration = errors.New("Invalid migration file")
+	ErrUnknownMigration = errors.New("Database has a migration applied that this version of mylo doesn't know about")
+	ErrNothingToRevert  = errors.New("There are no applied migrations to revert")
+)
+
+const (
+	// migrationLockID is the key of the Postgres advisory lock that's held while migrating
+	migrationLockID = 7315829460
+	// migrationLockName is the name of the MySQL lock that's held while migrating
+	migrationLockName = "mylo_migrations"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationFile matches the name of a migration ie. 0001_initial_schema.up.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration defines a single versioned change to the database schema
+type Migration struct {
+	// Version orders migrations. Migrations are applied in ascending order and reverted in descending order
+	Version uint
+	// Name describes what the migration does
+	Name string
+	// AppliedAt defines when the migration was applied. This is nil if it hasn't been applied yet
+	AppliedAt *time.Time
+
+	up   string
+	down string
+}
+
+// schemaMigration is a row in the schema version table
+type schemaMigration struct {
+	Version   uint
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationStatus retrieves every migration that's known to either mylo or the database, in order, along with whether
+// it's been applied
+func MigrationStatus(db *gorm.DB) ([]Migration, error) {
+	known, err := migrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint]int{}
+	for i, m := range known {
+		byVersion[m.Version] = i
+	}
+	for _, a := range applied {
+		appliedAt := a.AppliedAt
+		i, ok := byVersion[a.Version]
+		if !ok {
+			known = append(known, Migration{Version: a.Version, Name: a.Name})
+			i = len(known) - 1
+		}
+		known[i].AppliedAt = &appliedAt
+	}
+	sort.Slice(known, func(i, j int) bool {
+		return known[i].Version < known[j].Version
+	})
+	return known, nil
+}
+
+// MigrateUp applies every pending migration in order and returns the ones that were applied
+func MigrateUp(db *gorm.DB) ([]Migration, error) {
+	var applied []Migration
+	err := withMigrationLock(db, func(db *gorm.DB) error {
+		if err := createSchemaMigrations(db); err != nil {
+			return err
+		}
+		status, err := MigrationStatus(db)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range status {
+			if m.AppliedAt != nil {
+				continue
+			}
+			// Each migration is applied in a transaction along with its version so that a failure leaves nothing behind. MySQL
+			// commits DDL implicitly so a migration that fails there has to be cleaned up by hand
+			now := time.Now()
+			if err := db.Transaction(func(tx *gorm.DB) error {
+				if err := execMigration(tx, m.up); err != nil {
+					return err
+				}
+				return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: now}).Error
+			}); err != nil {
+				return fmt.Errorf("Failed to apply migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			m.AppliedAt = &now
+			applied = append(applied, m)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// MigrateDown reverts the latest steps applied migrations in reverse order and returns the ones that were reverted
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := withMigrationLock(db, func(db *gorm.DB) error {
+		status, err := MigrationStatus(db)
+		if err != nil {
+			return err
+		}
+
+		for i := len(status) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := status[i]
+			if m.AppliedAt == nil {
+				continue
+			}
+			if m.down == "" {
+				return fmt.Errorf("%w: %04d_%s", ErrUnknownMigration, m.Version, m.Name)
+			}
+			if err := db.Transaction(func(tx *gorm.DB) error {
+				if err := execMigration(tx, m.down); err != nil {
+					return err
+				}
+				return tx.Delete(&schemaMigration{}, "version = ?", m.Version).Error
+			}); err != nil {
+				return fmt.Errorf("Failed to revert migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			m.AppliedAt = nil
+			reverted = append(reverted, m)
+		}
+		if len(reverted) == 0 {
+			return ErrNothingToRevert
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNothingToRevert) {
+		return nil, err
+	}
+	return reverted, err
+}
+
+// CheckSchema makes sure that every migration has been applied
+func CheckSchema(db *gorm.DB) error {
+	status, err := MigrationStatus(db)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, m := range status {
+		if m.AppliedAt == nil {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d pending migration(s)", ErrSchemaBehind, pending)
+	}
+	return nil
+}
+
+// migrations loads the embedded migrations for a driver in order
+func migrations(driver string) ([]Migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, ErrNoMigrations
+	}
+
+	byVersion := map[uint]*Migration{}
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigration, entry.Name())
+		}
+		version, err := strconv.ParseUint(match[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigration, entry.Name())
+		}
+		contents, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[uint(version)]
+		if !ok {
+			m = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("%w: %s doesn't match %04d_%s", ErrInvalidMigration, entry.Name(), m.Version, m.Name)
+		}
+		if match[3] == "up" {
+			m.up = string(contents)
+		} else {
+			m.down = string(contents)
+		}
+	}
+
+	found := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("%w: %04d_%s must have both an up and a down", ErrInvalidMigration, m.Version, m.Name)
+		}
+		found = append(found, *m)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Version < found[j].Version
+	})
+	return found, nil
+}
+
+// appliedMigrations retrieves every migration that's been applied. A database without the schema version table simply
+// hasn't had any applied, this only reads so that checking the schema never changes it
+func appliedMigrations(db *gorm.DB) ([]schemaMigration, error) {
+	if !db.Migrator().HasTable(&schemaMigration{}) {
+		return nil, nil
+	}
+	var applied []schemaMigration
+	if err := db.Order("version").Find(&applied).Error; err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// createSchemaMigrations creates the schema version table if it doesn't exist yet
+func createSchemaMigrations(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint NOT NULL,
+		name varchar(255) NOT NULL,
+		applied_at timestamp NOT NULL,
+		PRIMARY KEY (version)
+	)`).Error
+}
+
+// withMigrationLock runs fn while holding a database wide lock so that only one instance migrates at a time, ie. when
+// several replicas start with AutoMigrate enabled. Advisory locks belong to a connection so fn is given a handle that's
+// pinned to the connection that holds the lock
+func withMigrationLock(db *gorm.DB, fn func(db *gorm.DB) error) error {
+	var lock, unlock string
+	switch db.Dialector.Name() {
+	case "postgres":
+		lock = fmt.Sprintf("SELECT pg_advisory_lock(%d)", migrationLockID)
+		unlock = fmt.Sprintf("SELECT pg_advisory_unlock(%d)", migrationLockID)
+	case "mysql":
+		lock = fmt.Sprintf("SELECT GET_LOCK('%s', -1)", migrationLockName)
+		unlock = fmt.Sprintf("SELECT RELEASE_LOCK('%s')", migrationLockName)
+	default:
+		return fn(db)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// pg_advisory_lock blocks until it's been acquired while GET_LOCK returns 1 once it has
+	if db.Dialector.Name() == "mysql" {
+		var acquired sql.NullInt64
+		if err := conn.QueryRowContext(ctx, lock).Scan(&acquired); err != nil {
+			return fmt.Errorf("Failed to acquire the migration lock: %w", err)
+		}
+		if acquired.Int64 != 1 {
+			return errors.New("Failed to acquire the migration lock")
+		}
+	} else if _, err := conn.ExecContext(ctx, lock); err != nil {
+		return fmt.Errorf("Failed to acquire the migration lock: %w", err)
+	}
+	defer conn.ExecContext(ctx, unlock)
+
+	pinned := db.Session(&gorm.Session{Context: ctx})
+	pinned.Statement.ConnPool = conn
+	return fn(pinned)
+}
+
+// execMigration executes every statement in a migration one at a time since not every driver supports multiple
+// statements in a single Exec. MySQL has no ADD COLUMN IF NOT EXISTS so its migrations add one column or index per
+// statement and the ones that already exist, ie. because AutoMigrate created them, are skipped
+func execMigration(tx *gorm.DB, contents string) error {
+	isMySQL := tx.Dialector.Name() == "mysql"
+	for _, statement := range splitStatements(contents) {
+		if err := tx.Exec(statement).Error; err != nil {
+			if isMySQL && alreadyExists(err) {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// alreadyExists checks whether err is MySQL refusing to add a column or an index that's already there
+func alreadyExists(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	// ER_DUP_FIELDNAME and ER_DUP_KEYNAME
+	return mysqlErr.Number == 1060 || mysqlErr.Number == 1061
+}
+
+// splitStatements splits a migration into its statements. Statements must end with a semicolon at the end of a line
+// and lines that start with -- are treated as comments
+func splitStatements(contents string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(contents, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
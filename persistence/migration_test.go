@@ -0,0 +1,108 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestMigrations(t *testing.T) {
+	for _, driver := range []string{"postgres", "mysql"} {
+		found, err := migrations(driver)
+		if err != nil {
+			t.Fatalf("%s: %v", driver, err)
+		}
+		if len(found) == 0 {
+			t.Fatalf("%s: expected migrations", driver)
+		}
+		for i, m := range found {
+			if m.Version != uint(i+1) {
+				t.Errorf("%s: expected version %d, got %04d_%s", driver, i+1, m.Version, m.Name)
+			}
+			if len(splitStatements(m.up)) == 0 || len(splitStatements(m.down)) == 0 {
+				t.Errorf("%s: %04d_%s has an empty up or down", driver, m.Version, m.Name)
+			}
+		}
+	}
+
+	if _, err := migrations("sqlite"); !errors.Is(err, ErrNoMigrations) {
+		t.Errorf("expected ErrNoMigrations for sqlite, got %v", err)
+	}
+}
+
+// TestInitialSchema makes sure that the initial migration keeps matching what AutoMigrate created before versioned
+// migrations, otherwise databases that it adopts would never get the columns that were added since
+func TestInitialSchema(t *testing.T) {
+	for _, driver := range []string{"postgres", "mysql"} {
+		found, err := migrations(driver)
+		if err != nil {
+			t.Fatalf("%s: %v", driver, err)
+		}
+		initial := found[0].up
+		for _, column := range []string{"failed_logins", "locked_at", "client_ip", "aal", "code_attempts", "link_id"} {
+			if strings.Contains(initial, column) {
+				t.Errorf("%s: %s must be added by a later migration", driver, column)
+			}
+		}
+		for _, table := range []string{"email_outbox", "webhook_deliveries", "oauth2_clients", "settings"} {
+			if strings.Contains(initial, table) {
+				t.Errorf("%s: %s must be created by a later migration", driver, table)
+			}
+		}
+	}
+}
+
+// TestMySQLAddColumns makes sure that MySQL migrations can be skipped a column at a time, since it has no ADD COLUMN IF
+// NOT EXISTS, and that only the errors for a column or index that already exists are skipped
+func TestMySQLAddColumns(t *testing.T) {
+	found, err := migrations("mysql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range found {
+		for _, statement := range splitStatements(m.up) {
+			if strings.HasPrefix(statement, "ALTER TABLE") && strings.Count(statement, "ADD ") > 1 {
+				t.Errorf("%04d_%s: expected a single column or index per statement, got %q", m.Version, m.Name, statement)
+			}
+		}
+	}
+
+	for number, expected := range map[uint16]bool{1060: true, 1061: true, 1054: false, 1146: false} {
+		err := fmt.Errorf("wrapped: %w", &mysql.MySQLError{Number: number})
+		if alreadyExists(err) != expected {
+			t.Errorf("expected alreadyExists to be %t for %d", expected, number)
+		}
+	}
+	if alreadyExists(errors.New("duplicate column name")) {
+		t.Error("expected errors from other drivers to not be skipped")
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	statements := splitStatements(`-- A comment
+CREATE TABLE "a" (
+  "id" uuid
+);
+
+-- Another comment
+ALTER TABLE "a"
+  ADD COLUMN "b" text;
+CREATE INDEX "idx_a_b" ON "a" ("b")`)
+
+	expected := []string{
+		"CREATE TABLE \"a\" (\n  \"id\" uuid\n)",
+		"ALTER TABLE \"a\"\n  ADD COLUMN \"b\" text",
+		"CREATE INDEX \"idx_a_b\" ON \"a\" (\"b\")",
+	}
+	if len(statements) != len(expected) {
+		t.Fatalf("expected %d statements, got %d: %q", len(expected), len(statements), statements)
+	}
+	for i := range expected {
+		if statements[i] != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], statements[i])
+		}
+	}
+}
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS `registrations`;
+DROP TABLE IF EXISTS `verifications`;
+DROP TABLE IF EXISTS `recoveries`;
+DROP TABLE IF EXISTS `logins`;
+DROP TABLE IF EXISTS `sessions`;
+DROP TABLE IF EXISTS `identifiers`;
+DROP TABLE IF EXISTS `credentials`;
+DROP TABLE IF EXISTS `contacts`;
+DROP TABLE IF EXISTS `identities`;
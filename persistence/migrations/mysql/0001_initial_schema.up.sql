@@ -0,0 +1,157 @@
+-- The initial schema, which matches the tables that AutoMigrate created before versioned migrations were introduced.
+-- Unlike what AutoMigrate used to create, UUIDs are stored as char(36) and unique strings as varchar(255) since MySQL
+-- has no uuid type and can't index text. Any column or table that was added since belongs in a later migration.
+
+CREATE TABLE IF NOT EXISTS `identities` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `deleted_at` datetime(3) NULL DEFAULT null,
+  `avatar` varchar(1024),
+  `first_name` varchar(64),
+  `last_name` varchar(64),
+  `email` varchar(255) NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX idx_identities_created_at (`created_at`),
+  INDEX idx_identities_deleted_at (`deleted_at`),
+  UNIQUE INDEX idx_identities_email (`email`),
+  INDEX idx_identities_updated_at (`updated_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `contacts` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `verified` boolean DEFAULT false,
+  `verified_at` datetime(3) NULL DEFAULT null,
+  `type` varchar(191) NOT NULL DEFAULT 'default',
+  `state` longtext NOT NULL,
+  `value` varchar(255) NOT NULL,
+  `identity_id` char(36) NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX idx_contacts_created_at (`created_at`),
+  INDEX idx_contacts_identity_id (`identity_id`),
+  INDEX idx_contacts_type (`type`),
+  INDEX idx_contacts_updated_at (`updated_at`),
+  UNIQUE INDEX idx_contacts_value (`value`),
+  CONSTRAINT `fk_identities_contacts` FOREIGN KEY (`identity_id`) REFERENCES `identities`(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `credentials` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `type` varchar(191) NOT NULL,
+  `values` json NOT NULL,
+  `identity_id` char(36) NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX idx_credentials_created_at (`created_at`),
+  INDEX idx_credentials_identity_id (`identity_id`),
+  INDEX idx_credentials_type (`type`),
+  INDEX idx_credentials_updated_at (`updated_at`),
+  CONSTRAINT `fk_identities_credentials` FOREIGN KEY (`identity_id`) REFERENCES `identities`(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `identifiers` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `credential_id` char(36) NOT NULL,
+  `type` longtext NOT NULL,
+  `value` varchar(255) NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX idx_identifiers_created_at (`created_at`),
+  INDEX idx_identifiers_credential_id (`credential_id`),
+  INDEX idx_identifiers_updated_at (`updated_at`),
+  UNIQUE INDEX idx_identifiers_value (`value`),
+  CONSTRAINT `fk_credentials_identifiers` FOREIGN KEY (`credential_id`) REFERENCES `credentials`(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `sessions` (
+  `id` varchar(191) NOT NULL,
+  `token` varchar(255) NOT NULL,
+  `state` varchar(191) NOT NULL DEFAULT 'Unauthenticated',
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `expires_at` datetime(3) NULL,
+  `authenticated_at` datetime(3) NULL,
+  `credential_methods` json DEFAULT null,
+  `identity_id` char(36),
+  PRIMARY KEY (`id`),
+  INDEX idx_sessions_created_at (`created_at`),
+  UNIQUE INDEX idx_sessions_token (`token`),
+  CONSTRAINT `fk_sessions_identity` FOREIGN KEY (`identity_id`) REFERENCES `identities`(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `logins` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `request_url` longtext NOT NULL,
+  `status` longtext NOT NULL,
+  `flow_id` varchar(255) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  `form` json,
+  PRIMARY KEY (`id`),
+  INDEX idx_logins_created_at (`created_at`),
+  INDEX idx_logins_expires_at (`expires_at`),
+  UNIQUE INDEX idx_logins_flow_id (`flow_id`),
+  INDEX idx_logins_updated_at (`updated_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `recoveries` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `request_url` longtext NOT NULL,
+  `status` longtext NOT NULL,
+  `flow_id` varchar(255) NOT NULL,
+  `recover_id` varchar(255) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  `form` json DEFAULT null,
+  `identity_id` char(36),
+  PRIMARY KEY (`id`),
+  INDEX idx_recoveries_created_at (`created_at`),
+  INDEX idx_recoveries_expires_at (`expires_at`),
+  UNIQUE INDEX idx_recoveries_flow_id (`flow_id`),
+  INDEX idx_recoveries_identity_id (`identity_id`),
+  UNIQUE INDEX idx_recoveries_recover_id (`recover_id`),
+  INDEX idx_recoveries_updated_at (`updated_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `verifications` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `request_url` longtext NOT NULL,
+  `status` longtext NOT NULL,
+  `flow_id` varchar(255) NOT NULL,
+  `verify_id` varchar(255) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  `form` json DEFAULT null,
+  `contact_id` char(36) NOT NULL,
+  `identity_id` char(36) NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX idx_verifications_contact_id (`contact_id`),
+  INDEX idx_verifications_created_at (`created_at`),
+  INDEX idx_verifications_expires_at (`expires_at`),
+  UNIQUE INDEX idx_verifications_flow_id (`flow_id`),
+  INDEX idx_verifications_identity_id (`identity_id`),
+  INDEX idx_verifications_updated_at (`updated_at`),
+  UNIQUE INDEX idx_verifications_verify_id (`verify_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `registrations` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `request_url` longtext NOT NULL,
+  `status` longtext NOT NULL,
+  `flow_id` varchar(255) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  `form` json,
+  PRIMARY KEY (`id`),
+  INDEX idx_registrations_created_at (`created_at`),
+  INDEX idx_registrations_expires_at (`expires_at`),
+  UNIQUE INDEX idx_registrations_flow_id (`flow_id`),
+  INDEX idx_registrations_updated_at (`updated_at`)
+);
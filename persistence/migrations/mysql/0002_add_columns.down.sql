@@ -0,0 +1,48 @@
+ALTER TABLE `registrations`
+  DROP COLUMN `client_ip`,
+  DROP COLUMN `client_user_agent`,
+  DROP COLUMN `client_device`,
+  DROP COLUMN `client_locale`;
+
+ALTER TABLE `verifications`
+  DROP COLUMN `client_ip`,
+  DROP COLUMN `client_user_agent`,
+  DROP COLUMN `client_device`,
+  DROP COLUMN `client_locale`;
+
+ALTER TABLE `recoveries`
+  DROP COLUMN `client_ip`,
+  DROP COLUMN `client_user_agent`,
+  DROP COLUMN `client_device`,
+  DROP COLUMN `client_locale`;
+
+ALTER TABLE `logins`
+  DROP COLUMN `client_ip`,
+  DROP COLUMN `client_user_agent`,
+  DROP COLUMN `client_device`,
+  DROP COLUMN `client_locale`,
+  DROP COLUMN `refresh`,
+  DROP COLUMN `passwordless_form`,
+  DROP COLUMN `identity_id`,
+  DROP COLUMN `first_factor`,
+  DROP COLUMN `code`,
+  DROP COLUMN `code_attempts`,
+  DROP COLUMN `code_sent_at`,
+  DROP COLUMN `link_id`;
+
+ALTER TABLE `sessions`
+  DROP COLUMN `aal`,
+  DROP COLUMN `client_ip`,
+  DROP COLUMN `client_user_agent`,
+  DROP COLUMN `client_device`,
+  DROP COLUMN `client_locale`,
+  DROP COLUMN `authenticated_client_ip`,
+  DROP COLUMN `authenticated_client_user_agent`,
+  DROP COLUMN `authenticated_client_device`,
+  DROP COLUMN `authenticated_client_locale`;
+
+ALTER TABLE `identities`
+  DROP COLUMN `locale`,
+  DROP COLUMN `failed_logins`,
+  DROP COLUMN `locked_at`,
+  DROP COLUMN `password_reset_required`;
@@ -0,0 +1,49 @@
+-- Columns that were added to the initial tables after versioned migrations were introduced. MySQL has no ADD COLUMN IF
+-- NOT EXISTS so every column and index is added by a statement of its own and execMigration skips the ones that a
+-- database that AutoMigrate created from a newer model already has, rather than failing.
+
+ALTER TABLE `identities` ADD COLUMN `locale` varchar(35);
+ALTER TABLE `identities` ADD COLUMN `failed_logins` bigint NOT NULL DEFAULT 0;
+ALTER TABLE `identities` ADD COLUMN `locked_at` datetime(3) NULL DEFAULT null;
+ALTER TABLE `identities` ADD COLUMN `password_reset_required` boolean NOT NULL DEFAULT false;
+
+ALTER TABLE `sessions` ADD COLUMN `aal` varchar(191) NOT NULL DEFAULT 'aal1';
+ALTER TABLE `sessions` ADD COLUMN `client_ip` varchar(64);
+ALTER TABLE `sessions` ADD COLUMN `client_user_agent` varchar(512);
+ALTER TABLE `sessions` ADD COLUMN `client_device` varchar(128);
+ALTER TABLE `sessions` ADD COLUMN `client_locale` varchar(35);
+ALTER TABLE `sessions` ADD COLUMN `authenticated_client_ip` varchar(64);
+ALTER TABLE `sessions` ADD COLUMN `authenticated_client_user_agent` varchar(512);
+ALTER TABLE `sessions` ADD COLUMN `authenticated_client_device` varchar(128);
+ALTER TABLE `sessions` ADD COLUMN `authenticated_client_locale` varchar(35);
+
+ALTER TABLE `logins` ADD COLUMN `client_ip` varchar(64);
+ALTER TABLE `logins` ADD COLUMN `client_user_agent` varchar(512);
+ALTER TABLE `logins` ADD COLUMN `client_device` varchar(128);
+ALTER TABLE `logins` ADD COLUMN `client_locale` varchar(35);
+ALTER TABLE `logins` ADD COLUMN `refresh` boolean NOT NULL DEFAULT false;
+ALTER TABLE `logins` ADD COLUMN `passwordless_form` json DEFAULT null;
+ALTER TABLE `logins` ADD COLUMN `identity_id` char(36);
+ALTER TABLE `logins` ADD COLUMN `first_factor` varchar(191) DEFAULT null;
+ALTER TABLE `logins` ADD COLUMN `code` varchar(191) DEFAULT null;
+ALTER TABLE `logins` ADD COLUMN `code_attempts` bigint NOT NULL DEFAULT 0;
+ALTER TABLE `logins` ADD COLUMN `code_sent_at` datetime(3) NULL DEFAULT null;
+ALTER TABLE `logins` ADD COLUMN `link_id` varchar(191) DEFAULT null;
+ALTER TABLE `logins` ADD INDEX idx_logins_code_sent_at (`code_sent_at`);
+ALTER TABLE `logins` ADD INDEX idx_logins_identity_id (`identity_id`);
+ALTER TABLE `logins` ADD UNIQUE INDEX idx_logins_link_id (`link_id`);
+
+ALTER TABLE `recoveries` ADD COLUMN `client_ip` varchar(64);
+ALTER TABLE `recoveries` ADD COLUMN `client_user_agent` varchar(512);
+ALTER TABLE `recoveries` ADD COLUMN `client_device` varchar(128);
+ALTER TABLE `recoveries` ADD COLUMN `client_locale` varchar(35);
+
+ALTER TABLE `verifications` ADD COLUMN `client_ip` varchar(64);
+ALTER TABLE `verifications` ADD COLUMN `client_user_agent` varchar(512);
+ALTER TABLE `verifications` ADD COLUMN `client_device` varchar(128);
+ALTER TABLE `verifications` ADD COLUMN `client_locale` varchar(35);
+
+ALTER TABLE `registrations` ADD COLUMN `client_ip` varchar(64);
+ALTER TABLE `registrations` ADD COLUMN `client_user_agent` varchar(512);
+ALTER TABLE `registrations` ADD COLUMN `client_device` varchar(128);
+ALTER TABLE `registrations` ADD COLUMN `client_locale` varchar(35);
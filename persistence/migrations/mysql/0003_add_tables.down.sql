@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS `settings`;
+DROP TABLE IF EXISTS `oidc_flows`;
+DROP TABLE IF EXISTS `oauth2_refresh_tokens`;
+DROP TABLE IF EXISTS `oauth2_requests`;
+DROP TABLE IF EXISTS `oauth2_consents`;
+DROP TABLE IF EXISTS `oauth2_clients`;
+DROP TABLE IF EXISTS `signing_keys`;
+DROP TABLE IF EXISTS `audit_log`;
+DROP TABLE IF EXISTS `webhook_deliveries`;
+DROP TABLE IF EXISTS `email_outbox`;
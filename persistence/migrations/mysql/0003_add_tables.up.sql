@@ -0,0 +1,210 @@
+-- Tables that were added after versioned migrations were introduced.
+
+CREATE TABLE IF NOT EXISTS `email_outbox` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `idempotency_key` varchar(255) NOT NULL,
+  `status` varchar(191) NOT NULL DEFAULT 'Pending',
+  `payload` json DEFAULT null,
+  `attempts` bigint NOT NULL DEFAULT 0,
+  `next_attempt_at` datetime(3) NOT NULL,
+  `last_error` varchar(1024),
+  `sent_at` datetime(3) NULL DEFAULT null,
+  PRIMARY KEY (`id`),
+  INDEX idx_email_outbox_created_at (`created_at`),
+  UNIQUE INDEX idx_email_outbox_idempotency_key (`idempotency_key`),
+  INDEX idx_email_outbox_next_attempt_at (`next_attempt_at`),
+  INDEX idx_email_outbox_status (`status`),
+  INDEX idx_email_outbox_updated_at (`updated_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `webhook_deliveries` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `event_id` char(36) NOT NULL,
+  `topic` varchar(64) NOT NULL,
+  `endpoint` varchar(64) NOT NULL,
+  `payload` text NOT NULL,
+  `status` varchar(191) NOT NULL DEFAULT 'Pending',
+  `attempts` bigint NOT NULL DEFAULT 0,
+  `next_attempt_at` datetime(3) NOT NULL,
+  `response_status` bigint NOT NULL DEFAULT 0,
+  `last_error` varchar(1024),
+  `delivered_at` datetime(3) NULL DEFAULT null,
+  PRIMARY KEY (`id`),
+  INDEX idx_webhook_deliveries_created_at (`created_at`),
+  INDEX idx_webhook_deliveries_endpoint (`endpoint`),
+  INDEX idx_webhook_deliveries_event_id (`event_id`),
+  INDEX idx_webhook_deliveries_next_attempt_at (`next_attempt_at`),
+  INDEX idx_webhook_deliveries_status (`status`),
+  INDEX idx_webhook_deliveries_topic (`topic`),
+  INDEX idx_webhook_deliveries_updated_at (`updated_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `audit_log` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `actor_id` char(36) DEFAULT null,
+  `session_id` char(36) DEFAULT null,
+  `client_ip` varchar(64),
+  `client_user_agent` varchar(512),
+  `client_device` varchar(128),
+  `client_locale` varchar(35),
+  `action` varchar(128) NOT NULL,
+  `target` varchar(512),
+  `outcome` longtext NOT NULL,
+  `reason` varchar(255),
+  PRIMARY KEY (`id`),
+  INDEX idx_audit_log_action (`action`),
+  INDEX idx_audit_log_actor_id (`actor_id`),
+  INDEX idx_audit_log_created_at (`created_at`),
+  INDEX idx_audit_log_session_id (`session_id`),
+  INDEX idx_audit_log_updated_at (`updated_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `signing_keys` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `algorithm` longtext NOT NULL,
+  `private_key` text NOT NULL,
+  `retires_at` datetime(3) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX idx_signing_keys_created_at (`created_at`),
+  INDEX idx_signing_keys_expires_at (`expires_at`),
+  INDEX idx_signing_keys_retires_at (`retires_at`),
+  INDEX idx_signing_keys_updated_at (`updated_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `oauth2_clients` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `name` varchar(128) NOT NULL,
+  `secret` varchar(191) DEFAULT null,
+  `public` boolean NOT NULL DEFAULT false,
+  `redirect_uris` json NOT NULL,
+  `scopes` json NOT NULL,
+  `skip_consent` boolean NOT NULL DEFAULT false,
+  PRIMARY KEY (`id`),
+  INDEX idx_oauth2_clients_created_at (`created_at`),
+  INDEX idx_oauth2_clients_updated_at (`updated_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `oauth2_consents` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `identity_id` char(36) NOT NULL,
+  `client_id` char(36) NOT NULL,
+  `scope` varchar(512) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX idx_consent_identity_client (`identity_id`,`client_id`),
+  INDEX idx_oauth2_consents_created_at (`created_at`),
+  INDEX idx_oauth2_consents_updated_at (`updated_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `oauth2_requests` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `challenge` varchar(255) NOT NULL,
+  `status` longtext NOT NULL,
+  `code` varchar(191) DEFAULT null,
+  `expires_at` datetime(3) NOT NULL,
+  `client_id` char(36) NOT NULL,
+  `identity_id` char(36) NOT NULL,
+  `session_id` char(36) NOT NULL,
+  `auth_time` datetime(3) NOT NULL,
+  `amr` json DEFAULT null,
+  `redirect_uri` varchar(2048) NOT NULL,
+  `scope` varchar(512),
+  `state` varchar(1024),
+  `nonce` varchar(1024),
+  `code_challenge` varchar(128) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX idx_oauth2_requests_challenge (`challenge`),
+  INDEX idx_oauth2_requests_client_id (`client_id`),
+  UNIQUE INDEX idx_oauth2_requests_code (`code`),
+  INDEX idx_oauth2_requests_created_at (`created_at`),
+  INDEX idx_oauth2_requests_expires_at (`expires_at`),
+  INDEX idx_oauth2_requests_identity_id (`identity_id`),
+  INDEX idx_oauth2_requests_updated_at (`updated_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `oauth2_refresh_tokens` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `token` varchar(255) NOT NULL,
+  `family_id` char(36) NOT NULL,
+  `client_id` char(36) NOT NULL,
+  `identity_id` char(36) NOT NULL,
+  `session_id` char(36) NOT NULL,
+  `auth_time` datetime(3) NOT NULL,
+  `amr` json DEFAULT null,
+  `scope` varchar(512),
+  `expires_at` datetime(3) NOT NULL,
+  `revoked_at` datetime(3) NULL DEFAULT null,
+  PRIMARY KEY (`id`),
+  INDEX idx_oauth2_refresh_tokens_client_id (`client_id`),
+  INDEX idx_oauth2_refresh_tokens_created_at (`created_at`),
+  INDEX idx_oauth2_refresh_tokens_expires_at (`expires_at`),
+  INDEX idx_oauth2_refresh_tokens_family_id (`family_id`),
+  INDEX idx_oauth2_refresh_tokens_identity_id (`identity_id`),
+  INDEX idx_oauth2_refresh_tokens_revoked_at (`revoked_at`),
+  UNIQUE INDEX idx_oauth2_refresh_tokens_token (`token`),
+  INDEX idx_oauth2_refresh_tokens_updated_at (`updated_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `oidc_flows` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `request_url` longtext NOT NULL,
+  `client_ip` varchar(64),
+  `client_user_agent` varchar(512),
+  `client_device` varchar(128),
+  `client_locale` varchar(35),
+  `status` longtext NOT NULL,
+  `flow_id` varchar(255) NOT NULL,
+  `provider` longtext NOT NULL,
+  `nonce` longtext NOT NULL,
+  `verifier` longtext NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  `session_id` char(36) NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX idx_oidc_flows_created_at (`created_at`),
+  INDEX idx_oidc_flows_expires_at (`expires_at`),
+  UNIQUE INDEX idx_oidc_flows_flow_id (`flow_id`),
+  INDEX idx_oidc_flows_session_id (`session_id`),
+  INDEX idx_oidc_flows_updated_at (`updated_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `settings` (
+  `id` char(36),
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NULL DEFAULT null,
+  `request_url` longtext NOT NULL,
+  `client_ip` varchar(64),
+  `client_user_agent` varchar(512),
+  `client_device` varchar(128),
+  `client_locale` varchar(35),
+  `status` longtext NOT NULL,
+  `flow_id` varchar(255) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  `form` json DEFAULT null,
+  `profile_form` json DEFAULT null,
+  `password_form` json DEFAULT null,
+  `identity_id` char(36) NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX idx_settings_created_at (`created_at`),
+  INDEX idx_settings_expires_at (`expires_at`),
+  UNIQUE INDEX idx_settings_flow_id (`flow_id`),
+  INDEX idx_settings_identity_id (`identity_id`),
+  INDEX idx_settings_updated_at (`updated_at`)
+);
@@ -0,0 +1,9 @@
+-- The time step of the last accepted totp code moves out of the credential's values into its own column so that it can
+-- be advanced with a conditional update.
+
+ALTER TABLE `credentials`
+  ADD COLUMN `last_counter` bigint NOT NULL DEFAULT 0;
+
+UPDATE `credentials`
+  SET `last_counter` = CAST(JSON_EXTRACT(`values`, '$.counter') AS SIGNED)
+  WHERE `type` = 'totp' AND JSON_EXTRACT(`values`, '$.counter') IS NOT NULL;
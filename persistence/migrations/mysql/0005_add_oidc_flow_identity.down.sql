@@ -0,0 +1,2 @@
+ALTER TABLE `oidc_flows`
+  DROP COLUMN `identity_id`;
@@ -0,0 +1,4 @@
+-- OIDC flows that were started by an authenticated session link the provider to the session's identity.
+
+ALTER TABLE `oidc_flows`
+  ADD COLUMN `identity_id` char(36) DEFAULT NULL;
@@ -0,0 +1,157 @@
+-- The initial schema, which is exactly what AutoMigrate created before versioned migrations were introduced. Every
+-- statement is IF NOT EXISTS so that databases that were created by AutoMigrate are adopted rather than failing. Any
+-- column or table that was added since belongs in a later migration so that adopted databases pick it up.
+
+CREATE TABLE IF NOT EXISTS "identities" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "deleted_at" timestamptz DEFAULT null,
+  "avatar" varchar(1024),
+  "first_name" varchar(64),
+  "last_name" varchar(64),
+  "email" text NOT NULL,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_identities_created_at" ON "identities" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_identities_deleted_at" ON "identities" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_identities_email" ON "identities" ("email");
+CREATE INDEX IF NOT EXISTS "idx_identities_updated_at" ON "identities" ("updated_at");
+
+CREATE TABLE IF NOT EXISTS "contacts" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "verified" boolean DEFAULT false,
+  "verified_at" timestamptz DEFAULT null,
+  "type" text NOT NULL DEFAULT 'default',
+  "state" text NOT NULL,
+  "value" text NOT NULL,
+  "identity_id" uuid NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_identities_contacts" FOREIGN KEY ("identity_id") REFERENCES "identities"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_contacts_created_at" ON "contacts" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_contacts_identity_id" ON "contacts" ("identity_id");
+CREATE INDEX IF NOT EXISTS "idx_contacts_type" ON "contacts" ("type");
+CREATE INDEX IF NOT EXISTS "idx_contacts_updated_at" ON "contacts" ("updated_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_contacts_value" ON "contacts" ("value");
+
+CREATE TABLE IF NOT EXISTS "credentials" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "type" text NOT NULL,
+  "values" json NOT NULL,
+  "identity_id" uuid NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_identities_credentials" FOREIGN KEY ("identity_id") REFERENCES "identities"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_credentials_created_at" ON "credentials" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_credentials_identity_id" ON "credentials" ("identity_id");
+CREATE INDEX IF NOT EXISTS "idx_credentials_type" ON "credentials" ("type");
+CREATE INDEX IF NOT EXISTS "idx_credentials_updated_at" ON "credentials" ("updated_at");
+
+CREATE TABLE IF NOT EXISTS "identifiers" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "credential_id" uuid NOT NULL,
+  "type" text NOT NULL,
+  "value" text NOT NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_credentials_identifiers" FOREIGN KEY ("credential_id") REFERENCES "credentials"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_identifiers_created_at" ON "identifiers" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_identifiers_credential_id" ON "identifiers" ("credential_id");
+CREATE INDEX IF NOT EXISTS "idx_identifiers_updated_at" ON "identifiers" ("updated_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_identifiers_value" ON "identifiers" ("value");
+
+CREATE TABLE IF NOT EXISTS "sessions" (
+  "id" text NOT NULL,
+  "token" text NOT NULL,
+  "state" text NOT NULL DEFAULT 'Unauthenticated',
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "expires_at" timestamptz,
+  "authenticated_at" timestamptz,
+  "credential_methods" json DEFAULT null,
+  "identity_id" uuid,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_sessions_identity" FOREIGN KEY ("identity_id") REFERENCES "identities"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_sessions_created_at" ON "sessions" ("created_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_sessions_token" ON "sessions" ("token");
+
+CREATE TABLE IF NOT EXISTS "logins" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "request_url" text NOT NULL,
+  "status" text NOT NULL,
+  "flow_id" text NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "form" json,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_logins_created_at" ON "logins" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_logins_expires_at" ON "logins" ("expires_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_logins_flow_id" ON "logins" ("flow_id");
+CREATE INDEX IF NOT EXISTS "idx_logins_updated_at" ON "logins" ("updated_at");
+
+CREATE TABLE IF NOT EXISTS "recoveries" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "request_url" text NOT NULL,
+  "status" text NOT NULL,
+  "flow_id" text NOT NULL,
+  "recover_id" text NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "form" json DEFAULT null,
+  "identity_id" uuid,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_recoveries_created_at" ON "recoveries" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_recoveries_expires_at" ON "recoveries" ("expires_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_recoveries_flow_id" ON "recoveries" ("flow_id");
+CREATE INDEX IF NOT EXISTS "idx_recoveries_identity_id" ON "recoveries" ("identity_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_recoveries_recover_id" ON "recoveries" ("recover_id");
+CREATE INDEX IF NOT EXISTS "idx_recoveries_updated_at" ON "recoveries" ("updated_at");
+
+CREATE TABLE IF NOT EXISTS "verifications" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "request_url" text NOT NULL,
+  "status" text NOT NULL,
+  "flow_id" text NOT NULL,
+  "verify_id" text NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "form" json DEFAULT null,
+  "contact_id" uuid NOT NULL,
+  "identity_id" uuid NOT NULL,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_verifications_contact_id" ON "verifications" ("contact_id");
+CREATE INDEX IF NOT EXISTS "idx_verifications_created_at" ON "verifications" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_verifications_expires_at" ON "verifications" ("expires_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_verifications_flow_id" ON "verifications" ("flow_id");
+CREATE INDEX IF NOT EXISTS "idx_verifications_identity_id" ON "verifications" ("identity_id");
+CREATE INDEX IF NOT EXISTS "idx_verifications_updated_at" ON "verifications" ("updated_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_verifications_verify_id" ON "verifications" ("verify_id");
+
+CREATE TABLE IF NOT EXISTS "registrations" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "request_url" text NOT NULL,
+  "status" text NOT NULL,
+  "flow_id" text NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "form" json,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_registrations_created_at" ON "registrations" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_registrations_expires_at" ON "registrations" ("expires_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_registrations_flow_id" ON "registrations" ("flow_id");
+CREATE INDEX IF NOT EXISTS "idx_registrations_updated_at" ON "registrations" ("updated_at");
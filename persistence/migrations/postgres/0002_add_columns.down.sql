@@ -0,0 +1,51 @@
+ALTER TABLE "registrations"
+  DROP COLUMN IF EXISTS "client_ip",
+  DROP COLUMN IF EXISTS "client_user_agent",
+  DROP COLUMN IF EXISTS "client_device",
+  DROP COLUMN IF EXISTS "client_locale";
+
+ALTER TABLE "verifications"
+  DROP COLUMN IF EXISTS "client_ip",
+  DROP COLUMN IF EXISTS "client_user_agent",
+  DROP COLUMN IF EXISTS "client_device",
+  DROP COLUMN IF EXISTS "client_locale";
+
+ALTER TABLE "recoveries"
+  DROP COLUMN IF EXISTS "client_ip",
+  DROP COLUMN IF EXISTS "client_user_agent",
+  DROP COLUMN IF EXISTS "client_device",
+  DROP COLUMN IF EXISTS "client_locale";
+
+DROP INDEX IF EXISTS "idx_logins_code_sent_at";
+DROP INDEX IF EXISTS "idx_logins_identity_id";
+DROP INDEX IF EXISTS "idx_logins_link_id";
+ALTER TABLE "logins"
+  DROP COLUMN IF EXISTS "client_ip",
+  DROP COLUMN IF EXISTS "client_user_agent",
+  DROP COLUMN IF EXISTS "client_device",
+  DROP COLUMN IF EXISTS "client_locale",
+  DROP COLUMN IF EXISTS "refresh",
+  DROP COLUMN IF EXISTS "passwordless_form",
+  DROP COLUMN IF EXISTS "identity_id",
+  DROP COLUMN IF EXISTS "first_factor",
+  DROP COLUMN IF EXISTS "code",
+  DROP COLUMN IF EXISTS "code_attempts",
+  DROP COLUMN IF EXISTS "code_sent_at",
+  DROP COLUMN IF EXISTS "link_id";
+
+ALTER TABLE "sessions"
+  DROP COLUMN IF EXISTS "aal",
+  DROP COLUMN IF EXISTS "client_ip",
+  DROP COLUMN IF EXISTS "client_user_agent",
+  DROP COLUMN IF EXISTS "client_device",
+  DROP COLUMN IF EXISTS "client_locale",
+  DROP COLUMN IF EXISTS "authenticated_client_ip",
+  DROP COLUMN IF EXISTS "authenticated_client_user_agent",
+  DROP COLUMN IF EXISTS "authenticated_client_device",
+  DROP COLUMN IF EXISTS "authenticated_client_locale";
+
+ALTER TABLE "identities"
+  DROP COLUMN IF EXISTS "locale",
+  DROP COLUMN IF EXISTS "failed_logins",
+  DROP COLUMN IF EXISTS "locked_at",
+  DROP COLUMN IF EXISTS "password_reset_required";
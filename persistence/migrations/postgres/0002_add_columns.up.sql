@@ -0,0 +1,54 @@
+-- Columns that were added to the initial tables after versioned migrations were introduced. Every column is IF NOT
+-- EXISTS so that a database that AutoMigrate created from a newer model is adopted rather than failing.
+
+ALTER TABLE "identities"
+  ADD COLUMN IF NOT EXISTS "locale" varchar(35),
+  ADD COLUMN IF NOT EXISTS "failed_logins" bigint NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS "locked_at" timestamptz DEFAULT null,
+  ADD COLUMN IF NOT EXISTS "password_reset_required" boolean NOT NULL DEFAULT false;
+
+ALTER TABLE "sessions"
+  ADD COLUMN IF NOT EXISTS "aal" text NOT NULL DEFAULT 'aal1',
+  ADD COLUMN IF NOT EXISTS "client_ip" varchar(64),
+  ADD COLUMN IF NOT EXISTS "client_user_agent" varchar(512),
+  ADD COLUMN IF NOT EXISTS "client_device" varchar(128),
+  ADD COLUMN IF NOT EXISTS "client_locale" varchar(35),
+  ADD COLUMN IF NOT EXISTS "authenticated_client_ip" varchar(64),
+  ADD COLUMN IF NOT EXISTS "authenticated_client_user_agent" varchar(512),
+  ADD COLUMN IF NOT EXISTS "authenticated_client_device" varchar(128),
+  ADD COLUMN IF NOT EXISTS "authenticated_client_locale" varchar(35);
+
+ALTER TABLE "logins"
+  ADD COLUMN IF NOT EXISTS "client_ip" varchar(64),
+  ADD COLUMN IF NOT EXISTS "client_user_agent" varchar(512),
+  ADD COLUMN IF NOT EXISTS "client_device" varchar(128),
+  ADD COLUMN IF NOT EXISTS "client_locale" varchar(35),
+  ADD COLUMN IF NOT EXISTS "refresh" boolean NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS "passwordless_form" json DEFAULT null,
+  ADD COLUMN IF NOT EXISTS "identity_id" uuid,
+  ADD COLUMN IF NOT EXISTS "first_factor" text DEFAULT null,
+  ADD COLUMN IF NOT EXISTS "code" text DEFAULT null,
+  ADD COLUMN IF NOT EXISTS "code_attempts" bigint NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS "code_sent_at" timestamptz DEFAULT null,
+  ADD COLUMN IF NOT EXISTS "link_id" text DEFAULT null;
+CREATE INDEX IF NOT EXISTS "idx_logins_code_sent_at" ON "logins" ("code_sent_at");
+CREATE INDEX IF NOT EXISTS "idx_logins_identity_id" ON "logins" ("identity_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_logins_link_id" ON "logins" ("link_id");
+
+ALTER TABLE "recoveries"
+  ADD COLUMN IF NOT EXISTS "client_ip" varchar(64),
+  ADD COLUMN IF NOT EXISTS "client_user_agent" varchar(512),
+  ADD COLUMN IF NOT EXISTS "client_device" varchar(128),
+  ADD COLUMN IF NOT EXISTS "client_locale" varchar(35);
+
+ALTER TABLE "verifications"
+  ADD COLUMN IF NOT EXISTS "client_ip" varchar(64),
+  ADD COLUMN IF NOT EXISTS "client_user_agent" varchar(512),
+  ADD COLUMN IF NOT EXISTS "client_device" varchar(128),
+  ADD COLUMN IF NOT EXISTS "client_locale" varchar(35);
+
+ALTER TABLE "registrations"
+  ADD COLUMN IF NOT EXISTS "client_ip" varchar(64),
+  ADD COLUMN IF NOT EXISTS "client_user_agent" varchar(512),
+  ADD COLUMN IF NOT EXISTS "client_device" varchar(128),
+  ADD COLUMN IF NOT EXISTS "client_locale" varchar(35);
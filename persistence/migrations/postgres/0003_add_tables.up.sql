@@ -0,0 +1,211 @@
+-- Tables that were added after versioned migrations were introduced. Every statement is IF NOT EXISTS so that a
+-- database that AutoMigrate created from a newer model is adopted rather than failing.
+
+CREATE TABLE IF NOT EXISTS "email_outbox" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "idempotency_key" varchar(255) NOT NULL,
+  "status" text NOT NULL DEFAULT 'Pending',
+  "payload" json DEFAULT null,
+  "attempts" bigint NOT NULL DEFAULT 0,
+  "next_attempt_at" timestamptz NOT NULL,
+  "last_error" varchar(1024),
+  "sent_at" timestamptz DEFAULT null,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_email_outbox_created_at" ON "email_outbox" ("created_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_email_outbox_idempotency_key" ON "email_outbox" ("idempotency_key");
+CREATE INDEX IF NOT EXISTS "idx_email_outbox_next_attempt_at" ON "email_outbox" ("next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_email_outbox_status" ON "email_outbox" ("status");
+CREATE INDEX IF NOT EXISTS "idx_email_outbox_updated_at" ON "email_outbox" ("updated_at");
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "event_id" uuid NOT NULL,
+  "topic" varchar(64) NOT NULL,
+  "endpoint" varchar(64) NOT NULL,
+  "payload" text NOT NULL,
+  "status" text NOT NULL DEFAULT 'Pending',
+  "attempts" bigint NOT NULL DEFAULT 0,
+  "next_attempt_at" timestamptz NOT NULL,
+  "response_status" bigint NOT NULL DEFAULT 0,
+  "last_error" varchar(1024),
+  "delivered_at" timestamptz DEFAULT null,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_created_at" ON "webhook_deliveries" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_endpoint" ON "webhook_deliveries" ("endpoint");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_event_id" ON "webhook_deliveries" ("event_id");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_next_attempt_at" ON "webhook_deliveries" ("next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_status" ON "webhook_deliveries" ("status");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_topic" ON "webhook_deliveries" ("topic");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_updated_at" ON "webhook_deliveries" ("updated_at");
+
+CREATE TABLE IF NOT EXISTS "audit_log" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "actor_id" uuid DEFAULT null,
+  "session_id" uuid DEFAULT null,
+  "client_ip" varchar(64),
+  "client_user_agent" varchar(512),
+  "client_device" varchar(128),
+  "client_locale" varchar(35),
+  "action" varchar(128) NOT NULL,
+  "target" varchar(512),
+  "outcome" text NOT NULL,
+  "reason" varchar(255),
+  PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_audit_log_action" ON "audit_log" ("action");
+CREATE INDEX IF NOT EXISTS "idx_audit_log_actor_id" ON "audit_log" ("actor_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_log_created_at" ON "audit_log" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_audit_log_session_id" ON "audit_log" ("session_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_log_updated_at" ON "audit_log" ("updated_at");
+
+CREATE TABLE IF NOT EXISTS "signing_keys" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "algorithm" text NOT NULL,
+  "private_key" text NOT NULL,
+  "retires_at" timestamptz NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_signing_keys_created_at" ON "signing_keys" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_signing_keys_expires_at" ON "signing_keys" ("expires_at");
+CREATE INDEX IF NOT EXISTS "idx_signing_keys_retires_at" ON "signing_keys" ("retires_at");
+CREATE INDEX IF NOT EXISTS "idx_signing_keys_updated_at" ON "signing_keys" ("updated_at");
+
+CREATE TABLE IF NOT EXISTS "oauth2_clients" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "name" varchar(128) NOT NULL,
+  "secret" text DEFAULT null,
+  "public" boolean NOT NULL DEFAULT false,
+  "redirect_uris" json NOT NULL,
+  "scopes" json NOT NULL,
+  "skip_consent" boolean NOT NULL DEFAULT false,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_oauth2_clients_created_at" ON "oauth2_clients" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_oauth2_clients_updated_at" ON "oauth2_clients" ("updated_at");
+
+CREATE TABLE IF NOT EXISTS "oauth2_consents" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "identity_id" uuid NOT NULL,
+  "client_id" uuid NOT NULL,
+  "scope" varchar(512) NOT NULL,
+  PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_consent_identity_client" ON "oauth2_consents" ("identity_id","client_id");
+CREATE INDEX IF NOT EXISTS "idx_oauth2_consents_created_at" ON "oauth2_consents" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_oauth2_consents_updated_at" ON "oauth2_consents" ("updated_at");
+
+CREATE TABLE IF NOT EXISTS "oauth2_requests" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "challenge" text NOT NULL,
+  "status" text NOT NULL,
+  "code" text DEFAULT null,
+  "expires_at" timestamptz NOT NULL,
+  "client_id" uuid NOT NULL,
+  "identity_id" uuid NOT NULL,
+  "session_id" uuid NOT NULL,
+  "auth_time" timestamptz NOT NULL,
+  "amr" json DEFAULT null,
+  "redirect_uri" varchar(2048) NOT NULL,
+  "scope" varchar(512),
+  "state" varchar(1024),
+  "nonce" varchar(1024),
+  "code_challenge" varchar(128) NOT NULL,
+  PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_oauth2_requests_challenge" ON "oauth2_requests" ("challenge");
+CREATE INDEX IF NOT EXISTS "idx_oauth2_requests_client_id" ON "oauth2_requests" ("client_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_oauth2_requests_code" ON "oauth2_requests" ("code");
+CREATE INDEX IF NOT EXISTS "idx_oauth2_requests_created_at" ON "oauth2_requests" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_oauth2_requests_expires_at" ON "oauth2_requests" ("expires_at");
+CREATE INDEX IF NOT EXISTS "idx_oauth2_requests_identity_id" ON "oauth2_requests" ("identity_id");
+CREATE INDEX IF NOT EXISTS "idx_oauth2_requests_updated_at" ON "oauth2_requests" ("updated_at");
+
+CREATE TABLE IF NOT EXISTS "oauth2_refresh_tokens" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "token" text NOT NULL,
+  "family_id" uuid NOT NULL,
+  "client_id" uuid NOT NULL,
+  "identity_id" uuid NOT NULL,
+  "session_id" uuid NOT NULL,
+  "auth_time" timestamptz NOT NULL,
+  "amr" json DEFAULT null,
+  "scope" varchar(512),
+  "expires_at" timestamptz NOT NULL,
+  "revoked_at" timestamptz DEFAULT null,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_oauth2_refresh_tokens_client_id" ON "oauth2_refresh_tokens" ("client_id");
+CREATE INDEX IF NOT EXISTS "idx_oauth2_refresh_tokens_created_at" ON "oauth2_refresh_tokens" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_oauth2_refresh_tokens_expires_at" ON "oauth2_refresh_tokens" ("expires_at");
+CREATE INDEX IF NOT EXISTS "idx_oauth2_refresh_tokens_family_id" ON "oauth2_refresh_tokens" ("family_id");
+CREATE INDEX IF NOT EXISTS "idx_oauth2_refresh_tokens_identity_id" ON "oauth2_refresh_tokens" ("identity_id");
+CREATE INDEX IF NOT EXISTS "idx_oauth2_refresh_tokens_revoked_at" ON "oauth2_refresh_tokens" ("revoked_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_oauth2_refresh_tokens_token" ON "oauth2_refresh_tokens" ("token");
+CREATE INDEX IF NOT EXISTS "idx_oauth2_refresh_tokens_updated_at" ON "oauth2_refresh_tokens" ("updated_at");
+
+CREATE TABLE IF NOT EXISTS "oidc_flows" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "request_url" text NOT NULL,
+  "client_ip" varchar(64),
+  "client_user_agent" varchar(512),
+  "client_device" varchar(128),
+  "client_locale" varchar(35),
+  "status" text NOT NULL,
+  "flow_id" text NOT NULL,
+  "provider" text NOT NULL,
+  "nonce" text NOT NULL,
+  "verifier" text NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "session_id" uuid NOT NULL,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_oidc_flows_created_at" ON "oidc_flows" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_oidc_flows_expires_at" ON "oidc_flows" ("expires_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_oidc_flows_flow_id" ON "oidc_flows" ("flow_id");
+CREATE INDEX IF NOT EXISTS "idx_oidc_flows_session_id" ON "oidc_flows" ("session_id");
+CREATE INDEX IF NOT EXISTS "idx_oidc_flows_updated_at" ON "oidc_flows" ("updated_at");
+
+CREATE TABLE IF NOT EXISTS "settings" (
+  "id" uuid,
+  "created_at" timestamptz NOT NULL DEFAULT current_timestamp,
+  "updated_at" timestamptz DEFAULT null,
+  "request_url" text NOT NULL,
+  "client_ip" varchar(64),
+  "client_user_agent" varchar(512),
+  "client_device" varchar(128),
+  "client_locale" varchar(35),
+  "status" text NOT NULL,
+  "flow_id" text NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "form" json DEFAULT null,
+  "profile_form" json DEFAULT null,
+  "password_form" json DEFAULT null,
+  "identity_id" uuid NOT NULL,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_settings_created_at" ON "settings" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_settings_expires_at" ON "settings" ("expires_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_settings_flow_id" ON "settings" ("flow_id");
+CREATE INDEX IF NOT EXISTS "idx_settings_identity_id" ON "settings" ("identity_id");
+CREATE INDEX IF NOT EXISTS "idx_settings_updated_at" ON "settings" ("updated_at");
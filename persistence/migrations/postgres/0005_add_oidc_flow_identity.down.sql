@@ -0,0 +1,2 @@
+ALTER TABLE "oidc_flows"
+  DROP COLUMN IF EXISTS "identity_id";
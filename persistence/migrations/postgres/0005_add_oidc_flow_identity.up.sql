@@ -0,0 +1,4 @@
+-- OIDC flows that were started by an authenticated session link the provider to the session's identity.
+
+ALTER TABLE "oidc_flows"
+  ADD COLUMN IF NOT EXISTS "identity_id" uuid DEFAULT NULL;
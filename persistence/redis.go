@@ -0,0 +1,36 @@
+package persistence
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/go-redis/redis/v8"
+)
+
+func NewRedis() (*redis.Client, error) {
+	cfg := config.Get()
+
+	opts := &redis.Options{
+		Addr:     cfg.Redis.Addr,
+		Username: cfg.Redis.Username,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	}
+	if cfg.Redis.TLS {
+		opts.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	client := redis.NewClient(opts)
+
+	// Fail fast on startup rather than on the first request
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
@@ -0,0 +1,49 @@
+package persistence
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+// sqliteDialector translates the Postgres column types that models are tagged with, ie. uuid and json, into text since
+// that's how SQLite stores them anyways
+type sqliteDialector struct {
+	sqlite.Dialector
+}
+
+// openSQLite opens the SQLite database at path. A path of :memory: opens an in-memory database that's shared by every
+// connection in the pool
+func openSQLite(path string) gorm.Dialector {
+	dsn := fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL", path)
+	if path == ":memory:" {
+		dsn = "file::memory:?cache=shared&_foreign_keys=on"
+	}
+	return sqliteDialector{
+		Dialector: sqlite.Dialector{DSN: dsn},
+	}
+}
+
+func (d sqliteDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return sqlite.Migrator{
+		Migrator: migrator.Migrator{
+			Config: migrator.Config{
+				DB:                          db,
+				Dialector:                   d,
+				CreateIndexAfterCreateTable: true,
+			},
+		},
+	}
+}
+
+func (d sqliteDialector) DataTypeOf(field *schema.Field) string {
+	switch strings.ToLower(string(field.DataType)) {
+	case "uuid", "json":
+		return "text"
+	}
+	return d.Dialector.DataTypeOf(field)
+}
@@ -0,0 +1,41 @@
+package persistence
+
+import (
+	"context"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"gorm.io/gorm"
+)
+
+// txKey is the context key that holds the current transaction
+type txKey struct{}
+
+type gormTransactor struct {
+	DB *gorm.DB
+}
+
+func NewGormTransactor(d *gorm.DB) internal.Transactor {
+	return &gormTransactor{DB: d}
+}
+
+func (g *gormTransactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+	ctx, runHooks := internal.WithCommitHooks(ctx)
+	if err := g.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	}); err != nil {
+		return err
+	}
+	runHooks()
+	return nil
+}
+
+// Conn retrieves the transaction that ctx is a part of. If ctx isn't a part of one then db is returned
+func Conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx
+	}
+	return db.WithContext(ctx)
+}
@@ -0,0 +1,88 @@
+package persistence_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config/configtest"
+	"github.com/RagOfJoes/mylo/persistence"
+	"github.com/RagOfJoes/mylo/user/contact"
+	contactGorm "github.com/RagOfJoes/mylo/user/contact/repository/gorm"
+	"github.com/RagOfJoes/mylo/user/identity"
+	identityGorm "github.com/RagOfJoes/mylo/user/identity/repository/gorm"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
+)
+
+func newSQLite(t *testing.T) *gorm.DB {
+	configtest.Setup(t, map[string]interface{}{
+		"database.driver":      "sqlite",
+		"database.name":        filepath.Join(t.TempDir(), "mylo.db"),
+		"database.automigrate": true,
+	})
+	db, err := persistence.NewGorm()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { persistence.CloseGorm(db) })
+	return db
+}
+
+func TestTransaction(t *testing.T) {
+	db := newSQLite(t)
+	tr := persistence.NewGormTransactor(db)
+	ir := identityGorm.NewGormUserRepository(db)
+	cr := contactGorm.NewGormContactRepository(db)
+
+	ctx := context.Background()
+	id := uuid.Must(uuid.NewV4())
+	contactID := uuid.Must(uuid.NewV4())
+	create := func(ctx context.Context) error {
+		if _, err := ir.Create(ctx, identity.Identity{
+			BaseSoftDelete: internal.BaseSoftDelete{ID: id, CreatedAt: time.Now()},
+			Email:          "jane@example.com",
+		}); err != nil {
+			return err
+		}
+		_, err := cr.Create(ctx, contact.Contact{
+			Base:       internal.Base{ID: contactID, CreatedAt: time.Now()},
+			Type:       contact.Default,
+			State:      contact.Sent,
+			Value:      "jane@example.com",
+			IdentityID: id,
+		})
+		return err
+	}
+
+	// Everything the repositories wrote must be rolled back along with the transaction
+	errRollback := errors.New("rollback")
+	if err := tr.Transaction(ctx, func(ctx context.Context) error {
+		if err := create(ctx); err != nil {
+			return err
+		}
+		return errRollback
+	}); !errors.Is(err, errRollback) {
+		t.Fatalf("expected the transaction to be rolled back, got %v", err)
+	}
+	if _, err := ir.Get(ctx, id, false); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected identity to be rolled back, got %v", err)
+	}
+	if _, err := cr.Get(ctx, contactID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected contact to be rolled back, got %v", err)
+	}
+
+	if err := tr.Transaction(ctx, create); err != nil {
+		t.Fatal(err)
+	}
+	found, err := ir.Get(ctx, id, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Contacts) != 1 || found.Contacts[0].ID != contactID {
+		t.Errorf("expected identity to be committed with its contact, got %+v", found.Contacts)
+	}
+}
@@ -0,0 +1,106 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Digits is the length of generated codes
+	Digits = 6
+	// Period is the number of seconds a code is valid for
+	Period = 30
+	// Skew is the number of periods before and after the current one that will also be accepted
+	Skew = 1
+
+	secretSize = 20
+)
+
+var (
+	// b32 is the encoding used by authenticator apps for secrets
+	b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// GenerateSecret generates a new base32 encoded secret
+func GenerateSecret() (string, error) {
+	bytes := make([]byte, secretSize)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(bytes), nil
+}
+
+// URL builds the otpauth URI that authenticator apps use to enroll a secret
+//
+// See: https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+func URL(issuer string, accountName string, secret string) string {
+	label := url.PathEscape(fmt.Sprintf("%s:%s", issuer, accountName))
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprintf("%d", Digits))
+	query.Set("period", fmt.Sprintf("%d", Period))
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, query.Encode())
+}
+
+// Counter returns the time step for the provided time
+func Counter(t time.Time) int64 {
+	return t.Unix() / Period
+}
+
+// Generate generates the code for the provided secret and time step
+func Generate(secret string, counter int64) (string, error) {
+	key, err := b32.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return "", err
+	}
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation as defined in RFC 4226
+	offset := sum[len(sum)-1] & 0xf
+	value := int64(((int(sum[offset]) & 0x7f) << 24) |
+		((int(sum[offset+1] & 0xff)) << 16) |
+		((int(sum[offset+2] & 0xff)) << 8) |
+		(int(sum[offset+3]) & 0xff))
+	mod := int64(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, value%mod), nil
+}
+
+// Validate checks code against the secret for the provided time. On success, the matched time step is returned
+// so callers can reject codes that have already been used
+func Validate(code string, secret string, t time.Time) (int64, error) {
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return 0, errors.New("invalid code length")
+	}
+	current := Counter(t)
+	for i := -Skew; i <= Skew; i++ {
+		counter := current + int64(i)
+		expected, err := Generate(secret, counter)
+		if err != nil {
+			return 0, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return counter, nil
+		}
+	}
+	return 0, errors.New("invalid code")
+}
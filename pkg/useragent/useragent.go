@@ -0,0 +1,89 @@
+package useragent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Agent defines a summary of a parsed User-Agent header
+type Agent struct {
+	// Browser is the name of the browser or client ie. Chrome, Firefox, curl
+	Browser string
+	// OS is the name of the operating system ie. macOS, Windows, Android
+	OS string
+	// Mobile is true when the client is a mobile device
+	Mobile bool
+}
+
+// match defines a token that, when found in a User-Agent, maps to name
+type match struct {
+	token string
+	name  string
+}
+
+var (
+	// browsers is ordered by specificity since most browsers include the tokens of the browsers they're based on
+	browsers = []match{
+		{token: "edg/", name: "Edge"},
+		{token: "edga/", name: "Edge"},
+		{token: "edgios/", name: "Edge"},
+		{token: "opr/", name: "Opera"},
+		{token: "samsungbrowser/", name: "Samsung Internet"},
+		{token: "firefox/", name: "Firefox"},
+		{token: "fxios/", name: "Firefox"},
+		{token: "crios/", name: "Chrome"},
+		{token: "chrome/", name: "Chrome"},
+		{token: "safari/", name: "Safari"},
+		{token: "msie ", name: "Internet Explorer"},
+		{token: "trident/", name: "Internet Explorer"},
+		{token: "curl/", name: "curl"},
+		{token: "postmanruntime/", name: "Postman"},
+		{token: "go-http-client/", name: "Go"},
+	}
+	// systems is ordered by specificity since some systems include the tokens of others ie. Android includes Linux
+	systems = []match{
+		{token: "iphone", name: "iOS"},
+		{token: "ipad", name: "iPadOS"},
+		{token: "android", name: "Android"},
+		{token: "cros", name: "ChromeOS"},
+		{token: "windows", name: "Windows"},
+		{token: "mac os x", name: "macOS"},
+		{token: "macintosh", name: "macOS"},
+		{token: "linux", name: "Linux"},
+	}
+)
+
+// Parse parses a User-Agent header. Unknown values are left empty
+func Parse(ua string) Agent {
+	lower := strings.ToLower(ua)
+	agent := Agent{
+		Mobile: strings.Contains(lower, "mobile") || strings.Contains(lower, "iphone") || strings.Contains(lower, "android"),
+	}
+	for _, m := range browsers {
+		if strings.Contains(lower, m.token) {
+			agent.Browser = m.name
+			break
+		}
+	}
+	for _, m := range systems {
+		if strings.Contains(lower, m.token) {
+			agent.OS = m.name
+			break
+		}
+	}
+	return agent
+}
+
+// String returns a human-readable summary of the agent ie. Chrome on macOS
+func (a Agent) String() string {
+	switch {
+	case a.Browser != "" && a.OS != "":
+		return fmt.Sprintf("%s on %s", a.Browser, a.OS)
+	case a.Browser != "":
+		return a.Browser
+	case a.OS != "":
+		return fmt.Sprintf("Unknown browser on %s", a.OS)
+	default:
+		return "Unknown device"
+	}
+}
@@ -2,7 +2,10 @@ package gorm
 
 import (
 	"context"
+	"time"
 
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/persistence"
 	"github.com/RagOfJoes/mylo/session"
 	"github.com/RagOfJoes/mylo/user/identity"
 	"github.com/gofrs/uuid"
@@ -19,7 +22,7 @@ func NewGormSessionRepository(d *gorm.DB) session.Repository {
 
 func (g *gormSessionRepository) Create(ctx context.Context, newSession session.Session) (*session.Session, error) {
 	created := newSession
-	if err := g.DB.Create(&created).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Create(&created).Error; err != nil {
 		return nil, err
 	}
 	return &created, nil
@@ -27,12 +30,13 @@ func (g *gormSessionRepository) Create(ctx context.Context, newSession session.S
 
 func (g *gormSessionRepository) Get(ctx context.Context, id uuid.UUID) (*session.Session, error) {
 	var found session.Session
-	if err := g.DB.First(&found, "id = ?", id).Error; err != nil {
+	db := persistence.Conn(ctx, g.DB)
+	if err := db.First(&found, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	if found.IdentityID != nil {
 		var user identity.Identity
-		if err := g.DB.Preload("Contacts").First(&user, "id = ?", found.IdentityID).Error; err != nil {
+		if err := db.Preload("Contacts").First(&user, "id = ?", found.IdentityID).Error; err != nil {
 			return nil, err
 		}
 		found.Identity = &user
@@ -42,12 +46,13 @@ func (g *gormSessionRepository) Get(ctx context.Context, id uuid.UUID) (*session
 
 func (g *gormSessionRepository) GetByToken(ctx context.Context, token string) (*session.Session, error) {
 	var found session.Session
-	if err := g.DB.First(&found, "token = ?", token).Error; err != nil {
+	db := persistence.Conn(ctx, g.DB)
+	if err := db.First(&found, "token = ?", token).Error; err != nil {
 		return nil, err
 	}
 	if found.IdentityID != nil {
 		var user identity.Identity
-		if err := g.DB.Preload("Contacts").First(&user, "id = ?", found.IdentityID).Error; err != nil {
+		if err := db.Preload("Contacts").First(&user, "id = ?", found.IdentityID).Error; err != nil {
 			return nil, err
 		}
 		found.Identity = &user
@@ -60,7 +65,7 @@ func (g *gormSessionRepository) Update(ctx context.Context, updateSession sessio
 	updated := updateSession
 	// Make sure we're not accidentally updating the Identity
 	updated.Identity = nil
-	if err := g.DB.Save(&updated).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Save(&updated).Error; err != nil {
 		return nil, err
 	}
 	updated.Identity = updateSession.Identity
@@ -68,15 +73,35 @@ func (g *gormSessionRepository) Update(ctx context.Context, updateSession sessio
 }
 
 func (g *gormSessionRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	if err := g.DB.Where("id = ?", id).Delete(session.Session{}).Error; err != nil && err != gorm.ErrRecordNotFound {
+	if err := persistence.Conn(ctx, g.DB).Where("id = ?", id).Delete(session.Session{}).Error; err != nil && err != gorm.ErrRecordNotFound {
 		return err
 	}
 	return nil
 }
 
+func (g *gormSessionRepository) GetAllIdentity(ctx context.Context, identityID uuid.UUID) ([]session.Session, error) {
+	var found []session.Session
+	if err := persistence.Conn(ctx, g.DB).Where("identity_id = ?", identityID).Order("authenticated_at desc").Find(&found).Error; err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
 func (g *gormSessionRepository) DeleteAllIdentity(ctx context.Context, identityID uuid.UUID) error {
-	if err := g.DB.Where("identity_Id = ?", identityID).Delete(session.Session{}).Error; err != nil && err != gorm.ErrRecordNotFound {
+	if err := persistence.Conn(ctx, g.DB).Where("identity_Id = ?", identityID).Delete(session.Session{}).Error; err != nil && err != gorm.ErrRecordNotFound {
 		return err
 	}
 	return nil
 }
+
+func (g *gormSessionRepository) DeleteAllIdentityExcept(ctx context.Context, identityID uuid.UUID, id uuid.UUID) error {
+	if err := persistence.Conn(ctx, g.DB).Where("identity_id = ? AND id <> ?", identityID, id).Delete(session.Session{}).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	return nil
+}
+
+func (g *gormSessionRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	created := before.Add(-config.Get().Session.Lifetime)
+	return persistence.DeleteBatch(persistence.Conn(ctx, g.DB), &session.Session{}, limit, "expires_at < ? OR (expires_at IS NULL AND created_at < ?)", before, created)
+}
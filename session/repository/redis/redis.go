@@ -0,0 +1,309 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/session"
+	"github.com/RagOfJoes/mylo/user/identity"
+	"github.com/go-redis/redis/v8"
+	"github.com/gofrs/uuid"
+)
+
+type redisSessionRepository struct {
+	c      *redis.Client
+	ir     identity.Repository
+	prefix string
+}
+
+// record is what's actually stored for a session. Token and IdentityID are hidden from the Session's JSON so they're
+// stored alongside it, while the Identity is always loaded fresh
+type record struct {
+	session.Session
+	Token      string     `json:"token"`
+	IdentityID *uuid.UUID `json:"identity_id"`
+}
+
+// NewRedisSessionRepository stores sessions in Redis with a TTL that matches their expiry, along with an index of
+// every session that belongs to an identity. Identities are still retrieved with the identity repository
+func NewRedisSessionRepository(c *redis.Client, ir identity.Repository) session.Repository {
+	return &redisSessionRepository{
+		c:      c,
+		ir:     ir,
+		prefix: config.Get().Redis.Prefix,
+	}
+}
+
+func (r *redisSessionRepository) Create(ctx context.Context, newSession session.Session) (*session.Session, error) {
+	if err := r.save(ctx, newSession, nil); err != nil {
+		return nil, err
+	}
+	created := newSession
+	return &created, nil
+}
+
+func (r *redisSessionRepository) Get(ctx context.Context, id uuid.UUID) (*session.Session, error) {
+	found, err := r.getRecord(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	sess := found.session()
+	if sess.IdentityID != nil {
+		user, err := r.ir.Get(ctx, *sess.IdentityID, false)
+		if err != nil {
+			return nil, err
+		}
+		sess.Identity = user
+	}
+	return &sess, nil
+}
+
+func (r *redisSessionRepository) GetByToken(ctx context.Context, token string) (*session.Session, error) {
+	raw, err := r.c.Get(ctx, r.tokenKey(token)).Result()
+	if err == redis.Nil {
+		return nil, session.ErrSessionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	id, err := uuid.FromString(raw)
+	if err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, id)
+}
+
+func (r *redisSessionRepository) Update(ctx context.Context, updateSession session.Session) (*session.Session, error) {
+	// Like gorm's Save, a session that doesn't exist anymore is created again
+	previous, err := r.getRecord(ctx, updateSession.ID)
+	if err != nil && err != session.ErrSessionNotFound {
+		return nil, err
+	}
+	if err := r.save(ctx, updateSession, previous); err != nil {
+		return nil, err
+	}
+	updated := updateSession
+	return &updated, nil
+}
+
+func (r *redisSessionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	found, err := r.getRecord(ctx, id)
+	if err == session.ErrSessionNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	_, err = r.c.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, r.sessionKey(id), r.tokenKey(found.Token))
+		if found.IdentityID != nil {
+			pipe.SRem(ctx, r.identityKey(*found.IdentityID), id.String())
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *redisSessionRepository) GetAllIdentity(ctx context.Context, identityID uuid.UUID) ([]session.Session, error) {
+	found, err := r.getAllIdentity(ctx, identityID)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return []session.Session{}, nil
+	}
+	// Every session in the index belongs to the same identity
+	user, err := r.ir.Get(ctx, identityID, false)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]session.Session, 0, len(found))
+	for _, rec := range found {
+		sess := rec.session()
+		sess.Identity = user
+		sessions = append(sessions, sess)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		a, b := sessions[i].AuthenticatedAt, sessions[j].AuthenticatedAt
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.After(*b)
+	})
+	return sessions, nil
+}
+
+func (r *redisSessionRepository) DeleteAllIdentity(ctx context.Context, identityID uuid.UUID) error {
+	return r.deleteAllIdentity(ctx, identityID, uuid.Nil)
+}
+
+func (r *redisSessionRepository) DeleteAllIdentityExcept(ctx context.Context, identityID uuid.UUID, id uuid.UUID) error {
+	return r.deleteAllIdentity(ctx, identityID, id)
+}
+
+// DeleteExpired has nothing to do since Redis removes sessions on its own once their TTL runs out
+func (r *redisSessionRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	return 0, nil
+}
+
+// save writes the session, its token and its place in the identity's index in a single transaction. previous is the
+// session as it's currently stored, if at all, so that a token or identity that changed doesn't leave anything behind
+func (r *redisSessionRepository) save(ctx context.Context, s session.Session, previous *record) error {
+	data, err := json.Marshal(newRecord(s))
+	if err != nil {
+		return err
+	}
+	ttl := r.ttl(s)
+	id := s.ID.String()
+	// The index has to live for as long as the longest lived session in it
+	var extendIndex bool
+	if s.IdentityID != nil {
+		current, err := r.c.PTTL(ctx, r.identityKey(*s.IdentityID)).Result()
+		if err != nil {
+			return err
+		}
+		extendIndex = current < ttl
+	}
+
+	_, err = r.c.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if previous != nil {
+			if previous.Token != s.Token {
+				pipe.Del(ctx, r.tokenKey(previous.Token))
+			}
+			if previous.IdentityID != nil && (s.IdentityID == nil || *previous.IdentityID != *s.IdentityID) {
+				pipe.SRem(ctx, r.identityKey(*previous.IdentityID), id)
+			}
+		}
+		pipe.Set(ctx, r.sessionKey(s.ID), data, ttl)
+		pipe.Set(ctx, r.tokenKey(s.Token), id, ttl)
+		if s.IdentityID != nil {
+			key := r.identityKey(*s.IdentityID)
+			pipe.SAdd(ctx, key, id)
+			if extendIndex {
+				pipe.PExpire(ctx, key, ttl)
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *redisSessionRepository) getRecord(ctx context.Context, id uuid.UUID) (*record, error) {
+	raw, err := r.c.Get(ctx, r.sessionKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, session.ErrSessionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	var found record
+	if err := json.Unmarshal(raw, &found); err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+// getAllIdentity retrieves every session in the identity's index. Sessions that Redis has already expired are pruned
+// from the index along the way
+func (r *redisSessionRepository) getAllIdentity(ctx context.Context, identityID uuid.UUID) ([]record, error) {
+	key := r.identityKey(identityID)
+	ids, err := r.c.SMembers(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = r.sessionKey(uuid.FromStringOrNil(id))
+	}
+	raws, err := r.c.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []interface{}
+	found := make([]record, 0, len(raws))
+	for i, raw := range raws {
+		str, ok := raw.(string)
+		if !ok {
+			expired = append(expired, ids[i])
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal([]byte(str), &rec); err != nil {
+			return nil, err
+		}
+		found = append(found, rec)
+	}
+	if len(expired) > 0 {
+		if err := r.c.SRem(ctx, key, expired...).Err(); err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+// deleteAllIdentity deletes every session that belongs to an identity except for except, which can be uuid.Nil
+func (r *redisSessionRepository) deleteAllIdentity(ctx context.Context, identityID uuid.UUID, except uuid.UUID) error {
+	found, err := r.getAllIdentity(ctx, identityID)
+	if err != nil {
+		return err
+	}
+	_, err = r.c.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		key := r.identityKey(identityID)
+		for _, rec := range found {
+			if rec.ID == except {
+				continue
+			}
+			pipe.Del(ctx, r.sessionKey(rec.ID), r.tokenKey(rec.Token))
+			pipe.SRem(ctx, key, rec.ID.String())
+		}
+		return nil
+	})
+	return err
+}
+
+// ttl determines how long Redis should keep a session for. Sessions that haven't been authenticated don't have an
+// expiry so they're kept for a Lifetime
+func (r *redisSessionRepository) ttl(s session.Session) time.Duration {
+	if s.ExpiresAt == nil {
+		return config.Get().Session.Lifetime
+	}
+	ttl := time.Until(*s.ExpiresAt)
+	// Redis rejects TTLs that aren't positive so an expired session is kept just long enough to be rejected by Valid
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	return ttl
+}
+
+func (r *redisSessionRepository) sessionKey(id uuid.UUID) string {
+	return r.prefix + "session:" + id.String()
+}
+
+func (r *redisSessionRepository) tokenKey(token string) string {
+	return r.prefix + "session_token:" + token
+}
+
+func (r *redisSessionRepository) identityKey(identityID uuid.UUID) string {
+	return r.prefix + "identity_sessions:" + identityID.String()
+}
+
+func newRecord(s session.Session) record {
+	rec := record{
+		Session:    s,
+		Token:      s.Token,
+		IdentityID: s.IdentityID,
+	}
+	rec.Session.Identity = nil
+	return rec
+}
+
+func (r record) session() session.Session {
+	s := r.Session
+	s.Token = r.Token
+	s.IdentityID = r.IdentityID
+	return s
+}
@@ -0,0 +1,178 @@
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RagOfJoes/mylo/event"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config/configtest"
+	"github.com/RagOfJoes/mylo/persistence/memory"
+	"github.com/RagOfJoes/mylo/session"
+	sessionRedis "github.com/RagOfJoes/mylo/session/repository/redis"
+	sessionService "github.com/RagOfJoes/mylo/session/service"
+	"github.com/RagOfJoes/mylo/user/credential"
+	"github.com/RagOfJoes/mylo/user/identity"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/gofrs/uuid"
+)
+
+func newRepository(t *testing.T) (*miniredis.Miniredis, session.Repository, identity.Identity) {
+	t.Helper()
+
+	configtest.Setup(t, map[string]interface{}{"redis.prefix": "mylo:"})
+	mr := miniredis.RunT(t)
+	c := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { c.Close() })
+
+	ir := memory.NewMemoryIdentityRepository(memory.NewStore())
+	created, err := ir.Create(context.Background(), identity.Identity{
+		BaseSoftDelete: internal.BaseSoftDelete{ID: uuid.Must(uuid.NewV4()), CreatedAt: time.Now()},
+		Email:          "jane@example.com",
+		FirstName:      "Jane",
+		LastName:       "Doe",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mr, sessionRedis.NewRedisSessionRepository(c, ir), *created
+}
+
+func newSession(t *testing.T, r session.Repository, user identity.Identity) session.Session {
+	t.Helper()
+
+	sess, err := session.NewAuthenticated(user, internal.Client{}, credential.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Create(context.Background(), *sess); err != nil {
+		t.Fatal(err)
+	}
+	return *sess
+}
+
+func TestSession(t *testing.T) {
+	mr, r, user := newRepository(t)
+	ctx := context.Background()
+	sess := newSession(t, r, user)
+
+	// Sessions live for exactly as long as they're valid
+	if ttl := mr.TTL("mylo:session:" + sess.ID.String()); ttl <= time.Hour || ttl > 2*time.Hour {
+		t.Errorf("expected the TTL to match the session's expiry, got %s", ttl)
+	}
+	found, err := r.GetByToken(ctx, sess.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != sess.ID || found.Identity == nil || found.Identity.ID != user.ID {
+		t.Errorf("expected the session along with its identity, got %+v", found)
+	}
+
+	// A rotated token must not keep working
+	rotated := *found
+	rotated.Token = "rotated"
+	if _, err := r.Update(ctx, rotated); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.GetByToken(ctx, sess.Token); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Errorf("expected the previous token to be removed, got %v", err)
+	}
+	if _, err := r.GetByToken(ctx, "rotated"); err != nil {
+		t.Errorf("expected the new token to work, got %v", err)
+	}
+
+	if err := r.Delete(ctx, sess.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Get(ctx, sess.ID); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Errorf("expected the session to be deleted, got %v", err)
+	}
+	if mr.Exists("mylo:session_token:rotated") {
+		t.Error("expected the token to be deleted along with the session")
+	}
+}
+
+func TestIdentitySessions(t *testing.T) {
+	mr, r, user := newRepository(t)
+	ctx := context.Background()
+	current := newSession(t, r, user)
+	other := newSession(t, r, user)
+	another := newSession(t, r, user)
+
+	found, err := r.GetAllIdentity(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 3 {
+		t.Fatalf("expected 3 sessions, got %d", len(found))
+	}
+
+	if err := r.DeleteAllIdentityExcept(ctx, user.ID, current.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []uuid.UUID{other.ID, another.ID} {
+		if _, err := r.Get(ctx, id); !errors.Is(err, session.ErrSessionNotFound) {
+			t.Errorf("expected session %s to be deleted, got %v", id, err)
+		}
+	}
+	if _, err := r.Get(ctx, current.ID); err != nil {
+		t.Errorf("expected the current session to be kept, got %v", err)
+	}
+
+	// Sessions that Redis expired are pruned from the index
+	mr.FastForward(3 * time.Hour)
+	if found, err := r.GetAllIdentity(ctx, user.ID); err != nil || len(found) != 0 {
+		t.Errorf("expected every session to have expired, got %d, %v", len(found), err)
+	}
+	if mr.Exists("mylo:identity_sessions:" + user.ID.String()) {
+		t.Error("expected the index to expire along with its sessions")
+	}
+
+	newSession(t, r, user)
+	if err := r.DeleteAllIdentity(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := r.GetAllIdentity(ctx, user.ID); err != nil || len(found) != 0 {
+		t.Errorf("expected every session to be deleted, got %d, %v", len(found), err)
+	}
+}
+
+func TestAuthenticatedRoundTrip(t *testing.T) {
+	_, r, user := newRepository(t)
+	ctx := context.Background()
+	se := sessionService.NewSessionService(r, event.NewBus())
+	current := newSession(t, r, user)
+	other := newSession(t, r, user)
+
+	// Whatever is stored in Redis must come back as an authenticated session
+	found, err := se.FindByToken(ctx, current.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found.Authenticated() || found.Identity.Email != user.Email {
+		t.Errorf("expected an authenticated session along with its identity, got %+v", found)
+	}
+	all, err := r.GetAllIdentity(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sess := range all {
+		if sess.Valid() != nil || !sess.Authenticated() {
+			t.Errorf("expected session %s to be valid and authenticated, got %+v", sess.ID, sess)
+		}
+	}
+	active, err := se.FindAllIdentity(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[uuid.UUID]bool{}
+	for _, sess := range active {
+		ids[sess.ID] = true
+	}
+	if len(active) != 2 || !ids[current.ID] || !ids[other.ID] {
+		t.Errorf("expected both sessions to be active, got %+v", active)
+	}
+}
@@ -2,7 +2,9 @@ package service
 
 import (
 	"context"
+	"time"
 
+	"github.com/RagOfJoes/mylo/event"
 	"github.com/RagOfJoes/mylo/internal"
 	"github.com/RagOfJoes/mylo/internal/validate"
 	"github.com/RagOfJoes/mylo/session"
@@ -10,12 +12,14 @@ import (
 )
 
 type service struct {
-	r session.Repository
+	r  session.Repository
+	eb event.Bus
 }
 
-func NewSessionService(r session.Repository) session.Service {
+func NewSessionService(r session.Repository, eb event.Bus) session.Service {
 	return &service{
-		r: r,
+		r:  r,
+		eb: eb,
 	}
 }
 
@@ -68,19 +72,80 @@ func (s *service) Update(ctx context.Context, currentSession session.Session) (*
 }
 
 func (s *service) Destroy(ctx context.Context, id uuid.UUID) error {
+	// Retrieve the session beforehand so that subscribers know who it belonged to
+	var identityID *uuid.UUID
+	if found, err := s.r.Get(ctx, id); err == nil {
+		identityID = found.IdentityID
+	}
 	err := s.r.Delete(ctx, id)
 	if err != nil {
 		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to delete session: %s", id)
 	}
-	return nil
+	return s.eb.Publish(ctx, event.SessionRevoked{
+		IdentityID: identityID,
+		SessionIDs: []uuid.UUID{id},
+	})
+}
+
+func (s *service) FindAllIdentity(ctx context.Context, identityID uuid.UUID) ([]session.Session, error) {
+	found, err := s.r.GetAllIdentity(ctx, identityID)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to retrieve all the session for: %s", identityID)
+	}
+	// Only return sessions that are still active. Repositories don't load the
+	// identity of every session so Valid and Authenticated can't be used here
+	now := time.Now()
+	active := []session.Session{}
+	for _, sess := range found {
+		if sess.State != session.Authenticated || sess.ExpiresAt == nil || !sess.ExpiresAt.After(now) {
+			continue
+		}
+		active = append(active, sess)
+	}
+	return active, nil
 }
 
 func (s *service) DestroyAllIdentity(ctx context.Context, identityID uuid.UUID) error {
+	revoked := s.sessionIDs(ctx, identityID, uuid.Nil)
 	err := s.r.DeleteAllIdentity(ctx, identityID)
 	if err != nil {
 		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to delete all the session for: %s", identityID)
 	}
-	return nil
+	return s.publishRevoked(ctx, identityID, revoked)
+}
+
+func (s *service) DestroyOthers(ctx context.Context, identityID uuid.UUID, id uuid.UUID) error {
+	revoked := s.sessionIDs(ctx, identityID, id)
+	err := s.r.DeleteAllIdentityExcept(ctx, identityID, id)
+	if err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to delete all the other session for: %s", identityID)
+	}
+	return s.publishRevoked(ctx, identityID, revoked)
+}
+
+// sessionIDs retrieves the ID of every session that belongs to an identity, excluding the one provided
+func (s *service) sessionIDs(ctx context.Context, identityID uuid.UUID, except uuid.UUID) []uuid.UUID {
+	found, err := s.r.GetAllIdentity(ctx, identityID)
+	if err != nil {
+		return nil
+	}
+	ids := []uuid.UUID{}
+	for _, sess := range found {
+		if sess.ID != except {
+			ids = append(ids, sess.ID)
+		}
+	}
+	return ids
+}
+
+func (s *service) publishRevoked(ctx context.Context, identityID uuid.UUID, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.eb.Publish(ctx, event.SessionRevoked{
+		IdentityID: &identityID,
+		SessionIDs: ids,
+	})
 }
 
 func stripSession(s *session.Session) {
@@ -20,21 +20,46 @@ var (
 	ErrSessionNotFound     = errors.New("No active session found")
 	ErrInvalidSessionID    = errors.New("Invalid session id provided")
 	ErrInvalidSessionToken = errors.New("Invalid session token provided")
+	ErrInsufficientAAL     = errors.New("A second factor is required to access this resource. Login again with your second factor to continue")
+	ErrStaleSession        = errors.New("You must login again to access this resource")
 )
 
-// TODO: Check if Locked state is at all useful here
 // State defines the current state of the session
 type State string
 
 const (
 	// Unauthenticated is the default State
 	Unauthenticated State = "Unauthenticated"
-	// Locked occurs when the User has 5 consecutive failed login attempts. The User must now go through the Recovery flow
+	// Locked occurs when the User has too many consecutive failed login attempts. The User must now wait for the lockout to expire or go through the Recovery flow
 	Locked State = "Locked"
 	// Authenticated occurs when the User has successfully authenticated
 	Authenticated State = "Authenticated"
 )
 
+// AAL defines the authenticator assurance level of a session
+type AAL string
+
+const (
+	// AAL0 occurs when the User has yet to authenticate
+	AAL0 AAL = "aal0"
+	// AAL1 occurs when the User has passed a single factor
+	AAL1 AAL = "aal1"
+	// AAL2 occurs when the User has passed a second factor ie. TOTP
+	AAL2 AAL = "aal2"
+)
+
+// Rank orders assurance levels so that they can be compared
+func (a AAL) Rank() int {
+	switch a {
+	case AAL1:
+		return 1
+	case AAL2:
+		return 2
+	default:
+		return 0
+	}
+}
+
 // Session defines the session model
 //
 // A Session will only be assigned when one of the following occur:
@@ -58,6 +83,12 @@ type Session struct {
 	AuthenticatedAt *time.Time `json:"authenticated_at" validate:"required_if=State Authenticated"`
 	// CredentialMethods defines the list of credentials used to authenticate the user
 	CredentialMethods CredentialMethods `json:"credential_methods,omitempty" gorm:"type:json;default:null" validate:"required_if=State Authenticated"`
+	// AAL defines the assurance level that the credential methods add up to
+	AAL AAL `json:"aal" gorm:"not null;default:aal1" validate:"required,oneof='aal0' 'aal1' 'aal2'"`
+	// Client defines the client that created the session
+	Client internal.Client `json:"client" gorm:"embedded;embeddedPrefix:client_"`
+	// AuthenticatedClient defines the client that last authenticated the session
+	AuthenticatedClient internal.Client `json:"authenticated_client" gorm:"embedded;embeddedPrefix:authenticated_client_"`
 
 	// IdentityID defines the ID of the User that the session belongs to
 	IdentityID *uuid.UUID `json:"-" validate:"required_if=State Authenticated"`
@@ -76,8 +107,15 @@ type Repository interface {
 	Update(ctx context.Context, updateSession Session) (*Session, error)
 	// Delete deletes a session via ID
 	Delete(ctx context.Context, id uuid.UUID) error
+	// GetAllIdentity retrieves all the session that belongs to an identity
+	GetAllIdentity(ctx context.Context, identityID uuid.UUID) ([]Session, error)
 	// DeleteAllIdentity deletes all the session that belongs to an identity
 	DeleteAllIdentity(ctx context.Context, identityID uuid.UUID) error
+	// DeleteAllIdentityExcept deletes all the session that belongs to an identity except for the session provided
+	DeleteAllIdentityExcept(ctx context.Context, identityID uuid.UUID, id uuid.UUID) error
+	// DeleteExpired deletes up to limit sessions that expired before the time provided and returns how many were deleted.
+	// Sessions that were never authenticated expire a Lifetime after they were created
+	DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error)
 }
 
 type Service interface {
@@ -91,11 +129,15 @@ type Service interface {
 	Update(ctx context.Context, updateSession Session) (*Session, error)
 	// Destroy deletes session
 	Destroy(ctx context.Context, id uuid.UUID) error
+	// FindAllIdentity finds all the active session that belongs to an identity
+	FindAllIdentity(ctx context.Context, identityID uuid.UUID) ([]Session, error)
 	// DestroyAllIdentity deletes all the session that belongs to an identity
 	DestroyAllIdentity(ctx context.Context, identityID uuid.UUID) error
+	// DestroyOthers deletes all the session that belongs to an identity except for the session provided
+	DestroyOthers(ctx context.Context, identityID uuid.UUID, id uuid.UUID) error
 }
 
-func NewUnauthenticated() (*Session, error) {
+func NewUnauthenticated(client internal.Client) (*Session, error) {
 	id, err := uuid.NewV4()
 	if err != nil {
 		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate uuid")
@@ -109,16 +151,18 @@ func NewUnauthenticated() (*Session, error) {
 		ID:        id,
 		CreatedAt: now,
 		Token:     token,
+		Client:    client,
 		State:     Unauthenticated,
+		AAL:       AAL0,
 	}, nil
 }
 
-func NewAuthenticated(identity identity.Identity, methods ...credential.CredentialType) (*Session, error) {
-	newSession, err := NewUnauthenticated()
+func NewAuthenticated(identity identity.Identity, client internal.Client, methods ...credential.CredentialType) (*Session, error) {
+	newSession, err := NewUnauthenticated(client)
 	if err != nil {
 		return nil, err
 	}
-	if err := newSession.Authenticate(identity, methods...); err != nil {
+	if err := newSession.Authenticate(identity, client, methods...); err != nil {
 		return nil, err
 	}
 	return newSession, nil
@@ -145,10 +189,11 @@ func (s *Session) AddCredential(method credential.CredentialType) error {
 		Method:   method,
 		IssuedAt: time.Now(),
 	})
+	s.AAL = assurance(s.CredentialMethods)
 	return nil
 }
 
-func (s *Session) Authenticate(identity identity.Identity, methods ...credential.CredentialType) error {
+func (s *Session) Authenticate(identity identity.Identity, client internal.Client, methods ...credential.CredentialType) error {
 	if s.State == Locked {
 		return internal.NewErrorf(internal.ErrorCodeUnauthorized, "Account has been locked. Reset password to unlock account")
 	}
@@ -158,14 +203,94 @@ func (s *Session) Authenticate(identity identity.Identity, methods ...credential
 		}
 	}
 
-	cfg := config.Get()
 	now := time.Now()
-	expire := now.Add(cfg.Session.Lifetime)
+	expire := expiry(now, now)
 	s.State = Authenticated
 	s.ExpiresAt = &expire
 	s.AuthenticatedAt = &now
 	s.IdentityID = &identity.ID
 	s.Identity = &identity
+	s.AuthenticatedClient = client
+	return nil
+}
+
+// Extend slides the expiry of an authenticated session forward to a full Lifetime from now, without going past
+// MaxLifetime. Unless force is set, the expiry is only moved when it would move by at least SlidingInterval so that an
+// active session isn't written on every request. Returns whether the expiry was moved
+func (s *Session) Extend(force bool) bool {
+	if !s.Authenticated() || s.AuthenticatedAt == nil {
+		return false
+	}
+	cfg := config.Get()
+	expire := expiry(*s.AuthenticatedAt, time.Now())
+	moved := expire.Sub(*s.ExpiresAt)
+	if moved <= 0 || (!force && moved < cfg.Session.SlidingInterval) {
+		return false
+	}
+	s.ExpiresAt = &expire
+	return true
+}
+
+func (s *Session) Lockout() {
+	s.State = Locked
+	s.ExpiresAt = nil
+	s.AuthenticatedAt = nil
+	s.CredentialMethods = nil
+	s.AAL = AAL0
+	s.IdentityID = nil
+	s.Identity = nil
+}
+
+func (s *Session) Unlock() {
+	if s.State == Locked {
+		s.State = Unauthenticated
+	}
+}
+
+// assurance determines the assurance level that credential methods add up to. A one-time password from an
+// authenticator app is the only second factor so anything else on its own is a single factor
+func assurance(methods CredentialMethods) AAL {
+	if len(methods) == 0 {
+		return AAL0
+	}
+	factors := map[bool]bool{}
+	for _, m := range methods {
+		factors[m.Method == credential.TOTP] = true
+	}
+	if len(factors) == 2 {
+		return AAL2
+	}
+	return AAL1
+}
+
+// expiry computes when a session that was authenticated at authenticatedAt should expire if it was last used at now
+func expiry(authenticatedAt time.Time, now time.Time) time.Time {
+	cfg := config.Get()
+	expire := now.Add(cfg.Session.Lifetime)
+	if cfg.Session.MaxLifetime > 0 {
+		if max := authenticatedAt.Add(cfg.Session.MaxLifetime); max.Before(expire) {
+			expire = max
+		}
+	}
+	return expire
+}
+
+func (s *Session) BelongsTo(identityID uuid.UUID) bool {
+	return s.IdentityID != nil && *s.IdentityID == identityID
+}
+
+// Satisfies checks whether the session is authenticated with at least the assurance level min and, if maxAge isn't 0,
+// has authenticated within maxAge
+func (s *Session) Satisfies(min AAL, maxAge time.Duration) error {
+	if !s.Authenticated() || s.AuthenticatedAt == nil {
+		return internal.NewErrorf(internal.ErrorCodeUnauthorized, "%v", internal.ErrUnauthorized)
+	}
+	if s.AAL.Rank() < min.Rank() {
+		return internal.NewErrorf(internal.ErrorCodeForbidden, "%v", ErrInsufficientAAL)
+	}
+	if maxAge > 0 && time.Since(*s.AuthenticatedAt) > maxAge {
+		return internal.NewErrorf(internal.ErrorCodeForbidden, "%v", ErrStaleSession)
+	}
 	return nil
 }
 
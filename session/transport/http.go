@@ -2,12 +2,17 @@ package transport
 
 import (
 	"context"
+	"log"
 	"net/http"
+	"time"
 
+	"github.com/RagOfJoes/mylo/audit"
 	"github.com/RagOfJoes/mylo/internal"
 	"github.com/RagOfJoes/mylo/internal/config"
 	"github.com/RagOfJoes/mylo/internal/validate"
 	"github.com/RagOfJoes/mylo/session"
+	"github.com/RagOfJoes/mylo/transport"
+	"github.com/gin-gonic/gin"
 	"github.com/gorilla/sessions"
 )
 
@@ -25,7 +30,7 @@ func NewSessionHttp(st sessions.Store, se session.Service) *Http {
 
 // New creates a new Unauthenticated session and stores it in Repository
 func (h *Http) New(ctx context.Context, req *http.Request, w http.ResponseWriter) (*session.Session, error) {
-	newSession, err := session.NewUnauthenticated()
+	newSession, err := session.NewUnauthenticated(transport.Client(req))
 	if err != nil {
 		return nil, err
 	}
@@ -33,6 +38,7 @@ func (h *Http) New(ctx context.Context, req *http.Request, w http.ResponseWriter
 	if err != nil {
 		return nil, err
 	}
+	audit.FromContext(ctx).SetSession(created.ID, created.IdentityID)
 	return created, nil
 }
 
@@ -70,6 +76,21 @@ func (h *Http) SetCookie(req *http.Request, w http.ResponseWriter, s session.Ses
 	return nil
 }
 
+// ClearCookie removes the session cookie from the client
+func (h *Http) ClearCookie(req *http.Request, w http.ResponseWriter) error {
+	cfg := config.Get()
+	cookie, err := h.st.Get(req, cfg.Session.Cookie.Name)
+	if err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to retrieve session from cookie store")
+	}
+	delete(cookie.Values, "session")
+	cookie.Options.MaxAge = -1
+	if err := cookie.Save(req, w); err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to clear session cookie")
+	}
+	return nil
+}
+
 // UpsertAndSetCookie will call Upsert method then SetCookie method
 func (h *Http) UpsertAndSetCookie(ctx context.Context, req *http.Request, w http.ResponseWriter, upsertSession session.Session) (*session.Session, error) {
 	upserted, err := h.Upsert(ctx, upsertSession)
@@ -97,6 +118,16 @@ func (h *Http) Session(ctx context.Context, req *http.Request, w http.ResponseWr
 	if found.IdentityID != nil && found.Identity != nil {
 		found.Identity.Credentials = nil
 	}
+	audit.FromContext(ctx).SetSession(found.ID, found.IdentityID)
+	// Keep active sessions alive. Failing to do so shouldn't fail the request since the session is still valid
+	if cfg := config.Get(); cfg.Session.Sliding && found.Extend(false) {
+		if updated, err := h.se.Update(ctx, *found); err != nil {
+			// TODO: Capture Error Here
+			log.Print(err)
+		} else {
+			found = updated
+		}
+	}
 	return found, nil
 }
 
@@ -139,6 +170,32 @@ func (h *Http) SessionOrNewAndSetCookie(ctx context.Context, req *http.Request,
 	return found, nil
 }
 
+// RequireAAL is a middleware that only lets requests through when the session is authenticated with at least the
+// assurance level min and, if maxAge isn't 0, has authenticated within maxAge. Anything else has to step up with a
+// refresh login flow, which upgrades the existing session
+func (h *Http) RequireAAL(min session.AAL, maxAge time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sess, err := h.Session(c.Request.Context(), c.Request, c.Writer, true)
+		if err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeUnauthorized, "%v", internal.ErrUnauthorized))
+			c.Abort()
+			return
+		}
+		if err := sess.Satisfies(min, maxAge); err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Protected is RequireAAL with the requirements in the Session configuration
+func (h *Http) Protected() gin.HandlerFunc {
+	cfg := config.Get()
+	return h.RequireAAL(session.AAL(cfg.Session.RequiredAAL), cfg.Session.MaxAuthenticationAge)
+}
+
 func (h *Http) getToken(req *http.Request) string {
 	// First check Headers
 	if token := req.Header.Get("X-Session-Token"); token != "" {
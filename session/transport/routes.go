@@ -0,0 +1,174 @@
+package transport
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/session"
+	"github.com/RagOfJoes/mylo/transport"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+// NewSessionRoutes attaches routes that allow a User to manage their sessions
+func NewSessionRoutes(h *Http, r *gin.Engine) {
+	group := r.Group("/sessions")
+	{
+		group.GET("/", h.list())
+		group.GET("/whoami", h.whoami())
+		group.HEAD("/whoami", h.whoami())
+		group.DELETE("/", h.revokeOthers())
+		group.DELETE("/:id", h.revoke())
+		group.POST("/refresh", h.refresh())
+	}
+	r.POST("/logout", h.logout())
+}
+
+func (h *Http) list() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.Session(ctx, c.Request, c.Writer, true)
+		if err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeUnauthorized, "%v", internal.ErrUnauthorized))
+			return
+		}
+		sessions, err := h.se.FindAllIdentity(ctx, *sess.IdentityID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Payload: sessions,
+		})
+	}
+}
+
+func (h *Http) revoke() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.Session(ctx, c.Request, c.Writer, true)
+		if err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeUnauthorized, "%v", internal.ErrUnauthorized))
+			return
+		}
+		id, err := uuid.FromString(c.Param("id"))
+		if err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", session.ErrInvalidSessionID))
+			return
+		}
+		// Make sure the session being revoked actually belongs to the User
+		found, err := h.se.FindByID(ctx, id)
+		if err != nil || !found.BelongsTo(*sess.IdentityID) {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeNotFound, "%v", session.ErrInvalidSessionID))
+			return
+		}
+		if err := h.se.Destroy(ctx, found.ID); err != nil {
+			c.Error(err)
+			return
+		}
+		// If the current session was revoked then treat it as a logout
+		if found.ID == sess.ID {
+			if err := h.ClearCookie(c.Request, c.Writer); err != nil {
+				// TODO: Capture Error Here
+				log.Print(err)
+			}
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+		})
+	}
+}
+
+func (h *Http) revokeOthers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.Session(ctx, c.Request, c.Writer, true)
+		if err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeUnauthorized, "%v", internal.ErrUnauthorized))
+			return
+		}
+		if err := h.se.DestroyOthers(ctx, *sess.IdentityID, sess.ID); err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+		})
+	}
+}
+
+// refresh extends the current session to a full lifetime from now, without going past the configured max lifetime. This
+// works whether or not sliding sessions are enabled
+func (h *Http) refresh() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.Session(ctx, c.Request, c.Writer, true)
+		if err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeUnauthorized, "%v", internal.ErrUnauthorized))
+			return
+		}
+		if sess.Extend(true) {
+			if sess, err = h.se.Update(ctx, *sess); err != nil {
+				c.Error(err)
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Payload: sess,
+		})
+	}
+}
+
+// whoami answers whether the request carries an authenticated session without a response body so that it can be used
+// by reverse proxies ie. nginx's auth_request or Traefik's ForwardAuth. The session is described with headers that the
+// proxy can forward upstream
+func (h *Http) whoami() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		sess, err := h.Session(c.Request.Context(), c.Request, c.Writer, true)
+		if err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeUnauthorized, "%v", internal.ErrUnauthorized))
+			return
+		}
+
+		c.Header("X-User-Id", sess.IdentityID.String())
+		c.Header("X-Session-Id", sess.ID.String())
+		c.Header("X-Session-Aal", string(sess.AAL))
+		c.Header("X-Session-Expires-At", sess.ExpiresAt.UTC().Format(time.RFC3339))
+		if sess.Identity != nil {
+			c.Header("X-User-Email", sess.Identity.Email)
+		}
+		c.Status(http.StatusOK)
+	}
+}
+
+func (h *Http) logout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.Session(ctx, c.Request, c.Writer, false)
+		if err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeUnauthorized, "%v", session.ErrSessionNotFound))
+			return
+		}
+		if err := h.se.Destroy(ctx, sess.ID); err != nil {
+			c.Error(err)
+			return
+		}
+		if err := h.ClearCookie(c.Request, c.Writer); err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package transport_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RagOfJoes/mylo/event"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config/configtest"
+	"github.com/RagOfJoes/mylo/persistence/memory"
+	"github.com/RagOfJoes/mylo/session"
+	sessionService "github.com/RagOfJoes/mylo/session/service"
+	sessionHttp "github.com/RagOfJoes/mylo/session/transport"
+	"github.com/RagOfJoes/mylo/transport"
+	"github.com/RagOfJoes/mylo/user/credential"
+	"github.com/RagOfJoes/mylo/user/identity"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/sessions"
+)
+
+func TestListSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	configtest.Setup(t, nil)
+	store := memory.NewStore()
+	ctx := context.Background()
+	ir := memory.NewMemoryIdentityRepository(store)
+	newIdentity := func(email string) identity.Identity {
+		created, err := ir.Create(ctx, identity.Identity{
+			BaseSoftDelete: internal.BaseSoftDelete{ID: uuid.Must(uuid.NewV4()), CreatedAt: time.Now()},
+			Email:          email,
+			FirstName:      "Jane",
+			LastName:       "Doe",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *created
+	}
+	se := sessionService.NewSessionService(memory.NewMemorySessionRepository(store), event.NewBus())
+	r := gin.New()
+	r.Use(transport.ErrorMiddleware())
+	sessionHttp.NewSessionRoutes(sessionHttp.NewSessionHttp(sessions.NewCookieStore([]byte("secret")), se), r)
+
+	newSession := func(user identity.Identity) session.Session {
+		sess, err := session.NewAuthenticated(user, internal.Client{}, credential.Password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := se.New(ctx, *sess); err != nil {
+			t.Fatal(err)
+		}
+		return *sess
+	}
+	user := newIdentity("jane@example.com")
+	current := newSession(user)
+	other := newSession(user)
+	// Sessions that belong to someone else or have expired are left out
+	newSession(newIdentity("john@example.com"))
+	expired := newSession(user)
+	expiresAt := time.Now().Add(-time.Minute)
+	expired.ExpiresAt = &expiresAt
+	if _, err := memory.NewMemorySessionRepository(store).Update(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/sessions/", nil)
+	req.Header.Set("X-Session-Token", current.Token)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the sessions to be listed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var res struct {
+		Payload []session.Session `json:"payload"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	found := map[uuid.UUID]bool{}
+	for _, sess := range res.Payload {
+		found[sess.ID] = true
+	}
+	if len(res.Payload) != 2 || !found[current.ID] || !found[other.ID] {
+		t.Errorf("expected the 2 live sessions, got %+v", res.Payload)
+	}
+}
@@ -0,0 +1,34 @@
+package gorm
+
+import (
+	"context"
+	"time"
+
+	"github.com/RagOfJoes/mylo/persistence"
+	"github.com/RagOfJoes/mylo/token"
+	"gorm.io/gorm"
+)
+
+type gormTokenRepository struct {
+	DB *gorm.DB
+}
+
+func NewGormTokenRepository(d *gorm.DB) token.Repository {
+	return &gormTokenRepository{DB: d}
+}
+
+func (g *gormTokenRepository) Create(ctx context.Context, newKey token.Key) (*token.Key, error) {
+	clone := newKey
+	if err := persistence.Conn(ctx, g.DB).Create(&clone).Error; err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
+
+func (g *gormTokenRepository) GetAllUnexpired(ctx context.Context) ([]token.Key, error) {
+	keys := []token.Key{}
+	if err := persistence.Conn(ctx, g.DB).Where("expires_at > ?", time.Now()).Order("created_at desc").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
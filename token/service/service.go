@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"crypto/rsa"
+	"sync"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/session"
+	"github.com/RagOfJoes/mylo/token"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const (
+	// refreshInterval is how long keys are cached before they're reloaded so that keys created by other instances are
+	// picked up
+	refreshInterval = time.Minute
+	// missInterval is the minimum time between reloads caused by a token signed with an unknown key
+	missInterval = time.Second * 10
+	// leeway is the clock skew tolerated when validating a token
+	leeway = time.Second * 30
+)
+
+// cachedKey is a key along with its decoded private key
+type cachedKey struct {
+	token.Key
+	private *rsa.PrivateKey
+}
+
+type service struct {
+	r   token.Repository
+	cfg config.Token
+
+	// rotating makes sure that concurrent requests on the same instance don't each generate a key
+	rotating    sync.Mutex
+	mu          sync.Mutex
+	keys        []cachedKey
+	refreshedAt time.Time
+}
+
+func NewTokenService(r token.Repository, cfg config.Token) token.Service {
+	return &service{
+		r:   r,
+		cfg: cfg,
+	}
+}
+
+func (s *service) Issue(ctx context.Context, sess session.Session) (*token.AccessToken, error) {
+	claims, err := token.NewClaims(sess, s.cfg.Issuer, s.cfg.Audience, time.Now().Add(s.cfg.Lifetime))
+	if err != nil {
+		return nil, err
+	}
+	signed, err := s.Sign(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := claims.Expiry.Time()
+	return &token.AccessToken{
+		AccessToken: signed,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(expiresAt).Seconds()),
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+func (s *service) Sign(ctx context.Context, claims ...interface{}) (string, error) {
+	return s.SignWithType(ctx, token.TypeJWT, claims...)
+}
+
+func (s *service) SignWithType(ctx context.Context, typ string, claims ...interface{}) (string, error) {
+	key, err := s.signingKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: token.Algorithm, Key: key.private}, (&jose.SignerOptions{}).WithType(jose.ContentType(typ)).WithHeader("kid", key.ID.String()))
+	if err != nil {
+		return "", internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to create token signer")
+	}
+	builder := jwt.Signed(signer)
+	for _, c := range claims {
+		builder = builder.Claims(c)
+	}
+	signed, err := builder.CompactSerialize()
+	if err != nil {
+		return "", internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to sign token")
+	}
+	return signed, nil
+}
+
+func (s *service) Verify(ctx context.Context, raw string) (*token.Claims, error) {
+	claims, err := s.verify(ctx, raw, token.TypeJWT)
+	if err != nil {
+		return nil, err
+	}
+	// Tokens issued to OAuth2 clients are signed with the same keys but are meant for the client's audience. ID tokens
+	// don't carry a client_id so their audience, the client, is what sets them apart from a session's token
+	if claims.ClientID != "" || !sameAudience(claims.Audience, s.cfg.Audience) {
+		return nil, internal.NewErrorf(internal.ErrorCodeUnauthorized, "%v", token.ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+func (s *service) VerifyClient(ctx context.Context, raw string) (*token.Claims, error) {
+	claims, err := s.verify(ctx, raw, token.TypeAccessToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.ClientID == "" {
+		return nil, internal.NewErrorf(internal.ErrorCodeUnauthorized, "%v", token.ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// verify verifies the signature, `typ` header and validity of a token
+func (s *service) verify(ctx context.Context, raw string, typ string) (*token.Claims, error) {
+	parsed, err := jwt.ParseSigned(raw)
+	if err != nil || len(parsed.Headers) != 1 || parsed.Headers[0].Algorithm != string(token.Algorithm) {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeUnauthorized, "%v", token.ErrInvalidToken)
+	}
+	if header, _ := parsed.Headers[0].ExtraHeaders[jose.HeaderType].(string); header != typ {
+		return nil, internal.NewErrorf(internal.ErrorCodeUnauthorized, "%v", token.ErrInvalidToken)
+	}
+	key, err := s.verificationKey(ctx, parsed.Headers[0].KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims token.Claims
+	if err := parsed.Claims(&key.private.PublicKey, &claims); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeUnauthorized, "%v", token.ErrInvalidToken)
+	}
+	if err := claims.ValidateWithLeeway(jwt.Expected{Issuer: s.cfg.Issuer, Time: time.Now()}, leeway); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeUnauthorized, "%v", token.ErrInvalidToken)
+	}
+	return &claims, nil
+}
+
+func (s *service) JWKS(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	// Make sure that there's a key to publish before any token has been issued
+	if _, err := s.signingKey(ctx); err != nil {
+		return nil, err
+	}
+	keys, err := s.load(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	set := &jose.JSONWebKeySet{
+		Keys: make([]jose.JSONWebKey, 0, len(keys)),
+	}
+	for _, k := range keys {
+		set.Keys = append(set.Keys, jose.JSONWebKey{
+			Key:       &k.private.PublicKey,
+			KeyID:     k.ID.String(),
+			Algorithm: k.Algorithm,
+			Use:       "sig",
+		})
+	}
+	return set, nil
+}
+
+// signingKey retrieves the newest key that hasn't retired. When every key has retired a new one is generated
+func (s *service) signingKey(ctx context.Context) (*cachedKey, error) {
+	keys, err := s.load(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if key := newestActive(keys); key != nil {
+		return key, nil
+	}
+	s.rotating.Lock()
+	defer s.rotating.Unlock()
+	// Another request, or instance, may have already rotated the key
+	keys, err = s.load(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	if key := newestActive(keys); key != nil {
+		return key, nil
+	}
+	return s.rotate(ctx)
+}
+
+// verificationKey retrieves the key with the id provided
+func (s *service) verificationKey(ctx context.Context, id string) (*cachedKey, error) {
+	keys, err := s.load(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if key := byID(keys, id); key != nil {
+		return key, nil
+	}
+
+	// The key may have been created by another instance since the cache was last refreshed. Reloads are throttled so
+	// that tokens with made up key ids can't be used to hammer the database
+	s.mu.Lock()
+	stale := time.Since(s.refreshedAt) > missInterval
+	s.mu.Unlock()
+	if stale {
+		keys, err = s.load(ctx, true)
+		if err != nil {
+			return nil, err
+		}
+		if key := byID(keys, id); key != nil {
+			return key, nil
+		}
+	}
+	return nil, internal.NewErrorf(internal.ErrorCodeUnauthorized, "%v", token.ErrInvalidToken)
+}
+
+// rotate generates and stores a new signing key
+func (s *service) rotate(ctx context.Context) (*cachedKey, error) {
+	newKey, err := token.NewKey(s.cfg.Rotation, s.cfg.Lifetime)
+	if err != nil {
+		return nil, err
+	}
+	created, err := s.r.Create(ctx, *newKey)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "%v", token.ErrNoSigningKey)
+	}
+	private, err := created.Signer()
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "%v", token.ErrNoSigningKey)
+	}
+	key := cachedKey{Key: *created, private: private}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = append([]cachedKey{key}, s.keys...)
+	return &key, nil
+}
+
+// load retrieves the cached keys, reloading them from the repository when they're stale or force is set
+func (s *service) load(ctx context.Context, force bool) ([]cachedKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !force && s.keys != nil && time.Since(s.refreshedAt) < refreshInterval {
+		return unexpired(s.keys), nil
+	}
+	found, err := s.r.GetAllUnexpired(ctx)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "%v", token.ErrNoSigningKey)
+	}
+	keys := make([]cachedKey, 0, len(found))
+	for _, k := range found {
+		private, err := k.Signer()
+		if err != nil {
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to decode signing key: %s", k.ID)
+		}
+		keys = append(keys, cachedKey{Key: k, private: private})
+	}
+	s.keys = keys
+	s.refreshedAt = time.Now()
+	return keys, nil
+}
+
+// newestActive finds the newest key that hasn't retired. keys must be ordered newest first
+func newestActive(keys []cachedKey) *cachedKey {
+	for i := range keys {
+		if !keys[i].Retired() {
+			return &keys[i]
+		}
+	}
+	return nil
+}
+
+func byID(keys []cachedKey, id string) *cachedKey {
+	for i := range keys {
+		if keys[i].ID.String() == id {
+			return &keys[i]
+		}
+	}
+	return nil
+}
+
+func unexpired(keys []cachedKey) []cachedKey {
+	now := time.Now()
+	filtered := make([]cachedKey, 0, len(keys))
+	for _, k := range keys {
+		if k.ExpiresAt.After(now) {
+			filtered = append(filtered, k)
+		}
+	}
+	return filtered
+}
+
+// sameAudience checks whether a token's audience is exactly the one that's configured for sessions
+func sameAudience(aud jwt.Audience, expected []string) bool {
+	if len(aud) != len(expected) {
+		return false
+	}
+	for _, a := range expected {
+		if !aud.Contains(a) {
+			return false
+		}
+	}
+	return true
+}
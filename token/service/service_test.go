@@ -0,0 +1,117 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/persistence/memory"
+	"github.com/RagOfJoes/mylo/token"
+	"github.com/RagOfJoes/mylo/token/service"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func newService() token.Service {
+	return service.NewTokenService(memory.NewMemoryTokenRepository(memory.NewStore()), config.Token{
+		Issuer:   "https://mylo.test",
+		Audience: []string{"api.mylo.test"},
+		Lifetime: time.Minute,
+		Rotation: time.Hour,
+	})
+}
+
+func newClaims(clientID string) token.Claims {
+	now := time.Now()
+	return token.Claims{
+		Claims: jwt.Claims{
+			Issuer:   "https://mylo.test",
+			Subject:  "subject",
+			Audience: jwt.Audience{"api.mylo.test"},
+			IssuedAt: jwt.NewNumericDate(now),
+			Expiry:   jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+		ClientID: clientID,
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	s := newService()
+
+	first, err := s.Sign(ctx, newClaims(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(ctx, first); err != nil {
+		t.Errorf("expected a session token to verify, got %v", err)
+	}
+	if _, err := s.VerifyClient(ctx, first); err == nil {
+		t.Error("expected a session token to be rejected as a client's token")
+	}
+
+	client, err := s.SignWithType(ctx, token.TypeAccessToken, newClaims("client"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := s.VerifyClient(ctx, client)
+	if err != nil {
+		t.Fatalf("expected a client's token to verify, got %v", err)
+	}
+	if claims.ClientID != "client" {
+		t.Errorf("expected client_id client, got %q", claims.ClientID)
+	}
+	if _, err := s.Verify(ctx, client); err == nil {
+		t.Error("expected a client's token to be rejected as a session token")
+	}
+
+	// A client_id is enough to reject a token even if it has the typ of a session token
+	mixed, err := s.Sign(ctx, newClaims("client"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(ctx, mixed); err == nil {
+		t.Error("expected a token with a client_id to be rejected as a session token")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	ctx := context.Background()
+	s := newService()
+
+	// ID tokens share the typ of a session token and, unlike the access tokens issued to OAuth2 clients, have no client_id
+	claims := newClaims("")
+	claims.Audience = jwt.Audience{"client"}
+	idToken, err := s.Sign(ctx, claims, map[string]interface{}{"nonce": "nonce"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(ctx, idToken); err == nil {
+		t.Error("expected an ID token to be rejected as a session token")
+	}
+
+	// Nor can a token with the session's audience sneak another one in
+	claims.Audience = jwt.Audience{"api.mylo.test", "client"}
+	mixed, err := s.Sign(ctx, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(ctx, mixed); err == nil {
+		t.Error("expected a token with another audience to be rejected as a session token")
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	ctx := context.Background()
+	s := newService()
+
+	claims := newClaims("")
+	claims.Expiry = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	expired, err := s.Sign(ctx, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(ctx, expired); err == nil {
+		t.Error("expected an expired token to be rejected")
+	}
+}
@@ -0,0 +1,194 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"time"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/session"
+	"github.com/gofrs/uuid"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+var (
+	ErrInvalidToken           = errors.New("Invalid or expired token provided")
+	ErrNoSigningKey           = errors.New("Failed to retrieve a signing key")
+	ErrSessionUnauthenticated = errors.New("Session must be authenticated to issue a token")
+)
+
+// Algorithm is the only algorithm that keys are generated for
+const Algorithm = jose.RS256
+
+const (
+	// TypeJWT is the `typ` header of access tokens issued to sessions
+	TypeJWT = "JWT"
+	// TypeAccessToken is the `typ` header of access tokens issued to OAuth2 clients as defined by RFC 9068
+	TypeAccessToken = "at+jwt"
+)
+
+// keyBits is the size of every generated key
+const keyBits = 2048
+
+// Key defines a key that tokens are signed with
+//
+// A Key signs new tokens until RetiresAt. After that it's only used to verify tokens, and is still published, until
+// ExpiresAt when every token that it could've signed has expired
+type Key struct {
+	internal.Base
+	// Algorithm defines the algorithm that the key signs with
+	Algorithm string `json:"alg" gorm:"not null" validate:"required"`
+	// PrivateKey is the PEM encoded PKCS #8 private key
+	PrivateKey string `json:"-" gorm:"type:text;not null" validate:"required"`
+	// RetiresAt defines when the key stops signing new tokens
+	RetiresAt time.Time `json:"retires_at" gorm:"index;not null" validate:"required"`
+	// ExpiresAt defines when the key is no longer published
+	ExpiresAt time.Time `json:"expires_at" gorm:"index;not null" validate:"required,gtfield=RetiresAt"`
+}
+
+// Claims defines the claims of an access token
+type Claims struct {
+	jwt.Claims
+	// SessionID is the session that the token was issued for
+	SessionID string `json:"sid"`
+	// AMR lists the credential methods that were used to authenticate the session
+	AMR []string `json:"amr,omitempty"`
+	// AuthTime is when the session was authenticated
+	AuthTime int64 `json:"auth_time"`
+	// Scope is the space delimited list of scopes that were granted. This is only set on tokens issued to OAuth2 clients
+	Scope string `json:"scope,omitempty"`
+	// ClientID is the OAuth2 client that the token was issued to. First-party APIs must reject tokens that carry this
+	// since they were issued for a client's audience, not theirs
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// AccessToken defines the response of a successful token request
+type AccessToken struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresIn   int64     `json:"expires_in"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type Repository interface {
+	// Create creates a new key
+	Create(ctx context.Context, newKey Key) (*Key, error)
+	// GetAllUnexpired retrieves every key that hasn't expired yet, newest first
+	GetAllUnexpired(ctx context.Context) ([]Key, error)
+}
+
+type Service interface {
+	// Issue issues an access token for an authenticated session. The token never outlives the session
+	Issue(ctx context.Context, sess session.Session) (*AccessToken, error)
+	// Sign signs claims with the current signing key, rotating it if necessary, and returns the compact serialized token
+	Sign(ctx context.Context, claims ...interface{}) (string, error)
+	// SignWithType signs claims like Sign but with typ as the token's `typ` header
+	SignWithType(ctx context.Context, typ string, claims ...interface{}) (string, error)
+	// Verify verifies the signature and validity of an access token issued by Issue. Tokens issued to OAuth2 clients,
+	// including ID tokens, and tokens without exactly the configured audience are rejected
+	Verify(ctx context.Context, raw string) (*Claims, error)
+	// VerifyClient verifies the signature and validity of an access token issued to an OAuth2 client
+	VerifyClient(ctx context.Context, raw string) (*Claims, error)
+	// JWKS retrieves every key that tokens could currently be verified with
+	JWKS(ctx context.Context) (*jose.JSONWebKeySet, error)
+}
+
+// NewKey generates a new key that'll retire after rotation, and expire lifetime after that
+func NewKey(rotation time.Duration, lifetime time.Duration) (*Key, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate uuid")
+	}
+	private, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate signing key")
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to encode signing key")
+	}
+
+	now := time.Now()
+	retiresAt := now.Add(rotation)
+	return &Key{
+		Base: internal.Base{
+			ID:        id,
+			CreatedAt: now,
+		},
+		Algorithm:  string(Algorithm),
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		RetiresAt:  retiresAt,
+		ExpiresAt:  retiresAt.Add(lifetime),
+	}, nil
+}
+
+// Signer decodes the private key
+func (k *Key) Signer() (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(k.PrivateKey))
+	if block == nil {
+		return nil, errors.New("invalid PEM block")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("key is not an RSA private key")
+	}
+	return private, nil
+}
+
+// Retired checks whether the key should no longer sign new tokens
+func (k *Key) Retired() bool {
+	return !k.RetiresAt.After(time.Now())
+}
+
+// NewClaims builds the claims of an access token for an authenticated session
+func NewClaims(sess session.Session, issuer string, audience []string, expiry time.Time) (*Claims, error) {
+	if !sess.Authenticated() || sess.AuthenticatedAt == nil {
+		return nil, internal.NewErrorf(internal.ErrorCodeUnauthorized, "%v", ErrSessionUnauthenticated)
+	}
+	jti, err := uuid.NewV4()
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate uuid")
+	}
+	// A token can't outlive the session it was issued for
+	if sess.ExpiresAt.Before(expiry) {
+		expiry = *sess.ExpiresAt
+	}
+
+	var amr []string
+	seen := map[string]bool{}
+	for _, m := range sess.CredentialMethods {
+		if method := string(m.Method); !seen[method] {
+			seen[method] = true
+			amr = append(amr, method)
+		}
+	}
+	now := time.Now()
+	return &Claims{
+		Claims: jwt.Claims{
+			ID:        jti.String(),
+			Issuer:    issuer,
+			Subject:   sess.IdentityID.String(),
+			Audience:  jwt.Audience(audience),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Expiry:    jwt.NewNumericDate(expiry),
+		},
+		SessionID: sess.ID.String(),
+		AMR:       amr,
+		AuthTime:  sess.AuthenticatedAt.Unix(),
+	}, nil
+}
+
+// TableName overrides GORM's table name
+func (Key) TableName() string {
+	return "signing_keys"
+}
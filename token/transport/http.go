@@ -0,0 +1,66 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/RagOfJoes/mylo/internal"
+	sessionHttp "github.com/RagOfJoes/mylo/session/transport"
+	"github.com/RagOfJoes/mylo/token"
+	"github.com/RagOfJoes/mylo/transport"
+	"github.com/gin-gonic/gin"
+)
+
+// jwksMaxAge is how long, in seconds, clients may cache the key set. Verifiers should refetch it when they come across
+// a key id that they don't know about
+const jwksMaxAge = 300
+
+type Http struct {
+	sh sessionHttp.Http
+	s  token.Service
+}
+
+func NewTokenHttp(sh sessionHttp.Http, s token.Service, r *gin.Engine) {
+	h := &Http{
+		sh: sh,
+		s:  s,
+	}
+	r.POST("/sessions/token", h.issue())
+	r.GET("/.well-known/jwks.json", h.jwks())
+}
+
+func (h *Http) issue() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.sh.Session(ctx, c.Request, c.Writer, true)
+		if err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeUnauthorized, "%v", internal.ErrUnauthorized))
+			return
+		}
+		issued, err := h.s.Issue(ctx, *sess)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Payload: issued,
+		})
+	}
+}
+
+// jwks responds with the bare key set, rather than an HttpResponse, since that's what JWT libraries expect
+func (h *Http) jwks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		set, err := h.s.JWKS(c.Request.Context())
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", jwksMaxAge))
+		c.JSON(http.StatusOK, set)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -39,7 +41,7 @@ func NewHttp() *gin.Engine {
 				param.TimeStamp.Format("2006/01/02 - 15:04:05"),
 				param.StatusCodeColor(), param.StatusCode, param.ResetColor(),
 				param.Latency,
-				param.ClientIP,
+				resolveIP(param.Request),
 				param.MethodColor(), param.Request.Method, param.ResetColor(),
 				param.Request.URL.Path,
 				param.ErrorMessage,
@@ -52,27 +54,29 @@ func NewHttp() *gin.Engine {
 	return ginEngine
 }
 
-// RunHttp runs the http server with a graceful shutdown
-// functionality
-func RunHttp(handler http.Handler) error {
-	cfg := config.Get()
-	// Create a new http server from gin engine
-	// instance
-	addr := resolveAddr(cfg.Server.Host, cfg.Server.Port)
-	srv := &http.Server{
-		Addr:    addr,
+// NewServer creates a http server that will listen on the host and port provided
+func NewServer(host string, port int, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:    resolveAddr(host, port),
 		Handler: handler,
 	}
+}
+
+// RunHttp runs the http servers with a graceful shutdown
+// functionality
+func RunHttp(servers ...*http.Server) error {
 	// Setup graceful shutdown handling
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
-	// Initializing the server in a goroutine so that
-	// it won't block the graceful shutdown handling below
-	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Listen: %s\n", err)
-		}
-	}()
+	// Initializing the servers in goroutines so that
+	// they won't block the graceful shutdown handling below
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Listen: %s\n", err)
+			}
+		}(srv)
+	}
 
 	// Listen for the interrupt signal.
 	<-ctx.Done()
@@ -81,12 +85,14 @@ func RunHttp(handler http.Handler) error {
 	stop()
 	log.Println("Shutting down gracefully, press Ctrl+C again to force")
 
-	// The context is used to inform the server it has 5 seconds to finish
-	// the request it is currently handling
+	// The context is used to inform the servers they have 5 seconds to finish
+	// the request they are currently handling
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		return err
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			return err
+		}
 	}
 
 	log.Println("Server exiting")
@@ -105,15 +111,79 @@ func resolveAddr(host string, port int) string {
 	return fmt.Sprintf("%s:%d", host, port)
 }
 
-// Retrieves request ip for logging purposes
+// maxIPLength is the length of the longest textual representation of an IPv6 address
+const maxIPLength = 45
+
+// Retrieves request ip for logging purposes. X-Forwarded-For is only honored when the peer is a trusted proxy and is
+// walked from the right since every hop appends to it, the first untrusted hop is the client and anything to its left
+// could have been spoofed
 func resolveIP(req *http.Request) string {
-	real := req.Header.Get("X-Real-Ip")
-	if len(real) > 0 {
-		return real
+	host, _, err := net.SplitHostPort(strings.TrimSpace(req.RemoteAddr))
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	ip := parseIP(host)
+	if ip == nil {
+		return ""
+	}
+	proxies := trustedProxies()
+	if !trusted(ip, proxies) {
+		return ip.String()
+	}
+	hops := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := parseIP(hops[i])
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !trusted(ip, proxies) {
+			break
+		}
+	}
+	return ip.String()
+}
+
+// parseIP parses an IP while making sure that the value isn't abnormally long
+func parseIP(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 || len(s) > maxIPLength {
+		return nil
 	}
-	forward := req.Header.Get("X-Forwarded-For")
-	if len(forward) > 0 {
-		return forward
+	return net.ParseIP(s)
+}
+
+// trustedProxies parses the configured trusted proxies. Single IPs are treated as a network of one
+func trustedProxies() []*net.IPNet {
+	cfg := config.Get()
+	proxies := make([]*net.IPNet, 0, len(cfg.Server.TrustedProxies))
+	for _, proxy := range cfg.Server.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				continue
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		if _, network, err := net.ParseCIDR(proxy); err == nil {
+			proxies = append(proxies, network)
+		}
+	}
+	return proxies
+}
+
+// trusted checks whether ip belongs to one of the trusted proxies
+func trusted(ip net.IP, proxies []*net.IPNet) bool {
+	for _, proxy := range proxies {
+		if proxy.Contains(ip) {
+			return true
+		}
 	}
-	return req.RemoteAddr
+	return false
 }
@@ -0,0 +1,47 @@
+package transport
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/RagOfJoes/mylo/internal/config/configtest"
+)
+
+func TestResolveIP(t *testing.T) {
+	configtest.Setup(t, map[string]interface{}{
+		"server.trustedproxies": []string{"10.0.0.0/8", "192.0.2.1"},
+	})
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		realIP    string
+		expected  string
+	}{
+		{name: "direct", remote: "203.0.113.7:1234", expected: "203.0.113.7"},
+		{name: "untrusted peer", remote: "203.0.113.7:1234", forwarded: []string{"198.51.100.1"}, realIP: "198.51.100.2", expected: "203.0.113.7"},
+		{name: "trusted peer", remote: "10.0.0.1:1234", forwarded: []string{"198.51.100.1"}, expected: "198.51.100.1"},
+		{name: "spoofed left-most hop", remote: "10.0.0.1:1234", forwarded: []string{"1.1.1.1, 198.51.100.1, 10.0.0.2"}, expected: "198.51.100.1"},
+		{name: "multiple headers", remote: "192.0.2.1:1234", forwarded: []string{"1.1.1.1", "198.51.100.1"}, expected: "198.51.100.1"},
+		{name: "invalid hop", remote: "10.0.0.1:1234", forwarded: []string{"198.51.100.1, not-an-ip"}, expected: "10.0.0.1"},
+		{name: "oversized hop", remote: "10.0.0.1:1234", forwarded: []string{"0000:0000:0000:0000:0000:0000:0000:0000:0000:0001"}, expected: "10.0.0.1"},
+		{name: "only proxies", remote: "10.0.0.1:1234", forwarded: []string{"10.0.0.3, 10.0.0.2"}, expected: "10.0.0.3"},
+		{name: "real ip ignored", remote: "10.0.0.1:1234", realIP: "198.51.100.2", expected: "10.0.0.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = test.remote
+			for _, forwarded := range test.forwarded {
+				req.Header.Add("X-Forwarded-For", forwarded)
+			}
+			if test.realIP != "" {
+				req.Header.Set("X-Real-Ip", test.realIP)
+			}
+			if ip := resolveIP(req); ip != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, ip)
+			}
+		})
+	}
+}
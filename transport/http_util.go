@@ -3,6 +3,15 @@ package transport
 import (
 	"fmt"
 	"net/http"
+	"net/url"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/pkg/useragent"
+	"golang.org/x/text/language"
+)
+
+const (
+	maxUserAgentLength = 512
 )
 
 // RequestURL retrieves entry path of request
@@ -15,3 +24,38 @@ func RequestURL(req *http.Request) string {
 	}
 	return url
 }
+
+// WithQuery sets a query param on rawURL
+func WithQuery(rawURL string, key string, value string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	q.Set(key, value)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Client retrieves the metadata of the client that made the request
+func Client(req *http.Request) internal.Client {
+	ua := req.UserAgent()
+	if len(ua) > maxUserAgentLength {
+		ua = ua[:maxUserAgentLength]
+	}
+	return internal.Client{
+		IP:        resolveIP(req),
+		UserAgent: ua,
+		Device:    useragent.Parse(ua).String(),
+		Locale:    locale(req),
+	}
+}
+
+// locale retrieves the client's most preferred locale from the Accept-Language header
+func locale(req *http.Request) string {
+	tags, _, err := language.ParseAcceptLanguage(req.Header.Get("Accept-Language"))
+	if err != nil || len(tags) == 0 {
+		return ""
+	}
+	return tags[0].String()
+}
@@ -15,14 +15,17 @@ const (
 	Link  Type = "link"
 	Input Type = "input"
 
+	Code     Group = "code"
 	Default  Group = "default"
 	OIDC     Group = "oidc"
+	TOTP     Group = "totp"
+	Profile  Group = "profile"
 	Password Group = "password"
 )
 
 type Node struct {
 	Type       Type       `json:"type" validate:"required"`
-	Group      Group      `json:"group" validate:"required,oneof='default' 'oidc' 'password'"`
+	Group      Group      `json:"group" validate:"required,oneof='code' 'default' 'oidc' 'password' 'profile' 'totp'"`
 	Attributes Attributes `json:"attributes" validate:"required"`
 }
 
@@ -3,9 +3,14 @@ package credential
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/ui/form"
+	"github.com/RagOfJoes/mylo/ui/node"
 	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
 )
 
 var (
@@ -15,6 +20,11 @@ var (
 	ErrFailedJSONEncodePassword  = errors.New("Failed to JSON encode hashed password")
 	ErrFailedJSONDecodePassword  = errors.New("Failed to JSON decode hashed password")
 	ErrInvalidIdentifierPassword = errors.New("Invalid identifier or password provided")
+	ErrInvalidTOTPCode           = errors.New("Invalid authentication code provided")
+	ErrTOTPAlreadyEnabled        = errors.New("Authenticator app has already been setup")
+	ErrFailedJSONEncodeTOTP      = errors.New("Failed to JSON encode totp secret")
+	ErrFailedJSONDecodeTOTP      = errors.New("Failed to JSON decode totp secret")
+	ErrFailedJSONEncodeOIDC      = errors.New("Failed to JSON encode oidc subject")
 )
 
 // Credential can be a Password, OTP, Device Code,
@@ -23,11 +33,11 @@ var (
 // A User can only have only have one Credential type.
 // Ie: 1 Password Credential, 1 OTP Credential, etc.
 type Credential struct {
-	ID        uuid.UUID  `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()" validate:"required,uuid4"`
+	ID        uuid.UUID  `gorm:"primaryKey;type:uuid" validate:"required,uuid4"`
 	CreatedAt time.Time  `gorm:"index;not null;default:current_timestamp" validate:"required"`
 	UpdatedAt *time.Time `gorm:"index;default:null"`
 
-	Type CredentialType `gorm:"index;not null" validate:"required,oneof='oidc' 'password'"`
+	Type CredentialType `gorm:"index;not null" validate:"required,oneof='oidc' 'password' 'totp'"`
 	// Depending on the type values stored in here
 	// will differ. For example:
 	// type: oidc
@@ -35,6 +45,10 @@ type Credential struct {
 	// 		- provider: google
 	//		- sub: 9s988s...
 	Values string `gorm:"not null;type:json" validate:"required"`
+	// LastCounter is the time step of the last accepted totp code. It's kept
+	// out of Values so that it can be moved forward atomically, which makes
+	// sure that a code can only ever be used once
+	LastCounter int64 `gorm:"not null;default:0"`
 
 	IdentityID  uuid.UUID `gorm:"index;not null" validate:"required,uuid4"`
 	Identifiers []Identifier
@@ -46,7 +60,13 @@ type CredentialType string
 const (
 	// CredentialTypes
 	OIDC     CredentialType = "oidc"
+	TOTP     CredentialType = "totp"
 	Password CredentialType = "password"
+
+	// Code and Link are only ever used as session credential methods since they are derived from the
+	// User's email rather than stored as a Credential
+	Code CredentialType = "code"
+	Link CredentialType = "link"
 )
 
 // CredentialPassword defines the structure for
@@ -64,6 +84,38 @@ type CredentialOIDC struct {
 	Sub      string `json:"sub"`
 }
 
+// Identifier returns the value of the Identifier
+// that'll be linked to the oidc credential
+func (c CredentialOIDC) Identifier() string {
+	return fmt.Sprintf("%s:%s", c.Provider, c.Sub)
+}
+
+// CredentialTOTP defines the structure for
+// a type totp's Values field
+type CredentialTOTP struct {
+	Secret string `json:"secret"`
+	// Confirmed is set once the User has provided
+	// the first code generated by their authenticator app
+	Confirmed bool `json:"confirmed"`
+}
+
+// TOTPEnrollment defines what's required for a User to
+// setup their authenticator app
+type TOTPEnrollment struct {
+	// Secret can be entered manually into an authenticator app
+	Secret string `json:"secret"`
+	// URL is the otpauth URI that can be rendered as a QR code
+	URL string `json:"url"`
+	// Form defines the form that'll be used to confirm the enrollment
+	Form *form.Form `json:"form,omitempty"`
+}
+
+// ConfirmTOTPPayload defines the data required to confirm a totp enrollment
+type ConfirmTOTPPayload struct {
+	// Code is the first code generated by the User's authenticator app
+	Code string `json:"code" form:"code" binding:"required" validate:"required,numeric,len=6"`
+}
+
 type Repository interface {
 	// Create creates a new credential
 	Create(ctx context.Context, newCredential Credential) (*Credential, error)
@@ -73,8 +125,13 @@ type Repository interface {
 	GetWithIdentifier(ctx context.Context, credentialType CredentialType, identifier string) (*Credential, error)
 	// GetWithIdentityID retrieves a credential with an identity id
 	GetWithIdentityID(ctx context.Context, credentialType CredentialType, identityID uuid.UUID) (*Credential, error)
+	// GetAllIdentity retrieves all the credentials that belong to an identity
+	GetAllIdentity(ctx context.Context, identityID uuid.UUID) ([]Credential, error)
 	// Update updates a credential
 	Update(ctx context.Context, updateCredential Credential) (*Credential, error)
+	// AdvanceCounter atomically moves a credential's LastCounter forward to counter. False is returned when it's
+	// already at or past counter so that concurrent requests can't both accept the same totp code
+	AdvanceCounter(ctx context.Context, id uuid.UUID, counter int64) (bool, error)
 	// Delete deletes a credential via id
 	Delete(ctx context.Context, id uuid.UUID) error
 }
@@ -88,4 +145,44 @@ type Service interface {
 	FindPasswordWithIdentifier(ctx context.Context, Identifier string) (*Credential, error)
 	// UpdatePassword updates a password credential
 	UpdatePassword(ctx context.Context, identityID uuid.UUID, newPassword string) (*Credential, error)
+	// CreateTOTP creates an unconfirmed totp credential. Any previous unconfirmed totp credential will be replaced
+	CreateTOTP(ctx context.Context, identityID uuid.UUID, accountName string) (*TOTPEnrollment, error)
+	// ConfirmTOTP confirms a totp credential with the first code generated by the User's authenticator app
+	ConfirmTOTP(ctx context.Context, identityID uuid.UUID, code string) (*Credential, error)
+	// HasTOTP checks whether an identity has a confirmed totp credential
+	HasTOTP(ctx context.Context, identityID uuid.UUID) bool
+	// CompareTOTP compares a code against a confirmed totp credential
+	CompareTOTP(ctx context.Context, identityID uuid.UUID, code string) error
+	// CreateOIDC creates an oidc credential that links a provider's subject to an identity
+	CreateOIDC(ctx context.Context, identityID uuid.UUID, provider string, sub string) (*Credential, error)
+	// FindOIDC finds an oidc credential with a provider and subject
+	FindOIDC(ctx context.Context, provider string, sub string) (*Credential, error)
+	// FindAllIdentity finds all the credentials that belong to an identity
+	FindAllIdentity(ctx context.Context, identityID uuid.UUID) ([]Credential, error)
+}
+
+// BeforeCreate generates an ID for credentials that weren't given one since not every database can generate them
+func (c *Credential) BeforeCreate(tx *gorm.DB) error {
+	return internal.GenerateID(&c.ID)
+}
+
+// ConfirmTOTPForm creates a form to confirm a totp enrollment
+func ConfirmTOTPForm(action string) form.Form {
+	return form.Form{
+		Action: action,
+		Method: form.POST,
+		Nodes: node.Nodes{
+			{
+				Type:  node.Input,
+				Group: node.TOTP,
+				Attributes: &node.InputAttribute{
+					Required: true,
+					Type:     "text",
+					Name:     "code",
+					Pattern:  "[0-9]{6}",
+					Label:    "Authentication code",
+				},
+			},
+		},
+	}
 }
@@ -3,12 +3,15 @@ package credential
 import (
 	"time"
 
+	"github.com/RagOfJoes/mylo/internal"
 	"github.com/gofrs/uuid"
+	"gorm.io/gorm"
 )
 
 const (
 	// IdentifierTypes
 	Email    IdentifierType = "email"
+	Subject  IdentifierType = "subject"
 	Username IdentifierType = "username"
 )
 
@@ -16,7 +19,7 @@ const (
 // Each Identifier can belong to multiple Credentials
 type Identifier struct {
 	// ID just a unique identifier
-	ID uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4()" validate:"required,uuid4"`
+	ID uuid.UUID `gorm:"primaryKey;type:uuid" validate:"required,uuid4"`
 	// CreatedAt meta data about Identifier
 	CreatedAt time.Time `gorm:"index;not null;default:current_timestamp" validate:"required"`
 	// UpdatedAt meta data about Identifier
@@ -32,5 +35,10 @@ type Identifier struct {
 	Value string `gorm:"not null;uniqueIndex" validate:"required"`
 }
 
+// BeforeCreate generates an ID for identifiers that weren't given one since not every database can generate them
+func (i *Identifier) BeforeCreate(tx *gorm.DB) error {
+	return internal.GenerateID(&i.ID)
+}
+
 // IdentifierType defines an Identifier Type
 type IdentifierType string
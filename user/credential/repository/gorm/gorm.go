@@ -3,6 +3,7 @@ package gorm
 import (
 	"context"
 
+	"github.com/RagOfJoes/mylo/persistence"
 	"github.com/RagOfJoes/mylo/user/credential"
 	"github.com/gofrs/uuid"
 	"gorm.io/gorm"
@@ -18,7 +19,7 @@ func NewGormCredentialRepository(d *gorm.DB) credential.Repository {
 
 func (g *gormCredentialRepository) Create(ctx context.Context, newCredential credential.Credential) (*credential.Credential, error) {
 	clone := newCredential
-	if err := g.DB.Create(&clone).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Create(&clone).Error; err != nil {
 		return nil, err
 	}
 	return &clone, nil
@@ -26,7 +27,7 @@ func (g *gormCredentialRepository) Create(ctx context.Context, newCredential cre
 
 func (g *gormCredentialRepository) GetIdentifier(ctx context.Context, id string) (*credential.Identifier, error) {
 	var identifier credential.Identifier
-	if err := g.DB.Preload("Identifiers").First(&identifier, "LOWER(value) = LOWER(?)", id).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Preload("Identifiers").First(&identifier, "LOWER(value) = LOWER(?)", id).Error; err != nil {
 		return nil, err
 	}
 	return &identifier, nil
@@ -35,10 +36,11 @@ func (g *gormCredentialRepository) GetIdentifier(ctx context.Context, id string)
 func (g *gormCredentialRepository) GetWithIdentifier(ctx context.Context, credentialType credential.CredentialType, id string) (*credential.Credential, error) {
 	var password credential.Credential
 	var identifier credential.Identifier
-	if err := g.DB.First(&identifier, "LOWER(value) = LOWER(?)", id).Error; err != nil {
+	db := persistence.Conn(ctx, g.DB)
+	if err := db.First(&identifier, "LOWER(value) = LOWER(?)", id).Error; err != nil {
 		return nil, err
 	}
-	if err := g.DB.Preload("Identifiers").First(&password, "id = ?", identifier.CredentialID).Error; err != nil {
+	if err := db.Preload("Identifiers").First(&password, "id = ? AND type = ?", identifier.CredentialID, credentialType).Error; err != nil {
 		return nil, err
 	}
 	return &password, nil
@@ -46,29 +48,47 @@ func (g *gormCredentialRepository) GetWithIdentifier(ctx context.Context, creden
 
 func (g *gormCredentialRepository) GetWithIdentityID(ctx context.Context, credentialType credential.CredentialType, identityID uuid.UUID) (*credential.Credential, error) {
 	var found credential.Credential
-	if err := g.DB.Preload("Identifiers").First(&found, "type = ? AND identity_id = ?", credentialType, identityID).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Preload("Identifiers").First(&found, "type = ? AND identity_id = ?", credentialType, identityID).Error; err != nil {
 		return nil, err
 	}
 	return &found, nil
 }
 
+func (g *gormCredentialRepository) GetAllIdentity(ctx context.Context, identityID uuid.UUID) ([]credential.Credential, error) {
+	found := []credential.Credential{}
+	if err := persistence.Conn(ctx, g.DB).Preload("Identifiers").Find(&found, "identity_id = ?", identityID).Error; err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
 func (g *gormCredentialRepository) Update(ctx context.Context, update credential.Credential) (*credential.Credential, error) {
 	updated := update
 	// Update Credential
-	if err := g.DB.Save(&updated).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Save(&updated).Error; err != nil {
 		return nil, err
 	}
 	return &updated, nil
 }
 
-func (g *gormCredentialRepository) Delete(ctx context.Context, credentialID uuid.UUID) error {
-	if err := g.DB.Where("credential_id = ?", credentialID).Delete(credential.Identifier{}).Error; err != nil && err != gorm.ErrRecordNotFound {
-		g.DB.Rollback()
-		return err
+func (g *gormCredentialRepository) AdvanceCounter(ctx context.Context, id uuid.UUID, counter int64) (bool, error) {
+	res := persistence.Conn(ctx, g.DB).Model(&credential.Credential{}).
+		Where("id = ? AND last_counter < ?", id, counter).
+		Update("last_counter", counter)
+	if res.Error != nil {
+		return false, res.Error
 	}
-	if err := g.DB.Where("id = ?", credentialID).Delete(credential.Credential{}).Error; err != nil && err != gorm.ErrRecordNotFound {
-		g.DB.Rollback()
-		return err
-	}
-	return nil
+	return res.RowsAffected == 1, nil
+}
+
+func (g *gormCredentialRepository) Delete(ctx context.Context, credentialID uuid.UUID) error {
+	return persistence.Conn(ctx, g.DB).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("credential_id = ?", credentialID).Delete(credential.Identifier{}).Error; err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		if err := tx.Where("id = ?", credentialID).Delete(credential.Credential{}).Error; err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		return nil
+	})
 }
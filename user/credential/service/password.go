@@ -17,7 +17,9 @@ var (
 	errIncompatibleVersion = errors.New("incompatible version of argon2")
 )
 
-func generateFromPassword(password string) (encodedHash string, err error) {
+// HashPassword hashes a password with argon2id using the configured parameters and encodes it along with its salt and
+// parameters
+func HashPassword(password string) (encodedHash string, err error) {
 	p := config.Get().Credential.Argon
 	// Generate a cryptographically secure random salt.
 	salt, err := generateRandomBytes(p.SaltLength)
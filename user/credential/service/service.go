@@ -7,6 +7,7 @@ import (
 
 	"github.com/RagOfJoes/mylo/internal"
 	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/pkg/totp"
 	"github.com/RagOfJoes/mylo/user/credential"
 	"github.com/gofrs/uuid"
 	"github.com/nbutton23/zxcvbn-go"
@@ -35,7 +36,7 @@ func (s *service) CreatePassword(ctx context.Context, uid uuid.UUID, password st
 		return nil, internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", credential.ErrWeakPassword)
 	}
 	// Hash password
-	newPass, err := generateFromPassword(password)
+	newPass, err := HashPassword(password)
 	if err != nil {
 		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "%v", credential.ErrFailedGeneratePassword)
 	}
@@ -119,7 +120,7 @@ func (s *service) UpdatePassword(ctx context.Context, uid uuid.UUID, newPassword
 		return nil, internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", credential.ErrInvalidIdentifierPassword)
 	}
 	// Create new password
-	newPass, err := generateFromPassword(newPassword)
+	newPass, err := HashPassword(newPassword)
 	if err != nil {
 		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "%v", credential.ErrFailedGeneratePassword)
 	}
@@ -147,3 +148,169 @@ func (s *service) UpdatePassword(ctx context.Context, uid uuid.UUID, newPassword
 	}
 	return updated, nil
 }
+
+func (s *service) CreateTOTP(ctx context.Context, uid uuid.UUID, accountName string) (*credential.TOTPEnrollment, error) {
+	// Check for an existing totp credential. If it hasn't been confirmed
+	// yet then remove it so the User can start over
+	if existing, err := s.cr.GetWithIdentityID(ctx, credential.TOTP, uid); err == nil {
+		var values credential.CredentialTOTP
+		if err := json.Unmarshal([]byte(existing.Values), &values); err != nil {
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "%v", credential.ErrFailedJSONDecodeTOTP)
+		}
+		if values.Confirmed {
+			return nil, internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", credential.ErrTOTPAlreadyEnabled)
+		}
+		if err := s.cr.Delete(ctx, existing.ID); err != nil {
+			return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to delete totp credential: %s", existing.ID)
+		}
+	}
+	// Generate new secret
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate totp secret")
+	}
+	jsonTOTP, err := json.Marshal(credential.CredentialTOTP{
+		Secret: secret,
+	})
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "%v", credential.ErrFailedJSONEncodeTOTP)
+	}
+	// Build Credential
+	newCredential := credential.Credential{
+		Type:       credential.TOTP,
+		IdentityID: uid,
+		Values:     string(jsonTOTP[:]),
+	}
+	if _, err := s.cr.Create(ctx, newCredential); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to create totp credential")
+	}
+
+	cfg := config.Get()
+	return &credential.TOTPEnrollment{
+		Secret: secret,
+		URL:    totp.URL(cfg.Name, accountName, secret),
+	}, nil
+}
+
+func (s *service) ConfirmTOTP(ctx context.Context, uid uuid.UUID, code string) (*credential.Credential, error) {
+	found, err := s.cr.GetWithIdentityID(ctx, credential.TOTP, uid)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "The account doesn't have an authenticator app setup")
+	}
+	var values credential.CredentialTOTP
+	if err := json.Unmarshal([]byte(found.Values), &values); err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "%v", credential.ErrFailedJSONDecodeTOTP)
+	}
+	if values.Confirmed {
+		return nil, internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", credential.ErrTOTPAlreadyEnabled)
+	}
+	counter, err := totp.Validate(code, values.Secret, time.Now())
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", credential.ErrInvalidTOTPCode)
+	}
+	values.Confirmed = true
+	found.LastCounter = counter
+	return s.updateTOTP(ctx, *found, values)
+}
+
+func (s *service) HasTOTP(ctx context.Context, uid uuid.UUID) bool {
+	found, err := s.cr.GetWithIdentityID(ctx, credential.TOTP, uid)
+	if err != nil {
+		return false
+	}
+	var values credential.CredentialTOTP
+	if err := json.Unmarshal([]byte(found.Values), &values); err != nil {
+		return false
+	}
+	return values.Confirmed
+}
+
+func (s *service) CompareTOTP(ctx context.Context, uid uuid.UUID, code string) error {
+	found, err := s.cr.GetWithIdentityID(ctx, credential.TOTP, uid)
+	if err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", credential.ErrInvalidTOTPCode)
+	}
+	var values credential.CredentialTOTP
+	if err := json.Unmarshal([]byte(found.Values), &values); err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "%v", credential.ErrFailedJSONDecodeTOTP)
+	}
+	if !values.Confirmed {
+		return internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", credential.ErrInvalidTOTPCode)
+	}
+	counter, err := totp.Validate(code, values.Secret, time.Now())
+	if err != nil || counter <= found.LastCounter {
+		return internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", credential.ErrInvalidTOTPCode)
+	}
+	// Make sure codes can't be replayed, not even by requests that were made at the same time
+	advanced, err := s.cr.AdvanceCounter(ctx, found.ID, counter)
+	if err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update totp credential: %s", found.ID)
+	}
+	if !advanced {
+		return internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", credential.ErrInvalidTOTPCode)
+	}
+	return nil
+}
+
+func (s *service) updateTOTP(ctx context.Context, cred credential.Credential, values credential.CredentialTOTP) (*credential.Credential, error) {
+	jsonTOTP, err := json.Marshal(values)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "%v", credential.ErrFailedJSONEncodeTOTP)
+	}
+	updatedAt := time.Now()
+	cred.UpdatedAt = &updatedAt
+	cred.Values = string(jsonTOTP[:])
+	updated, err := s.cr.Update(ctx, cred)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update totp credential: %s", cred.ID)
+	}
+	return updated, nil
+}
+
+func (s *service) CreateOIDC(ctx context.Context, uid uuid.UUID, provider string, sub string) (*credential.Credential, error) {
+	credOIDC := credential.CredentialOIDC{
+		Provider: provider,
+		Sub:      sub,
+	}
+	jsonOIDC, err := json.Marshal(credOIDC)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "%v", credential.ErrFailedJSONEncodeOIDC)
+	}
+	// Build Credential
+	newCredential := credential.Credential{
+		Type:       credential.OIDC,
+		IdentityID: uid,
+		Values:     string(jsonOIDC[:]),
+		Identifiers: []credential.Identifier{
+			{
+				Type:  credential.Subject,
+				Value: credOIDC.Identifier(),
+			},
+		},
+	}
+	created, err := s.cr.Create(ctx, newCredential)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to create oidc credential")
+	}
+	return created, nil
+}
+
+func (s *service) FindOIDC(ctx context.Context, provider string, sub string) (*credential.Credential, error) {
+	credOIDC := credential.CredentialOIDC{
+		Provider: provider,
+		Sub:      sub,
+	}
+	found, err := s.cr.GetWithIdentifier(ctx, credential.OIDC, credOIDC.Identifier())
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "Invalid identifier provided")
+	}
+	return found, nil
+}
+
+func (s *service) FindAllIdentity(ctx context.Context, identityID uuid.UUID) ([]credential.Credential, error) {
+	found, err := s.cr.GetAllIdentity(ctx, identityID)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to retrieve all the credentials for: %s", identityID)
+	}
+	return found, nil
+}
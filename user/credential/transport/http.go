@@ -0,0 +1,101 @@
+package transport
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/session"
+	sessionHttp "github.com/RagOfJoes/mylo/session/transport"
+	"github.com/RagOfJoes/mylo/transport"
+	"github.com/RagOfJoes/mylo/user/credential"
+	"github.com/gin-gonic/gin"
+)
+
+type Http struct {
+	sh sessionHttp.Http
+	s  credential.Service
+}
+
+func NewCredentialHttp(sh sessionHttp.Http, s credential.Service, r *gin.Engine) {
+	h := &Http{
+		sh: sh,
+		s:  s,
+	}
+
+	group := r.Group("/credentials")
+	{
+		// Enrolling creates a pending secret so it must never be reachable with a GET
+		group.POST("/totp", h.enrollTOTP())
+		group.POST("/totp/confirm", h.confirmTOTP())
+	}
+}
+
+func (h *Http) enrollTOTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.sh.Session(ctx, c.Request, c.Writer, true)
+		if err != nil || sess == nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeUnauthorized, "%v", internal.ErrUnauthorized))
+			return
+		}
+		if err := h.privileged(c, sess); err != nil {
+			c.Error(err)
+			return
+		}
+		enrollment, err := h.s.CreateTOTP(ctx, sess.Identity.ID, sess.Identity.Email)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		cfg := config.Get()
+		form := credential.ConfirmTOTPForm(fmt.Sprintf("%s/credentials/totp/confirm", cfg.Server.URL))
+		enrollment.Form = &form
+
+		c.JSON(http.StatusCreated, transport.HttpResponse{
+			Success: true,
+			Payload: enrollment,
+		})
+	}
+}
+
+func (h *Http) confirmTOTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		sess, err := h.sh.Session(ctx, c.Request, c.Writer, true)
+		if err != nil || sess == nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeUnauthorized, "%v", internal.ErrUnauthorized))
+			return
+		}
+		if err := h.privileged(c, sess); err != nil {
+			c.Error(err)
+			return
+		}
+		var payload credential.ConfirmTOTPPayload
+		if err := c.ShouldBind(&payload); err != nil {
+			c.Error(internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", credential.ErrInvalidTOTPCode))
+			return
+		}
+		if _, err := h.s.ConfirmTOTP(ctx, sess.Identity.ID, payload.Code); err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, transport.HttpResponse{
+			Success: true,
+			Message: "Authenticator app has been setup successfully",
+		})
+	}
+}
+
+// privileged makes sure that the session has recently authenticated before a second factor can be setup, and with a
+// second factor if the User already has one, so that a hijacked session can't take over the User's second factor
+func (h *Http) privileged(c *gin.Context, sess *session.Session) error {
+	cfg := config.Get()
+	min := session.AAL1
+	if h.s.HasTOTP(c.Request.Context(), sess.Identity.ID) {
+		min = session.AAL2
+	}
+	return sess.Satisfies(min, cfg.Settings.PrivilegedLifetime)
+}
@@ -0,0 +1,122 @@
+package transport_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RagOfJoes/mylo/event"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config/configtest"
+	"github.com/RagOfJoes/mylo/persistence/memory"
+	"github.com/RagOfJoes/mylo/pkg/totp"
+	"github.com/RagOfJoes/mylo/session"
+	sessionService "github.com/RagOfJoes/mylo/session/service"
+	sessionHttp "github.com/RagOfJoes/mylo/session/transport"
+	"github.com/RagOfJoes/mylo/transport"
+	"github.com/RagOfJoes/mylo/user/credential"
+	credentialService "github.com/RagOfJoes/mylo/user/credential/service"
+	credentialTransport "github.com/RagOfJoes/mylo/user/credential/transport"
+	"github.com/RagOfJoes/mylo/user/identity"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/sessions"
+)
+
+func TestEnrollTOTPMethod(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.HandleMethodNotAllowed = true
+	credentialTransport.NewCredentialHttp(sessionHttp.Http{}, nil, r)
+
+	// Link prefetchers and cross-site GETs must not be able to start an enrollment
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/credentials/totp", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected GET to not be allowed, got %d", rec.Code)
+	}
+}
+
+func TestEnrollTOTPPrivileged(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	configtest.Setup(t, nil)
+	store := memory.NewStore()
+	ctx := context.Background()
+	user, err := memory.NewMemoryIdentityRepository(store).Create(ctx, identity.Identity{
+		BaseSoftDelete: internal.BaseSoftDelete{ID: uuid.Must(uuid.NewV4()), CreatedAt: time.Now()},
+		Email:          "jane@example.com",
+		FirstName:      "Jane",
+		LastName:       "Doe",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs := credentialService.NewCredentialService(memory.NewMemoryCredentialRepository(store))
+	se := sessionService.NewSessionService(memory.NewMemorySessionRepository(store), event.NewBus())
+	r := gin.New()
+	r.Use(transport.ErrorMiddleware())
+	credentialTransport.NewCredentialHttp(*sessionHttp.NewSessionHttp(sessions.NewCookieStore([]byte("secret")), se), cs, r)
+
+	// newSession creates a session that was authenticated ago with methods
+	newSession := func(ago time.Duration, methods ...credential.CredentialType) string {
+		sess, err := session.NewAuthenticated(*user, internal.Client{}, methods...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authenticatedAt := sess.AuthenticatedAt.Add(-ago)
+		sess.AuthenticatedAt = &authenticatedAt
+		if _, err := se.New(ctx, *sess); err != nil {
+			t.Fatal(err)
+		}
+		return sess.Token
+	}
+	post := func(token string, path string, body string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("X-Session-Token", token)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// A session that authenticated a while ago, ie. a hijacked one, must login again first
+	stale := newSession(time.Hour, credential.Password)
+	if code := post(stale, "/credentials/totp", ""); code != http.StatusForbidden {
+		t.Fatalf("expected a stale session to be refused, got %d", code)
+	}
+	if code := post(stale, "/credentials/totp/confirm", "code=123456"); code != http.StatusForbidden {
+		t.Fatalf("expected a stale session to be refused, got %d", code)
+	}
+
+	fresh := newSession(0, credential.Password)
+	if code := post(fresh, "/credentials/totp", ""); code != http.StatusCreated {
+		t.Fatalf("expected a recently authenticated session to enroll, got %d", code)
+	}
+	found, err := memory.NewMemoryCredentialRepository(store).GetWithIdentityID(ctx, credential.TOTP, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values credential.CredentialTOTP
+	if err := json.Unmarshal([]byte(found.Values), &values); err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.Generate(values.Secret, totp.Counter(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := post(fresh, "/credentials/totp/confirm", "code="+code); code != http.StatusOK {
+		t.Fatalf("expected the enrollment to be confirmed, got %d", code)
+	}
+
+	// Once a second factor is setup, only a session that passed it can touch it
+	if code := post(fresh, "/credentials/totp", ""); code != http.StatusForbidden {
+		t.Errorf("expected a session without a second factor to be refused, got %d", code)
+	}
+	if code := post(newSession(0, credential.Password, credential.TOTP), "/credentials/totp", ""); code != http.StatusBadRequest {
+		t.Errorf("expected a session with a second factor to be told that it's already setup, got %d", code)
+	}
+}
@@ -3,8 +3,10 @@ package identity
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
 	"github.com/RagOfJoes/mylo/user/contact"
 	"github.com/RagOfJoes/mylo/user/credential"
 	"github.com/gofrs/uuid"
@@ -13,6 +15,7 @@ import (
 var (
 	ErrUsernameProfane           = errors.New("Username must not contain any profanity")
 	ErrInvalidIdentifierPassword = errors.New("Invalid identifier(s) or password provided")
+	ErrInvalidPagination         = errors.New("Invalid page or per_page provided")
 )
 
 // Identity defines the base Identity model
@@ -24,6 +27,15 @@ type Identity struct {
 	// Email is the primary email that will be used for account
 	// security related notifications
 	Email string `json:"email" gorm:"uniqueIndex;not null;" validate:"email,required"`
+	// Locale defines the preferred locale of the identity ie. en-US. This is used to localize emails
+	Locale string `json:"locale" gorm:"size:35" validate:"max=35"`
+	// FailedLogins defines the number of consecutive failed login attempts
+	FailedLogins int `json:"-" gorm:"not null;default:0"`
+	// LockedAt defines the time when the identity was locked due to too many failed login attempts
+	LockedAt *time.Time `json:"-" gorm:"default:null"`
+	// PasswordResetRequired is true when an administrator has forced the identity to reset its password. Logging in with
+	// a password is refused until the identity has been recovered
+	PasswordResetRequired bool `json:"-" gorm:"not null;default:false"`
 
 	Credentials []credential.Credential `json:"-"`
 	Contacts    []contact.Contact       `json:"contacts"`
@@ -38,8 +50,16 @@ type Repository interface {
 	GetWithIdentifier(ctx context.Context, identifier string, critical bool) (*Identity, error)
 	// Update updates an identity
 	Update(ctx context.Context, updateIdentity Identity) (*Identity, error)
+	// FailLogin atomically increments the failed login attempts of an identity and locks it once max has been reached. A
+	// lock from before cooledDown is lifted first so that counting starts again, if nil then locks never cool down. A max
+	// of 0 never locks
+	FailLogin(ctx context.Context, id uuid.UUID, max int, cooledDown *time.Time) (*Identity, error)
 	// Delete deletes an identity
 	Delete(ctx context.Context, id uuid.UUID, permanent bool) error
+	// List retrieves a page of identities that match the filter along with the total number of matches
+	List(ctx context.Context, filter Filter, offset int, limit int) ([]Identity, int64, error)
+	// Restore restores a soft deleted identity
+	Restore(ctx context.Context, id uuid.UUID) error
 }
 
 type Service interface {
@@ -47,6 +67,46 @@ type Service interface {
 	Create(ctx context.Context, user Identity, username string, password string) (*Identity, error)
 	// Find finds an identity with either its id or an identifier
 	Find(ctx context.Context, id string) (*Identity, error)
+	// Update updates an identity's profile
+	Update(ctx context.Context, updateIdentity Identity) (*Identity, error)
 	// Delete deletes an identity
 	Delete(ctx context.Context, id string, permanent bool) error
+	// FailLogin records a failed login attempt and locks the identity once the configured threshold has been reached
+	FailLogin(ctx context.Context, id uuid.UUID) (*Identity, error)
+	// Unlock resets the failed login attempts of an identity and removes its lock, if any
+	Unlock(ctx context.Context, id uuid.UUID) error
+	// List finds a page of identities that match the filter. Pages start at 1
+	List(ctx context.Context, filter Filter, page int, perPage int) (*Page, error)
+	// Restore restores a soft deleted identity
+	Restore(ctx context.Context, id uuid.UUID) error
+	// RequirePasswordReset sets whether the identity must reset its password before it can login with one again
+	RequirePasswordReset(ctx context.Context, id uuid.UUID, required bool) error
+}
+
+// Filter defines the criteria used to list identities
+type Filter struct {
+	// Search matches against the email, name and identifiers of an identity
+	Search string
+	// Deleted lists soft deleted identities instead
+	Deleted bool
+}
+
+// Page defines a single page of identities, ordered from newest to oldest
+type Page struct {
+	Identities []Identity `json:"identities"`
+	Page       int        `json:"page"`
+	PerPage    int        `json:"per_page"`
+	Total      int64      `json:"total"`
+}
+
+// Locked checks whether the identity is currently locked
+func (i *Identity) Locked() bool {
+	if i.LockedAt == nil {
+		return false
+	}
+	cfg := config.Get()
+	if cfg.Login.LockoutDuration == 0 {
+		return true
+	}
+	return time.Since(*i.LockedAt) < cfg.Login.LockoutDuration
 }
@@ -2,8 +2,13 @@ package gorm
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/persistence"
+	"github.com/RagOfJoes/mylo/session"
+	"github.com/RagOfJoes/mylo/user/contact"
 	"github.com/RagOfJoes/mylo/user/credential"
 	"github.com/RagOfJoes/mylo/user/identity"
 	"github.com/gofrs/uuid"
@@ -21,7 +26,7 @@ func NewGormUserRepository(d *gorm.DB) identity.Repository {
 
 func (g *gormUserRepository) Create(ctx context.Context, newIdentity identity.Identity) (*identity.Identity, error) {
 	clone := newIdentity
-	if err := g.DB.Create(&clone).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Create(&clone).Error; err != nil {
 		return nil, err
 	}
 	return &clone, nil
@@ -29,7 +34,7 @@ func (g *gormUserRepository) Create(ctx context.Context, newIdentity identity.Id
 
 func (g *gormUserRepository) Get(ctx context.Context, id uuid.UUID, c bool) (*identity.Identity, error) {
 	var found identity.Identity
-	if err := g.DB.Preload("Credentials").Preload("Contacts").First(&found, "id = ?", id).Error; err != nil {
+	if err := persistence.Conn(ctx, g.DB).Preload("Credentials").Preload("Contacts").First(&found, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	if !c {
@@ -42,7 +47,7 @@ func (g *gormUserRepository) GetWithIdentifier(ctx context.Context, identifier s
 	// First check the credentials to make sure that the identifier provided is valid
 	var cred credential.Credential
 	var idenf credential.Identifier
-	db := g.DB.WithContext(ctx)
+	db := persistence.Conn(ctx, g.DB)
 	if err := db.First(&idenf, "LOWER(value) = LOWER(?)", identifier).Error; err != nil {
 		return nil, err
 	}
@@ -61,11 +66,36 @@ func (g *gormUserRepository) GetWithIdentifier(ctx context.Context, identifier s
 }
 
 func (g *gormUserRepository) Update(ctx context.Context, updateIdentity identity.Identity) (*identity.Identity, error) {
-	var found identity.Identity
-	if err := g.DB.Model(&found).Omit("Credentials").Updates(updateIdentity).Error; err != nil {
+	updated := updateIdentity
+	// Make sure we're not accidentally updating any associations
+	if err := persistence.Conn(ctx, g.DB).Omit(clause.Associations).Save(&updated).Error; err != nil {
 		return nil, err
 	}
-	return &found, nil
+	return &updated, nil
+}
+
+func (g *gormUserRepository) FailLogin(ctx context.Context, id uuid.UUID, max int, cooledDown *time.Time) (*identity.Identity, error) {
+	db := persistence.Conn(ctx, g.DB)
+	if cooledDown != nil {
+		if err := db.Model(&identity.Identity{}).Where("id = ? AND locked_at <= ?", id, *cooledDown).Updates(map[string]interface{}{
+			"failed_logins": 0,
+			"locked_at":     nil,
+		}).Error; err != nil {
+			return nil, err
+		}
+	}
+	// MySQL assigns columns in order so locked_at has to come first to be based on the previous count
+	res := db.Exec(`UPDATE identities SET
+		locked_at = CASE WHEN ? > 0 AND locked_at IS NULL AND failed_logins + 1 >= ? THEN ? ELSE locked_at END,
+		failed_logins = failed_logins + 1
+		WHERE id = ? AND deleted_at IS NULL`, max, max, time.Now(), id)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return g.Get(ctx, id, false)
 }
 
 func (g *gormUserRepository) Delete(ctx context.Context, id uuid.UUID, permanent bool) error {
@@ -74,11 +104,73 @@ func (g *gormUserRepository) Delete(ctx context.Context, id uuid.UUID, permanent
 			ID: id,
 		},
 	}
-	if permanent {
-		g.DB.Unscoped()
+	// Associations are only removed when permanently deleting so that a soft deleted identity can be restored
+	if !permanent {
+		if err := persistence.Conn(ctx, g.DB).Delete(&i).Error; err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		return nil
+	}
+	// Everything that references the identity has to go first, in order, otherwise foreign keys will refuse the delete.
+	// This includes identifiers, which reference credentials rather than the identity
+	return persistence.Conn(ctx, g.DB).Transaction(func(tx *gorm.DB) error {
+		credentials := tx.Model(&credential.Credential{}).Select("id").Where("identity_id = ?", id)
+		if err := tx.Where("credential_id IN (?)", credentials).Delete(&credential.Identifier{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("identity_id = ?", id).Delete(&credential.Credential{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("identity_id = ?", id).Delete(&contact.Contact{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("identity_id = ?", id).Delete(&session.Session{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&i).Error
+	})
+}
+
+func (g *gormUserRepository) List(ctx context.Context, filter identity.Filter, offset int, limit int) ([]identity.Identity, int64, error) {
+	conn := persistence.Conn(ctx, g.DB)
+	db := conn.Model(&identity.Identity{})
+	if filter.Deleted {
+		db = db.Unscoped().Where("deleted_at IS NOT NULL")
 	}
-	if err := g.DB.Select(clause.Associations).Delete(&i).Error; err != nil && err != gorm.ErrRecordNotFound {
-		return err
+	if filter.Search != "" {
+		like := "%" + escapeLike(strings.ToLower(filter.Search)) + "%"
+		identified := conn.Model(&credential.Credential{}).
+			Select("credentials.identity_id").
+			Joins("JOIN identifiers ON identifiers.credential_id = credentials.id").
+			Where("LOWER(identifiers.value) LIKE ?", like)
+		db = db.Where("LOWER(email) LIKE ? OR LOWER(first_name) LIKE ? OR LOWER(last_name) LIKE ? OR id IN (?)", like, like, like, identified)
+	}
+	// Start a new session so that the conditions can be shared by both queries
+	db = db.Session(&gorm.Session{})
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	found := []identity.Identity{}
+	if err := db.Preload("Contacts").Order("created_at desc").Offset(offset).Limit(limit).Find(&found).Error; err != nil {
+		return nil, 0, err
+	}
+	return found, total, nil
+}
+
+func (g *gormUserRepository) Restore(ctx context.Context, id uuid.UUID) error {
+	res := persistence.Conn(ctx, g.DB).Unscoped().Model(&identity.Identity{}).Where("id = ? AND deleted_at IS NOT NULL", id).Update("deleted_at", nil)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
 	}
 	return nil
 }
+
+// escapeLike escapes the wildcards of a LIKE pattern so that they're matched literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -2,21 +2,27 @@ package service
 
 import (
 	"context"
+	"time"
 
+	"github.com/RagOfJoes/mylo/event"
 	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
 	"github.com/RagOfJoes/mylo/user/identity"
 	goaway "github.com/TwiN/go-away"
 	"github.com/gofrs/uuid"
-	"golang.org/x/sync/errgroup"
 )
 
+const maxPerPage = 100
+
 type service struct {
 	ir identity.Repository
+	eb event.Bus
 }
 
-func NewIdentityService(ir identity.Repository) identity.Service {
+func NewIdentityService(ir identity.Repository, eb event.Bus) identity.Service {
 	return &service{
 		ir: ir,
+		eb: eb,
 	}
 }
 
@@ -25,33 +31,20 @@ func (s *service) Create(ctx context.Context, newIdentity identity.Identity, use
 	if goaway.IsProfane(username) {
 		return nil, internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", identity.ErrUsernameProfane)
 	}
-	// Check if email and username already exist
-	var eg errgroup.Group
-	var f *identity.Identity
-	eg.Go(func() error {
-		fi, err := s.ir.GetWithIdentifier(ctx, username, false)
-		if err != nil {
-			return internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", identity.ErrInvalidIdentifierPassword)
-		}
-		f = fi
-		return nil
-	})
-	eg.Go(func() error {
-		fi, err := s.ir.GetWithIdentifier(ctx, newIdentity.Email, false)
-		if err != nil {
-			return internal.WrapErrorf(err, internal.ErrorCodeInvalidArgument, "%v", identity.ErrInvalidIdentifierPassword)
+	// Check if email and username already exist. These run one after the other since ctx may carry a transaction, which
+	// can't be used concurrently
+	for _, identifier := range []string{username, newIdentity.Email} {
+		if _, err := s.ir.GetWithIdentifier(ctx, identifier, false); err == nil {
+			return nil, internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", identity.ErrInvalidIdentifierPassword)
 		}
-		f = fi
-		return err
-	})
-	if eg.Wait(); f != nil {
-		return nil, internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", identity.ErrInvalidIdentifierPassword)
 	}
 	// Instantiate new identity
 	builtUser := identity.Identity{
+		Avatar:    newIdentity.Avatar,
 		FirstName: newIdentity.FirstName,
 		LastName:  newIdentity.LastName,
 		Email:     newIdentity.Email,
+		Locale:    newIdentity.Locale,
 	}
 	newUser, err := s.ir.Create(ctx, builtUser)
 	if err != nil {
@@ -77,6 +70,22 @@ func (s *service) Find(ctx context.Context, id string) (*identity.Identity, erro
 	return f, nil
 }
 
+func (s *service) Update(ctx context.Context, updateIdentity identity.Identity) (*identity.Identity, error) {
+	found, err := s.ir.Get(ctx, updateIdentity.ID, false)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeNotFound, "Account with id %s does not exist", updateIdentity.ID)
+	}
+	// Only allow profile fields to be updated
+	found.Avatar = updateIdentity.Avatar
+	found.FirstName = updateIdentity.FirstName
+	found.LastName = updateIdentity.LastName
+	updated, err := s.ir.Update(ctx, *found)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update identity: %s", updateIdentity.ID)
+	}
+	return updated, nil
+}
+
 // Delete defines a delete function for User identity
 func (s *service) Delete(ctx context.Context, id string, perm bool) error {
 	uid, err := uuid.FromString(id)
@@ -86,5 +95,76 @@ func (s *service) Delete(ctx context.Context, id string, perm bool) error {
 	if err := s.ir.Delete(ctx, uid, perm); err != nil {
 		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to delete identity: %s", id)
 	}
+	return s.eb.Publish(ctx, event.IdentityDeleted{
+		IdentityID: uid,
+		Permanent:  perm,
+	})
+}
+
+func (s *service) FailLogin(ctx context.Context, id uuid.UUID) (*identity.Identity, error) {
+	cfg := config.Get()
+	var cooledDown *time.Time
+	if cfg.Login.LockoutDuration > 0 {
+		since := time.Now().Add(-cfg.Login.LockoutDuration)
+		cooledDown = &since
+	}
+	updated, err := s.ir.FailLogin(ctx, id, cfg.Login.MaxAttempts, cooledDown)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update identity: %s", id)
+	}
+	return updated, nil
+}
+
+func (s *service) Unlock(ctx context.Context, id uuid.UUID) error {
+	found, err := s.ir.Get(ctx, id, false)
+	if err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeNotFound, "Account with id %s does not exist", id)
+	}
+	if found.FailedLogins == 0 && found.LockedAt == nil {
+		return nil
+	}
+	found.LockedAt = nil
+	found.FailedLogins = 0
+	if _, err := s.ir.Update(ctx, *found); err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update identity: %s", id)
+	}
+	return nil
+}
+
+func (s *service) List(ctx context.Context, filter identity.Filter, page int, perPage int) (*identity.Page, error) {
+	if page < 1 || perPage < 1 || perPage > maxPerPage {
+		return nil, internal.NewErrorf(internal.ErrorCodeInvalidArgument, "%v", identity.ErrInvalidPagination)
+	}
+	found, total, err := s.ir.List(ctx, filter, (page-1)*perPage, perPage)
+	if err != nil {
+		return nil, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to list identities")
+	}
+	return &identity.Page{
+		Identities: found,
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+	}, nil
+}
+
+func (s *service) Restore(ctx context.Context, id uuid.UUID) error {
+	if err := s.ir.Restore(ctx, id); err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeNotFound, "Deleted account with id %s does not exist", id)
+	}
+	return nil
+}
+
+func (s *service) RequirePasswordReset(ctx context.Context, id uuid.UUID, required bool) error {
+	found, err := s.ir.Get(ctx, id, false)
+	if err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeNotFound, "Account with id %s does not exist", id)
+	}
+	if found.PasswordResetRequired == required {
+		return nil
+	}
+	found.PasswordResetRequired = required
+	if _, err := s.ir.Update(ctx, *found); err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update identity: %s", id)
+	}
 	return nil
 }
@@ -17,7 +17,7 @@ func NewIdentityHttp(sh sessionHttp.Http, r *gin.Engine) {
 	h := &Http{
 		sh: sh,
 	}
-	r.GET("/me", h.me())
+	r.GET("/me", sh.Protected(), h.me())
 }
 
 func (h *Http) me() gin.HandlerFunc {
@@ -0,0 +1,62 @@
+package gorm
+
+import (
+	"context"
+	"time"
+
+	"github.com/RagOfJoes/mylo/persistence"
+	"github.com/RagOfJoes/mylo/webhook"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormWebhookRepository struct {
+	DB *gorm.DB
+}
+
+func NewGormWebhookRepository(d *gorm.DB) webhook.Repository {
+	return &gormWebhookRepository{DB: d}
+}
+
+func (g *gormWebhookRepository) Create(ctx context.Context, newDeliveries ...webhook.Delivery) error {
+	if len(newDeliveries) == 0 {
+		return nil
+	}
+	clone := newDeliveries
+	return persistence.Conn(ctx, g.DB).Create(&clone).Error
+}
+
+func (g *gormWebhookRepository) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]webhook.Delivery, error) {
+	var claimed []webhook.Delivery
+	err := persistence.Conn(ctx, g.DB).Transaction(func(tx *gorm.DB) error {
+		// Skip rows that are locked by other dispatchers rather than waiting on them
+		if err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", webhook.Pending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&claimed).Error; err != nil {
+			return err
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+		ids := make([]interface{}, 0, len(claimed))
+		for _, d := range claimed {
+			ids = append(ids, d.ID)
+		}
+		return tx.Model(&webhook.Delivery{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+func (g *gormWebhookRepository) Update(ctx context.Context, updateDelivery webhook.Delivery) (*webhook.Delivery, error) {
+	clone := updateDelivery
+	if err := persistence.Conn(ctx, g.DB).Save(&clone).Error; err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/RagOfJoes/mylo/event"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/webhook"
+	"github.com/gofrs/uuid"
+)
+
+type service struct {
+	r      webhook.Repository
+	cfg    config.Webhook
+	client *http.Client
+}
+
+// NewWebhookService creates a service that delivers events to the endpoints in cfg. If client is nil then one is
+// created with the configured timeout
+func NewWebhookService(r webhook.Repository, cfg config.Webhook, client *http.Client) webhook.Service {
+	if client == nil {
+		client = &http.Client{Timeout: cfg.Timeout}
+	}
+	return &service{
+		r:      r,
+		cfg:    cfg,
+		client: client,
+	}
+}
+
+func (s *service) Enqueue(ctx context.Context, topic event.Topic, data interface{}) error {
+	var endpoints []string
+	for _, e := range s.cfg.Endpoints {
+		if webhook.Subscribed(e, topic) {
+			endpoints = append(endpoints, e.Name)
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to generate uuid")
+	}
+	payload, err := json.Marshal(webhook.Payload{
+		ID:        id,
+		Type:      topic,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to marshal %s webhook payload", topic)
+	}
+	deliveries := make([]webhook.Delivery, 0, len(endpoints))
+	for _, name := range endpoints {
+		deliveries = append(deliveries, *webhook.New(id, topic, name, payload))
+	}
+	if err := s.r.Create(ctx, deliveries...); err != nil {
+		return internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to queue %s webhook", topic)
+	}
+	return nil
+}
+
+func (s *service) Dispatch(ctx context.Context) (int, error) {
+	claimed, err := s.r.Claim(ctx, time.Now(), s.cfg.Lease, s.cfg.BatchSize)
+	if err != nil {
+		return 0, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to claim queued webhooks")
+	}
+
+	delivered := 0
+	for _, d := range claimed {
+		endpoint, ok := s.endpoint(d.Endpoint)
+		if !ok {
+			d.Kill(webhook.ErrUnknownEndpoint)
+		} else if status, err := s.deliver(ctx, endpoint, d); err != nil {
+			d.Fail(status, err, s.cfg)
+			log.Printf("Failed to deliver webhook %s to %s (attempt %d, status %s): %v", d.ID, d.Endpoint, d.Attempts, d.Status, err)
+		} else {
+			d.Delivered(status)
+			delivered++
+		}
+		// The claim's lease guarantees that a delivery that fails to update will be retried once it expires
+		if _, err := s.r.Update(ctx, d); err != nil {
+			return delivered, internal.WrapErrorf(err, internal.ErrorCodeInternal, "Failed to update webhook delivery: %s", d.ID)
+		}
+	}
+	return delivered, nil
+}
+
+func (s *service) Run(ctx context.Context) {
+	if len(s.cfg.Endpoints) == 0 {
+		return
+	}
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		// Keep draining while full batches are being returned so that a backlog is cleared quickly
+		for {
+			delivered, err := s.Dispatch(ctx)
+			if err != nil {
+				log.Print(err)
+				break
+			}
+			if delivered < s.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// endpoint retrieves the configured endpoint via name
+func (s *service) endpoint(name string) (config.WebhookEndpoint, bool) {
+	for _, e := range s.cfg.Endpoints {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return config.WebhookEndpoint{}, false
+}
+
+// deliver signs then POSTs the delivery's payload to the endpoint. Any non 2xx response is considered a failure
+func (s *service) deliver(ctx context.Context, endpoint config.WebhookEndpoint, d webhook.Delivery) (int, error) {
+	body := []byte(d.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Mylo-Webhook")
+	req.Header.Set(webhook.EventHeader, string(d.Topic))
+	req.Header.Set(webhook.DeliveryHeader, d.ID.String())
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(endpoint.Secret, time.Now(), body))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	// Only keep a snippet of the response around for debugging
+	snippet, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("Endpoint responded with %d: %s", res.StatusCode, snippet)
+	}
+	return res.StatusCode, nil
+}
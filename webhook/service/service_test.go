@@ -0,0 +1,170 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RagOfJoes/mylo/event"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/RagOfJoes/mylo/persistence/memory"
+	"github.com/RagOfJoes/mylo/webhook"
+	"github.com/RagOfJoes/mylo/webhook/service"
+)
+
+const secret = "0123456789abcdef"
+
+// receiver stands in for an endpoint. Every request must be signed with secret and is recorded, statuses are responded
+// with in order and once they run out every request is accepted
+type receiver struct {
+	t        *testing.T
+	mu       sync.Mutex
+	statuses []int
+	payloads []webhook.Payload
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, *httptest.Server) {
+	r := &receiver{t: t, statuses: statuses}
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return r, srv
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		r.t.Error(err)
+		return
+	}
+	if err := webhook.Verify(secret, req.Header.Get(webhook.SignatureHeader), body, time.Minute); err != nil {
+		r.t.Errorf("expected a valid signature, got %v", err)
+	}
+	var payload webhook.Payload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		r.t.Error(err)
+	}
+	if req.Header.Get(webhook.EventHeader) != string(payload.Type) {
+		r.t.Errorf("expected event header %s, got %s", payload.Type, req.Header.Get(webhook.EventHeader))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.payloads = append(r.payloads, payload)
+	status := http.StatusNoContent
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *receiver) received() []webhook.Payload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]webhook.Payload(nil), r.payloads...)
+}
+
+func newConfig(endpoints ...config.WebhookEndpoint) config.Webhook {
+	return config.Webhook{
+		Endpoints:   endpoints,
+		Timeout:     time.Second,
+		BatchSize:   10,
+		MaxAttempts: 2,
+		Backoff:     time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+		Lease:       time.Minute,
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	billing, billingSrv := newReceiver(t)
+	crm, crmSrv := newReceiver(t)
+	s := service.NewWebhookService(memory.NewMemoryWebhookRepository(memory.NewStore()), newConfig(
+		config.WebhookEndpoint{Name: "billing", URL: billingSrv.URL, Secret: secret, Events: []string{string(event.TopicIdentityRegistered)}},
+		config.WebhookEndpoint{Name: "crm", URL: crmSrv.URL, Secret: secret},
+	), nil)
+	ctx := context.Background()
+
+	if err := s.Enqueue(ctx, event.TopicIdentityRegistered, map[string]string{"identity_id": "registered"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Enqueue(ctx, event.TopicIdentityDeleted, map[string]string{"identity_id": "deleted"}); err != nil {
+		t.Fatal(err)
+	}
+	delivered, err := s.Dispatch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 3 {
+		t.Errorf("expected 3 deliveries, got %d", delivered)
+	}
+
+	// Endpoints only receive the events they're subscribed to
+	if received := billing.received(); len(received) != 1 || received[0].Type != event.TopicIdentityRegistered {
+		t.Errorf("expected billing to only receive %s, got %+v", event.TopicIdentityRegistered, received)
+	}
+	received := crm.received()
+	if len(received) != 2 {
+		t.Fatalf("expected crm to receive every event, got %+v", received)
+	}
+	// Every endpoint receives the same event ID so that it can be deduplicated
+	for _, p := range received {
+		if p.Type == event.TopicIdentityRegistered && p.ID != billing.received()[0].ID {
+			t.Errorf("expected endpoints to receive the same event ID, got %s and %s", p.ID, billing.received()[0].ID)
+		}
+	}
+
+	// Delivered events are never sent again
+	if delivered, err := s.Dispatch(ctx); err != nil || delivered != 0 {
+		t.Errorf("expected nothing left to deliver, got %d, %v", delivered, err)
+	}
+}
+
+func TestDispatchRetry(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("recovers", func(t *testing.T) {
+		r, srv := newReceiver(t, http.StatusInternalServerError)
+		s := service.NewWebhookService(memory.NewMemoryWebhookRepository(memory.NewStore()), newConfig(
+			config.WebhookEndpoint{Name: "crm", URL: srv.URL, Secret: secret},
+		), nil)
+		if err := s.Enqueue(ctx, event.TopicPasswordChanged, nil); err != nil {
+			t.Fatal(err)
+		}
+		if delivered, err := s.Dispatch(ctx); err != nil || delivered != 0 {
+			t.Fatalf("expected the first attempt to fail, got %d, %v", delivered, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if delivered, err := s.Dispatch(ctx); err != nil || delivered != 1 {
+			t.Fatalf("expected the retry to be delivered, got %d, %v", delivered, err)
+		}
+		received := r.received()
+		if len(received) != 2 || received[0].ID != received[1].ID {
+			t.Errorf("expected the same event to be retried, got %+v", received)
+		}
+	})
+
+	t.Run("dead-letters", func(t *testing.T) {
+		r, srv := newReceiver(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusInternalServerError)
+		s := service.NewWebhookService(memory.NewMemoryWebhookRepository(memory.NewStore()), newConfig(
+			config.WebhookEndpoint{Name: "crm", URL: srv.URL, Secret: secret},
+		), nil)
+		if err := s.Enqueue(ctx, event.TopicPasswordChanged, nil); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			if delivered, err := s.Dispatch(ctx); err != nil || delivered != 0 {
+				t.Fatalf("expected attempt %d to fail, got %d, %v", i+1, delivered, err)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		// MaxAttempts is 2 so the third dispatch must not have reached the endpoint
+		if received := r.received(); len(received) != 2 {
+			t.Errorf("expected delivery to stop after 2 attempts, got %d", len(received))
+		}
+	})
+}
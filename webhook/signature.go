@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader is the header that holds the payload's signature
+	SignatureHeader = "X-Mylo-Signature"
+	// EventHeader is the header that holds the event's topic
+	EventHeader = "X-Mylo-Event"
+	// DeliveryHeader is the header that holds the delivery's ID. This changes per endpoint, use the payload's ID to
+	// deduplicate events
+	DeliveryHeader = "X-Mylo-Delivery"
+)
+
+// Sign computes the value of the signature header for body. The timestamp is included in the signed content so that
+// receivers can reject replayed payloads
+//
+// Format: t=<unix timestamp>,v1=<hex encoded HMAC-SHA256 of "<unix timestamp>.<body>">
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(mac(secret, ts, body)))
+}
+
+// Verify checks that header is a valid signature of body that was created within tolerance. A tolerance of 0 skips the
+// timestamp check
+func Verify(secret string, header string, body []byte, tolerance time.Duration) error {
+	var ts string
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			ts = kv[1]
+		case "v1":
+			if sig, err := hex.DecodeString(kv[1]); err == nil {
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 && time.Since(time.Unix(unix, 0)) > tolerance {
+		return ErrExpiredSignature
+	}
+	expected := mac(secret, ts, body)
+	for _, sig := range signatures {
+		if hmac.Equal(sig, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func mac(secret string, ts string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
@@ -0,0 +1,73 @@
+package subscriber
+
+import (
+	"context"
+
+	"github.com/RagOfJoes/mylo/event"
+	"github.com/RagOfJoes/mylo/user/contact"
+	"github.com/RagOfJoes/mylo/user/identity"
+	"github.com/RagOfJoes/mylo/webhook"
+	"github.com/gofrs/uuid"
+)
+
+type subscriber struct {
+	s webhook.Service
+}
+
+// IdentityDeletedData defines the data sent for `identity.deleted`
+type IdentityDeletedData struct {
+	IdentityID uuid.UUID `json:"identity_id"`
+	Permanent  bool      `json:"permanent"`
+}
+
+// ContactVerifiedData defines the data sent for `contact.verified`
+type ContactVerifiedData struct {
+	Identity identity.Identity `json:"identity"`
+	Contact  contact.Contact   `json:"contact"`
+}
+
+// PasswordChangedData defines the data sent for `password.changed`
+type PasswordChangedData struct {
+	IdentityID uuid.UUID `json:"identity_id"`
+}
+
+// Register subscribes the handlers that queue webhooks in response to events. Every handler runs synchronously so that
+// deliveries are queued in the same transaction as the change that triggered them
+func Register(bus event.Bus, s webhook.Service) {
+	sub := &subscriber{
+		s: s,
+	}
+	bus.Subscribe(event.TopicIdentityRegistered, sub.identityRegistered)
+	bus.Subscribe(event.TopicIdentityDeleted, sub.identityDeleted)
+	bus.Subscribe(event.TopicContactVerified, sub.contactVerified)
+	bus.Subscribe(event.TopicPasswordChanged, sub.passwordChanged)
+}
+
+// identityRegistered sends the new identity, including its contacts
+func (sub *subscriber) identityRegistered(ctx context.Context, e event.Event) error {
+	ev := e.(event.IdentityRegistered)
+	return sub.s.Enqueue(ctx, ev.Topic(), ev.Identity)
+}
+
+func (sub *subscriber) identityDeleted(ctx context.Context, e event.Event) error {
+	ev := e.(event.IdentityDeleted)
+	return sub.s.Enqueue(ctx, ev.Topic(), IdentityDeletedData{
+		IdentityID: ev.IdentityID,
+		Permanent:  ev.Permanent,
+	})
+}
+
+func (sub *subscriber) contactVerified(ctx context.Context, e event.Event) error {
+	ev := e.(event.ContactVerified)
+	return sub.s.Enqueue(ctx, ev.Topic(), ContactVerifiedData{
+		Identity: ev.Identity,
+		Contact:  ev.Contact,
+	})
+}
+
+func (sub *subscriber) passwordChanged(ctx context.Context, e event.Event) error {
+	ev := e.(event.PasswordChanged)
+	return sub.s.Enqueue(ctx, ev.Topic(), PasswordChangedData{
+		IdentityID: ev.IdentityID,
+	})
+}
@@ -0,0 +1,163 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/RagOfJoes/mylo/event"
+	"github.com/RagOfJoes/mylo/internal"
+	"github.com/RagOfJoes/mylo/internal/config"
+	"github.com/gofrs/uuid"
+)
+
+var (
+	ErrUnknownEndpoint  = errors.New("Webhook endpoint is no longer configured")
+	ErrInvalidSignature = errors.New("Invalid webhook signature provided")
+	ErrExpiredSignature = errors.New("Webhook signature has expired")
+)
+
+// Status defines the current status of a delivery
+type Status string
+
+const (
+	// Pending is the default Status. The delivery is waiting to be attempted or retried
+	Pending Status = "Pending"
+	// Delivered occurs when the endpoint has responded with a 2xx status
+	Delivered Status = "Delivered"
+	// Dead occurs when every attempt has failed. The delivery will no longer be retried
+	Dead Status = "Dead"
+)
+
+// Delivery defines a single event that has been queued for a single endpoint. Deliveries are kept once they've been
+// attempted so that they double as a log of everything that has been sent to an endpoint
+type Delivery struct {
+	internal.Base
+	// EventID uniquely identifies the event. Every endpoint receives the same ID so that receivers can deduplicate
+	EventID uuid.UUID `json:"event_id" gorm:"index;type:uuid;not null" validate:"required"`
+	// Topic defines the kind of event that is being delivered
+	Topic event.Topic `json:"topic" gorm:"index;size:64;not null" validate:"required"`
+	// Endpoint defines the name of the configured endpoint that the event will be delivered to
+	Endpoint string `json:"endpoint" gorm:"index;size:64;not null" validate:"required,max=64"`
+	// Payload defines the JSON body that'll be sent. This is stored as is so that every attempt is signed over the exact
+	// same bytes
+	Payload string `json:"-" gorm:"type:text;not null" validate:"required"`
+	// Status defines the current status of the delivery
+	Status Status `json:"status" gorm:"index;not null;default:Pending" validate:"required,oneof='Pending' 'Delivered' 'Dead'"`
+	// Attempts defines the number of times delivery has been attempted
+	Attempts int `json:"attempts" gorm:"not null;default:0"`
+	// NextAttemptAt defines the earliest time the delivery will be picked up by a dispatcher
+	NextAttemptAt time.Time `json:"next_attempt_at" gorm:"index;not null"`
+	// ResponseStatus defines the HTTP status code returned by the most recent attempt. This is 0 when the endpoint could
+	// not be reached
+	ResponseStatus int `json:"response_status" gorm:"not null;default:0"`
+	// LastError defines the error from the most recent failed attempt
+	LastError string `json:"last_error,omitempty" gorm:"size:1024"`
+	// DeliveredAt defines the time the endpoint accepted the event
+	DeliveredAt *time.Time `json:"delivered_at,omitempty" gorm:"default:null"`
+}
+
+// Payload defines the JSON body that is POSTed to endpoints
+type Payload struct {
+	// ID uniquely identifies the event
+	ID uuid.UUID `json:"id"`
+	// Type defines the kind of event ie. `identity.registered`
+	Type event.Topic `json:"type"`
+	// CreatedAt defines the time the event occurred
+	CreatedAt time.Time `json:"created_at"`
+	// Data defines the event specific data
+	Data interface{} `json:"data"`
+}
+
+type Repository interface {
+	// Create creates new deliveries
+	Create(ctx context.Context, newDeliveries ...Delivery) error
+	// Claim retrieves up to limit pending deliveries that are due and pushes their NextAttemptAt back by lease so that
+	// other dispatchers skip them while they're being attempted
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error)
+	// Update updates a delivery
+	Update(ctx context.Context, updateDelivery Delivery) (*Delivery, error)
+}
+
+type Service interface {
+	// Enqueue queues a delivery of the event for every endpoint that is subscribed to topic. When called within a
+	// transaction, the event will only be delivered once the transaction has been committed
+	Enqueue(ctx context.Context, topic event.Topic, data interface{}) error
+	// Dispatch attempts a single batch of due deliveries and returns the number of deliveries that were accepted
+	Dispatch(ctx context.Context) (int, error)
+	// Run dispatches deliveries on the configured interval until ctx is done
+	Run(ctx context.Context)
+}
+
+// TableName overrides GORM's table name
+func (Delivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// Subscribed checks whether an endpoint should receive events of topic
+func Subscribed(endpoint config.WebhookEndpoint, topic event.Topic) bool {
+	if len(endpoint.Events) == 0 {
+		return true
+	}
+	for _, e := range endpoint.Events {
+		if event.Topic(e) == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// New creates a new pending delivery that is due immediately
+func New(eventID uuid.UUID, topic event.Topic, endpoint string, payload []byte) *Delivery {
+	return &Delivery{
+		EventID:       eventID,
+		Topic:         topic,
+		Endpoint:      endpoint,
+		Payload:       string(payload),
+		Status:        Pending,
+		NextAttemptAt: time.Now(),
+	}
+}
+
+// Delivered marks the delivery as accepted by the endpoint
+func (d *Delivery) Delivered(status int) {
+	now := time.Now()
+	d.Attempts++
+	d.Status = Delivered
+	d.ResponseStatus = status
+	d.DeliveredAt = &now
+	d.LastError = ""
+}
+
+// Fail records a failed attempt. The delivery is retried with an exponential backoff until the configured max attempts
+// has been reached, at which point it's dead-lettered
+func (d *Delivery) Fail(status int, err error, cfg config.Webhook) {
+	d.Attempts++
+	d.ResponseStatus = status
+	d.LastError = err.Error()
+	if len(d.LastError) > 1024 {
+		d.LastError = d.LastError[:1024]
+	}
+	if d.Attempts >= cfg.MaxAttempts {
+		d.Status = Dead
+		return
+	}
+	d.NextAttemptAt = time.Now().Add(backoff(d.Attempts, cfg.Backoff, cfg.MaxBackoff))
+}
+
+// Kill dead-letters the delivery without retrying it
+func (d *Delivery) Kill(err error) {
+	d.Attempts++
+	d.Status = Dead
+	d.LastError = err.Error()
+}
+
+// backoff doubles base for every attempt that has been made, capped at max
+func backoff(attempts int, base time.Duration, max time.Duration) time.Duration {
+	delay := float64(base) * math.Pow(2, float64(attempts-1))
+	if delay > float64(max) {
+		return max
+	}
+	return time.Duration(delay)
+}